@@ -15,6 +15,7 @@ import (
 	"TGFaqBot/database"
 	"TGFaqBot/handlers"
 	"TGFaqBot/multichat"
+	"TGFaqBot/utils"
 )
 
 // TelegramBot Bot实例管理器
@@ -31,6 +32,7 @@ type TelegramBot struct {
 	messageHandler  *handlers.MessageHandler
 	adminHandler    *handlers.AdminHandler
 	listHandler     *handlers.ListHandler
+	inlineHandler   *handlers.InlineHandler
 }
 
 // NewTelegramBot 创建新的Bot实例
@@ -49,12 +51,12 @@ func NewTelegramBot(conf *config.Config, db database.Database, multichatMgr *mul
 	prefManager := handlers.NewPreferenceManager()
 
 	adminHandler := handlers.NewAdminHandler(db, conf, state)
-	listHandler := handlers.NewListHandler(db, state)
+	listHandler := handlers.NewListHandler(db, conf, state)
 	commandHandler := handlers.NewCommandHandler(db, conf, adminHandler, listHandler, multichatMgr, state, streamer, prefManager)
-	callbackHandler := handlers.NewCallbackHandler(db, conf, state, prefManager, multichatMgr)
+	callbackHandler := handlers.NewCallbackHandler(db, conf, state, streamer, prefManager, multichatMgr)
 	messageHandler := handlers.NewMessageHandler(db, conf, state, streamer, multichatMgr, prefManager)
 
-	return &TelegramBot{
+	tb := &TelegramBot{
 		bot:             bot,
 		conf:            conf,
 		db:              db,
@@ -67,7 +69,21 @@ func NewTelegramBot(conf *config.Config, db database.Database, multichatMgr *mul
 		messageHandler:  messageHandler,
 		adminHandler:    adminHandler,
 		listHandler:     listHandler,
-	}, nil
+		inlineHandler:   handlers.NewInlineHandler(db, conf, multichatMgr),
+	}
+
+	// 自定义命令变化后更新命令菜单
+	commandHandler.SetCommandsChangedHook(func() {
+		if err := tb.registerCommands(); err != nil {
+			log.Printf("Failed to register commands: %v", err)
+		}
+	})
+
+	// 提供商被断路器停用或恢复时通知超级管理员
+	if multichatMgr != nil {
+		multichatMgr.SetProviderStateHook(tb.notifyProviderState)
+	}
+	return tb, nil
 }
 
 // Start 启动Bot
@@ -80,6 +96,9 @@ func (tb *TelegramBot) Start() error {
 	// 启动超时清理机制
 	tb.startTimeoutCleanup()
 
+	// 启动条目过期提醒
+	tb.startExpiryNotifier()
+
 	// 注册命令
 	if err := tb.registerCommands(); err != nil {
 		return fmt.Errorf("failed to register commands: %v", err)
@@ -115,7 +134,9 @@ func (tb *TelegramBot) registerCommands() error {
 			{Command: "delete", Description: "删除条目"},
 			{Command: "batchdelete", Description: "批量删除条目"},
 			{Command: "list", Description: "列出所有条目"},
-			{Command: "reload", Description: "重新加载数据库"},
+			{Command: "stats", Description: "查看条目命中统计"},
+			{Command: "unanswered", Description: "查看未回答的问题"},
+			{Command: "reload", Description: "重新加载数据库和配置"},
 			{Command: "deleteall", Description: "删除所有条目"},
 			{Command: "tgtext", Description: "创建Telegraph文本页面"},
 			{Command: "tgimage", Description: "创建Telegraph图文页面"},
@@ -125,14 +146,26 @@ func (tb *TelegramBot) registerCommands() error {
 	// 添加超级管理员专用指令
 	if len(tb.conf.Admin.SuperAdminIDs) > 0 {
 		commands = append(commands, []tgbotapi.BotCommand{
+			{Command: "pending", Description: "查看待审核的修改"},
+			{Command: "setcmd", Description: "设置自定义命令"},
+			{Command: "delcmd", Description: "删除自定义命令"},
 			{Command: "addadmin", Description: "添加管理员"},
 			{Command: "deladmin", Description: "删除管理员"},
 			{Command: "addgroup", Description: "添加允许的群组"},
 			{Command: "delgroup", Description: "删除允许的群组"},
 			{Command: "listadmin", Description: "列出所有管理员"},
+			{Command: "keys", Description: "查看AI密钥使用情况"},
 		}...)
 	}
 
+	// 超级管理员定义的命令
+	commands = append(commands, handlers.CustomBotCommands(tb.db)...)
+	// Telegram 最多接受 100 个命令
+	if len(commands) > 100 {
+		log.Printf("Too many commands (%d), only the first 100 are registered", len(commands))
+		commands = commands[:100]
+	}
+
 	_, err := tb.bot.Request(tgbotapi.NewSetMyCommands(commands...))
 	return err
 }
@@ -184,6 +217,80 @@ func (tb *TelegramBot) startWebhook() error {
 	}
 }
 
+// startExpiryNotifier 定期检查即将过期的条目并通知管理员
+func (tb *TelegramBot) startExpiryNotifier() {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute) // 每10分钟检查一次
+		defer ticker.Stop()
+
+		for {
+			tb.notifyExpiringEntries()
+			<-ticker.C
+		}
+	}()
+}
+
+// notifyExpiringEntries 向管理员发送即将过期条目的提醒，每个有效期只提醒一次
+func (tb *TelegramBot) notifyExpiringEntries() {
+	now := time.Now()
+	window := time.Duration(tb.conf.FAQ.ExpiryNoticeHours) * time.Hour
+	entries, err := handlers.ExpiringEntries(tb.db, now, window)
+	if err != nil {
+		log.Printf("Error checking expiring entries: %v", err)
+		return
+	}
+	if len(entries) == 0 {
+		return
+	}
+
+	text := handlers.FormatExpiryNotice(entries, now)
+	recipients := append(append([]int64{}, tb.conf.Admin.SuperAdminIDs...), tb.conf.Admin.AdminIDs...)
+	notified := make(map[int64]bool)
+	for _, adminID := range recipients {
+		if notified[adminID] {
+			continue
+		}
+		notified[adminID] = true
+		if _, err := tb.bot.Send(tgbotapi.NewMessage(adminID, text)); err != nil {
+			log.Printf("Error sending expiry notice to %d: %v", adminID, err)
+		}
+	}
+
+	for i := range entries {
+		if err := handlers.MarkExpiryNotified(tb.db, &entries[i]); err != nil {
+			log.Printf("Error marking expiry notice for entry %s: %v", entries[i].Key, err)
+		}
+	}
+}
+
+// notifyProviderState 向超级管理员发送AI提供商停用或恢复的通知，半开状态只是试探，不通知
+func (tb *TelegramBot) notifyProviderState(providerName, from, to string) {
+	var text string
+	switch to {
+	case utils.CircuitOpen:
+		if from == utils.CircuitHalfOpen {
+			return // 试探失败，仍处于停用状态
+		}
+		text = fmt.Sprintf("⚠️ AI提供商 %s 连续失败，已暂时停用，期间的请求将使用回退链中的其他提供商", providerName)
+	case utils.CircuitClosed:
+		text = fmt.Sprintf("✅ AI提供商 %s 已恢复", providerName)
+	default:
+		return
+	}
+
+	log.Printf("Provider %s circuit breaker: %s -> %s", providerName, from, to)
+	notified := make(map[int64]bool)
+	for _, adminID := range tb.conf.Admin.SuperAdminIDs {
+		if notified[adminID] {
+			continue
+		}
+		notified[adminID] = true
+		if _, err := tb.bot.Send(tgbotapi.NewMessage(adminID, text)); err != nil {
+			log.Printf("Error sending provider state notice to %d: %v", adminID, err)
+		}
+	}
+}
+
 // startTimeoutCleanup 启动超时清理机制
 func (tb *TelegramBot) startTimeoutCleanup() {
 	go func() {
@@ -222,12 +329,17 @@ func (tb *TelegramBot) processUpdate(update *tgbotapi.Update) {
 		tb.processMessage(update.Message)
 	} else if update.CallbackQuery != nil {
 		tb.callbackHandler.HandleCallbackQuery(tb.bot, update.CallbackQuery)
+	} else if update.InlineQuery != nil {
+		go tb.inlineHandler.HandleInlineQuery(tb.bot, update.InlineQuery)
 	}
 }
 
 // processMessage 处理消息
 func (tb *TelegramBot) processMessage(message *tgbotapi.Message) {
 	chatID := message.Chat.ID
+
+	// 记录相册消息，管理员回复其中一条即可将整个相册添加为回答
+	tb.adminHandler.TrackMediaGroup(message)
 	isGroup := message.Chat.Type == "group" || message.Chat.Type == "supergroup"
 	isAllowedGroup := false
 
@@ -47,6 +47,8 @@ func (c *CLI) Run() {
 		c.handleRestart()
 	case "status":
 		c.handleStatus()
+	case "stats":
+		c.handleStats()
 	case "version", "-v", "--version":
 		c.handleVersion()
 	case "help", "-h", "--help":
@@ -73,6 +75,7 @@ func (c *CLI) printUsage() {
   stop                 停止服务
   restart              重启服务
   status               查看服务状态
+  stats                查看或导出FAQ命中统计
   version              显示版本信息
   help                 显示帮助信息
 
@@ -81,9 +84,10 @@ func (c *CLI) printUsage() {
   %s init --output custom.json  # 生成配置文件到指定路径
   %s install --name mybot    # 安装为服务，指定服务名称
   %s start                   # 启动服务
+  %s stats --format csv -o hits.csv  # 导出命中记录
 
 更多信息请访问: https://github.com/HsukqiLee/telegram-faq-bot
-`, getExecutableName(), getExecutableName(), getExecutableName(), getExecutableName(), getExecutableName())
+`, getExecutableName(), getExecutableName(), getExecutableName(), getExecutableName(), getExecutableName(), getExecutableName())
 }
 
 // handleInit 处理配置文件生成
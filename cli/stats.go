@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/handlers"
+)
+
+// handleStats 处理命中统计查看与导出
+func (c *CLI) handleStats() {
+	var configPath string
+	var format string
+	var outputPath string
+	var top int
+	var days int
+
+	flagSet := flag.NewFlagSet("stats", flag.ExitOnError)
+	flagSet.StringVar(&configPath, "config", "config.json", "配置文件路径")
+	flagSet.StringVar(&configPath, "c", "config.json", "配置文件路径 (简写)")
+	flagSet.StringVar(&format, "format", "text", "输出格式: text, json, csv")
+	flagSet.StringVar(&format, "f", "text", "输出格式 (简写)")
+	flagSet.StringVar(&outputPath, "output", "", "输出文件路径，默认输出到终端")
+	flagSet.StringVar(&outputPath, "o", "", "输出文件路径 (简写)")
+	flagSet.IntVar(&top, "top", 10, "热门条目数量")
+	flagSet.IntVar(&days, "days", 0, "导出最近多少天的命中记录，0 表示全部")
+
+	flagSet.Parse(c.args[1:])
+
+	if err := exportStats(configPath, format, outputPath, top, days); err != nil {
+		fmt.Printf("导出统计失败: %v\n", err)
+		os.Exit(1)
+	}
+
+	if outputPath != "" {
+		fmt.Printf("✅ 统计已导出: %s\n", outputPath)
+	}
+}
+
+// exportStats 读取数据库中的命中记录并按指定格式输出
+func exportStats(configPath, format, outputPath string, top, days int) error {
+	conf, err := config.LoadConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("加载配置失败: %v", err)
+	}
+
+	db, err := database.NewDatabase(conf.Database)
+	if err != nil {
+		return fmt.Errorf("连接数据库失败: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now()
+	var since time.Time
+	if days > 0 {
+		since = now.AddDate(0, 0, -days)
+	}
+
+	var out io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	switch format {
+	case "text":
+		report, err := database.BuildHitReport(db, now, top)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(out, handlers.FormatHitReport(report))
+		return err
+	case "json":
+		report, err := database.BuildHitReport(db, now, top)
+		if err != nil {
+			return err
+		}
+		hits, err := db.ListHits(since)
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"report": report,
+			"hits":   hits,
+		})
+	case "csv":
+		hits, err := db.ListHits(since)
+		if err != nil {
+			return err
+		}
+		return writeHitsCSV(out, hits)
+	default:
+		return fmt.Errorf("不支持的输出格式: %s", format)
+	}
+}
+
+// writeHitsCSV 以 CSV 格式输出命中记录
+func writeHitsCSV(out io.Writer, hits []database.Hit) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"time", "entry_id", "match_type", "entry_key", "chat_id"}); err != nil {
+		return err
+	}
+	for _, hit := range hits {
+		record := []string{
+			hit.Time.Format(time.RFC3339),
+			strconv.Itoa(hit.EntryID),
+			string(hit.MatchType),
+			hit.EntryKey,
+			strconv.FormatInt(hit.ChatID, 10),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
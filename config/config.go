@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"regexp"
 	"strings"
 )
 
@@ -16,6 +17,7 @@ type Config struct {
 	Database DatabaseConfig `json:"database"`
 	Redis    RedisConfig    `json:"redis,omitempty"`
 	Admin    AdminConfig    `json:"admin"`
+	FAQ      FAQConfig      `json:"faq"`
 }
 
 type TelegramConfig struct {
@@ -37,16 +39,64 @@ type ChatConfig struct {
 	Anthropic             *ProviderConfig `json:"anthropic,omitempty"`
 	Gemini                *ProviderConfig `json:"gemini,omitempty"`
 	Ollama                *ProviderConfig `json:"ollama,omitempty"`
+	Providers             []NamedProvider `json:"providers,omitempty"`       // 命名的提供商实例，如 groq、openrouter、deepseek
+	Fallback              []FallbackRoute `json:"fallback,omitempty"`        // 有序回退链，首选提供商失败后依次尝试
+	FallbackRules         map[string]bool `json:"fallback_rules,omitempty"`  // 按错误类型设置失败后是否回退
+	Retry                 *RetryConfig    `json:"retry,omitempty"`           // 同一提供商的重试设置
+	CircuitBreaker        *BreakerConfig  `json:"circuit_breaker,omitempty"` // 提供商断路器设置
+}
+
+// RetryConfig 提供商请求失败后的重试设置，未设置的字段使用默认值
+type RetryConfig struct {
+	MaxAttempts   int   `json:"max_attempts,omitempty"`    // 包括首次请求在内的最多尝试次数，1 表示不重试
+	InitialWaitMs int64 `json:"initial_wait_ms,omitempty"` // 首次重试前的等待毫秒数，之后按指数增长
+	MaxWaitMs     int64 `json:"max_wait_ms,omitempty"`     // 最长等待毫秒数，服务端要求等待更久时直接回退
+}
+
+// BreakerConfig 提供商断路器设置，连续失败达到上限后暂时跳过该提供商
+type BreakerConfig struct {
+	MaxFailures  int   `json:"max_failures,omitempty"`  // 连续失败多少次后打开断路器
+	ResetSeconds int64 `json:"reset_seconds,omitempty"` // 打开多少秒后放行一次请求试探是否恢复
+}
+
+// FallbackRoute 回退链中的一项，Model 为空时使用提供商的默认模型
+type FallbackRoute struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model,omitempty"`
 }
 
 type ProviderConfig struct {
-	Enabled        bool     `json:"enabled"`
-	APIKey         string   `json:"api_key"`
-	APIURL         string   `json:"api_url"`
-	DefaultModel   string   `json:"default_model"`
-	DisabledModels []string `json:"disabled_models,omitempty"`
-	SystemPrompt   string   `json:"system_prompt,omitempty"` // 可选，覆盖全局设置
-	Timeout        int64    `json:"timeout,omitempty"`       // 可选，覆盖全局设置
+	Enabled        bool              `json:"enabled"`
+	APIKey         string            `json:"api_key"`
+	APIURL         string            `json:"api_url"`
+	DefaultModel   string            `json:"default_model"`
+	DisabledModels []string          `json:"disabled_models,omitempty"`
+	SystemPrompt   string            `json:"system_prompt,omitempty"` // 可选，覆盖全局设置
+	Timeout        int64             `json:"timeout,omitempty"`       // 可选，覆盖全局设置
+	Temperature    *float64          `json:"temperature,omitempty"`   // 可选，采样温度
+	TopP           *float64          `json:"top_p,omitempty"`         // 可选，核采样概率
+	MaxTokens      int               `json:"max_tokens,omitempty"`    // 可选，单次回复的最大token数
+	APIKeys        []string          `json:"api_keys,omitempty"`      // 可选，额外的API密钥，与 api_key 一起轮换使用
+	KeyRotation    string            `json:"key_rotation,omitempty"`  // 可选，密钥轮换策略：round_robin（默认）或 least_rate_limited
+	KeyCooldown    int64             `json:"key_cooldown,omitempty"`  // 可选，密钥被限流或额度不足后停用的秒数，默认60
+	Headers        map[string]string `json:"headers,omitempty"`       // 可选，每个请求额外附带的请求头
+	APIVersion     string            `json:"api_version,omitempty"`   // 可选，Azure OpenAI 的 api-version 参数
+	Deployments    map[string]string `json:"deployments,omitempty"`   // 可选，Azure OpenAI 模型ID到部署名称的映射
+}
+
+// NamedProvider 命名的提供商实例，Type 为提供商类型（如 openai 表示 OpenAI 兼容接口），
+// 其余字段与内置提供商相同
+type NamedProvider struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	ProviderConfig
+}
+
+// ProviderInstance 一个已配置的提供商实例
+type ProviderInstance struct {
+	Name string
+	Type string
+	*ProviderConfig
 }
 
 type Model struct {
@@ -113,6 +163,26 @@ type RedisConfig struct {
 	AICacheTTL     int  `json:"ai_cache_ttl"`     // AI对话缓存过期时间(秒)
 }
 
+type FAQConfig struct {
+	DefaultLanguage string   `json:"default_language"`    // 条目默认内容的语言，默认 zh
+	Languages       []string `json:"languages,omitempty"` // 需要提供翻译的语言，用于在列表中提示缺失的翻译
+	AITranslate     bool     `json:"ai_translate"`        // 缺少翻译时使用AI翻译并缓存
+	// ExpiryNoticeHours 条目过期前多少小时提醒管理员，默认 24
+	ExpiryNoticeHours int `json:"expiry_notice_hours,omitempty"`
+	// MatchOrder 多个条目同时命中时匹配类型的优先顺序，默认 exact, prefix, suffix, contains, regex
+	MatchOrder []string `json:"match_order,omitempty"`
+	// MatchPolicy 多个条目同时命中时的回复方式：all 全部回复（默认），best 只回复优先级最高的条目
+	MatchPolicy string `json:"match_policy,omitempty"`
+	// ReviewMode 开启后普通管理员的 /add、/update、/delete 需要超级管理员批准
+	ReviewMode bool `json:"review_mode"`
+}
+
+// 多条目命中时的回复方式
+const (
+	MatchPolicyAll  = "all"
+	MatchPolicyBest = "best"
+)
+
 type AdminConfig struct {
 	SuperAdminIDs   []int64 `json:"super_admin_ids"`
 	AdminIDs        []int64 `json:"admin_ids"`
@@ -147,6 +217,17 @@ func LoadConfig(filename string) (*Config, error) {
 		config.Chat.Timeout = 60
 	}
 
+	// 设置FAQ配置的默认值
+	if config.FAQ.DefaultLanguage == "" {
+		config.FAQ.DefaultLanguage = "zh"
+	}
+	if config.FAQ.ExpiryNoticeHours == 0 {
+		config.FAQ.ExpiryNoticeHours = 24
+	}
+	if config.FAQ.MatchPolicy == "" {
+		config.FAQ.MatchPolicy = MatchPolicyAll
+	}
+
 	// 加载环境变量覆盖配置
 	config.LoadEnvVariables()
 
@@ -193,6 +274,14 @@ func (c *Config) LoadEnvVariables() {
 	} else if c.Chat.Gemini != nil && c.Chat.Gemini.APIKey != "" {
 		log.Printf("⚠️  WARNING: Using Gemini API key from config file. Consider using GEMINI_API_KEY environment variable for better security.")
 	}
+
+	// 命名提供商实例的API Key，例如 groq 对应 GROQ_API_KEY
+	for i := range c.Chat.Providers {
+		named := &c.Chat.Providers[i]
+		if apiKey := os.Getenv(strings.ToUpper(strings.ReplaceAll(named.Name, "-", "_")) + "_API_KEY"); apiKey != "" {
+			named.APIKey = apiKey
+		}
+	}
 }
 
 // Validate 验证配置
@@ -274,35 +363,76 @@ func (c *Config) validateDatabase() error {
 	return nil
 }
 
+// providerNamePattern 命名提供商实例的名称格式
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
 func (c *Config) validateAIProviders() error {
 	enabledCount := 0
 
 	if c.Chat.OpenAI != nil && c.Chat.OpenAI.Enabled {
-		if c.Chat.OpenAI.APIKey == "" {
+		if len(c.Chat.OpenAI.Keys()) == 0 {
 			return errors.New("OpenAI API key is required when enabled")
 		}
 		enabledCount++
 	}
 
 	if c.Chat.Anthropic != nil && c.Chat.Anthropic.Enabled {
-		if c.Chat.Anthropic.APIKey == "" {
+		if len(c.Chat.Anthropic.Keys()) == 0 {
 			return errors.New("anthropic API key is required when enabled")
 		}
 		enabledCount++
 	}
 
 	if c.Chat.Gemini != nil && c.Chat.Gemini.Enabled {
-		if c.Chat.Gemini.APIKey == "" {
+		if len(c.Chat.Gemini.Keys()) == 0 {
 			return errors.New("gemini API key is required when enabled")
 		}
 		enabledCount++
 	}
 
+	reserved := map[string]bool{"openai": true, "anthropic": true, "gemini": true, "ollama": true}
+	seen := make(map[string]bool)
+	for i, named := range c.Chat.Providers {
+		switch {
+		case !providerNamePattern.MatchString(named.Name):
+			return fmt.Errorf("providers[%d]: name %q must be 1-32 lowercase letters, digits, '-' or '_'", i, named.Name)
+		case reserved[named.Name]:
+			return fmt.Errorf("providers[%d]: name %q is reserved for the built-in provider", i, named.Name)
+		case seen[named.Name]:
+			return fmt.Errorf("providers[%d]: duplicate name %q", i, named.Name)
+		case named.Type == "":
+			return fmt.Errorf("providers[%d]: type is required", i)
+		case named.Type == "azure" && named.Enabled && (named.APIURL == "" || named.DefaultModel == ""):
+			return fmt.Errorf("providers[%d]: api_url and default_model are required for type azure", i)
+		}
+		seen[named.Name] = true
+		if named.Enabled {
+			enabledCount++
+		}
+	}
+
 	// 允许没有AI提供商启用的情况，此时将显示相应的警告消息
 	if enabledCount == 0 {
 		log.Println("⚠️ WARNING: No AI providers are enabled. AI chat functionality will be disabled.")
 	}
 
+	enabled := c.Chat.GetEnabledProviders()
+	for name, providerConfig := range enabled {
+		switch providerConfig.KeyRotation {
+		case "", "round_robin", "least_rate_limited":
+		default:
+			return fmt.Errorf("%s: unknown key_rotation %q", name, providerConfig.KeyRotation)
+		}
+	}
+	for i, route := range c.Chat.Fallback {
+		if _, exists := c.Chat.FindInstance(route.Provider); !exists {
+			return fmt.Errorf("fallback[%d]: unknown provider %q", i, route.Provider)
+		}
+		if _, exists := enabled[route.Provider]; !exists {
+			log.Printf("⚠️ WARNING: fallback[%d] provider %s is not enabled and will be skipped", i, route.Provider)
+		}
+	}
+
 	return nil
 }
 
@@ -315,26 +445,81 @@ func SaveConfig(filename string, config *Config) error {
 	return os.WriteFile(filename, bytes, 0644)
 }
 
-// GetEnabledProviders 返回所有启用的提供商
-func (c *ChatConfig) GetEnabledProviders() map[string]*ProviderConfig {
-	providers := make(map[string]*ProviderConfig)
-
-	if c.OpenAI != nil && c.OpenAI.Enabled {
-		providers["openai"] = c.OpenAI
+// Instances 按固定顺序返回所有已配置的提供商实例：先是四个内置提供商，再是 providers 中的命名实例
+func (c *ChatConfig) Instances() []ProviderInstance {
+	var instances []ProviderInstance
+	for _, builtin := range []struct {
+		name   string
+		config *ProviderConfig
+	}{
+		{"openai", c.OpenAI},
+		{"anthropic", c.Anthropic},
+		{"gemini", c.Gemini},
+		{"ollama", c.Ollama},
+	} {
+		if builtin.config != nil {
+			instances = append(instances, ProviderInstance{Name: builtin.name, Type: builtin.name, ProviderConfig: builtin.config})
+		}
+	}
+	for i := range c.Providers {
+		named := &c.Providers[i]
+		instances = append(instances, ProviderInstance{Name: named.Name, Type: named.Type, ProviderConfig: &named.ProviderConfig})
 	}
-	if c.Anthropic != nil && c.Anthropic.Enabled {
-		providers["anthropic"] = c.Anthropic
+	return instances
+}
+
+// EnabledInstances 按固定顺序返回启用的提供商实例
+func (c *ChatConfig) EnabledInstances() []ProviderInstance {
+	var enabled []ProviderInstance
+	for _, instance := range c.Instances() {
+		if instance.Enabled {
+			enabled = append(enabled, instance)
+		}
 	}
-	if c.Gemini != nil && c.Gemini.Enabled {
-		providers["gemini"] = c.Gemini
+	return enabled
+}
+
+// FindInstance 按名称查找提供商实例，包括未启用的
+func (c *ChatConfig) FindInstance(name string) (ProviderInstance, bool) {
+	for _, instance := range c.Instances() {
+		if instance.Name == name {
+			return instance, true
+		}
 	}
-	if c.Ollama != nil && c.Ollama.Enabled {
-		providers["ollama"] = c.Ollama
+	return ProviderInstance{}, false
+}
+
+// EnabledProviderNames 按固定顺序返回启用的提供商名称
+func (c *ChatConfig) EnabledProviderNames() []string {
+	var names []string
+	for _, instance := range c.EnabledInstances() {
+		names = append(names, instance.Name)
 	}
+	return names
+}
 
+// GetEnabledProviders 返回所有启用的提供商
+func (c *ChatConfig) GetEnabledProviders() map[string]*ProviderConfig {
+	providers := make(map[string]*ProviderConfig)
+	for _, instance := range c.EnabledInstances() {
+		providers[instance.Name] = instance.ProviderConfig
+	}
 	return providers
 }
 
+// Keys 返回去重后的所有API密钥，api_key 在前
+func (p *ProviderConfig) Keys() []string {
+	var keys []string
+	seen := make(map[string]bool)
+	for _, key := range append([]string{p.APIKey}, p.APIKeys...) {
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
 // GetProviderTimeout 获取提供商的超时设置，如果未设置则使用全局设置
 func (p *ProviderConfig) GetTimeout(globalTimeout int64) int64 {
 	if p.Timeout > 0 {
@@ -0,0 +1,232 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// 待审核修改的操作类型
+const (
+	ChangeAdd    = "add"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+	ChangeMeta   = "meta"
+)
+
+// PendingChange 审核模式下等待超级管理员批准的条目修改
+type PendingChange struct {
+	ID        int64     `json:"id"`
+	Action    string    `json:"action"`
+	Key       string    `json:"key"`
+	MatchType MatchType `json:"match_type"`
+	// NewType 更新后的匹配类型，仅用于 update
+	NewType MatchType `json:"new_type,omitempty"`
+	Value   string    `json:"value,omitempty"`
+	// Meta 新条目的扩展属性（媒体、按钮等），仅用于 add
+	Meta *EntryMeta `json:"meta,omitempty"`
+	// Buttons 更新后的按钮，SetButtons 为 false 时保留原有按钮，仅用于 update
+	Buttons    [][]EntryButton `json:"buttons,omitempty"`
+	SetButtons bool            `json:"set_buttons,omitempty"`
+	// Patch 扩展属性（翻译、有效期、优先级、可见性）的修改，仅用于 meta
+	Patch *MetaPatch `json:"patch,omitempty"`
+	// MissIDs 新条目回答的未回答问题记录，批准后删除，仅用于 add
+	MissIDs []int64 `json:"miss_ids,omitempty"`
+	// Warning 提交时检测到的与已有条目的重叠，供审核人参考，仅用于 add
+	Warning string `json:"warning,omitempty"`
+
+	RequestedBy   int64     `json:"requested_by"`
+	RequesterName string    `json:"requester_name"`
+	ChatID        int64     `json:"chat_id"` // 审核结果通知的聊天
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// MetaPatch 对条目扩展属性的修改，只应用不为空的字段，其他属性保持不变
+type MetaPatch struct {
+	Translation *TranslationPatch `json:"translation,omitempty"`
+	Validity    *ValidityPatch    `json:"validity,omitempty"`
+	Priority    *int              `json:"priority,omitempty"`
+	Visibility  *VisibilityPatch  `json:"visibility,omitempty"`
+}
+
+// TranslationPatch 设置某语言的人工翻译，Text 为空时删除该翻译
+type TranslationPatch struct {
+	Language string `json:"language"`
+	Text     string `json:"text,omitempty"`
+}
+
+// ValidityPatch 设置有效期，两端都为空时清除有效期
+type ValidityPatch struct {
+	From  *time.Time `json:"from,omitempty"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// VisibilityPatch 设置可见性，Users 仅用于 VisibilityUsers
+type VisibilityPatch struct {
+	Visibility string  `json:"visibility"`
+	Users      []int64 `json:"users,omitempty"`
+}
+
+// Apply 将修改应用到扩展属性
+func (p *MetaPatch) Apply(meta *EntryMeta) {
+	if p.Translation != nil {
+		translations := make(map[string]string, len(meta.Translations)+1)
+		for k, v := range meta.Translations {
+			translations[k] = v
+		}
+		if p.Translation.Text == "" {
+			delete(translations, p.Translation.Language)
+		} else {
+			translations[p.Translation.Language] = p.Translation.Text
+		}
+		meta.Translations = translations
+	}
+	if p.Validity != nil {
+		meta.ValidFrom = p.Validity.From
+		meta.ValidUntil = p.Validity.Until
+		// 有效期变化后重新发送过期提醒
+		meta.ExpiryNotifiedFor = nil
+	}
+	if p.Priority != nil {
+		meta.Priority = *p.Priority
+	}
+	if p.Visibility != nil {
+		meta.Visibility = p.Visibility.Visibility
+		meta.VisibleTo = p.Visibility.Users
+		if meta.Visibility == VisibilityPublic {
+			meta.Visibility = ""
+		}
+		if meta.Visibility != VisibilityUsers {
+			meta.VisibleTo = nil
+		}
+	}
+}
+
+// ApplyChange 将已批准的修改写入数据库
+func ApplyChange(db Database, change PendingChange) error {
+	switch change.Action {
+	case ChangeAdd:
+		if existing, _ := findEntryByKey(db, change.Key, change.MatchType); existing != nil {
+			return fmt.Errorf("entry %s already exists", change.Key)
+		}
+		if err := db.AddEntry(change.Key, change.MatchType, change.Value); err != nil {
+			return err
+		}
+		if change.Meta != nil && !change.Meta.IsEmpty() {
+			if err := db.SetEntryMeta(change.Key, change.MatchType, *change.Meta); err != nil {
+				db.DeleteEntry(change.Key, change.MatchType)
+				return err
+			}
+		}
+		// 修改已生效，创建者信息和问题记录处理失败不影响结果
+		StampEntry(db, change.Key, change.MatchType, change.RequestedBy, true)
+		if len(change.MissIDs) > 0 {
+			db.DeleteMisses(change.MissIDs...)
+		}
+		return nil
+	case ChangeUpdate:
+		if err := db.UpdateEntry(change.Key, change.MatchType, change.NewType, change.Value); err != nil {
+			return err
+		}
+		StampEntry(db, change.Key, change.NewType, change.RequestedBy, false)
+		if !change.SetButtons {
+			return nil
+		}
+		return db.UpdateEntryMeta(change.Key, change.NewType, func(meta *EntryMeta) {
+			meta.Buttons = change.Buttons
+		})
+	case ChangeDelete:
+		return db.DeleteEntry(change.Key, change.MatchType)
+	case ChangeMeta:
+		if change.Patch == nil {
+			return fmt.Errorf("change %d has no meta patch", change.ID)
+		}
+		if err := db.UpdateEntryMeta(change.Key, change.MatchType, change.Patch.Apply); err != nil {
+			return err
+		}
+		StampEntry(db, change.Key, change.MatchType, change.RequestedBy, false)
+		return nil
+	default:
+		return fmt.Errorf("unknown change action: %s", change.Action)
+	}
+}
+
+// FindPendingChange 按 ID 查找待审核的修改
+func FindPendingChange(db Database, id int64) (*PendingChange, error) {
+	changes, err := db.ListPendingChanges()
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		if changes[i].ID == id {
+			return &changes[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// changeStore SQL 后端的待审核修改存储，修改内容以 JSON 保存
+type changeStore struct {
+	db *sql.DB
+	// postgres 使用 $n 占位符
+	postgres bool
+}
+
+const createChangesTable = `CREATE TABLE IF NOT EXISTS faq_changes (
+	id BIGINT NOT NULL PRIMARY KEY,
+	payload TEXT NOT NULL,
+	created_at BIGINT NOT NULL
+)`
+
+func newChangeStore(db *sql.DB, postgres bool) *changeStore {
+	return &changeStore{db: db, postgres: postgres}
+}
+
+// createTable 创建待审核修改表
+func (s *changeStore) createTable() error {
+	if _, err := s.db.Exec(createChangesTable); err != nil {
+		return fmt.Errorf("failed to create faq_changes table: %v", err)
+	}
+	return nil
+}
+
+// Add 保存一条待审核的修改
+func (s *changeStore) Add(change PendingChange) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(rebindQuery(`INSERT INTO faq_changes (id, payload, created_at) VALUES (?, ?, ?)`, s.postgres),
+		change.ID, string(payload), change.CreatedAt.Unix())
+	return err
+}
+
+// List 按提交时间返回所有待审核的修改
+func (s *changeStore) List() ([]PendingChange, error) {
+	rows, err := s.db.Query(`SELECT payload FROM faq_changes ORDER BY created_at, id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []PendingChange
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, err
+		}
+		var change PendingChange
+		if err := json.Unmarshal([]byte(payload), &change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// Delete 删除待审核的修改
+func (s *changeStore) Delete(id int64) error {
+	_, err := s.db.Exec(rebindQuery(`DELETE FROM faq_changes WHERE id = ?`, s.postgres), id)
+	return err
+}
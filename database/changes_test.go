@@ -0,0 +1,281 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testBackends 返回在临时目录中创建的 JSON 和 SQLite 数据库
+func testBackends(t *testing.T) map[string]func(t *testing.T) Database {
+	return map[string]func(t *testing.T) Database{
+		"json": func(t *testing.T) Database {
+			path := filepath.Join(t.TempDir(), "faq.json")
+			if err := os.WriteFile(path, nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+			db, err := NewJSONDB(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return db
+		},
+		"sqlite": func(t *testing.T) Database {
+			db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "faq.db"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		},
+	}
+}
+
+func TestApplyChange(t *testing.T) {
+	const requester = 1001
+	priority := 5
+	until := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, db Database)
+		change  PendingChange
+		wantErr bool
+		check   func(t *testing.T, db Database)
+	}{
+		{
+			name: "add creates the entry with meta and author",
+			change: PendingChange{
+				Action: ChangeAdd, Key: "退款", MatchType: MatchExact, Value: "7 天内可退款",
+				Meta:        &EntryMeta{Buttons: [][]EntryButton{{{Text: "官网", Type: ButtonURL, URL: "https://example.com"}}}},
+				RequestedBy: requester,
+			},
+			check: func(t *testing.T, db Database) {
+				entry := mustFind(t, db, "退款", MatchExact)
+				if entry.Value != "7 天内可退款" || len(entry.Buttons) != 1 {
+					t.Errorf("entry = %+v, want value and buttons from the change", entry)
+				}
+				if entry.CreatedBy != requester || entry.UpdatedBy != requester || entry.CreatedAt == nil {
+					t.Errorf("authors = %d/%d, want %d", entry.CreatedBy, entry.UpdatedBy, requester)
+				}
+			},
+		},
+		{
+			name: "add resolves the answered questions",
+			setup: func(t *testing.T, db Database) {
+				mustRecordMiss(t, db, "怎么退款")
+				mustRecordMiss(t, db, "营业时间")
+			},
+			change: PendingChange{
+				Action: ChangeAdd, Key: "退款", MatchType: MatchContains, Value: "v",
+				MissIDs: []int64{MissID("怎么退款")}, RequestedBy: requester,
+			},
+			check: func(t *testing.T, db Database) {
+				misses, err := db.ListMisses()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(misses) != 1 || misses[0].Text != "营业时间" {
+					t.Errorf("misses = %+v, want only the unrelated question", misses)
+				}
+			},
+		},
+		{
+			name:    "add rejects an existing key",
+			setup:   func(t *testing.T, db Database) { mustAdd(t, db, "退款", MatchExact) },
+			change:  PendingChange{Action: ChangeAdd, Key: "退款", MatchType: MatchExact, Value: "v2"},
+			wantErr: true,
+			check: func(t *testing.T, db Database) {
+				if entry := mustFind(t, db, "退款", MatchExact); entry.Value != "v" {
+					t.Errorf("value = %q, want the original value", entry.Value)
+				}
+			},
+		},
+		{
+			name: "update changes value and type and keeps buttons",
+			setup: func(t *testing.T, db Database) {
+				mustAdd(t, db, "退款", MatchExact)
+				if err := db.SetEntryMeta("退款", MatchExact, EntryMeta{Buttons: [][]EntryButton{{{Text: "a", Type: ButtonStart, StartParam: "a"}}}}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			change: PendingChange{Action: ChangeUpdate, Key: "退款", MatchType: MatchExact, NewType: MatchContains, Value: "v2", RequestedBy: requester},
+			check: func(t *testing.T, db Database) {
+				entry := mustFind(t, db, "退款", MatchContains)
+				if entry.Value != "v2" || len(entry.Buttons) != 1 || entry.UpdatedBy != requester {
+					t.Errorf("entry = %+v, want new value, original buttons and updater", entry)
+				}
+			},
+		},
+		{
+			name: "update replaces buttons when requested",
+			setup: func(t *testing.T, db Database) {
+				mustAdd(t, db, "退款", MatchExact)
+				if err := db.SetEntryMeta("退款", MatchExact, EntryMeta{Buttons: [][]EntryButton{{{Text: "a", Type: ButtonStart, StartParam: "a"}}}}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			change: PendingChange{Action: ChangeUpdate, Key: "退款", MatchType: MatchExact, NewType: MatchExact, Value: "v2", SetButtons: true},
+			check: func(t *testing.T, db Database) {
+				if entry := mustFind(t, db, "退款", MatchExact); len(entry.Buttons) != 0 {
+					t.Errorf("buttons = %+v, want cleared", entry.Buttons)
+				}
+			},
+		},
+		{
+			name:   "delete removes the entry",
+			setup:  func(t *testing.T, db Database) { mustAdd(t, db, "退款", MatchRegex) },
+			change: PendingChange{Action: ChangeDelete, Key: "退款", MatchType: MatchRegex},
+			check: func(t *testing.T, db Database) {
+				if entry, _ := findEntryByKey(db, "退款", MatchRegex); entry != nil {
+					t.Errorf("entry still exists: %+v", entry)
+				}
+			},
+		},
+		{
+			name: "meta patch only touches patched fields",
+			setup: func(t *testing.T, db Database) {
+				mustAdd(t, db, "退款", MatchExact)
+				if err := db.SetEntryMeta("退款", MatchExact, EntryMeta{Translations: map[string]string{"en": "refund"}}); err != nil {
+					t.Fatal(err)
+				}
+			},
+			change: PendingChange{
+				Action: ChangeMeta, Key: "退款", MatchType: MatchExact, RequestedBy: requester,
+				Patch: &MetaPatch{Priority: &priority, Validity: &ValidityPatch{Until: &until}},
+			},
+			check: func(t *testing.T, db Database) {
+				entry := mustFind(t, db, "退款", MatchExact)
+				if entry.Priority != priority || entry.ValidUntil == nil || !entry.ValidUntil.Equal(until) {
+					t.Errorf("priority = %d, valid until = %v, want %d and %v", entry.Priority, entry.ValidUntil, priority, until)
+				}
+				if entry.Translations["en"] != "refund" || entry.UpdatedBy != requester {
+					t.Errorf("entry = %+v, want translation kept and updater recorded", entry)
+				}
+			},
+		},
+		{
+			name:    "meta change without patch",
+			setup:   func(t *testing.T, db Database) { mustAdd(t, db, "退款", MatchExact) },
+			change:  PendingChange{Action: ChangeMeta, Key: "退款", MatchType: MatchExact},
+			wantErr: true,
+		},
+		{
+			name:    "unknown action",
+			change:  PendingChange{Action: "rename", Key: "退款", MatchType: MatchExact},
+			wantErr: true,
+		},
+	}
+
+	for backend, open := range testBackends(t) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				db := open(t)
+				if tt.setup != nil {
+					tt.setup(t, db)
+				}
+				err := ApplyChange(db, tt.change)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("ApplyChange() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if tt.check != nil {
+					tt.check(t, db)
+				}
+			})
+		}
+	}
+}
+
+func TestMetaPatchApply(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	notified := from.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		meta  EntryMeta
+		patch MetaPatch
+		check func(t *testing.T, meta EntryMeta)
+	}{
+		{
+			name:  "add translation",
+			meta:  EntryMeta{Translations: map[string]string{"en": "refund"}},
+			patch: MetaPatch{Translation: &TranslationPatch{Language: "ja", Text: "返金"}},
+			check: func(t *testing.T, meta EntryMeta) {
+				if len(meta.Translations) != 2 || meta.Translations["ja"] != "返金" {
+					t.Errorf("translations = %v", meta.Translations)
+				}
+			},
+		},
+		{
+			name:  "empty text removes translation",
+			meta:  EntryMeta{Translations: map[string]string{"en": "refund"}},
+			patch: MetaPatch{Translation: &TranslationPatch{Language: "en"}},
+			check: func(t *testing.T, meta EntryMeta) {
+				if len(meta.Translations) != 0 {
+					t.Errorf("translations = %v, want empty", meta.Translations)
+				}
+			},
+		},
+		{
+			name:  "validity resets the expiry reminder",
+			meta:  EntryMeta{ExpiryNotifiedFor: &notified},
+			patch: MetaPatch{Validity: &ValidityPatch{From: &from}},
+			check: func(t *testing.T, meta EntryMeta) {
+				if meta.ValidFrom == nil || !meta.ValidFrom.Equal(from) || meta.ExpiryNotifiedFor != nil {
+					t.Errorf("validity = %v, notified = %v", meta.ValidFrom, meta.ExpiryNotifiedFor)
+				}
+			},
+		},
+		{
+			name:  "public visibility is stored as empty",
+			meta:  EntryMeta{Visibility: VisibilityUsers, VisibleTo: []int64{1}},
+			patch: MetaPatch{Visibility: &VisibilityPatch{Visibility: VisibilityPublic, Users: []int64{2}}},
+			check: func(t *testing.T, meta EntryMeta) {
+				if meta.Visibility != "" || meta.VisibleTo != nil {
+					t.Errorf("visibility = %q %v, want public", meta.Visibility, meta.VisibleTo)
+				}
+			},
+		},
+		{
+			name:  "users visibility keeps the user list",
+			patch: MetaPatch{Visibility: &VisibilityPatch{Visibility: VisibilityUsers, Users: []int64{2, 3}}},
+			check: func(t *testing.T, meta EntryMeta) {
+				if meta.Visibility != VisibilityUsers || len(meta.VisibleTo) != 2 {
+					t.Errorf("visibility = %q %v", meta.Visibility, meta.VisibleTo)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := tt.meta
+			tt.patch.Apply(&meta)
+			tt.check(t, meta)
+		})
+	}
+}
+
+func mustAdd(t *testing.T, db Database, key string, matchType MatchType) {
+	t.Helper()
+	if err := db.AddEntry(key, matchType, "v"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustFind(t *testing.T, db Database, key string, matchType MatchType) *Entry {
+	t.Helper()
+	entry, err := findEntryByKey(db, key, matchType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entry
+}
+
+func mustRecordMiss(t *testing.T, db Database, text string) {
+	t.Helper()
+	if err := db.RecordMiss(Miss{Text: text, Count: 1, FirstSeen: time.Now(), LastSeen: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// CustomCommand 超级管理员定义的命令，执行时回复指定的条目
+type CustomCommand struct {
+	Command     string    `json:"command"` // 不含 /，小写
+	Description string    `json:"description"`
+	EntryKey    string    `json:"entry_key"`
+	MatchType   MatchType `json:"match_type"`
+	CreatedBy   int64     `json:"created_by"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// FindCustomCommand 按命令名查找自定义命令
+func FindCustomCommand(db Database, command string) (*CustomCommand, error) {
+	commands, err := db.ListCustomCommands()
+	if err != nil {
+		return nil, err
+	}
+	for i := range commands {
+		if commands[i].Command == command {
+			return &commands[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// commandStore SQL 后端的自定义命令存储，命令内容以 JSON 保存
+type commandStore struct {
+	db *sql.DB
+	// postgres 使用 $n 占位符
+	postgres bool
+}
+
+const createCommandsTable = `CREATE TABLE IF NOT EXISTS faq_commands (
+	command VARCHAR(64) NOT NULL PRIMARY KEY,
+	payload TEXT NOT NULL,
+	created_at BIGINT NOT NULL
+)`
+
+func newCommandStore(db *sql.DB, postgres bool) *commandStore {
+	return &commandStore{db: db, postgres: postgres}
+}
+
+// createTable 创建自定义命令表
+func (s *commandStore) createTable() error {
+	if _, err := s.db.Exec(createCommandsTable); err != nil {
+		return fmt.Errorf("failed to create faq_commands table: %v", err)
+	}
+	return nil
+}
+
+// Set 保存自定义命令，同名命令会被覆盖
+func (s *commandStore) Set(command CustomCommand) error {
+	payload, err := json.Marshal(command)
+	if err != nil {
+		return err
+	}
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.Exec(rebindQuery(`DELETE FROM faq_commands WHERE command = ?`, s.postgres), command.Command); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(rebindQuery(`INSERT INTO faq_commands (command, payload, created_at) VALUES (?, ?, ?)`, s.postgres),
+		command.Command, string(payload), command.CreatedAt.Unix()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// List 按创建时间返回所有自定义命令
+func (s *commandStore) List() ([]CustomCommand, error) {
+	rows, err := s.db.Query(`SELECT payload FROM faq_commands ORDER BY created_at, command`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var commands []CustomCommand
+	for rows.Next() {
+		var payload string
+		if err := rows.Scan(&payload); err != nil {
+			return nil, err
+		}
+		var command CustomCommand
+		if err := json.Unmarshal([]byte(payload), &command); err != nil {
+			return nil, err
+		}
+		commands = append(commands, command)
+	}
+	return commands, rows.Err()
+}
+
+// Delete 删除自定义命令
+func (s *commandStore) Delete(command string) error {
+	_, err := s.db.Exec(rebindQuery(`DELETE FROM faq_commands WHERE command = ?`, s.postgres), command)
+	return err
+}
@@ -0,0 +1,45 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCustomCommands(t *testing.T) {
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			created := time.Now().Truncate(time.Second)
+			commands := []CustomCommand{
+				{Command: "rules", Description: "查看群规", EntryKey: "群规", MatchType: MatchExact, CreatedBy: 1, CreatedAt: created},
+				{Command: "refund", Description: "退款", EntryKey: "退款", MatchType: MatchContains, CreatedBy: 1, CreatedAt: created.Add(time.Second)},
+				// 同名命令覆盖之前的设置
+				{Command: "rules", Description: "新群规", EntryKey: "新群规", MatchType: MatchRegex, CreatedBy: 2, CreatedAt: created.Add(2 * time.Second)},
+			}
+			for _, command := range commands {
+				if err := db.SetCustomCommand(command); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			list, err := db.ListCustomCommands()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(list) != 2 || list[0].Command != "refund" || list[1].Command != "rules" {
+				t.Fatalf("ListCustomCommands() = %+v, want refund then rules in creation order", list)
+			}
+			rules, err := FindCustomCommand(db, "rules")
+			if err != nil || rules == nil || rules.EntryKey != "新群规" || rules.MatchType != MatchRegex || rules.CreatedBy != 2 {
+				t.Errorf("FindCustomCommand(rules) = %+v, %v, want the latest setting", rules, err)
+			}
+
+			if err := db.DeleteCustomCommand("rules"); err != nil {
+				t.Fatal(err)
+			}
+			if command, err := FindCustomCommand(db, "rules"); err != nil || command != nil {
+				t.Errorf("FindCustomCommand() after delete = %+v, %v, want nil", command, err)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package database
 import (
 	"TGFaqBot/config"
 	"fmt"
+	"time"
 )
 
 type Entry struct {
@@ -10,9 +11,10 @@ type Entry struct {
 	Key           string    `json:"key"`
 	Value         string    `json:"value"`
 	MatchType     MatchType `json:"match_type"`
-	ContentType   string    `json:"content_type"`   // "text", "telegraph_text", "telegraph_image"
+	ContentType   string    `json:"content_type"`   // "text", "telegraph_text", "telegraph_image", 媒体类型或 "album"
 	TelegraphURL  string    `json:"telegraph_url"`  // Telegraph 页面 URL
 	TelegraphPath string    `json:"telegraph_path"` // Telegraph 页面路径
+	EntryMeta               // 扩展属性（媒体等）
 }
 
 // ModelInfo 存储AI模型信息
@@ -26,6 +28,8 @@ type ModelInfo struct {
 
 type Database interface {
 	Query(query string) ([]Entry, error)
+	// QueryByID 按 ID 查找条目，不检查有效期，管理员需要编辑和延长已过期的条目
+	// 向用户展示条目时使用 QueryByIDActive
 	QueryByID(id int, matchType MatchType) (*Entry, error)
 	AddEntry(key string, matchType MatchType, value string) error
 	UpdateEntry(key string, oldType MatchType, newType MatchType, value string) error
@@ -33,6 +37,9 @@ type Database interface {
 	DeleteAllEntries() error
 	ListEntries(table string) ([]Entry, error)
 	ListSpecificEntries(matchTypes ...MatchType) ([]Entry, error)
+	// ListEntriesByAuthor 返回指定用户创建或最后修改的条目，authors 为空时不筛选作者
+	// recent 为 true 或按作者筛选时按最后修改时间从新到旧排列
+	ListEntriesByAuthor(authors []int64, recent bool, matchTypes ...MatchType) ([]Entry, error)
 	QueryExact(query string) ([]Entry, error)
 	QueryContains(query string) ([]Entry, error)
 	QueryRegex(query string) ([]Entry, error)
@@ -66,6 +73,34 @@ type Database interface {
 	UpdateTelegraphEntry(key string, matchType MatchType, value, contentType, telegraphURL, telegraphPath string) error
 	GetTelegraphContent(key string, matchType MatchType) (*Entry, error)
 
+	// 条目扩展属性
+	SetEntryMeta(key string, matchType MatchType, meta EntryMeta) error
+	// UpdateEntryMeta 读取最新的扩展属性并只修改 update 中变更的字段，避免覆盖并发的修改
+	UpdateEntryMeta(key string, matchType MatchType, update func(meta *EntryMeta)) error
+
+	// 命中统计
+	RecordHit(hit Hit) error
+	ListHits(since time.Time) ([]Hit, error)
+	// HitCounts 按条目汇总命中次数和最近命中时间，不包含条目的 key
+	HitCounts() ([]EntryHitCount, error)
+	// HitTotals 统计 [since, until) 内的命中次数和聊天数，until 为零值时不限制结束时间
+	HitTotals(since, until time.Time) (hits int, chats int, err error)
+
+	// 未回答问题
+	RecordMiss(miss Miss) error
+	ListMisses() ([]Miss, error)
+	DeleteMisses(ids ...int64) error
+
+	// 待审核的条目修改
+	AddPendingChange(change PendingChange) error
+	ListPendingChanges() ([]PendingChange, error)
+	DeletePendingChange(id int64) error
+
+	// 自定义命令
+	SetCustomCommand(command CustomCommand) error
+	ListCustomCommands() ([]CustomCommand, error)
+	DeleteCustomCommand(command string) error
+
 	Reload() error
 	Close() error
 }
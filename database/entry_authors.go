@@ -0,0 +1,312 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// entryAuthors 条目的创建者、最后修改者和对应时间
+// SQL 后端保存在 entry_authors 表的独立列中，按作者筛选和按修改时间排序可以使用索引
+type entryAuthors struct {
+	CreatedBy int64
+	CreatedAt int64 // Unix 秒，0 表示没有记录
+	UpdatedBy int64
+	UpdatedAt int64
+}
+
+// 时间以 Unix 秒保存，与 faq_hits 一致
+const createEntryAuthorsTable = `CREATE TABLE entry_authors (
+	match_type INTEGER NOT NULL,
+	entry_id INTEGER NOT NULL,
+	created_by BIGINT NOT NULL DEFAULT 0,
+	created_at BIGINT NOT NULL DEFAULT 0,
+	updated_by BIGINT NOT NULL DEFAULT 0,
+	updated_at BIGINT NOT NULL DEFAULT 0,
+	PRIMARY KEY (match_type, entry_id)
+)`
+
+// MySQL 不支持 CREATE INDEX IF NOT EXISTS，索引只在创建表时一起创建
+var createEntryAuthorsIndexes = []string{
+	"CREATE INDEX idx_entry_authors_created_by ON entry_authors (created_by)",
+	"CREATE INDEX idx_entry_authors_updated_by ON entry_authors (updated_by)",
+	"CREATE INDEX idx_entry_authors_updated_at ON entry_authors (updated_at, created_at)",
+}
+
+// authorsOf 取出扩展属性中的作者信息
+func authorsOf(meta EntryMeta) entryAuthors {
+	authors := entryAuthors{CreatedBy: meta.CreatedBy, UpdatedBy: meta.UpdatedBy}
+	if meta.CreatedAt != nil {
+		authors.CreatedAt = meta.CreatedAt.Unix()
+	}
+	if meta.UpdatedAt != nil {
+		authors.UpdatedAt = meta.UpdatedAt.Unix()
+	}
+	return authors
+}
+
+// IsEmpty 检查是否没有任何作者记录
+func (a entryAuthors) IsEmpty() bool {
+	return a == entryAuthors{}
+}
+
+// apply 将作者信息写回扩展属性
+func (a entryAuthors) apply(meta *EntryMeta) {
+	meta.CreatedBy = a.CreatedBy
+	meta.UpdatedBy = a.UpdatedBy
+	meta.CreatedAt, meta.UpdatedAt = nil, nil
+	if a.CreatedAt != 0 {
+		createdAt := time.Unix(a.CreatedAt, 0)
+		meta.CreatedAt = &createdAt
+	}
+	if a.UpdatedAt != 0 {
+		updatedAt := time.Unix(a.UpdatedAt, 0)
+		meta.UpdatedAt = &updatedAt
+	}
+}
+
+// withoutAuthors 返回去掉作者信息的扩展属性，作者信息不再写入 entry_meta 的 JSON 文本
+func (m EntryMeta) withoutAuthors() EntryMeta {
+	m.CreatedBy, m.CreatedAt, m.UpdatedBy, m.UpdatedAt = 0, nil, 0, nil
+	return m
+}
+
+// createAuthorsTable 创建作者表，并迁移旧版本保存在 entry_meta 中的作者信息
+func (s *entryMetaStore) createAuthorsTable() error {
+	var count int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM entry_authors").Scan(&count); err != nil {
+		if _, err := s.db.Exec(createEntryAuthorsTable); err != nil {
+			return fmt.Errorf("failed to create entry_authors table: %v", err)
+		}
+		for _, query := range createEntryAuthorsIndexes {
+			if _, err := s.db.Exec(query); err != nil {
+				return fmt.Errorf("failed to create entry_authors index: %v", err)
+			}
+		}
+	}
+	return s.migrateAuthors()
+}
+
+// migrateAuthors 将 entry_meta 中残留的作者信息移到 entry_authors 表
+// 迁移后的记录不再包含这些字段，之后启动时不会再次处理
+func (s *entryMetaStore) migrateAuthors() error {
+	rows, err := s.db.Query(`SELECT match_type, entry_id, meta FROM entry_meta WHERE meta LIKE '%"created_%' OR meta LIKE '%"updated_%'`)
+	if err != nil {
+		return fmt.Errorf("failed to read entry meta for migration: %v", err)
+	}
+	type legacyMeta struct {
+		typeKey int
+		entryID int
+		meta    EntryMeta
+	}
+	var legacy []legacyMeta
+	for rows.Next() {
+		var record legacyMeta
+		var raw string
+		if err := rows.Scan(&record.typeKey, &record.entryID, &raw); err != nil {
+			rows.Close()
+			return err
+		}
+		if err := json.Unmarshal([]byte(raw), &record.meta); err != nil {
+			continue // 跳过损坏的记录
+		}
+		legacy = append(legacy, record)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, record := range legacy {
+		err := s.inTx(func(tx *sql.Tx) error {
+			return s.writeKey(tx, record.typeKey, record.entryID, record.meta)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate entry authors: %v", err)
+		}
+	}
+	return nil
+}
+
+// readAuthors 在事务中读取条目的作者记录
+func (s *entryMetaStore) readAuthors(tx *sql.Tx, typeKey, entryID int) (entryAuthors, error) {
+	var authors entryAuthors
+	err := tx.QueryRow(s.rebind("SELECT created_by, created_at, updated_by, updated_at FROM entry_authors WHERE match_type = ? AND entry_id = ?"),
+		typeKey, entryID).Scan(&authors.CreatedBy, &authors.CreatedAt, &authors.UpdatedBy, &authors.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return authors, nil
+	}
+	return authors, err
+}
+
+// writeAuthors 在事务中替换条目的作者记录，调用方已删除旧记录
+func (s *entryMetaStore) writeAuthors(tx *sql.Tx, typeKey, entryID int, authors entryAuthors) error {
+	if authors.IsEmpty() {
+		return nil
+	}
+	_, err := tx.Exec(s.rebind("INSERT INTO entry_authors (match_type, entry_id, created_by, created_at, updated_by, updated_at) VALUES (?, ?, ?, ?, ?, ?)"),
+		typeKey, entryID, authors.CreatedBy, authors.CreatedAt, authors.UpdatedBy, authors.UpdatedAt)
+	return err
+}
+
+// attachAuthors 为同一匹配类型的一批条目填充作者信息
+func (s *entryMetaStore) attachAuthors(entries []Entry, typeKey int, byID map[int][]int, ids []interface{}) error {
+	query := "SELECT entry_id, created_by, created_at, updated_by, updated_at FROM entry_authors WHERE match_type = ? AND entry_id IN (" + placeholders(len(ids)) + ")"
+	rows, err := s.db.Query(s.rebind(query), append([]interface{}{typeKey}, ids...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var authors entryAuthors
+		if err := rows.Scan(&id, &authors.CreatedBy, &authors.CreatedAt, &authors.UpdatedBy, &authors.UpdatedAt); err != nil {
+			return err
+		}
+		for _, i := range byID[id] {
+			authors.apply(&entries[i].EntryMeta)
+		}
+	}
+	return rows.Err()
+}
+
+// placeholders 返回 n 个以逗号分隔的 ? 占位符
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// authoredEntry 作者表中的一条记录对应的条目
+type authoredEntry struct {
+	typeKey int
+	entryID int
+}
+
+// authored 返回指定用户创建或最后修改的条目，按最后修改时间从新到旧排列
+// authors 为空时返回所有有作者记录的条目；matchTypes 不为空时只返回这些匹配类型
+func (s *entryMetaStore) authored(authors []int64, matchTypes []MatchType) ([]authoredEntry, error) {
+	var conditions []string
+	var args []interface{}
+	if len(authors) > 0 {
+		for _, author := range authors {
+			args = append(args, author)
+		}
+		for _, author := range authors {
+			args = append(args, author)
+		}
+		conditions = append(conditions, fmt.Sprintf("(created_by IN (%s) OR updated_by IN (%s))", placeholders(len(authors)), placeholders(len(authors))))
+	}
+	if len(matchTypes) > 0 && !s.sharedIDs {
+		for _, matchType := range matchTypes {
+			args = append(args, matchType.ToInt())
+		}
+		conditions = append(conditions, fmt.Sprintf("match_type IN (%s)", placeholders(len(matchTypes))))
+	}
+
+	query := "SELECT match_type, entry_id FROM entry_authors"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY updated_at DESC, created_at DESC"
+
+	rows, err := s.db.Query(s.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []authoredEntry
+	for rows.Next() {
+		var ref authoredEntry
+		if err := rows.Scan(&ref.typeKey, &ref.entryID); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// listByAuthor SQL 后端 ListEntriesByAuthor 的通用实现
+// 作者筛选和修改时间排序由 entry_authors 表的查询完成，按作者筛选时只读取命中的条目
+// list 读取指定匹配类型的全部条目；load 按 ID 读取条目，typeKey 与 entry_authors 的 match_type 相同
+func (s *entryMetaStore) listByAuthor(authors []int64, recent bool, matchTypes []MatchType,
+	list func(matchTypes ...MatchType) ([]Entry, error),
+	load func(typeKey int, ids []int) ([]Entry, error)) ([]Entry, error) {
+	if len(authors) == 0 && !recent {
+		return list(matchTypes...)
+	}
+
+	refs, err := s.authored(authors, matchTypes)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	if len(authors) == 0 {
+		if entries, err = list(matchTypes...); err != nil {
+			return nil, err
+		}
+	} else {
+		byType := make(map[int][]int)
+		var typeKeys []int
+		for _, ref := range refs {
+			if _, exists := byType[ref.typeKey]; !exists {
+				typeKeys = append(typeKeys, ref.typeKey)
+			}
+			byType[ref.typeKey] = append(byType[ref.typeKey], ref.entryID)
+		}
+		for _, typeKey := range typeKeys {
+			ids := byType[typeKey]
+			for start := 0; start < len(ids); start += attachBatchSize {
+				loaded, err := load(typeKey, ids[start:min(start+attachBatchSize, len(ids))])
+				if err != nil {
+					return nil, err
+				}
+				entries = append(entries, loaded...)
+			}
+		}
+	}
+
+	// 按查询结果的顺序排列，没有作者记录的条目排在最后并保持原顺序
+	rank := make(map[authoredEntry]int, len(refs))
+	for i, ref := range refs {
+		rank[ref] = i
+	}
+	position := func(entry *Entry) int {
+		if i, exists := rank[authoredEntry{s.typeKey(entry.MatchType), entry.ID}]; exists {
+			return i
+		}
+		return len(refs)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return position(&entries[i]) < position(&entries[j])
+	})
+	return entries, nil
+}
+
+// selectByAuthor 在内存中按作者筛选并按最后修改时间排序，供 JSON 后端使用
+func selectByAuthor(entries []Entry, authors []int64, recent bool) []Entry {
+	if len(authors) > 0 {
+		var filtered []Entry
+		for _, entry := range entries {
+			for _, author := range authors {
+				if entry.CreatedBy == author || entry.UpdatedBy == author {
+					filtered = append(filtered, entry)
+					break
+				}
+			}
+		}
+		entries = filtered
+	}
+	if recent || len(authors) > 0 {
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].LastModified().After(entries[j].LastModified())
+		})
+	}
+	return entries
+}
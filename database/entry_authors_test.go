@@ -0,0 +1,131 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestListEntriesByAuthor(t *testing.T) {
+	base := time.Now().Add(-24 * time.Hour).Truncate(time.Second)
+	at := func(hours int) *time.Time {
+		value := base.Add(time.Duration(hours) * time.Hour)
+		return &value
+	}
+
+	tests := []struct {
+		name       string
+		authors    []int64
+		recent     bool
+		matchTypes []MatchType
+		want       []string
+		// ordered 为 false 时只比较集合
+		ordered bool
+	}{
+		{name: "no filter", want: []string{"退款", "发票", "地址", "营业时间"}},
+		{name: "created or updated by user", authors: []int64{1}, want: []string{"发票", "退款"}, ordered: true},
+		{name: "several authors", authors: []int64{2, 3}, want: []string{"营业时间", "发票"}, ordered: true},
+		{name: "recent puts entries without timestamps last", recent: true, want: []string{"营业时间", "发票", "退款", "地址"}, ordered: true},
+		{name: "match type filter", authors: []int64{1}, matchTypes: []MatchType{MatchExact}, want: []string{"退款"}},
+		{name: "unknown author", authors: []int64{99}},
+	}
+
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			mustAdd(t, db, "退款", MatchExact)
+			mustAdd(t, db, "发票", MatchContains)
+			mustAdd(t, db, "地址", MatchExact)
+			mustAdd(t, db, "营业时间", MatchRegex)
+			authors := map[string]struct {
+				matchType MatchType
+				createdBy int64
+				createdAt *time.Time
+				updatedBy int64
+				updatedAt *time.Time
+			}{
+				"退款":   {MatchExact, 1, at(1), 1, at(1)},
+				"发票":   {MatchContains, 2, at(2), 1, at(5)},
+				"营业时间": {MatchRegex, 3, at(6), 3, at(6)},
+			}
+			for key, a := range authors {
+				err := db.UpdateEntryMeta(key, a.matchType, func(meta *EntryMeta) {
+					meta.CreatedBy, meta.CreatedAt, meta.UpdatedBy, meta.UpdatedAt = a.createdBy, a.createdAt, a.updatedBy, a.updatedAt
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for _, tt := range tests {
+				t.Run(tt.name, func(t *testing.T) {
+					entries, err := db.ListEntriesByAuthor(tt.authors, tt.recent, tt.matchTypes...)
+					if err != nil {
+						t.Fatal(err)
+					}
+					var got []string
+					for _, entry := range entries {
+						got = append(got, entry.Key)
+					}
+					if !sameKeys(got, tt.want, tt.ordered) {
+						t.Errorf("ListEntriesByAuthor() = %v, want %v", got, tt.want)
+					}
+				})
+			}
+
+			refund := mustFind(t, db, "退款", MatchExact)
+			if refund.CreatedBy != 1 || refund.CreatedAt == nil || !refund.CreatedAt.Equal(*at(1)) {
+				t.Errorf("authors = %d %v, want user 1 at %v", refund.CreatedBy, refund.CreatedAt, at(1))
+			}
+		})
+	}
+}
+
+func TestStampEntry(t *testing.T) {
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			mustAdd(t, db, "退款", MatchExact)
+			if err := StampEntry(db, "退款", MatchExact, 1, true); err != nil {
+				t.Fatal(err)
+			}
+			if err := StampEntry(db, "退款", MatchExact, 2, false); err != nil {
+				t.Fatal(err)
+			}
+
+			entry := mustFind(t, db, "退款", MatchExact)
+			if entry.CreatedBy != 1 || entry.UpdatedBy != 2 || entry.CreatedAt == nil || entry.UpdatedAt == nil {
+				t.Errorf("entry = %+v, want created by 1 and updated by 2", entry.EntryMeta)
+			}
+			if entry.LastModified().IsZero() {
+				t.Errorf("LastModified() is zero after stamping")
+			}
+		})
+	}
+}
+
+func sameKeys(got, want []string, ordered bool) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	if ordered {
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+	count := make(map[string]int)
+	for _, key := range got {
+		count[key]++
+	}
+	for _, key := range want {
+		count[key]--
+	}
+	for _, n := range count {
+		if n != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,473 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 媒体类型（以 Telegram file_id 存储）
+const (
+	MediaPhoto     = "photo"
+	MediaDocument  = "document"
+	MediaVideo     = "video"
+	MediaVoice     = "voice"
+	MediaSticker   = "sticker"
+	MediaAnimation = "animation"
+	// ContentAlbum 多个媒体组成的相册
+	ContentAlbum = "album"
+)
+
+// MediaItem 单个媒体文件
+type MediaItem struct {
+	Type   string `json:"type"`
+	FileID string `json:"file_id"`
+}
+
+// 回答按钮类型
+const (
+	ButtonURL   = "url"   // 打开链接
+	ButtonEntry = "entry" // 显示相关条目
+	ButtonStart = "start" // 机器人深链接
+)
+
+// EntryButton 回答下方的内联按钮
+type EntryButton struct {
+	Text       string    `json:"text"`
+	Type       string    `json:"type"`
+	URL        string    `json:"url,omitempty"`         // 链接按钮的地址
+	EntryKey   string    `json:"entry_key,omitempty"`   // 相关条目的 key
+	EntryType  MatchType `json:"entry_type,omitempty"`  // 相关条目的匹配类型
+	StartParam string    `json:"start_param,omitempty"` // 深链接的 start 参数
+}
+
+// MachineTranslation AI 生成的翻译缓存
+type MachineTranslation struct {
+	Text   string `json:"text"`
+	Source string `json:"source"` // 翻译时的原文，原文变化后缓存失效
+}
+
+// EntryMeta 条目扩展属性
+// JSON 后端随条目一起保存，SQL 后端以 JSON 文本保存在 entry_meta 表中，作者信息保存在 entry_authors 表中
+type EntryMeta struct {
+	Media   []MediaItem     `json:"media,omitempty"`   // 媒体回答
+	Caption string          `json:"caption,omitempty"` // 媒体说明文字
+	Buttons [][]EntryButton `json:"buttons,omitempty"` // 内联按钮，按行排列
+	// Translations 各语言的回答内容（媒体回答为说明文字），默认语言使用 Value
+	Translations        map[string]string             `json:"translations,omitempty"`
+	MachineTranslations map[string]MachineTranslation `json:"machine_translations,omitempty"`
+	// 有效期，为空表示不限制
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	ValidUntil *time.Time `json:"valid_until,omitempty"`
+	// ExpiryNotifiedFor 已发送过期提醒时对应的 ValidUntil，有效期修改后会重新提醒
+	ExpiryNotifiedFor *time.Time `json:"expiry_notified_for,omitempty"`
+	// Priority 多个条目同时命中时，优先级高的条目优先
+	Priority int `json:"priority,omitempty"`
+	// Visibility 可以查看条目的用户范围，为空表示公开；VisibleTo 为 users 级别允许的用户 ID
+	Visibility string  `json:"visibility,omitempty"`
+	VisibleTo  []int64 `json:"visible_to,omitempty"`
+	// 创建者、最后修改者（Telegram 用户 ID）和对应时间
+	CreatedBy int64      `json:"created_by,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedBy int64      `json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// 条目可见性
+const (
+	VisibilityPublic      = "public"       // 所有人
+	VisibilityAdmins      = "admins"       // 管理员（包括超级管理员）
+	VisibilitySuperAdmins = "super_admins" // 超级管理员
+	VisibilityUsers       = "users"        // 指定用户
+)
+
+// IsEmpty 检查扩展属性是否为空
+func (m EntryMeta) IsEmpty() bool {
+	return len(m.Media) == 0 && m.Caption == "" && len(m.Buttons) == 0 &&
+		len(m.Translations) == 0 && len(m.MachineTranslations) == 0 &&
+		m.ValidFrom == nil && m.ValidUntil == nil && m.ExpiryNotifiedFor == nil &&
+		m.Priority == 0 && m.Visibility == "" && len(m.VisibleTo) == 0 &&
+		m.CreatedBy == 0 && m.CreatedAt == nil && m.UpdatedBy == 0 && m.UpdatedAt == nil
+}
+
+// AnswerText 返回默认语言的回答文本，媒体回答为说明文字
+func (e *Entry) AnswerText() string {
+	if e.HasMedia() {
+		return e.Caption
+	}
+	return e.Value
+}
+
+// 条目有效期状态
+const (
+	EntryActive    = "active"    // 有效
+	EntryScheduled = "scheduled" // 尚未生效
+	EntryExpired   = "expired"   // 已过期
+)
+
+// ValidityStatus 返回条目在指定时间的有效期状态
+func (e *Entry) ValidityStatus(now time.Time) string {
+	if e.ValidFrom != nil && now.Before(*e.ValidFrom) {
+		return EntryScheduled
+	}
+	if e.ValidUntil != nil && !now.Before(*e.ValidUntil) {
+		return EntryExpired
+	}
+	return EntryActive
+}
+
+// IsActive 检查条目在指定时间是否处于有效期内
+func (e *Entry) IsActive(now time.Time) bool {
+	return e.ValidityStatus(now) == EntryActive
+}
+
+// LastModified 返回条目最后修改的时间，没有记录时返回零值
+func (e *Entry) LastModified() time.Time {
+	switch {
+	case e.UpdatedAt != nil:
+		return *e.UpdatedAt
+	case e.CreatedAt != nil:
+		return *e.CreatedAt
+	default:
+		return time.Time{}
+	}
+}
+
+// StampEntry 记录条目的修改者和修改时间，created 为 true 时同时记录创建者
+func StampEntry(db Database, key string, matchType MatchType, userID int64, created bool) error {
+	now := time.Now()
+	return db.UpdateEntryMeta(key, matchType, func(meta *EntryMeta) {
+		if created {
+			meta.CreatedBy = userID
+			meta.CreatedAt = &now
+		}
+		meta.UpdatedBy = userID
+		meta.UpdatedAt = &now
+	})
+}
+
+// QueryByIDActive 按 ID 查找处于有效期内的条目，不存在或不在有效期内时返回 nil
+// 向用户展示条目的路径（深链接、分类列表、相关条目按钮）都应使用该方法而不是 QueryByID
+func QueryByIDActive(db Database, id int, matchType MatchType) (*Entry, error) {
+	entry, err := db.QueryByID(id, matchType)
+	if err != nil || entry == nil || !entry.IsActive(time.Now()) {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// filterActive 过滤掉不在有效期内的条目，供各后端的查询方法使用
+func filterActive(entries []Entry, err error) ([]Entry, error) {
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	active := entries[:0]
+	for _, entry := range entries {
+		if entry.IsActive(now) {
+			active = append(active, entry)
+		}
+	}
+	return active, nil
+}
+
+// HasMedia 检查条目是否为媒体回答
+func (e *Entry) HasMedia() bool {
+	return len(e.Media) > 0
+}
+
+// MediaContentType 返回媒体回答的内容类型，多个媒体时为 album
+func (m EntryMeta) MediaContentType() string {
+	switch len(m.Media) {
+	case 0:
+		return ""
+	case 1:
+		return m.Media[0].Type
+	default:
+		return ContentAlbum
+	}
+}
+
+// applyMeta 设置条目扩展属性，并根据媒体同步 ContentType
+func (e *Entry) applyMeta(meta EntryMeta) {
+	e.EntryMeta = meta
+	if contentType := meta.MediaContentType(); contentType != "" {
+		e.ContentType = contentType
+	}
+}
+
+// decodeEntryMeta 从 JSON 对象中解析扩展属性
+func decodeEntryMeta(entryMap map[string]interface{}) EntryMeta {
+	var meta EntryMeta
+	raw, err := json.Marshal(entryMap)
+	if err != nil {
+		return meta
+	}
+	json.Unmarshal(raw, &meta)
+	return meta
+}
+
+// findEntryByKey 在指定匹配类型中按 key 查找条目
+func findEntryByKey(db Database, key string, matchType MatchType) (*Entry, error) {
+	entries, err := db.ListSpecificEntries(matchType)
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Key == key {
+			return &entries[i], nil
+		}
+	}
+	return nil, fmt.Errorf("entry %s not found in %s", key, matchType.GetTableName())
+}
+
+// entryMetaStore SQL 后端的扩展属性存储
+type entryMetaStore struct {
+	db *sql.DB
+	// mu 串行化同一进程内对扩展属性的读改写，事务保证删除和插入一起生效
+	mu sync.Mutex
+	// postgres 使用 $n 占位符
+	postgres bool
+	// sharedIDs 为 true 时条目 ID 在所有匹配类型中唯一（PostgreSQL 单表），不再按匹配类型区分
+	sharedIDs bool
+}
+
+const createEntryMetaTable = `CREATE TABLE IF NOT EXISTS entry_meta (
+	match_type INTEGER NOT NULL,
+	entry_id INTEGER NOT NULL,
+	meta TEXT NOT NULL,
+	PRIMARY KEY (match_type, entry_id)
+)`
+
+func newEntryMetaStore(db *sql.DB, postgres, sharedIDs bool) *entryMetaStore {
+	return &entryMetaStore{db: db, postgres: postgres, sharedIDs: sharedIDs}
+}
+
+// createTable 创建扩展属性表
+func (s *entryMetaStore) createTable() error {
+	if _, err := s.db.Exec(createEntryMetaTable); err != nil {
+		return fmt.Errorf("failed to create entry_meta table: %v", err)
+	}
+	return s.createAuthorsTable()
+}
+
+// rebindQuery 将 ? 占位符转换为 PostgreSQL 的 $n 格式
+func rebindQuery(query string, postgres bool) string {
+	if !postgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// rebind 将 ? 占位符转换为当前数据库的格式
+func (s *entryMetaStore) rebind(query string) string {
+	return rebindQuery(query, s.postgres)
+}
+
+func (s *entryMetaStore) typeKey(matchType MatchType) int {
+	if s.sharedIDs {
+		return 0
+	}
+	return matchType.ToInt()
+}
+
+// Set 保存条目扩展属性，空属性会删除记录
+func (s *entryMetaStore) Set(matchType MatchType, entryID int, meta EntryMeta) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inTx(func(tx *sql.Tx) error {
+		return s.write(tx, matchType, entryID, meta)
+	})
+}
+
+// Update 在同一事务中读取、修改并保存条目扩展属性，update 只修改需要变更的字段，
+// 其他字段保持数据库中的最新值
+func (s *entryMetaStore) Update(matchType MatchType, entryID int, update func(meta *EntryMeta)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inTx(func(tx *sql.Tx) error {
+		var meta EntryMeta
+		var raw string
+		err := tx.QueryRow(s.rebind("SELECT meta FROM entry_meta WHERE match_type = ? AND entry_id = ?"),
+			s.typeKey(matchType), entryID).Scan(&raw)
+		switch {
+		case err == sql.ErrNoRows:
+		case err != nil:
+			return err
+		default:
+			if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+				return fmt.Errorf("failed to parse entry meta: %v", err)
+			}
+		}
+		authors, err := s.readAuthors(tx, s.typeKey(matchType), entryID)
+		if err != nil {
+			return err
+		}
+		if !authors.IsEmpty() {
+			authors.apply(&meta)
+		}
+		update(&meta)
+		return s.write(tx, matchType, entryID, meta)
+	})
+}
+
+// write 在事务中替换条目的扩展属性和作者记录
+func (s *entryMetaStore) write(tx *sql.Tx, matchType MatchType, entryID int, meta EntryMeta) error {
+	return s.writeKey(tx, s.typeKey(matchType), entryID, meta)
+}
+
+// writeKey 与 write 相同，typeKey 为表中保存的 match_type
+func (s *entryMetaStore) writeKey(tx *sql.Tx, typeKey, entryID int, meta EntryMeta) error {
+	if err := s.deleteKey(tx, typeKey, entryID); err != nil {
+		return err
+	}
+	if err := s.writeAuthors(tx, typeKey, entryID, authorsOf(meta)); err != nil {
+		return err
+	}
+	meta = meta.withoutAuthors()
+	if meta.IsEmpty() {
+		return nil
+	}
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal entry meta: %v", err)
+	}
+	_, err = tx.Exec(s.rebind("INSERT INTO entry_meta (match_type, entry_id, meta) VALUES (?, ?, ?)"),
+		typeKey, entryID, string(raw))
+	return err
+}
+
+// deleteKey 在事务中删除条目的扩展属性和作者记录
+func (s *entryMetaStore) deleteKey(tx *sql.Tx, typeKey, entryID int) error {
+	for _, table := range []string{"entry_meta", "entry_authors"} {
+		if _, err := tx.Exec(s.rebind("DELETE FROM "+table+" WHERE match_type = ? AND entry_id = ?"), typeKey, entryID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inTx 在事务中执行 fn，出错时回滚
+func (s *entryMetaStore) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete 删除条目扩展属性和作者记录
+func (s *entryMetaStore) Delete(matchType MatchType, entryID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inTx(func(tx *sql.Tx) error {
+		return s.deleteKey(tx, s.typeKey(matchType), entryID)
+	})
+}
+
+// Clear 删除所有扩展属性和作者记录
+func (s *entryMetaStore) Clear() error {
+	if _, err := s.db.Exec("DELETE FROM entry_meta"); err != nil {
+		return err
+	}
+	_, err := s.db.Exec("DELETE FROM entry_authors")
+	return err
+}
+
+// attachBatchSize 每次查询扩展属性的最大条目数，避免超出数据库的参数数量限制
+const attachBatchSize = 500
+
+// Attach 为查询结果填充扩展属性，只读取这些条目的记录
+func (s *entryMetaStore) Attach(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// 按匹配类型分组，查询可以使用 (match_type, entry_id) 主键
+	byType := make(map[int][]int)
+	for i := range entries {
+		typeKey := s.typeKey(entries[i].MatchType)
+		byType[typeKey] = append(byType[typeKey], i)
+	}
+	for typeKey, indexes := range byType {
+		for start := 0; start < len(indexes); start += attachBatchSize {
+			end := min(start+attachBatchSize, len(indexes))
+			if err := s.attachBatch(entries, typeKey, indexes[start:end]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// attachBatch 为同一匹配类型的一批条目填充扩展属性和作者信息，indexes 为条目在 entries 中的位置
+func (s *entryMetaStore) attachBatch(entries []Entry, typeKey int, indexes []int) error {
+	byID := make(map[int][]int)
+	var ids []interface{}
+	for _, i := range indexes {
+		id := entries[i].ID
+		if _, exists := byID[id]; !exists {
+			ids = append(ids, id)
+		}
+		byID[id] = append(byID[id], i)
+	}
+
+	query := "SELECT entry_id, meta FROM entry_meta WHERE match_type = ? AND entry_id IN (" + placeholders(len(ids)) + ")"
+	rows, err := s.db.Query(s.rebind(query), append([]interface{}{typeKey}, ids...)...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var raw string
+		if err := rows.Scan(&id, &raw); err != nil {
+			return err
+		}
+		var meta EntryMeta
+		if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+			continue // 跳过损坏的记录
+		}
+		for _, i := range byID[id] {
+			entries[i].applyMeta(meta)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	// 作者信息在扩展属性之后填充，覆盖旧版本 JSON 文本中可能残留的字段
+	return s.attachAuthors(entries, typeKey, byID, ids)
+}
+
+// attachOne 为单个条目填充扩展属性
+func (s *entryMetaStore) attachOne(entry *Entry) error {
+	if entry == nil {
+		return nil
+	}
+	entries := []Entry{*entry}
+	if err := s.Attach(entries); err != nil {
+		return err
+	}
+	*entry = entries[0]
+	return nil
+}
@@ -0,0 +1,115 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidityStatus(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name  string
+		from  *time.Time
+		until *time.Time
+		want  string
+	}{
+		{name: "no validity", want: EntryActive},
+		{name: "started", from: &before, want: EntryActive},
+		{name: "starts exactly now", from: &now, want: EntryActive},
+		{name: "not started", from: &after, want: EntryScheduled},
+		{name: "not expired", until: &after, want: EntryActive},
+		{name: "expires exactly now", until: &now, want: EntryExpired},
+		{name: "expired", until: &before, want: EntryExpired},
+		{name: "within range", from: &before, until: &after, want: EntryActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := Entry{EntryMeta: EntryMeta{ValidFrom: tt.from, ValidUntil: tt.until}}
+			if got := entry.ValidityStatus(now); got != tt.want {
+				t.Errorf("ValidityStatus() = %q, want %q", got, tt.want)
+			}
+			if got := entry.IsActive(now); got != (tt.want == EntryActive) {
+				t.Errorf("IsActive() = %v, want %v", got, tt.want == EntryActive)
+			}
+		})
+	}
+}
+
+func TestFilterActive(t *testing.T) {
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	entries := []Entry{
+		{Key: "active"},
+		{Key: "expired", EntryMeta: EntryMeta{ValidUntil: &past}},
+		{Key: "scheduled", EntryMeta: EntryMeta{ValidFrom: &future}},
+		{Key: "window", EntryMeta: EntryMeta{ValidFrom: &past, ValidUntil: &future}},
+	}
+
+	got, err := filterActive(entries, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || got[0].Key != "active" || got[1].Key != "window" {
+		t.Errorf("filterActive() = %+v, want active and window", got)
+	}
+}
+
+func TestQueryByIDActive(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			mustAdd(t, db, "退款", MatchExact)
+			mustAdd(t, db, "发票", MatchExact)
+			if err := db.SetEntryMeta("发票", MatchExact, EntryMeta{ValidUntil: &past}); err != nil {
+				t.Fatal(err)
+			}
+
+			active := mustFind(t, db, "退款", MatchExact)
+			if entry, err := QueryByIDActive(db, active.ID, MatchExact); err != nil || entry == nil || entry.Key != "退款" {
+				t.Errorf("QueryByIDActive(active) = %+v, %v, want the entry", entry, err)
+			}
+
+			expired := mustFind(t, db, "发票", MatchExact)
+			if entry, err := QueryByIDActive(db, expired.ID, MatchExact); err != nil || entry != nil {
+				t.Errorf("QueryByIDActive(expired) = %+v, %v, want nil", entry, err)
+			}
+			// 管理员路径仍能取到过期条目
+			if entry, err := db.QueryByID(expired.ID, MatchExact); err != nil || entry == nil {
+				t.Errorf("QueryByID(expired) = %+v, %v, want the entry", entry, err)
+			}
+			if entry, err := db.QueryExact("发票"); err != nil || len(entry) != 0 {
+				t.Errorf("QueryExact(expired) = %+v, %v, want no results", entry, err)
+			}
+		})
+	}
+}
+
+func TestEntryMediaRoundTrip(t *testing.T) {
+	media := []MediaItem{{Type: MediaPhoto, FileID: "a"}, {Type: MediaVideo, FileID: "b"}}
+
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			mustAdd(t, db, "地址", MatchExact)
+			if err := db.SetEntryMeta("地址", MatchExact, EntryMeta{Media: media, Caption: "公司地址"}); err != nil {
+				t.Fatal(err)
+			}
+
+			results, err := db.QueryExact("地址")
+			if err != nil || len(results) != 1 {
+				t.Fatalf("QueryExact() = %+v, %v", results, err)
+			}
+			entry := results[0]
+			if entry.ContentType != ContentAlbum || len(entry.Media) != 2 || entry.Media[1] != media[1] || entry.Caption != "公司地址" {
+				t.Errorf("entry = %+v, want the album with its caption", entry)
+			}
+			if entry.AnswerText() != "公司地址" {
+				t.Errorf("AnswerText() = %q, want the caption", entry.AnswerText())
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Hit 一次条目命中记录
+type Hit struct {
+	EntryID   int       `json:"entry_id"`
+	MatchType MatchType `json:"match_type"`
+	EntryKey  string    `json:"entry_key"`
+	ChatID    int64     `json:"chat_id"`
+	Time      time.Time `json:"time"`
+}
+
+// EntryHitCount 条目命中次数
+type EntryHitCount struct {
+	EntryID   int       `json:"entry_id"`
+	MatchType MatchType `json:"match_type"`
+	Key       string    `json:"key"`
+	Count     int       `json:"count"`
+	LastHit   time.Time `json:"last_hit"`
+}
+
+// HitPeriod 一个统计周期内的命中情况
+type HitPeriod struct {
+	Days         int `json:"days"`
+	Hits         int `json:"hits"`
+	Chats        int `json:"chats"`
+	PreviousHits int `json:"previous_hits"` // 上一个同等长度周期的命中次数，用于比较趋势
+}
+
+// HitReport 命中统计报告
+type HitReport struct {
+	GeneratedAt time.Time       `json:"generated_at"`
+	TotalHits   int             `json:"total_hits"`
+	Periods     []HitPeriod     `json:"periods"`
+	Top         []EntryHitCount `json:"top"`
+	NeverHit    []Entry         `json:"never_hit"`
+}
+
+// hitReportPeriods 报告中统计趋势的周期（天）
+var hitReportPeriods = []int{1, 7, 30}
+
+// BuildHitReport 汇总命中记录，生成热门条目、从未命中条目和各周期趋势
+// 命中次数由数据库按条目和时间段聚合，不读取全部命中记录
+func BuildHitReport(db Database, now time.Time, topN int) (*HitReport, error) {
+	entries, err := db.ListAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	hitCounts, err := db.HitCounts()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HitReport{GeneratedAt: now}
+
+	type entryRef struct {
+		id        int
+		matchType int
+	}
+	counts := make(map[entryRef]EntryHitCount, len(hitCounts))
+	for _, count := range hitCounts {
+		report.TotalHits += count.Count
+		counts[entryRef{count.EntryID, count.MatchType.ToInt()}] = count
+	}
+
+	// 只统计仍然存在的条目，key 以当前条目为准
+	for _, entry := range entries {
+		count, exists := counts[entryRef{entry.ID, entry.MatchType.ToInt()}]
+		if !exists {
+			report.NeverHit = append(report.NeverHit, entry)
+			continue
+		}
+		count.Key = entry.Key
+		report.Top = append(report.Top, count)
+	}
+	sort.Slice(report.Top, func(i, j int) bool {
+		if report.Top[i].Count != report.Top[j].Count {
+			return report.Top[i].Count > report.Top[j].Count
+		}
+		return report.Top[i].LastHit.After(report.Top[j].LastHit)
+	})
+	if topN > 0 && len(report.Top) > topN {
+		report.Top = report.Top[:topN]
+	}
+
+	for _, days := range hitReportPeriods {
+		start := now.AddDate(0, 0, -days)
+		previousStart := start.AddDate(0, 0, -days)
+		period := HitPeriod{Days: days}
+		if period.Hits, period.Chats, err = db.HitTotals(start, time.Time{}); err != nil {
+			return nil, err
+		}
+		if period.PreviousHits, _, err = db.HitTotals(previousStart, start); err != nil {
+			return nil, err
+		}
+		report.Periods = append(report.Periods, period)
+	}
+
+	return report, nil
+}
+
+// hitStore SQL 后端的命中记录存储
+type hitStore struct {
+	db *sql.DB
+	// postgres 使用 $n 占位符
+	postgres bool
+}
+
+// 时间以 Unix 秒保存，避免不同数据库的时间类型差异
+const createHitsTable = `CREATE TABLE IF NOT EXISTS faq_hits (
+	entry_id INTEGER NOT NULL,
+	match_type INTEGER NOT NULL,
+	entry_key TEXT NOT NULL,
+	chat_id BIGINT NOT NULL,
+	hit_at BIGINT NOT NULL
+)`
+
+func newHitStore(db *sql.DB, postgres bool) *hitStore {
+	return &hitStore{db: db, postgres: postgres}
+}
+
+// createTable 创建命中记录表
+func (s *hitStore) createTable() error {
+	if _, err := s.db.Exec(createHitsTable); err != nil {
+		return fmt.Errorf("failed to create faq_hits table: %v", err)
+	}
+	return nil
+}
+
+// Record 保存一次命中
+func (s *hitStore) Record(hit Hit) error {
+	query := rebindQuery(`INSERT INTO faq_hits (entry_id, match_type, entry_key, chat_id, hit_at) VALUES (?, ?, ?, ?, ?)`, s.postgres)
+	_, err := s.db.Exec(query, hit.EntryID, hit.MatchType.ToInt(), hit.EntryKey, hit.ChatID, hit.Time.Unix())
+	return err
+}
+
+// List 返回 since 之后的命中记录，按时间排序
+func (s *hitStore) List(since time.Time) ([]Hit, error) {
+	query := rebindQuery(`SELECT entry_id, match_type, entry_key, chat_id, hit_at FROM faq_hits WHERE hit_at >= ? ORDER BY hit_at`, s.postgres)
+	rows, err := s.db.Query(query, since.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hits []Hit
+	for rows.Next() {
+		var hit Hit
+		var matchType int
+		var hitAt int64
+		if err := rows.Scan(&hit.EntryID, &matchType, &hit.EntryKey, &hit.ChatID, &hitAt); err != nil {
+			return nil, err
+		}
+		hit.MatchType = intToMatchType(matchType)
+		hit.Time = time.Unix(hitAt, 0)
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// Counts 按条目汇总命中次数和最近命中时间
+func (s *hitStore) Counts() ([]EntryHitCount, error) {
+	rows, err := s.db.Query(`SELECT entry_id, match_type, COUNT(*), MAX(hit_at) FROM faq_hits GROUP BY entry_id, match_type`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []EntryHitCount
+	for rows.Next() {
+		var count EntryHitCount
+		var matchType int
+		var lastHit int64
+		if err := rows.Scan(&count.EntryID, &matchType, &count.Count, &lastHit); err != nil {
+			return nil, err
+		}
+		count.MatchType = intToMatchType(matchType)
+		count.LastHit = time.Unix(lastHit, 0)
+		counts = append(counts, count)
+	}
+	return counts, rows.Err()
+}
+
+// Totals 统计 [since, until) 内的命中次数和聊天数，until 为零值时不限制结束时间
+func (s *hitStore) Totals(since, until time.Time) (int, int, error) {
+	query := `SELECT COUNT(*), COUNT(DISTINCT chat_id) FROM faq_hits WHERE hit_at >= ?`
+	args := []interface{}{since.Unix()}
+	if !until.IsZero() {
+		query += ` AND hit_at < ?`
+		args = append(args, until.Unix())
+	}
+	var hits, chats int
+	err := s.db.QueryRow(rebindQuery(query, s.postgres), args...).Scan(&hits, &chats)
+	return hits, chats, err
+}
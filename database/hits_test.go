@@ -0,0 +1,73 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBuildHitReport(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			for _, key := range []string{"退款", "发票", "地址"} {
+				mustAdd(t, db, key, MatchExact)
+			}
+			refund, invoice := mustFind(t, db, "退款", MatchExact), mustFind(t, db, "发票", MatchExact)
+
+			hits := []Hit{
+				{EntryID: refund.ID, MatchType: MatchExact, EntryKey: "退款", ChatID: 1, Time: now.Add(-time.Hour)},
+				{EntryID: refund.ID, MatchType: MatchExact, EntryKey: "退款", ChatID: 2, Time: now.Add(-36 * time.Hour)},
+				{EntryID: refund.ID, MatchType: MatchExact, EntryKey: "退款", ChatID: 1, Time: now.AddDate(0, 0, -10)},
+				{EntryID: invoice.ID, MatchType: MatchExact, EntryKey: "发票", ChatID: 3, Time: now.Add(-3 * time.Hour)},
+				// 已删除条目的命中计入总数，但不出现在热门条目中
+				{EntryID: 999, MatchType: MatchExact, EntryKey: "旧条目", ChatID: 4, Time: now.AddDate(0, 0, -20)},
+			}
+			for _, hit := range hits {
+				if err := db.RecordHit(hit); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			report, err := BuildHitReport(db, now, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.TotalHits != 5 {
+				t.Errorf("TotalHits = %d, want 5", report.TotalHits)
+			}
+			if len(report.Top) != 2 || report.Top[0].Key != "退款" || report.Top[0].Count != 3 || report.Top[1].Key != "发票" {
+				t.Errorf("Top = %+v, want 退款 (3) then 发票", report.Top)
+			}
+			if !report.Top[0].LastHit.Equal(now.Add(-time.Hour)) {
+				t.Errorf("LastHit = %v, want %v", report.Top[0].LastHit, now.Add(-time.Hour))
+			}
+			if len(report.NeverHit) != 1 || report.NeverHit[0].Key != "地址" {
+				t.Errorf("NeverHit = %+v, want 地址", report.NeverHit)
+			}
+
+			want := []HitPeriod{
+				{Days: 1, Hits: 2, Chats: 2, PreviousHits: 1},
+				{Days: 7, Hits: 3, Chats: 3, PreviousHits: 1},
+				{Days: 30, Hits: 5, Chats: 4, PreviousHits: 0},
+			}
+			if len(report.Periods) != len(want) {
+				t.Fatalf("Periods = %+v, want %+v", report.Periods, want)
+			}
+			for i := range want {
+				if report.Periods[i] != want[i] {
+					t.Errorf("Periods[%d] = %+v, want %+v", i, report.Periods[i], want[i])
+				}
+			}
+
+			limited, err := BuildHitReport(db, now, 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(limited.Top) != 1 || limited.Top[0].Key != "退款" {
+				t.Errorf("Top with limit = %+v, want only 退款", limited.Top)
+			}
+		})
+	}
+}
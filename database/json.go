@@ -7,6 +7,8 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"TGFaqBot/config"
 )
@@ -17,6 +19,11 @@ type JSONDB struct {
 	models     map[string][]ModelInfo // {"openai": [], "anthropic": [], ...}
 	modelCache []config.Model         // 缓存的模型列表
 	cacheTime  string                 // 缓存时间
+	hits       []Hit                  // 命中记录
+	misses     []Miss                 // 未回答问题
+	changes    []PendingChange        // 待审核的修改
+	commands   []CustomCommand        // 自定义命令
+	metaMu     sync.Mutex             // 串行化扩展属性的读改写
 }
 
 func NewJSONDB(filename string) (*JSONDB, error) {
@@ -98,10 +105,20 @@ func (j *JSONDB) UpdateEntry(key string, oldType MatchType, newType MatchType, v
 		}
 	} else {
 		// Different types, delete from old and add to new
+		var meta EntryMeta
+		if entry, err := findEntryByKey(j, key, oldType); err == nil {
+			meta = entry.EntryMeta
+		}
 		if err := j.DeleteEntry(key, oldType); err != nil {
 			return err
 		}
-		return j.AddEntry(key, newType, value)
+		if err := j.AddEntry(key, newType, value); err != nil {
+			return err
+		}
+		if meta.IsEmpty() {
+			return nil
+		}
+		return j.SetEntryMeta(key, newType, meta)
 	}
 }
 
@@ -193,6 +210,15 @@ func (j *JSONDB) ListSpecificEntries(matchTypes ...MatchType) ([]Entry, error) {
 	return allEntries, nil
 }
 
+// ListEntriesByAuthor 按作者筛选条目，recent 为 true 时按最后修改时间从新到旧排列
+func (j *JSONDB) ListEntriesByAuthor(authors []int64, recent bool, matchTypes ...MatchType) ([]Entry, error) {
+	entries, err := j.ListSpecificEntries(matchTypes...)
+	if err != nil {
+		return nil, err
+	}
+	return selectByAuthor(entries, authors, recent), nil
+}
+
 func (j *JSONDB) ListAllEntries() ([]Entry, error) {
 	var allEntries []Entry
 	for matchType, entries := range j.data {
@@ -253,7 +279,7 @@ func (j *JSONDB) query(query string, matchType string) ([]Entry, error) {
 			}
 		}
 	}
-	return results, nil
+	return filterActive(results, nil)
 }
 
 func (j *JSONDB) AddEntryExact(key string, value string) error {
@@ -402,6 +428,10 @@ func (j *JSONDB) Reload() error {
 		j.models = make(map[string][]ModelInfo)
 		j.modelCache = []config.Model{}
 		j.cacheTime = ""
+		j.hits = nil
+		j.misses = nil
+		j.changes = nil
+		j.commands = nil
 		return nil
 	}
 
@@ -417,6 +447,10 @@ func (j *JSONDB) Reload() error {
 	j.models = make(map[string][]ModelInfo)
 	j.modelCache = []config.Model{}
 	j.cacheTime = ""
+	j.hits = nil
+	j.misses = nil
+	j.changes = nil
+	j.commands = nil
 
 	// 解析FAQ数据
 	for key, value := range fullData {
@@ -462,6 +496,42 @@ func (j *JSONDB) Reload() error {
 					}
 				}
 			}
+		case "hits":
+			// 解析命中记录
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(raw, &j.hits); err != nil {
+				return fmt.Errorf("failed to parse hits: %v", err)
+			}
+		case "misses":
+			// 解析未回答问题
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(raw, &j.misses); err != nil {
+				return fmt.Errorf("failed to parse misses: %v", err)
+			}
+		case "changes":
+			// 解析待审核的修改
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(raw, &j.changes); err != nil {
+				return fmt.Errorf("failed to parse changes: %v", err)
+			}
+		case "commands":
+			// 解析自定义命令
+			raw, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(raw, &j.commands); err != nil {
+				return fmt.Errorf("failed to parse commands: %v", err)
+			}
 		default:
 			// 解析FAQ条目数据
 			if entryList, ok := value.([]interface{}); ok {
@@ -469,11 +539,15 @@ func (j *JSONDB) Reload() error {
 				for _, entry := range entryList {
 					if entryMap, ok := entry.(map[string]interface{}); ok {
 						entryInfo := Entry{
-							ID:        int(getFloat64(entryMap, "id")),
-							Key:       getString(entryMap, "key"),
-							Value:     getString(entryMap, "value"),
-							MatchType: intToMatchType(int(getFloat64(entryMap, "match_type"))),
+							ID:            int(getFloat64(entryMap, "id")),
+							Key:           getString(entryMap, "key"),
+							Value:         getString(entryMap, "value"),
+							MatchType:     intToMatchType(int(getFloat64(entryMap, "match_type"))),
+							ContentType:   getString(entryMap, "content_type"),
+							TelegraphURL:  getString(entryMap, "telegraph_url"),
+							TelegraphPath: getString(entryMap, "telegraph_path"),
 						}
+						entryInfo.applyMeta(decodeEntryMeta(entryMap))
 						entries = append(entries, entryInfo)
 					}
 				}
@@ -504,12 +578,8 @@ func (j *JSONDB) DeleteAllEntries() error {
 }
 
 func (j *JSONDB) Save() error {
-	bytes, err := json.MarshalIndent(j.data, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return os.WriteFile(j.filename, bytes, 0644)
+	// 同时写入模型和命中记录，避免保存条目时丢失其他数据
+	return j.SaveWithModels()
 }
 
 func (j *JSONDB) Close() error {
@@ -557,6 +627,19 @@ func (j *JSONDB) SaveWithModels() error {
 		"models":   j.models,
 	}
 
+	if len(j.hits) > 0 {
+		fullData["hits"] = j.hits
+	}
+	if len(j.misses) > 0 {
+		fullData["misses"] = j.misses
+	}
+	if len(j.changes) > 0 {
+		fullData["changes"] = j.changes
+	}
+	if len(j.commands) > 0 {
+		fullData["commands"] = j.commands
+	}
+
 	// 添加模型缓存数据
 	if len(j.modelCache) > 0 {
 		fullData["model_cache"] = map[string]interface{}{
@@ -624,3 +707,171 @@ func (j *JSONDB) GetTelegraphContent(key string, matchType MatchType) (*Entry, e
 	// 暂时使用现有的查询方法
 	return j.QueryByID(1, matchType) // 默认ID为1
 }
+
+// SetEntryMeta 设置条目扩展属性
+func (j *JSONDB) SetEntryMeta(key string, matchType MatchType, meta EntryMeta) error {
+	j.metaMu.Lock()
+	defer j.metaMu.Unlock()
+	tableName := matchType.GetTableName()
+	for i, entry := range j.data[tableName] {
+		if entry.Key == key {
+			j.data[tableName][i].applyMeta(meta)
+			return j.Save()
+		}
+	}
+	return fmt.Errorf("entry not found")
+}
+
+// UpdateEntryMeta 修改条目扩展属性的部分字段
+func (j *JSONDB) UpdateEntryMeta(key string, matchType MatchType, update func(meta *EntryMeta)) error {
+	j.metaMu.Lock()
+	defer j.metaMu.Unlock()
+	tableName := matchType.GetTableName()
+	for i, entry := range j.data[tableName] {
+		if entry.Key == key {
+			meta := entry.EntryMeta
+			update(&meta)
+			j.data[tableName][i].applyMeta(meta)
+			return j.Save()
+		}
+	}
+	return fmt.Errorf("entry not found")
+}
+
+func (j *JSONDB) RecordHit(hit Hit) error {
+	j.hits = append(j.hits, hit)
+	return j.Save()
+}
+
+func (j *JSONDB) ListHits(since time.Time) ([]Hit, error) {
+	var hits []Hit
+	for _, hit := range j.hits {
+		if !hit.Time.Before(since) {
+			hits = append(hits, hit)
+		}
+	}
+	return hits, nil
+}
+
+func (j *JSONDB) HitCounts() ([]EntryHitCount, error) {
+	type entryRef struct {
+		id        int
+		matchType MatchType
+	}
+	index := make(map[entryRef]int)
+	var counts []EntryHitCount
+	for _, hit := range j.hits {
+		ref := entryRef{hit.EntryID, hit.MatchType}
+		i, exists := index[ref]
+		if !exists {
+			i = len(counts)
+			index[ref] = i
+			counts = append(counts, EntryHitCount{EntryID: hit.EntryID, MatchType: hit.MatchType})
+		}
+		counts[i].Count++
+		if hit.Time.After(counts[i].LastHit) {
+			counts[i].LastHit = hit.Time
+		}
+	}
+	return counts, nil
+}
+
+func (j *JSONDB) HitTotals(since, until time.Time) (int, int, error) {
+	hits := 0
+	chats := make(map[int64]bool)
+	for _, hit := range j.hits {
+		if hit.Time.Before(since) || (!until.IsZero() && !hit.Time.Before(until)) {
+			continue
+		}
+		hits++
+		chats[hit.ChatID] = true
+	}
+	return hits, len(chats), nil
+}
+
+func (j *JSONDB) RecordMiss(miss Miss) error {
+	id := MissID(miss.Text)
+	for i := range j.misses {
+		if j.misses[i].ID == id {
+			mergeMiss(&j.misses[i], miss)
+			return j.Save()
+		}
+	}
+	miss.ID = id
+	miss.Count = 1
+	miss.FirstSeen = miss.LastSeen
+	j.misses = append(j.misses, miss)
+	return j.Save()
+}
+
+func (j *JSONDB) ListMisses() ([]Miss, error) {
+	misses := make([]Miss, len(j.misses))
+	copy(misses, j.misses)
+	return misses, nil
+}
+
+func (j *JSONDB) DeleteMisses(ids ...int64) error {
+	remove := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		remove[id] = true
+	}
+	var kept []Miss
+	for _, miss := range j.misses {
+		if !remove[miss.ID] {
+			kept = append(kept, miss)
+		}
+	}
+	j.misses = kept
+	return j.Save()
+}
+
+func (j *JSONDB) AddPendingChange(change PendingChange) error {
+	j.changes = append(j.changes, change)
+	return j.Save()
+}
+
+func (j *JSONDB) ListPendingChanges() ([]PendingChange, error) {
+	changes := make([]PendingChange, len(j.changes))
+	copy(changes, j.changes)
+	return changes, nil
+}
+
+func (j *JSONDB) DeletePendingChange(id int64) error {
+	var kept []PendingChange
+	for _, change := range j.changes {
+		if change.ID != id {
+			kept = append(kept, change)
+		}
+	}
+	j.changes = kept
+	return j.Save()
+}
+
+func (j *JSONDB) SetCustomCommand(command CustomCommand) error {
+	// 与 SQL 后端一致：覆盖的命令按新的创建时间排到最后
+	var kept []CustomCommand
+	for _, existing := range j.commands {
+		if existing.Command != command.Command {
+			kept = append(kept, existing)
+		}
+	}
+	j.commands = append(kept, command)
+	return j.Save()
+}
+
+func (j *JSONDB) ListCustomCommands() ([]CustomCommand, error) {
+	commands := make([]CustomCommand, len(j.commands))
+	copy(commands, j.commands)
+	return commands, nil
+}
+
+func (j *JSONDB) DeleteCustomCommand(command string) error {
+	var kept []CustomCommand
+	for _, existing := range j.commands {
+		if existing.Command != command {
+			kept = append(kept, existing)
+		}
+	}
+	j.commands = kept
+	return j.Save()
+}
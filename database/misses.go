@@ -0,0 +1,219 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Miss 未找到答案的问题，相同问题（规范化后）合并为一条记录
+type Miss struct {
+	ID        int64     `json:"id"` // 由规范化文本计算
+	Text      string    `json:"text"`
+	ChatID    int64     `json:"chat_id"`
+	UserID    int64     `json:"user_id"`
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// MissGroup 相似问题分组
+type MissGroup struct {
+	Misses   []Miss
+	Count    int
+	LastSeen time.Time
+}
+
+// Representative 返回分组中出现次数最多的问题
+func (g MissGroup) Representative() Miss {
+	return g.Misses[0]
+}
+
+// NormalizeMissText 规范化问题文本：忽略大小写、多余空白和结尾标点
+func NormalizeMissText(text string) string {
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	return strings.TrimRightFunc(text, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSpace(r)
+	})
+}
+
+// MissID 计算问题的记录 ID
+func MissID(text string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(NormalizeMissText(text)))
+	return int64(h.Sum32())
+}
+
+// missSimilarity 计算两个规范化文本的相似度（字符二元组的 Jaccard 系数），包含关系视为相似
+func missSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if a == "" || b == "" {
+		return 0
+	}
+	if strings.Contains(a, b) || strings.Contains(b, a) {
+		return 1
+	}
+	setA, setB := runeBigrams(a), runeBigrams(b)
+	intersection := 0
+	for gram := range setA {
+		if setB[gram] {
+			intersection++
+		}
+	}
+	union := len(setA) + len(setB) - intersection
+	if union == 0 {
+		return 0
+	}
+	return float64(intersection) / float64(union)
+}
+
+func runeBigrams(text string) map[string]bool {
+	runes := []rune(strings.ReplaceAll(text, " ", ""))
+	grams := make(map[string]bool)
+	if len(runes) == 1 {
+		grams[string(runes)] = true
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		grams[string(runes[i:i+2])] = true
+	}
+	return grams
+}
+
+// GroupMisses 按相似度将问题分组，出现次数多的问题优先作为分组代表
+func GroupMisses(misses []Miss, threshold float64) []MissGroup {
+	sorted := make([]Miss, len(misses))
+	copy(sorted, misses)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Count != sorted[j].Count {
+			return sorted[i].Count > sorted[j].Count
+		}
+		return sorted[i].LastSeen.After(sorted[j].LastSeen)
+	})
+
+	var groups []MissGroup
+	var representatives []string
+	for _, miss := range sorted {
+		normalized := NormalizeMissText(miss.Text)
+		index := -1
+		for i, rep := range representatives {
+			if missSimilarity(normalized, rep) >= threshold {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			groups = append(groups, MissGroup{})
+			representatives = append(representatives, normalized)
+			index = len(groups) - 1
+		}
+		group := &groups[index]
+		group.Misses = append(group.Misses, miss)
+		group.Count += miss.Count
+		if miss.LastSeen.After(group.LastSeen) {
+			group.LastSeen = miss.LastSeen
+		}
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+	return groups
+}
+
+// mergeMiss 将新的问题合并到已有记录
+func mergeMiss(existing *Miss, miss Miss) {
+	existing.Text = miss.Text
+	existing.ChatID = miss.ChatID
+	existing.UserID = miss.UserID
+	existing.Count++
+	existing.LastSeen = miss.LastSeen
+}
+
+// missStore SQL 后端的未回答问题存储
+type missStore struct {
+	db *sql.DB
+	// postgres 使用 $n 占位符
+	postgres bool
+}
+
+// 时间以 Unix 秒保存，避免不同数据库的时间类型差异
+const createMissesTable = `CREATE TABLE IF NOT EXISTS faq_misses (
+	id BIGINT NOT NULL PRIMARY KEY,
+	question_text TEXT NOT NULL,
+	chat_id BIGINT NOT NULL,
+	user_id BIGINT NOT NULL,
+	miss_count INTEGER NOT NULL,
+	first_seen BIGINT NOT NULL,
+	last_seen BIGINT NOT NULL
+)`
+
+func newMissStore(db *sql.DB, postgres bool) *missStore {
+	return &missStore{db: db, postgres: postgres}
+}
+
+// createTable 创建未回答问题表
+func (s *missStore) createTable() error {
+	if _, err := s.db.Exec(createMissesTable); err != nil {
+		return fmt.Errorf("failed to create faq_misses table: %v", err)
+	}
+	return nil
+}
+
+// Record 记录一次未回答的问题，相同问题累加次数
+func (s *missStore) Record(miss Miss) error {
+	id := MissID(miss.Text)
+	var count int
+	err := s.db.QueryRow(rebindQuery(`SELECT miss_count FROM faq_misses WHERE id = ?`, s.postgres), id).Scan(&count)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = s.db.Exec(rebindQuery(`INSERT INTO faq_misses (id, question_text, chat_id, user_id, miss_count, first_seen, last_seen) VALUES (?, ?, ?, ?, 1, ?, ?)`, s.postgres),
+			id, miss.Text, miss.ChatID, miss.UserID, miss.LastSeen.Unix(), miss.LastSeen.Unix())
+		return err
+	case err != nil:
+		return err
+	}
+	_, err = s.db.Exec(rebindQuery(`UPDATE faq_misses SET question_text = ?, chat_id = ?, user_id = ?, miss_count = ?, last_seen = ? WHERE id = ?`, s.postgres),
+		miss.Text, miss.ChatID, miss.UserID, count+1, miss.LastSeen.Unix(), id)
+	return err
+}
+
+// List 返回所有未回答的问题
+func (s *missStore) List() ([]Miss, error) {
+	rows, err := s.db.Query(`SELECT id, question_text, chat_id, user_id, miss_count, first_seen, last_seen FROM faq_misses ORDER BY last_seen DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var misses []Miss
+	for rows.Next() {
+		var miss Miss
+		var firstSeen, lastSeen int64
+		if err := rows.Scan(&miss.ID, &miss.Text, &miss.ChatID, &miss.UserID, &miss.Count, &firstSeen, &lastSeen); err != nil {
+			return nil, err
+		}
+		miss.FirstSeen = time.Unix(firstSeen, 0)
+		miss.LastSeen = time.Unix(lastSeen, 0)
+		misses = append(misses, miss)
+	}
+	return misses, rows.Err()
+}
+
+// Delete 删除指定的问题记录
+func (s *missStore) Delete(ids ...int64) error {
+	for _, id := range ids {
+		if _, err := s.db.Exec(rebindQuery(`DELETE FROM faq_misses WHERE id = ?`, s.postgres), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeMissText(t *testing.T) {
+	tests := map[string]string{
+		"怎么退款？":                  "怎么退款",
+		"  How   do I REFUND?! ": "how do i refund",
+		"营业时间":                   "营业时间",
+		"？？":                     "",
+	}
+	for text, want := range tests {
+		if got := NormalizeMissText(text); got != want {
+			t.Errorf("NormalizeMissText(%q) = %q, want %q", text, got, want)
+		}
+	}
+	if MissID("怎么退款？") != MissID("怎么退款") {
+		t.Errorf("MissID() differs for texts that normalize to the same question")
+	}
+}
+
+func TestMissSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		min  float64
+		max  float64
+	}{
+		{a: "怎么退款", b: "怎么退款", min: 1, max: 1},
+		{a: "退款", b: "怎么退款", min: 1, max: 1},
+		{a: "怎么申请退款", b: "怎么申请退货", min: 0.5, max: 0.9},
+		{a: "怎么申请退款", b: "如何申请退款", min: 0.3, max: 0.5},
+		{a: "营业时间", b: "怎么退款", min: 0, max: 0},
+		{a: "", b: "退款", min: 0, max: 0},
+	}
+	for _, tt := range tests {
+		if got := missSimilarity(tt.a, tt.b); got < tt.min || got > tt.max {
+			t.Errorf("missSimilarity(%q, %q) = %v, want between %v and %v", tt.a, tt.b, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestGroupMisses(t *testing.T) {
+	now := time.Now()
+	misses := []Miss{
+		{ID: 1, Text: "怎么申请退款", Count: 2, LastSeen: now.Add(-time.Hour)},
+		{ID: 2, Text: "营业时间", Count: 1, LastSeen: now},
+		{ID: 3, Text: "怎么申请退货", Count: 4, LastSeen: now.Add(-2 * time.Hour)},
+		{ID: 4, Text: "发票", Count: 1, LastSeen: now.Add(-3 * time.Hour)},
+	}
+
+	groups := GroupMisses(misses, 0.5)
+	if len(groups) != 3 {
+		t.Fatalf("GroupMisses() returned %d groups, want 3: %+v", len(groups), groups)
+	}
+	refund := groups[0]
+	if refund.Representative().ID != 3 || len(refund.Misses) != 2 || refund.Count != 6 || !refund.LastSeen.Equal(now.Add(-time.Hour)) {
+		t.Errorf("first group = %+v, want both refund questions led by the most frequent", refund)
+	}
+	// 次数相同时最近出现的分组在前
+	if groups[1].Representative().ID != 2 || groups[2].Representative().ID != 4 {
+		t.Errorf("group order = %d, %d, want 2, 4", groups[1].Representative().ID, groups[2].Representative().ID)
+	}
+}
+
+func TestRecordMiss(t *testing.T) {
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			first := time.Now().Add(-time.Hour).Truncate(time.Second)
+			last := first.Add(30 * time.Minute)
+			if err := db.RecordMiss(Miss{Text: "怎么退款？", ChatID: 1, UserID: 10, LastSeen: first}); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.RecordMiss(Miss{Text: "怎么退款", ChatID: 2, UserID: 20, LastSeen: last}); err != nil {
+				t.Fatal(err)
+			}
+			mustRecordMiss(t, db, "营业时间")
+
+			misses, err := db.ListMisses()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(misses) != 2 {
+				t.Fatalf("ListMisses() = %+v, want 2 records", misses)
+			}
+			var refund *Miss
+			for i := range misses {
+				if misses[i].ID == MissID("怎么退款") {
+					refund = &misses[i]
+				}
+			}
+			if refund == nil || refund.Count != 2 || refund.ChatID != 2 || refund.UserID != 20 || !refund.LastSeen.Equal(last) {
+				t.Fatalf("merged miss = %+v, want count 2 with the latest chat, user and time", refund)
+			}
+
+			if err := db.DeleteMisses(refund.ID); err != nil {
+				t.Fatal(err)
+			}
+			if misses, _ := db.ListMisses(); len(misses) != 1 || misses[0].Text != "营业时间" {
+				t.Errorf("ListMisses() after delete = %+v, want only 营业时间", misses)
+			}
+		})
+	}
+}
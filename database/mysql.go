@@ -4,6 +4,7 @@ import (
 	"TGFaqBot/config"
 	"database/sql"
 	"fmt"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 )
@@ -12,6 +13,11 @@ type MySQLDB struct {
 	cfg       config.MySQLConfig
 	db        *sql.DB
 	commonOps *CommonSQLOperations
+	meta      *entryMetaStore
+	hits      *hitStore
+	misses    *missStore
+	changes   *changeStore
+	commands  *commandStore
 }
 
 func NewMySQLDB(cfg config.MySQLConfig) (*MySQLDB, error) {
@@ -49,7 +55,11 @@ func (m *MySQLDB) Query(query string) ([]Entry, error) {
 }
 
 func (m *MySQLDB) QueryByID(id int, matchType MatchType) (*Entry, error) {
-	return m.commonOps.QueryByID(id, matchType, nil)
+	entry, err := m.commonOps.QueryByID(id, matchType, nil)
+	if err != nil {
+		return nil, err
+	}
+	return entry, m.meta.attachOne(entry)
 }
 
 func (m *MySQLDB) AddEntry(key string, matchType MatchType, value string) error {
@@ -80,62 +90,62 @@ func (m *MySQLDB) UpdateEntry(key string, oldType MatchType, newType MatchType,
 		}
 	} else {
 		// Different types, delete from old and add to new
+		var meta EntryMeta
+		if entry, err := findEntryByKey(m, key, oldType); err == nil {
+			meta = entry.EntryMeta
+		}
 		if err := m.DeleteEntry(key, oldType); err != nil {
 			return err
 		}
-		return m.AddEntry(key, newType, value)
+		if err := m.AddEntry(key, newType, value); err != nil {
+			return err
+		}
+		if meta.IsEmpty() {
+			return nil
+		}
+		return m.SetEntryMeta(key, newType, meta)
 	}
 }
 
 func (m *MySQLDB) DeleteEntry(key string, matchType MatchType) error {
+	entry, _ := findEntryByKey(m, key, matchType)
+
+	var err error
 	switch matchType {
 	case MatchExact: // Exact
-		return m.DeleteEntryExact(key)
+		err = m.DeleteEntryExact(key)
 	case MatchContains: // Contains
-		return m.DeleteEntryContains(key)
+		err = m.DeleteEntryContains(key)
 	case MatchRegex: // Regex
-		return m.DeleteEntryRegex(key)
+		err = m.DeleteEntryRegex(key)
 	default:
 		return fmt.Errorf("invalid match type: %s", matchType)
 	}
+	if err != nil || entry == nil {
+		return err
+	}
+	return m.meta.Delete(matchType, entry.ID)
 }
 
 func (m *MySQLDB) ListEntries(table string) ([]Entry, error) {
-	return m.commonOps.ListEntries(table, nil)
+	return m.attachMeta(m.commonOps.ListEntries(table, nil))
 }
 
 func (m *MySQLDB) ListAllEntries() ([]Entry, error) {
-	// 使用单个查询获取所有条目，按匹配类型排序，比分别查询更高效
-	query := `SELECT id, key_text, value_text, match_type FROM faq_entries ORDER BY match_type, id`
-
-	rows, err := m.db.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var allEntries []Entry
-	for rows.Next() {
-		var entry Entry
-		if err := rows.Scan(&entry.ID, &entry.Key, &entry.Value, &entry.MatchType); err != nil {
-			return nil, err
-		}
-		allEntries = append(allEntries, entry)
-	}
-
-	return allEntries, rows.Err()
+	// MySQL 按匹配类型分表存储，依次读取各表
+	return m.ListSpecificEntries(MatchExact, MatchContains, MatchRegex)
 }
 
 func (m *MySQLDB) QueryExact(query string) ([]Entry, error) {
-	return m.commonOps.QueryWithCondition(query, "exact", nil)
+	return filterActive(m.attachMeta(m.commonOps.QueryWithCondition(query, "exact", nil)))
 }
 
 func (m *MySQLDB) QueryContains(query string) ([]Entry, error) {
-	return m.commonOps.QueryWithCondition(query, "contains", nil)
+	return filterActive(m.attachMeta(m.commonOps.QueryWithCondition(query, "contains", nil)))
 }
 
 func (m *MySQLDB) QueryRegex(query string) ([]Entry, error) {
-	return m.commonOps.QueryWithCondition(query, "regex", nil)
+	return filterActive(m.attachMeta(m.commonOps.QueryWithCondition(query, "regex", nil)))
 }
 
 func (m *MySQLDB) AddEntryExact(key string, value string) error {
@@ -175,15 +185,15 @@ func (m *MySQLDB) DeleteEntryRegex(key string) error {
 }
 
 func (m *MySQLDB) ListEntriesExact() ([]Entry, error) {
-	return m.commonOps.ListEntries("exact", nil)
+	return m.attachMeta(m.commonOps.ListEntries("exact", nil))
 }
 
 func (m *MySQLDB) ListEntriesContains() ([]Entry, error) {
-	return m.commonOps.ListEntries("contains", nil)
+	return m.attachMeta(m.commonOps.ListEntries("contains", nil))
 }
 
 func (m *MySQLDB) ListEntriesRegex() ([]Entry, error) {
-	return m.commonOps.ListEntries("regex", nil)
+	return m.attachMeta(m.commonOps.ListEntries("regex", nil))
 }
 
 func (m *MySQLDB) ListSpecificEntries(matchTypes ...MatchType) ([]Entry, error) {
@@ -223,7 +233,17 @@ func (m *MySQLDB) ListSpecificEntries(matchTypes ...MatchType) ([]Entry, error)
 	return allEntries, nil
 }
 
+// ListEntriesByAuthor 按作者筛选条目，recent 为 true 时按最后修改时间从新到旧排列
+func (m *MySQLDB) ListEntriesByAuthor(authors []int64, recent bool, matchTypes ...MatchType) ([]Entry, error) {
+	return m.meta.listByAuthor(authors, recent, matchTypes, m.ListSpecificEntries, func(typeKey int, ids []int) ([]Entry, error) {
+		return m.attachMeta(m.commonOps.ListEntriesByIDs(intToMatchType(typeKey).GetTableName(), nil, ids))
+	})
+}
+
 func (m *MySQLDB) DeleteAllEntries() error {
+	if err := m.meta.Clear(); err != nil {
+		return err
+	}
 	_, err := m.db.Exec("DELETE FROM exact")
 	if err != nil {
 		return err
@@ -275,6 +295,31 @@ func (m *MySQLDB) Reload() error {
 		return err
 	}
 
+	m.meta = newEntryMetaStore(m.db, false, false)
+	if err := m.meta.createTable(); err != nil {
+		return err
+	}
+
+	m.hits = newHitStore(m.db, false)
+	if err := m.hits.createTable(); err != nil {
+		return err
+	}
+
+	m.misses = newMissStore(m.db, false)
+	if err := m.misses.createTable(); err != nil {
+		return err
+	}
+
+	m.changes = newChangeStore(m.db, false)
+	if err := m.changes.createTable(); err != nil {
+		return err
+	}
+
+	m.commands = newCommandStore(m.db, false)
+	if err := m.commands.createTable(); err != nil {
+		return err
+	}
+
 	// 重新初始化common operations
 	m.commonOps = NewCommonSQLOperations(m.db)
 	return nil
@@ -433,3 +478,81 @@ func (m *MySQLDB) ClearModelCache() error {
 	_, err := m.db.Exec("DELETE FROM ai_models WHERE provider = '__cache__'")
 	return err
 }
+
+// SetEntryMeta 设置条目扩展属性
+func (m *MySQLDB) SetEntryMeta(key string, matchType MatchType, meta EntryMeta) error {
+	entry, err := findEntryByKey(m, key, matchType)
+	if err != nil {
+		return err
+	}
+	return m.meta.Set(matchType, entry.ID, meta)
+}
+
+// UpdateEntryMeta 在事务中修改条目扩展属性的部分字段
+func (m *MySQLDB) UpdateEntryMeta(key string, matchType MatchType, update func(meta *EntryMeta)) error {
+	entry, err := findEntryByKey(m, key, matchType)
+	if err != nil {
+		return err
+	}
+	return m.meta.Update(matchType, entry.ID, update)
+}
+
+// attachMeta 为查询结果填充扩展属性
+func (m *MySQLDB) attachMeta(entries []Entry, err error) ([]Entry, error) {
+	if err != nil {
+		return nil, err
+	}
+	return entries, m.meta.Attach(entries)
+}
+
+func (m *MySQLDB) RecordHit(hit Hit) error {
+	return m.hits.Record(hit)
+}
+
+func (m *MySQLDB) ListHits(since time.Time) ([]Hit, error) {
+	return m.hits.List(since)
+}
+
+func (m *MySQLDB) HitCounts() ([]EntryHitCount, error) {
+	return m.hits.Counts()
+}
+
+func (m *MySQLDB) HitTotals(since, until time.Time) (int, int, error) {
+	return m.hits.Totals(since, until)
+}
+
+func (m *MySQLDB) RecordMiss(miss Miss) error {
+	return m.misses.Record(miss)
+}
+
+func (m *MySQLDB) ListMisses() ([]Miss, error) {
+	return m.misses.List()
+}
+
+func (m *MySQLDB) DeleteMisses(ids ...int64) error {
+	return m.misses.Delete(ids...)
+}
+
+func (m *MySQLDB) AddPendingChange(change PendingChange) error {
+	return m.changes.Add(change)
+}
+
+func (m *MySQLDB) ListPendingChanges() ([]PendingChange, error) {
+	return m.changes.List()
+}
+
+func (m *MySQLDB) DeletePendingChange(id int64) error {
+	return m.changes.Delete(id)
+}
+
+func (m *MySQLDB) SetCustomCommand(command CustomCommand) error {
+	return m.commands.Set(command)
+}
+
+func (m *MySQLDB) ListCustomCommands() ([]CustomCommand, error) {
+	return m.commands.List()
+}
+
+func (m *MySQLDB) DeleteCustomCommand(command string) error {
+	return m.commands.Delete(command)
+}
@@ -12,7 +12,12 @@ import (
 )
 
 type PostgreSQLDB struct {
-	db *sql.DB
+	db       *sql.DB
+	meta     *entryMetaStore
+	hits     *hitStore
+	misses   *missStore
+	changes  *changeStore
+	commands *commandStore
 }
 
 func NewPostgreSQLDB(cfg config.PostgreSQLConfig) (*PostgreSQLDB, error) {
@@ -29,7 +34,7 @@ func NewPostgreSQLDB(cfg config.PostgreSQLConfig) (*PostgreSQLDB, error) {
 		return nil, err
 	}
 
-	pgdb := &PostgreSQLDB{db: db}
+	pgdb := &PostgreSQLDB{db: db, meta: newEntryMetaStore(db, true, true), hits: newHitStore(db, true), misses: newMissStore(db, true), changes: newChangeStore(db, true), commands: newCommandStore(db, true)}
 	if err := pgdb.createTables(); err != nil {
 		return nil, err
 	}
@@ -76,7 +81,23 @@ func (p *PostgreSQLDB) createTables() error {
 		return fmt.Errorf("failed to create model table: %v", err)
 	}
 
-	return nil
+	if err := p.meta.createTable(); err != nil {
+		return err
+	}
+
+	if err := p.hits.createTable(); err != nil {
+		return err
+	}
+
+	if err := p.misses.createTable(); err != nil {
+		return err
+	}
+
+	if err := p.changes.createTable(); err != nil {
+		return err
+	}
+
+	return p.commands.createTable()
 }
 
 // FAQ查询方法
@@ -120,17 +141,17 @@ func (p *PostgreSQLDB) QueryByID(id int, matchType MatchType) (*Entry, error) {
 		return nil, err
 	}
 
-	return &entry, nil
+	return &entry, p.meta.attachOne(&entry)
 }
 
 func (p *PostgreSQLDB) QueryExact(query string) ([]Entry, error) {
 	sqlQuery := `SELECT id, key_text, value_text, match_type FROM faq_entries WHERE match_type = 1 AND key_text = $1`
-	return p.queryWithSQL(sqlQuery, query)
+	return filterActive(p.queryWithSQL(sqlQuery, query))
 }
 
 func (p *PostgreSQLDB) QueryContains(query string) ([]Entry, error) {
 	sqlQuery := `SELECT id, key_text, value_text, match_type FROM faq_entries WHERE match_type = 2 AND key_text ILIKE $1`
-	return p.queryWithSQL(sqlQuery, "%"+query+"%")
+	return filterActive(p.queryWithSQL(sqlQuery, "%"+query+"%"))
 }
 
 func (p *PostgreSQLDB) QueryRegex(query string) ([]Entry, error) {
@@ -157,8 +178,11 @@ func (p *PostgreSQLDB) QueryRegex(query string) ([]Entry, error) {
 			entries = append(entries, entry)
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return entries, rows.Err()
+	return filterActive(entries, p.meta.Attach(entries))
 }
 
 func (p *PostgreSQLDB) queryWithSQL(sqlQuery string, args ...interface{}) ([]Entry, error) {
@@ -176,8 +200,11 @@ func (p *PostgreSQLDB) queryWithSQL(sqlQuery string, args ...interface{}) ([]Ent
 		}
 		entries = append(entries, entry)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return entries, rows.Err()
+	return entries, p.meta.Attach(entries)
 }
 
 // FAQ管理方法
@@ -194,12 +221,20 @@ func (p *PostgreSQLDB) UpdateEntry(key string, oldType MatchType, newType MatchT
 }
 
 func (p *PostgreSQLDB) DeleteEntry(key string, matchType MatchType) error {
+	entry, _ := findEntryByKey(p, key, matchType)
+
 	query := `DELETE FROM faq_entries WHERE key_text = $1 AND match_type = $2`
 	_, err := p.db.Exec(query, key, matchType)
-	return err
+	if err != nil || entry == nil {
+		return err
+	}
+	return p.meta.Delete(matchType, entry.ID)
 }
 
 func (p *PostgreSQLDB) DeleteAllEntries() error {
+	if err := p.meta.Clear(); err != nil {
+		return err
+	}
 	query := `DELETE FROM faq_entries`
 	_, err := p.db.Exec(query)
 	return err
@@ -228,6 +263,25 @@ func (p *PostgreSQLDB) ListSpecificEntries(matchTypes ...MatchType) ([]Entry, er
 	return p.queryWithSQL(query, args...)
 }
 
+// ListEntriesByAuthor 按作者筛选条目，recent 为 true 时按最后修改时间从新到旧排列
+// 条目 ID 在所有匹配类型中唯一，按 ID 读取时再按匹配类型筛选
+func (p *PostgreSQLDB) ListEntriesByAuthor(authors []int64, recent bool, matchTypes ...MatchType) ([]Entry, error) {
+	return p.meta.listByAuthor(authors, recent, matchTypes, p.ListSpecificEntries, func(_ int, ids []int) ([]Entry, error) {
+		var args []interface{}
+		for _, id := range ids {
+			args = append(args, id)
+		}
+		query := "SELECT id, key_text, value_text, match_type FROM faq_entries WHERE id IN (" + placeholders(len(ids)) + ")"
+		if len(matchTypes) > 0 {
+			for _, mt := range matchTypes {
+				args = append(args, mt.ToInt())
+			}
+			query += " AND match_type IN (" + placeholders(len(matchTypes)) + ")"
+		}
+		return p.queryWithSQL(rebindQuery(query, true), args...)
+	})
+}
+
 func (p *PostgreSQLDB) ListAllEntries() ([]Entry, error) {
 	query := `SELECT id, key_text, value_text, match_type FROM faq_entries ORDER BY id`
 	return p.queryWithSQL(query)
@@ -443,3 +497,73 @@ func (p *PostgreSQLDB) ClearModelCache() error {
 	_, err := p.db.Exec("DELETE FROM ai_models WHERE provider = '__cache__'")
 	return err
 }
+
+// SetEntryMeta 设置条目扩展属性
+func (p *PostgreSQLDB) SetEntryMeta(key string, matchType MatchType, meta EntryMeta) error {
+	entry, err := findEntryByKey(p, key, matchType)
+	if err != nil {
+		return err
+	}
+	return p.meta.Set(matchType, entry.ID, meta)
+}
+
+// UpdateEntryMeta 在事务中修改条目扩展属性的部分字段
+func (p *PostgreSQLDB) UpdateEntryMeta(key string, matchType MatchType, update func(meta *EntryMeta)) error {
+	entry, err := findEntryByKey(p, key, matchType)
+	if err != nil {
+		return err
+	}
+	return p.meta.Update(matchType, entry.ID, update)
+}
+
+func (p *PostgreSQLDB) RecordHit(hit Hit) error {
+	return p.hits.Record(hit)
+}
+
+func (p *PostgreSQLDB) ListHits(since time.Time) ([]Hit, error) {
+	return p.hits.List(since)
+}
+
+func (p *PostgreSQLDB) HitCounts() ([]EntryHitCount, error) {
+	return p.hits.Counts()
+}
+
+func (p *PostgreSQLDB) HitTotals(since, until time.Time) (int, int, error) {
+	return p.hits.Totals(since, until)
+}
+
+func (p *PostgreSQLDB) RecordMiss(miss Miss) error {
+	return p.misses.Record(miss)
+}
+
+func (p *PostgreSQLDB) ListMisses() ([]Miss, error) {
+	return p.misses.List()
+}
+
+func (p *PostgreSQLDB) DeleteMisses(ids ...int64) error {
+	return p.misses.Delete(ids...)
+}
+
+func (p *PostgreSQLDB) AddPendingChange(change PendingChange) error {
+	return p.changes.Add(change)
+}
+
+func (p *PostgreSQLDB) ListPendingChanges() ([]PendingChange, error) {
+	return p.changes.List()
+}
+
+func (p *PostgreSQLDB) DeletePendingChange(id int64) error {
+	return p.changes.Delete(id)
+}
+
+func (p *PostgreSQLDB) SetCustomCommand(command CustomCommand) error {
+	return p.commands.Set(command)
+}
+
+func (p *PostgreSQLDB) ListCustomCommands() ([]CustomCommand, error) {
+	return p.commands.List()
+}
+
+func (p *PostgreSQLDB) DeleteCustomCommand(command string) error {
+	return p.commands.Delete(command)
+}
@@ -0,0 +1,211 @@
+package database
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// DefaultMatchOrder 默认的匹配类型优先顺序
+var DefaultMatchOrder = []MatchType{MatchExact, MatchPrefix, MatchSuffix, MatchContains, MatchRegex}
+
+// MatchPolicy 多个条目同时命中时的处理策略
+type MatchPolicy struct {
+	// Order 匹配类型的优先顺序，未列出的类型排在最后
+	Order []MatchType
+	// BestOnly 为 true 时只返回优先级最高的条目
+	BestOnly bool
+}
+
+// rank 返回匹配类型在优先顺序中的位置
+func (p MatchPolicy) rank(matchType MatchType) int {
+	order := p.Order
+	if len(order) == 0 {
+		order = DefaultMatchOrder
+	}
+	for i, mt := range order {
+		if mt == matchType {
+			return i
+		}
+	}
+	return len(order)
+}
+
+// Less 判断条目 a 是否优先于 b：先比较条目优先级，再比较匹配类型顺序，
+// 同类型时 key 更长（更具体）的优先，最后按 ID 保证结果稳定
+func (p MatchPolicy) Less(a, b *Entry) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if ra, rb := p.rank(a.MatchType), p.rank(b.MatchType); ra != rb {
+		return ra < rb
+	}
+	if la, lb := len([]rune(a.Key)), len([]rune(b.Key)); la != lb {
+		return la > lb
+	}
+	return a.ID < b.ID
+}
+
+// Resolve 按策略排序命中的条目，BestOnly 时只保留第一个
+func (p MatchPolicy) Resolve(entries []Entry) []Entry {
+	sorted := make([]Entry, len(entries))
+	copy(sorted, entries)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return p.Less(&sorted[i], &sorted[j])
+	})
+	if p.BestOnly && len(sorted) > 1 {
+		sorted = sorted[:1]
+	}
+	return sorted
+}
+
+// EntryMatches 检查条目是否会被指定文本命中
+func EntryMatches(entry *Entry, text string) bool {
+	switch entry.MatchType {
+	case MatchExact:
+		return text == entry.Key
+	case MatchContains:
+		return strings.Contains(text, entry.Key)
+	case MatchPrefix:
+		return strings.HasPrefix(text, entry.Key)
+	case MatchSuffix:
+		return strings.HasSuffix(text, entry.Key)
+	case MatchRegex:
+		matched, err := regexp.MatchString(entry.Key, text)
+		return err == nil && matched
+	default:
+		return false
+	}
+}
+
+// Overlap 新条目与已有条目的重叠情况
+type Overlap struct {
+	Entry Entry
+	// Sample 同时命中两个条目的输入
+	Sample string
+	// Shadowed 为 true 表示新条目在该输入下会被已有条目盖过
+	Shadowed bool
+}
+
+// FindOverlaps 查找与新条目存在同时命中的已有条目。
+// 由两个条目的 key（正则为能命中它的样例）及其拼接构造候选输入，
+// 找到同时命中两者的输入即视为重叠，因此只能发现存在重叠，不保证找出所有重叠
+func FindOverlaps(db Database, entry Entry, policy MatchPolicy) ([]Overlap, error) {
+	entries, err := db.ListAllEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	var overlaps []Overlap
+	for _, existing := range entries {
+		if existing.Key == entry.Key && existing.MatchType == entry.MatchType {
+			continue
+		}
+		sample, ok := overlapSample(&entry, &existing)
+		if !ok {
+			continue
+		}
+		overlaps = append(overlaps, Overlap{
+			Entry:    existing,
+			Sample:   sample,
+			Shadowed: policy.Less(&existing, &entry),
+		})
+	}
+	return overlaps, nil
+}
+
+// overlapSample 返回同时命中两个条目的输入
+func overlapSample(a, b *Entry) (string, bool) {
+	samplesA, samplesB := keySamples(a), keySamples(b)
+	var candidates []string
+	candidates = append(candidates, samplesA...)
+	candidates = append(candidates, samplesB...)
+	// contains、prefix、suffix 和未锚定的正则可以由两段拼接同时命中，加空格的拼接用于带 \b 的正则
+	for _, x := range samplesA {
+		for _, y := range samplesB {
+			candidates = append(candidates, x+y, y+x, x+" "+y, y+" "+x)
+		}
+	}
+	for _, candidate := range candidates {
+		if EntryMatches(a, candidate) && EntryMatches(b, candidate) {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// maxRegexSamples 每个正则最多生成的样例数量
+const maxRegexSamples = 8
+
+// keySamples 返回会命中条目的输入，非正则条目就是 key 本身
+func keySamples(entry *Entry) []string {
+	if entry.MatchType != MatchRegex {
+		return []string{entry.Key}
+	}
+	re, err := syntax.Parse(entry.Key, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	return regexSamples(re.Simplify())
+}
+
+// regexSamples 生成能被正则命中的简短输入，分支各取一个样例，重复取最少次数
+func regexSamples(re *syntax.Regexp) []string {
+	switch re.Op {
+	case syntax.OpLiteral:
+		if re.Flags&syntax.FoldCase != 0 {
+			return []string{strings.ToLower(string(re.Rune))}
+		}
+		return []string{string(re.Rune)}
+	case syntax.OpCharClass:
+		if len(re.Rune) == 0 {
+			return nil
+		}
+		return []string{string(re.Rune[0])}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return []string{"a"}
+	case syntax.OpCapture:
+		return regexSamples(re.Sub[0])
+	case syntax.OpPlus:
+		return regexSamples(re.Sub[0])
+	case syntax.OpRepeat:
+		if re.Min == 0 {
+			return []string{""}
+		}
+		var repeated []string
+		for _, sample := range regexSamples(re.Sub[0]) {
+			repeated = append(repeated, strings.Repeat(sample, re.Min))
+		}
+		return repeated
+	case syntax.OpConcat:
+		samples := []string{""}
+		for _, sub := range re.Sub {
+			var next []string
+			for _, prefix := range samples {
+				for _, sample := range regexSamples(sub) {
+					if len(next) < maxRegexSamples {
+						next = append(next, prefix+sample)
+					}
+				}
+			}
+			samples = next
+		}
+		return samples
+	case syntax.OpAlternate:
+		var samples []string
+		for _, sub := range re.Sub {
+			for _, sample := range regexSamples(sub) {
+				if len(samples) < maxRegexSamples {
+					samples = append(samples, sample)
+				}
+			}
+		}
+		return samples
+	case syntax.OpNoMatch:
+		return nil
+	default:
+		// 空匹配、* 和 ?、行首行尾等锚点不需要输入
+		return []string{""}
+	}
+}
@@ -0,0 +1,223 @@
+package database
+
+import "testing"
+
+func TestMatchPolicyLess(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy MatchPolicy
+		a, b   Entry
+		want   bool
+	}{
+		{
+			name: "higher priority wins over match type",
+			a:    Entry{ID: 2, Key: "k", MatchType: MatchRegex, EntryMeta: EntryMeta{Priority: 1}},
+			b:    Entry{ID: 1, Key: "k", MatchType: MatchExact},
+			want: true,
+		},
+		{
+			name: "lower priority loses",
+			a:    Entry{ID: 1, Key: "k", MatchType: MatchExact, EntryMeta: EntryMeta{Priority: -1}},
+			b:    Entry{ID: 2, Key: "k", MatchType: MatchRegex},
+			want: false,
+		},
+		{
+			name: "default order prefers exact over contains",
+			a:    Entry{ID: 2, Key: "k", MatchType: MatchExact},
+			b:    Entry{ID: 1, Key: "k", MatchType: MatchContains},
+			want: true,
+		},
+		{
+			name: "default order puts regex last",
+			a:    Entry{ID: 1, Key: "k", MatchType: MatchRegex},
+			b:    Entry{ID: 2, Key: "k", MatchType: MatchSuffix},
+			want: false,
+		},
+		{
+			name:   "custom order",
+			policy: MatchPolicy{Order: []MatchType{MatchRegex, MatchExact}},
+			a:      Entry{ID: 2, Key: "k", MatchType: MatchRegex},
+			b:      Entry{ID: 1, Key: "k", MatchType: MatchExact},
+			want:   true,
+		},
+		{
+			name:   "types missing from custom order come last",
+			policy: MatchPolicy{Order: []MatchType{MatchRegex}},
+			a:      Entry{ID: 1, Key: "k", MatchType: MatchExact},
+			b:      Entry{ID: 2, Key: "k", MatchType: MatchRegex},
+			want:   false,
+		},
+		{
+			name: "longer key wins within the same type",
+			a:    Entry{ID: 2, Key: "退款流程", MatchType: MatchContains},
+			b:    Entry{ID: 1, Key: "refund", MatchType: MatchContains},
+			want: false,
+		},
+		{
+			name: "key length counts characters not bytes",
+			a:    Entry{ID: 2, Key: "退款流程说明", MatchType: MatchContains},
+			b:    Entry{ID: 1, Key: "refund", MatchType: MatchContains},
+			want: false,
+		},
+		{
+			name: "lower id breaks ties",
+			a:    Entry{ID: 1, Key: "ab", MatchType: MatchPrefix},
+			b:    Entry{ID: 2, Key: "cd", MatchType: MatchPrefix},
+			want: true,
+		},
+		{
+			name: "equal entries are not less",
+			a:    Entry{ID: 1, Key: "ab", MatchType: MatchPrefix},
+			b:    Entry{ID: 1, Key: "ab", MatchType: MatchPrefix},
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Less(&tt.a, &tt.b); got != tt.want {
+				t.Errorf("Less() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchPolicyResolve(t *testing.T) {
+	entries := []Entry{
+		{ID: 1, Key: "退款", MatchType: MatchContains},
+		{ID: 2, Key: "退款", MatchType: MatchExact},
+		{ID: 3, Key: "退", MatchType: MatchRegex, EntryMeta: EntryMeta{Priority: 5}},
+	}
+
+	tests := []struct {
+		name   string
+		policy MatchPolicy
+		want   []int
+	}{
+		{name: "all entries in priority order", policy: MatchPolicy{}, want: []int{3, 2, 1}},
+		{name: "best only", policy: MatchPolicy{BestOnly: true}, want: []int{3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.policy.Resolve(entries)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Resolve() returned %d entries, want %d", len(got), len(tt.want))
+			}
+			for i, id := range tt.want {
+				if got[i].ID != id {
+					t.Errorf("Resolve()[%d].ID = %d, want %d", i, got[i].ID, id)
+				}
+			}
+		})
+	}
+	if entries[0].ID != 1 {
+		t.Errorf("Resolve() modified its input")
+	}
+}
+
+func TestOverlapSample(t *testing.T) {
+	tests := []struct {
+		name       string
+		existing   Entry
+		entry      Entry
+		wantSample string // 为空表示不重叠
+	}{
+		{
+			name:       "contains inside a longer contains",
+			existing:   Entry{Key: "vpn", MatchType: MatchContains},
+			entry:      Entry{Key: "vpn setup", MatchType: MatchContains},
+			wantSample: "vpn setup",
+		},
+		{
+			name:       "unrelated contains keys meet in one message",
+			existing:   Entry{Key: "vpn", MatchType: MatchContains},
+			entry:      Entry{Key: "退款", MatchType: MatchContains},
+			wantSample: "退款vpn",
+		},
+		{
+			name:       "prefix and suffix",
+			existing:   Entry{Key: "怎么", MatchType: MatchPrefix},
+			entry:      Entry{Key: "退款", MatchType: MatchSuffix},
+			wantSample: "怎么退款",
+		},
+		{
+			name:     "different prefixes never overlap",
+			existing: Entry{Key: "退款", MatchType: MatchPrefix},
+			entry:    Entry{Key: "发票", MatchType: MatchPrefix},
+		},
+		{
+			name:     "exact does not contain the other key",
+			existing: Entry{Key: "退款", MatchType: MatchExact},
+			entry:    Entry{Key: "发票", MatchType: MatchContains},
+		},
+		{
+			name:       "exact key matched by a regex",
+			existing:   Entry{Key: "^退.+$", MatchType: MatchRegex},
+			entry:      Entry{Key: "退款", MatchType: MatchExact},
+			wantSample: "退款",
+		},
+		{
+			name:       "anchored regex and contains",
+			existing:   Entry{Key: "^vpn", MatchType: MatchRegex},
+			entry:      Entry{Key: "setup", MatchType: MatchContains},
+			wantSample: "vpnsetup",
+		},
+		{
+			name:       "word boundary regex and contains",
+			existing:   Entry{Key: `\bvpn\b`, MatchType: MatchRegex},
+			entry:      Entry{Key: "setup", MatchType: MatchContains},
+			wantSample: "setup vpn",
+		},
+		{
+			name:       "overlapping regexes",
+			existing:   Entry{Key: `(?i)vpn\s*(setup|config)`, MatchType: MatchRegex},
+			entry:      Entry{Key: `config$`, MatchType: MatchRegex},
+			wantSample: "vpnconfig",
+		},
+		{
+			name:     "regexes anchored to different starts",
+			existing: Entry{Key: "^a+$", MatchType: MatchRegex},
+			entry:    Entry{Key: "^b", MatchType: MatchRegex},
+		},
+		{
+			name:     "invalid regex is ignored",
+			existing: Entry{Key: "([", MatchType: MatchRegex},
+			entry:    Entry{Key: "退款", MatchType: MatchContains},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sample, ok := overlapSample(&tt.entry, &tt.existing)
+			if ok != (tt.wantSample != "") || sample != tt.wantSample {
+				t.Errorf("overlapSample() = %q, %v, want %q", sample, ok, tt.wantSample)
+			}
+		})
+	}
+}
+
+func TestFindOverlaps(t *testing.T) {
+	for backend, open := range testBackends(t) {
+		t.Run(backend, func(t *testing.T) {
+			db := open(t)
+			mustAdd(t, db, "退款", MatchExact)
+			mustAdd(t, db, "退", MatchContains)
+			mustAdd(t, db, "^发票$", MatchRegex)
+
+			// 新条目尚未保存，ID 排在已有条目之后
+			overlaps, err := FindOverlaps(db, Entry{ID: 99, Key: "退款", MatchType: MatchContains}, MatchPolicy{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			shadowed := make(map[MatchType]bool)
+			for _, overlap := range overlaps {
+				shadowed[overlap.Entry.MatchType] = overlap.Shadowed
+			}
+			// exact 排在 contains 之前，同类型时较长的 key 优先
+			if len(overlaps) != 2 || !shadowed[MatchExact] || shadowed[MatchContains] {
+				t.Errorf("FindOverlaps() = %+v, want exact to win and contains to lose", overlaps)
+			}
+		})
+	}
+}
@@ -371,8 +371,28 @@ func (ops *CommonSQLOperations) ListEntries(tableName string, columns []string)
 	if err != nil {
 		return nil, err
 	}
+	return ops.listWithQuery(query, tableName, columns)
+}
+
+// ListEntriesByIDs 列出表中指定 ID 的记录
+func (ops *CommonSQLOperations) ListEntriesByIDs(tableName string, columns []string, ids []int) ([]Entry, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	query, err := ops.builder.BuildSelectAll(tableName, columns)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return ops.listWithQuery(query+" WHERE id IN ("+placeholders(len(ids))+")", tableName, columns, args...)
+}
 
-	rows, err := ops.db.Query(query)
+// listWithQuery 执行查询并按列数解析记录
+func (ops *CommonSQLOperations) listWithQuery(query, tableName string, columns []string, args ...interface{}) ([]Entry, error) {
+	rows, err := ops.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -3,6 +3,7 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"time"
 
 	"TGFaqBot/config"
 
@@ -13,6 +14,11 @@ type SQLiteDB struct {
 	filename  string
 	db        *sql.DB
 	commonOps *CommonSQLOperations
+	meta      *entryMetaStore
+	hits      *hitStore
+	misses    *missStore
+	changes   *changeStore
+	commands  *commandStore
 }
 
 func NewSQLiteDB(filename string) (*SQLiteDB, error) {
@@ -51,7 +57,11 @@ func (s *SQLiteDB) Query(query string) ([]Entry, error) {
 
 func (s *SQLiteDB) QueryByID(id int, matchType MatchType) (*Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.QueryByID(id, matchType, columns)
+	entry, err := s.commonOps.QueryByID(id, matchType, columns)
+	if err != nil {
+		return nil, err
+	}
+	return entry, s.meta.attachOne(entry)
 }
 
 func (s *SQLiteDB) AddEntry(key string, matchType MatchType, value string) error {
@@ -82,24 +92,41 @@ func (s *SQLiteDB) UpdateEntry(key string, oldType MatchType, newType MatchType,
 		}
 	} else {
 		// Different types, delete from old and add to new
+		var meta EntryMeta
+		if entry, err := findEntryByKey(s, key, oldType); err == nil {
+			meta = entry.EntryMeta
+		}
 		if err := s.DeleteEntry(key, oldType); err != nil {
 			return err
 		}
-		return s.AddEntry(key, newType, value)
+		if err := s.AddEntry(key, newType, value); err != nil {
+			return err
+		}
+		if meta.IsEmpty() {
+			return nil
+		}
+		return s.SetEntryMeta(key, newType, meta)
 	}
 }
 
 func (s *SQLiteDB) DeleteEntry(key string, matchType MatchType) error {
+	entry, _ := findEntryByKey(s, key, matchType)
+
+	var err error
 	switch matchType {
 	case MatchExact:
-		return s.DeleteEntryExact(key)
+		err = s.DeleteEntryExact(key)
 	case MatchContains:
-		return s.DeleteEntryContains(key)
+		err = s.DeleteEntryContains(key)
 	case MatchRegex:
-		return s.DeleteEntryRegex(key)
+		err = s.DeleteEntryRegex(key)
 	default:
 		return fmt.Errorf("invalid match type: %s", matchType)
 	}
+	if err != nil || entry == nil {
+		return err
+	}
+	return s.meta.Delete(matchType, entry.ID)
 }
 
 func (s *SQLiteDB) ListEntries(table string) ([]Entry, error) {
@@ -134,28 +161,33 @@ func (s *SQLiteDB) ListAllEntries() ([]Entry, error) {
 	var allEntries []Entry
 	for rows.Next() {
 		var entry Entry
-		if err := rows.Scan(&entry.ID, &entry.Key, &entry.Value, &entry.ContentType, &entry.TelegraphURL, &entry.TelegraphPath, &entry.MatchType); err != nil {
+		var matchType int
+		if err := rows.Scan(&entry.ID, &entry.Key, &entry.Value, &entry.ContentType, &entry.TelegraphURL, &entry.TelegraphPath, &matchType); err != nil {
 			return nil, err
 		}
+		entry.MatchType = intToMatchType(matchType)
 		allEntries = append(allEntries, entry)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
-	return allEntries, rows.Err()
+	return allEntries, s.meta.Attach(allEntries)
 }
 
 func (s *SQLiteDB) QueryExact(query string) ([]Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.QueryWithCondition(query, "exact", columns)
+	return filterActive(s.attachMeta(s.commonOps.QueryWithCondition(query, "exact", columns)))
 }
 
 func (s *SQLiteDB) QueryContains(query string) ([]Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.QueryWithCondition(query, "contains", columns)
+	return filterActive(s.attachMeta(s.commonOps.QueryWithCondition(query, "contains", columns)))
 }
 
 func (s *SQLiteDB) QueryRegex(query string) ([]Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.QueryWithCondition(query, "regex", columns)
+	return filterActive(s.attachMeta(s.commonOps.QueryWithCondition(query, "regex", columns)))
 }
 
 func (s *SQLiteDB) AddEntryExact(key string, value string) error {
@@ -196,17 +228,17 @@ func (s *SQLiteDB) DeleteEntryRegex(key string) error {
 
 func (s *SQLiteDB) ListEntriesExact() ([]Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.ListEntries("exact", columns)
+	return s.attachMeta(s.commonOps.ListEntries("exact", columns))
 }
 
 func (s *SQLiteDB) ListEntriesContains() ([]Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.ListEntries("contains", columns)
+	return s.attachMeta(s.commonOps.ListEntries("contains", columns))
 }
 
 func (s *SQLiteDB) ListEntriesRegex() ([]Entry, error) {
 	columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
-	return s.commonOps.ListEntries("regex", columns)
+	return s.attachMeta(s.commonOps.ListEntries("regex", columns))
 }
 
 func (s *SQLiteDB) ListSpecificEntries(matchTypes ...MatchType) ([]Entry, error) {
@@ -246,7 +278,18 @@ func (s *SQLiteDB) ListSpecificEntries(matchTypes ...MatchType) ([]Entry, error)
 	return allEntries, nil
 }
 
+// ListEntriesByAuthor 按作者筛选条目，recent 为 true 时按最后修改时间从新到旧排列
+func (s *SQLiteDB) ListEntriesByAuthor(authors []int64, recent bool, matchTypes ...MatchType) ([]Entry, error) {
+	return s.meta.listByAuthor(authors, recent, matchTypes, s.ListSpecificEntries, func(typeKey int, ids []int) ([]Entry, error) {
+		columns := []string{"id", "key", "value", "content_type", "telegraph_url", "telegraph_path"}
+		return s.attachMeta(s.commonOps.ListEntriesByIDs(intToMatchType(typeKey).GetTableName(), columns, ids))
+	})
+}
+
 func (s *SQLiteDB) DeleteAllEntries() error {
+	if err := s.meta.Clear(); err != nil {
+		return err
+	}
 	_, err := s.db.Exec("DELETE FROM exact")
 	if err != nil {
 		return err
@@ -332,6 +375,31 @@ func (s *SQLiteDB) Reload() error {
 		return err
 	}
 
+	s.meta = newEntryMetaStore(s.db, false, false)
+	if err := s.meta.createTable(); err != nil {
+		return err
+	}
+
+	s.hits = newHitStore(s.db, false)
+	if err := s.hits.createTable(); err != nil {
+		return err
+	}
+
+	s.misses = newMissStore(s.db, false)
+	if err := s.misses.createTable(); err != nil {
+		return err
+	}
+
+	s.changes = newChangeStore(s.db, false)
+	if err := s.changes.createTable(); err != nil {
+		return err
+	}
+
+	s.commands = newCommandStore(s.db, false)
+	if err := s.commands.createTable(); err != nil {
+		return err
+	}
+
 	// 重新初始化common operations
 	s.commonOps = NewCommonSQLOperations(s.db)
 	return nil
@@ -537,7 +605,7 @@ func (s *SQLiteDB) GetTelegraphContent(key string, matchType MatchType) (*Entry,
 	}
 
 	entry.MatchType = matchType
-	return &entry, nil
+	return &entry, s.meta.attachOne(&entry)
 }
 
 // 模型缓存接口实现
@@ -592,3 +660,81 @@ func (s *SQLiteDB) ClearModelCache() error {
 	_, err := s.db.Exec("DELETE FROM model_cache")
 	return err
 }
+
+// SetEntryMeta 设置条目扩展属性
+func (s *SQLiteDB) SetEntryMeta(key string, matchType MatchType, meta EntryMeta) error {
+	entry, err := findEntryByKey(s, key, matchType)
+	if err != nil {
+		return err
+	}
+	return s.meta.Set(matchType, entry.ID, meta)
+}
+
+// UpdateEntryMeta 在事务中修改条目扩展属性的部分字段
+func (s *SQLiteDB) UpdateEntryMeta(key string, matchType MatchType, update func(meta *EntryMeta)) error {
+	entry, err := findEntryByKey(s, key, matchType)
+	if err != nil {
+		return err
+	}
+	return s.meta.Update(matchType, entry.ID, update)
+}
+
+// attachMeta 为查询结果填充扩展属性
+func (s *SQLiteDB) attachMeta(entries []Entry, err error) ([]Entry, error) {
+	if err != nil {
+		return nil, err
+	}
+	return entries, s.meta.Attach(entries)
+}
+
+func (s *SQLiteDB) RecordHit(hit Hit) error {
+	return s.hits.Record(hit)
+}
+
+func (s *SQLiteDB) ListHits(since time.Time) ([]Hit, error) {
+	return s.hits.List(since)
+}
+
+func (s *SQLiteDB) HitCounts() ([]EntryHitCount, error) {
+	return s.hits.Counts()
+}
+
+func (s *SQLiteDB) HitTotals(since, until time.Time) (int, int, error) {
+	return s.hits.Totals(since, until)
+}
+
+func (s *SQLiteDB) RecordMiss(miss Miss) error {
+	return s.misses.Record(miss)
+}
+
+func (s *SQLiteDB) ListMisses() ([]Miss, error) {
+	return s.misses.List()
+}
+
+func (s *SQLiteDB) DeleteMisses(ids ...int64) error {
+	return s.misses.Delete(ids...)
+}
+
+func (s *SQLiteDB) AddPendingChange(change PendingChange) error {
+	return s.changes.Add(change)
+}
+
+func (s *SQLiteDB) ListPendingChanges() ([]PendingChange, error) {
+	return s.changes.List()
+}
+
+func (s *SQLiteDB) DeletePendingChange(id int64) error {
+	return s.changes.Delete(id)
+}
+
+func (s *SQLiteDB) SetCustomCommand(command CustomCommand) error {
+	return s.commands.Set(command)
+}
+
+func (s *SQLiteDB) ListCustomCommands() ([]CustomCommand, error) {
+	return s.commands.List()
+}
+
+func (s *SQLiteDB) DeleteCustomCommand(command string) error {
+	return s.commands.Delete(command)
+}
@@ -49,209 +49,186 @@ func RemoveID(slice []int64, id int64) []int64 {
 }
 
 type AdminHandler struct {
-	db    database.Database
-	conf  *config.Config
-	state *State
+	db          database.Database
+	conf        *config.Config
+	state       *State
+	mediaGroups *MediaGroupCache
+	wizard      *AddWizard
 }
 
 func NewAdminHandler(db database.Database, conf *config.Config, state *State) *AdminHandler {
 	return &AdminHandler{
-		db:    db,
-		conf:  conf,
-		state: state,
+		db:          db,
+		conf:        conf,
+		state:       state,
+		mediaGroups: NewMediaGroupCache(),
+		wizard:      NewAddWizard(db, conf, state),
 	}
 }
 
+// TrackMediaGroup 记录相册消息，供回复 /add 时还原整个相册
+func (h *AdminHandler) TrackMediaGroup(message *tgbotapi.Message) {
+	h.mediaGroups.Track(message)
+}
+
 func (h *AdminHandler) HandleAdminCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	args := message.CommandArguments()
-	parts := strings.SplitN(args, " ", 3)
+	// 不带参数的 /add 进入分步向导
+	if message.Command() == "add" && strings.TrimSpace(args) == "" && message.ReplyToMessage == nil {
+		h.wizard.Start(bot, message)
+		return
+	}
+	// /update 需要 key、旧类型、新类型和内容四个参数
+	fields := 3
+	if message.Command() == "update" {
+		fields = 4
+	}
+	parts := strings.SplitN(args, " ", fields)
+
+	// 回复媒体消息时使用 /add key type 创建媒体回答
+	if message.Command() == "add" && message.ReplyToMessage != nil {
+		if media, caption := mediaFromMessage(message.ReplyToMessage, h.mediaGroups); len(media) > 0 {
+			h.handleAddMedia(bot, message, parts, media, caption)
+			return
+		}
+	}
 
 	if message.Command() == "delete" {
 		if len(parts) < 2 {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/delete key type\n其中，type 的取值可以是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配"))
 			return
 		}
-	} else if len(parts) < 3 {
+	} else if len(parts) < fields {
 		var helpMsg string
 		switch message.Command() {
 		case "add":
-			helpMsg = "格式错误，请使用：/add key type value\n其中，type 的取值可以是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配"
+			helpMsg = "格式错误，请使用：/add key type value\n其中，type 的取值可以是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配\n\n" + buttonHelp
 		case "update":
-			helpMsg = "格式错误，请使用：/update key oldType newType value\n其中，type 的取值可以是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配"
+			helpMsg = "格式错误，请使用：/update key oldType newType value\n其中，type 的取值可以是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配\n\n" + buttonHelp
 		}
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, helpMsg))
 		return
 	}
 
-	{
-		key := parts[0]
-		matchTypeStr := parts[1]
-
-		matchType, errType := utils.ParseMatchType(matchTypeStr)
-		if errType != nil {
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "type 参数错误，在 /"+message.Command()+" 命令中，type 必须是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配\n• prefix: 表示前缀匹配\n• suffix: 表示后缀匹配"))
-			return
-		}
-
-		switch message.Command() {
-		case "add":
-			if len(parts) < 3 {
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/add key type value"))
-				return
-			}
-			value := parts[2]
-
-			// Check if the entry already exists
-			existingEntries, err := h.db.QueryExact(key)
-			if err != nil {
-				log.Printf("Error querying database: %v", err)
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "查询失败"))
-				return
-			}
-			if len(existingEntries) > 0 {
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "该条目已存在"))
-				return
-			}
-
-			err = h.db.AddEntry(key, matchType, value)
-			if err != nil {
-				log.Printf("Error adding entry: %v", err)
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加失败"))
-				return
-			}
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加成功"))
-
-		case "update":
-			if len(parts) < 4 {
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/update key oldType newType value"))
-				return
-			}
-			newTypeStr := parts[2]
-			newValue := parts[3]
-
-			// 验证并转换新匹配类型
-			var newType database.MatchType
-			switch newTypeStr {
-			case "exact":
-				newType = database.MatchExact
-			case "contains":
-				newType = database.MatchContains
-			case "regex":
-				newType = database.MatchRegex
-			case "prefix":
-				newType = database.MatchPrefix
-			case "suffix":
-				newType = database.MatchSuffix
-			default:
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "NewType 参数错误，必须是 exact, contains, regex, prefix, suffix"))
-				return
-			}
-
-			err := h.db.UpdateEntry(key, matchType, newType, newValue)
-			if err != nil {
-				log.Printf("Error updating entry: %v", err)
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "更新失败"))
-				return
-			}
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "更新成功"))
-
-		case "delete":
-			err := h.db.DeleteEntry(key, matchType)
-			if err != nil {
-				log.Printf("Error deleting entry: %v", err)
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "删除失败"))
-				return
-			}
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "删除成功"))
-		}
-	}
-	// end of HandleAdminCommand
-
 	key := parts[0]
 	matchTypeStr := parts[1]
 
-	// 验证并转换匹配类型
-	var matchType database.MatchType
-	switch matchTypeStr {
-	case "exact":
-		matchType = database.MatchExact
-	case "contains":
-		matchType = database.MatchContains
-	case "regex":
-		matchType = database.MatchRegex
-	case "prefix":
-		matchType = database.MatchPrefix
-	case "suffix":
-		matchType = database.MatchSuffix
-	default:
-		helpMsg := fmt.Sprintf("type 参数错误，在 /%s 命令中，type 必须是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配\n• prefix: 表示前缀匹配\n• suffix: 表示后缀匹配", message.Command())
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, helpMsg))
+	matchType, errType := utils.ParseMatchType(matchTypeStr)
+	if errType != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "type 参数错误，在 /"+message.Command()+" 命令中，type 必须是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配\n• prefix: 表示前缀匹配\n• suffix: 表示后缀匹配"))
 		return
 	}
 
 	switch message.Command() {
 	case "add":
-		if len(parts) < 3 {
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/add key type value"))
+		value, buttons, hasButtons, err := splitButtonLayout(parts[2])
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()+"\n\n"+buttonHelp))
+			return
+		}
+		if err := resolveButtonEntries(h.db, buttons); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
 			return
 		}
-		value := parts[2]
 
 		// Check if the entry already exists
-		existingEntries, err := h.db.QueryExact(key)
+		exists, err := entryExists(h.db, key)
 		if err != nil {
 			log.Printf("Error querying database: %v", err)
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "查询失败"))
 			return
 		}
-		if len(existingEntries) > 0 {
+		if exists {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "该条目已存在"))
 			return
 		}
 
+		if requiresReview(h.conf, message.From.ID) {
+			change := database.PendingChange{Action: database.ChangeAdd, Key: key, MatchType: matchType, Value: value}
+			if len(buttons) > 0 {
+				change.Meta = &database.EntryMeta{Buttons: buttons}
+			}
+			h.submitChange(bot, message, change)
+			return
+		}
+
+		warning := overlapWarning(h.db, h.conf, key, matchType)
 		err = h.db.AddEntry(key, matchType, value)
 		if err != nil {
 			log.Printf("Error adding entry: %v", err)
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加失败"))
 			return
 		}
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加成功"))
+		if hasButtons && len(buttons) > 0 {
+			if err := h.db.SetEntryMeta(key, matchType, database.EntryMeta{Buttons: buttons}); err != nil {
+				log.Printf("Error saving buttons for entry %s: %v", key, err)
+				h.db.DeleteEntry(key, matchType)
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加失败"))
+				return
+			}
+		}
+		stampEntry(h.db, key, matchType, message.From.ID, true)
+		result := "添加成功"
+		if hasButtons && len(buttons) > 0 {
+			result += "，" + describeButtons(buttons)
+		}
+		if warning != "" {
+			result += "\n\n" + warning
+		}
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, result))
 
 	case "update":
-		if len(parts) < 4 {
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/update key oldType newType value"))
+		newType, err := utils.ParseMatchType(parts[2])
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "NewType 参数错误，必须是 exact, contains, regex, prefix, suffix"))
 			return
 		}
-		newTypeStr := parts[2]
-		newValue := parts[3]
-
-		// 验证并转换新匹配类型
-		var newType database.MatchType
-		switch newTypeStr {
-		case "exact":
-			newType = database.MatchExact
-		case "contains":
-			newType = database.MatchContains
-		case "regex":
-			newType = database.MatchRegex
-		case "prefix":
-			newType = database.MatchPrefix
-		case "suffix":
-			newType = database.MatchSuffix
-		default:
-			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "NewType 参数错误，必须是 exact, contains, regex, prefix, suffix"))
+		newValue, buttons, hasButtons, err := splitButtonLayout(parts[3])
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()+"\n\n"+buttonHelp))
+			return
+		}
+		if err := resolveButtonEntries(h.db, buttons); err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+			return
+		}
+
+		if requiresReview(h.conf, message.From.ID) {
+			h.submitChange(bot, message, database.PendingChange{
+				Action:     database.ChangeUpdate,
+				Key:        key,
+				MatchType:  matchType,
+				NewType:    newType,
+				Value:      newValue,
+				Buttons:    buttons,
+				SetButtons: hasButtons,
+			})
 			return
 		}
 
-		err := h.db.UpdateEntry(key, matchType, newType, newValue)
+		err = h.db.UpdateEntry(key, matchType, newType, newValue)
 		if err != nil {
 			log.Printf("Error updating entry: %v", err)
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "更新失败"))
 			return
 		}
+		stampEntry(h.db, key, newType, message.From.ID, false)
+		// 未给出按钮定义时保留原有按钮
+		if hasButtons {
+			if err := saveEntryButtons(h.db, key, newType, buttons); err != nil {
+				log.Printf("Error saving buttons for entry %s: %v", key, err)
+				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "内容已更新，但按钮保存失败"))
+				return
+			}
+		}
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "更新成功"))
 
 	case "delete":
+		if requiresReview(h.conf, message.From.ID) {
+			h.submitChange(bot, message, database.PendingChange{Action: database.ChangeDelete, Key: key, MatchType: matchType})
+			return
+		}
+
 		err := h.db.DeleteEntry(key, matchType)
 		if err != nil {
 			log.Printf("Error deleting entry: %v", err)
@@ -361,3 +338,110 @@ func (h *AdminHandler) HandleAdminSelection(bot *tgbotapi.BotAPI, message *tgbot
 	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 	bot.Send(editMsg)
 }
+
+// submitChange 审核模式下提交修改，等待超级管理员批准
+func (h *AdminHandler) submitChange(bot *tgbotapi.BotAPI, message *tgbotapi.Message, change database.PendingChange) {
+	if err := submitChange(bot, h.db, h.conf, message.From, message.Chat.ID, change); err != nil {
+		log.Printf("Error submitting change for entry %s: %v", change.Key, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "提交审核失败"))
+		return
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, reviewSubmitted(change)))
+}
+
+// stampEntry 记录条目的创建者或修改者，失败时只记录日志
+func stampEntry(db database.Database, key string, matchType database.MatchType, userID int64, created bool) {
+	if err := database.StampEntry(db, key, matchType, userID, created); err != nil {
+		log.Printf("Error recording author for entry %s: %v", key, err)
+	}
+}
+
+// entryExists 检查精确匹配中是否已有该 key 的条目，包括不在有效期内的条目
+func entryExists(db database.Database, key string) (bool, error) {
+	entries, err := db.ListSpecificEntries(database.MatchExact)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if entry.Key == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// handleAddMedia 将被回复的媒体消息保存为条目回答
+func (h *AdminHandler) handleAddMedia(bot *tgbotapi.BotAPI, message *tgbotapi.Message, parts []string, media []database.MediaItem, caption string) {
+	if len(parts) < 2 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请回复媒体消息并使用：/add key type [说明文字]\n其中，type 的取值可以是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配"))
+		return
+	}
+
+	key := parts[0]
+	matchType, err := utils.ParseMatchType(parts[1])
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "type 参数错误，在 /add 命令中，type 必须是：\n• exact: 表示精确匹配\n• contains: 表示包含匹配\n• regex: 表示正则匹配\n• prefix: 表示前缀匹配\n• suffix: 表示后缀匹配"))
+		return
+	}
+	// 命令中给出的说明文字优先于原消息的说明
+	if len(parts) > 2 {
+		caption = parts[2]
+	}
+	caption, buttons, _, err := splitButtonLayout(caption)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()+"\n\n"+buttonHelp))
+		return
+	}
+	if err := resolveButtonEntries(h.db, buttons); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+		return
+	}
+
+	meta := database.EntryMeta{Media: media, Caption: caption, Buttons: buttons}
+	if err := validateAlbum(media); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+		return
+	}
+
+	exists, err := entryExists(h.db, key)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "查询失败"))
+		return
+	}
+	if exists {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "该条目已存在"))
+		return
+	}
+
+	// value 保存说明文字，无说明时保存媒体描述，便于列表展示
+	value := caption
+	if value == "" {
+		value = describeMedia(meta)
+	}
+	if requiresReview(h.conf, message.From.ID) {
+		h.submitChange(bot, message, database.PendingChange{Action: database.ChangeAdd, Key: key, MatchType: matchType, Value: value, Meta: &meta})
+		return
+	}
+	warning := overlapWarning(h.db, h.conf, key, matchType)
+	if err := h.db.AddEntry(key, matchType, value); err != nil {
+		log.Printf("Error adding entry: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加失败"))
+		return
+	}
+	if err := h.db.SetEntryMeta(key, matchType, meta); err != nil {
+		log.Printf("Error saving media for entry %s: %v", key, err)
+		h.db.DeleteEntry(key, matchType)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "添加失败"))
+		return
+	}
+	stampEntry(h.db, key, matchType, message.From.ID, true)
+	result := fmt.Sprintf("添加成功：%s %s", key, describeMedia(meta))
+	if len(buttons) > 0 {
+		result += "，" + describeButtons(buttons)
+	}
+	if warning != "" {
+		result += "\n\n" + warning
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, result))
+}
@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+)
+
+// SendEntryAnswer 按条目内容类型发送 FAQ 回答
+func SendEntryAnswer(bot *tgbotapi.BotAPI, chatID int64, entry *database.Entry) error {
+	keyboard := buildEntryKeyboard(bot, entry)
+	if entry.HasMedia() {
+		return sendMediaEntry(bot, chatID, entry, keyboard)
+	}
+
+	text, parseMode := entryAnswerText(entry)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = parseMode
+	if keyboard != nil {
+		msg.ReplyMarkup = keyboard
+	}
+	_, err := bot.Send(msg)
+	return err
+}
+
+// entryAnswerText 返回文本类回答的内容及解析模式
+func entryAnswerText(entry *database.Entry) (string, string) {
+	switch entry.ContentType {
+	case "telegraph_image", "telegraph_text":
+		if entry.TelegraphURL != "" {
+			// 发送 Telegraph 链接，Telegram 会自动生成预览
+			return entry.TelegraphURL, ""
+		}
+	}
+	return entry.Value, "HTML"
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+)
+
+// buttonSeparator 回答内容与按钮定义之间的分隔行
+const buttonSeparator = "---"
+
+var (
+	// buttonPattern 匹配 [文本](目标) 格式的按钮定义
+	buttonPattern = regexp.MustCompile(`\[([^\[\]]+)\]\(([^()\s]+)\)`)
+	// startParamPattern Telegram 深链接 start 参数只允许字母、数字、下划线和连字符
+	startParamPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+)
+
+// buttonHelp 按钮语法说明
+const buttonHelp = "按钮格式：在内容末尾另起一行输入 ---，之后每行为一排按钮，同一排的按钮用 | 分隔：\n" +
+	"• [文本](https://example.com)：打开链接\n" +
+	"• [文本](entry:条目key)：显示相关条目\n" +
+	"• [文本](start:参数)：机器人深链接\n" +
+	"只输入 --- 而不跟按钮时清除已有按钮"
+
+// splitButtonLayout 从内容中分离按钮定义
+// 返回去掉按钮部分后的内容、按钮行，以及是否包含按钮定义（仅有分隔行时表示清除按钮）
+func splitButtonLayout(value string) (string, [][]database.EntryButton, bool, error) {
+	lines := strings.Split(value, "\n")
+	separator := -1
+	for i := len(lines) - 1; i >= 0; i-- {
+		if strings.TrimSpace(lines[i]) == buttonSeparator {
+			separator = i
+			break
+		}
+	}
+	if separator < 0 {
+		return value, nil, false, nil
+	}
+
+	var rows [][]database.EntryButton
+	for _, line := range lines[separator+1:] {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		// 分隔行之后出现非按钮内容时，视为普通文本
+		rest := strings.TrimSpace(strings.ReplaceAll(buttonPattern.ReplaceAllString(line, ""), "|", ""))
+		if rest != "" {
+			return value, nil, false, nil
+		}
+
+		var row []database.EntryButton
+		for _, match := range buttonPattern.FindAllStringSubmatch(line, -1) {
+			button, err := parseButtonTarget(strings.TrimSpace(match[1]), match[2])
+			if err != nil {
+				return value, nil, false, err
+			}
+			row = append(row, button)
+		}
+		rows = append(rows, row)
+	}
+
+	body := strings.TrimRight(strings.Join(lines[:separator], "\n"), " \n")
+	return body, rows, true, nil
+}
+
+// parseButtonTarget 解析按钮目标
+func parseButtonTarget(text, target string) (database.EntryButton, error) {
+	switch {
+	case strings.HasPrefix(target, "entry:"):
+		key := strings.TrimPrefix(target, "entry:")
+		if key == "" {
+			return database.EntryButton{}, fmt.Errorf("按钮「%s」缺少条目 key", text)
+		}
+		return database.EntryButton{Text: text, Type: database.ButtonEntry, EntryKey: key}, nil
+	case strings.HasPrefix(target, "start:"):
+		param := strings.TrimPrefix(target, "start:")
+		if !startParamPattern.MatchString(param) {
+			return database.EntryButton{}, fmt.Errorf("按钮「%s」的 start 参数只能包含字母、数字、_ 和 -，且不超过 64 个字符", text)
+		}
+		return database.EntryButton{Text: text, Type: database.ButtonStart, StartParam: param}, nil
+	case strings.HasPrefix(target, "http://"), strings.HasPrefix(target, "https://"), strings.HasPrefix(target, "tg://"):
+		return database.EntryButton{Text: text, Type: database.ButtonURL, URL: target}, nil
+	default:
+		return database.EntryButton{}, fmt.Errorf("按钮「%s」的目标无法识别：%s", text, target)
+	}
+}
+
+// findEntryByKeyInTypes 在所有匹配类型中按 key 查找条目，优先查找 preferred 类型
+func findEntryByKeyInTypes(db database.Database, key string, preferred database.MatchType) (*database.Entry, error) {
+	if preferred != "" {
+		entries, err := db.ListSpecificEntries(preferred)
+		if err != nil {
+			return nil, err
+		}
+		for i := range entries {
+			if entries[i].Key == key {
+				return &entries[i], nil
+			}
+		}
+	}
+
+	entries, err := db.ListAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		if entries[i].Key == key {
+			return &entries[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// resolveButtonEntries 检查相关条目按钮指向的条目是否存在，并记录其匹配类型
+func resolveButtonEntries(db database.Database, rows [][]database.EntryButton) error {
+	for i := range rows {
+		for j := range rows[i] {
+			button := &rows[i][j]
+			if button.Type != database.ButtonEntry {
+				continue
+			}
+			target, err := findEntryByKeyInTypes(db, button.EntryKey, "")
+			if err != nil {
+				return err
+			}
+			if target == nil {
+				return fmt.Errorf("按钮「%s」指向的条目不存在：%s", button.Text, button.EntryKey)
+			}
+			button.EntryType = target.MatchType
+		}
+	}
+	return nil
+}
+
+// saveEntryButtons 更新条目的按钮，保留媒体等其他扩展属性
+func saveEntryButtons(db database.Database, key string, matchType database.MatchType, rows [][]database.EntryButton) error {
+	entry, err := findEntryByKeyInTypes(db, key, matchType)
+	if err != nil {
+		return err
+	}
+	if entry == nil || entry.MatchType != matchType {
+		return fmt.Errorf("entry %s not found", key)
+	}
+	return db.UpdateEntryMeta(key, matchType, func(meta *database.EntryMeta) {
+		meta.Buttons = rows
+	})
+}
+
+// buildEntryKeyboard 根据条目的按钮定义生成内联键盘，末尾附加分享按钮，没有按钮时返回 nil
+func buildEntryKeyboard(bot *tgbotapi.BotAPI, entry *database.Entry) *tgbotapi.InlineKeyboardMarkup {
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for i, row := range entry.Buttons {
+		var buttons []tgbotapi.InlineKeyboardButton
+		for j, button := range row {
+			switch button.Type {
+			case database.ButtonURL:
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(button.Text, button.URL))
+			case database.ButtonStart:
+				link := fmt.Sprintf("https://t.me/%s?start=%s", bot.Self.UserName, button.StartParam)
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonURL(button.Text, link))
+			case database.ButtonEntry:
+				// 回调中只携带来源条目和按钮位置，点击时再查找目标条目，避免目标 ID 变化后失效
+				data := fmt.Sprintf("faqbtn_%d_%d_%d_%d", entry.ID, entry.MatchType.ToInt(), i, j)
+				buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(button.Text, data))
+			}
+		}
+		if len(buttons) > 0 {
+			keyboard = append(keyboard, buttons)
+		}
+	}
+	if share := entryShareRow(bot, entry); share != nil {
+		keyboard = append(keyboard, share)
+	}
+	if len(keyboard) == 0 {
+		return nil
+	}
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: keyboard}
+}
+
+// describeButtons 返回按钮的简短描述，用于确认消息和条目详情
+func describeButtons(rows [][]database.EntryButton) string {
+	var names []string
+	for _, row := range rows {
+		for _, button := range row {
+			names = append(names, button.Text)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("按钮：%s", strings.Join(names, "、"))
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"TGFaqBot/database"
+)
+
+func TestSplitButtonLayout(t *testing.T) {
+	tests := []struct {
+		name        string
+		value       string
+		wantBody    string
+		wantRows    [][]database.EntryButton
+		wantButtons bool
+		wantErr     bool
+	}{
+		{
+			name:     "no separator",
+			value:    "普通回答",
+			wantBody: "普通回答",
+		},
+		{
+			name:     "single url button",
+			value:    "回答\n---\n[官网](https://example.com)",
+			wantBody: "回答",
+			wantRows: [][]database.EntryButton{
+				{{Text: "官网", Type: database.ButtonURL, URL: "https://example.com"}},
+			},
+			wantButtons: true,
+		},
+		{
+			name:     "rows and columns of mixed buttons",
+			value:    "回答\n\n---\n[官网](https://example.com) | [退款](entry:退款)\n\n[开始](start:ref_1-a)",
+			wantBody: "回答",
+			wantRows: [][]database.EntryButton{
+				{
+					{Text: "官网", Type: database.ButtonURL, URL: "https://example.com"},
+					{Text: "退款", Type: database.ButtonEntry, EntryKey: "退款"},
+				},
+				{{Text: "开始", Type: database.ButtonStart, StartParam: "ref_1-a"}},
+			},
+			wantButtons: true,
+		},
+		{
+			name:        "separator only clears buttons",
+			value:       "回答\n---",
+			wantBody:    "回答",
+			wantButtons: true,
+		},
+		{
+			name:     "last separator wins",
+			value:    "第一段\n---\n第二段\n---\n[频道](tg://resolve?domain=x)",
+			wantBody: "第一段\n---\n第二段",
+			wantRows: [][]database.EntryButton{
+				{{Text: "频道", Type: database.ButtonURL, URL: "tg://resolve?domain=x"}},
+			},
+			wantButtons: true,
+		},
+		{
+			name:     "text after separator is not a layout",
+			value:    "标题\n---\n正文内容",
+			wantBody: "标题\n---\n正文内容",
+		},
+		{
+			name:     "mixed text and button line is not a layout",
+			value:    "回答\n---\n点击 [官网](https://example.com)",
+			wantBody: "回答\n---\n点击 [官网](https://example.com)",
+		},
+		{
+			name:    "unknown target",
+			value:   "回答\n---\n[官网](ftp://example.com)",
+			wantErr: true,
+		},
+		{
+			name:    "invalid start parameter",
+			value:   "回答\n---\n[开始](start:a.b)",
+			wantErr: true,
+		},
+		{
+			name:    "entry button without key",
+			value:   "回答\n---\n[相关](entry:)",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, rows, hasButtons, err := splitButtonLayout(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitButtonLayout() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if body != tt.wantBody {
+				t.Errorf("body = %q, want %q", body, tt.wantBody)
+			}
+			if hasButtons != tt.wantButtons {
+				t.Errorf("hasButtons = %v, want %v", hasButtons, tt.wantButtons)
+			}
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %+v, want %+v", rows, tt.wantRows)
+			}
+		})
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
@@ -21,26 +23,74 @@ type CallbackHandler struct {
 	listHandler  *ListHandler
 	prefManager  *PreferenceManager
 	multichatMgr *multichat.Manager
+	translator   *Translator
+	unanswered   *UnansweredHandler
+	wizard       *AddWizard
+	importer     *ImportHandler
+	streamer     *StreamingManager
+
+	// answered 已由处理函数应答的回调 ID
+	answered map[string]bool
+	mutex    sync.Mutex
 }
 
-func NewCallbackHandler(db database.Database, conf *config.Config, state *State, prefManager *PreferenceManager, multichatMgr *multichat.Manager) *CallbackHandler {
+func NewCallbackHandler(db database.Database, conf *config.Config, state *State, streamer *StreamingManager, prefManager *PreferenceManager, multichatMgr *multichat.Manager) *CallbackHandler {
 	return &CallbackHandler{
 		db:           db,
 		conf:         conf,
 		state:        state,
 		adminHandler: NewAdminHandler(db, conf, state),
-		listHandler:  NewListHandler(db, state),
+		listHandler:  NewListHandler(db, conf, state),
 		prefManager:  prefManager,
 		multichatMgr: multichatMgr,
+		translator:   NewTranslator(db, conf, multichatMgr),
+		unanswered:   NewUnansweredHandler(db, state),
+		wizard:       NewAddWizard(db, conf, state),
+		importer:     NewImportHandler(db, conf, state),
+		streamer:     streamer,
+		answered:     make(map[string]bool),
 	}
 }
 
 func (h *CallbackHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery) {
 	data := callbackQuery.Data
+	// 内联消息的回调不带原消息，机器人不会在内联结果中放置回调按钮
+	if callbackQuery.Message == nil {
+		bot.Request(tgbotapi.NewCallback(callbackQuery.ID, ""))
+		return
+	}
 	chatID := callbackQuery.Message.Chat.ID
 	messageID := callbackQuery.Message.MessageID
 
 	switch {
+	case data == "aistop":
+		h.handleStopCallback(bot, callbackQuery, chatID, messageID)
+	case strings.HasPrefix(data, "faqbtn_"):
+		h.handleEntryButtonCallback(bot, callbackQuery, data, chatID, messageID)
+	case strings.HasPrefix(data, "faqcat_"):
+		h.handleCategoryCallback(bot, callbackQuery, data, chatID, messageID)
+	case strings.HasPrefix(data, "faqopen_"):
+		h.handleCategoryOpenCallback(bot, callbackQuery, data, chatID)
+	case strings.HasPrefix(data, "trmenu_"):
+		h.handleTranslationMenuCallback(bot, callbackQuery, data)
+	case strings.HasPrefix(data, "trlang_"):
+		h.handleTranslationLanguageCallback(bot, callbackQuery, data, chatID, messageID)
+	case strings.HasPrefix(data, "review_"):
+		h.handleReviewCallback(bot, callbackQuery, data, chatID, messageID)
+	case strings.HasPrefix(data, "vismenu_"):
+		h.handleVisibilityMenuCallback(bot, callbackQuery, data)
+	case strings.HasPrefix(data, "visset_"):
+		h.handleVisibilitySetCallback(bot, callbackQuery, data)
+	case strings.HasPrefix(data, "validity_"):
+		h.handleValidityCallback(bot, callbackQuery, data)
+	case strings.HasPrefix(data, "priority_"):
+		h.handlePriorityCallback(bot, callbackQuery, data)
+	case strings.HasPrefix(data, "addwiz_"):
+		h.wizard.HandleCallback(bot, callbackQuery, data)
+	case data == "import_confirm":
+		h.importer.HandleConfirm(bot, callbackQuery)
+	case strings.HasPrefix(data, "unans_"):
+		h.handleUnansweredCallback(bot, callbackQuery, data)
 	case strings.HasPrefix(data, "list_"):
 		h.handleListCallback(bot, callbackQuery, data)
 	case strings.HasPrefix(data, "entry_"):
@@ -80,13 +130,38 @@ func (h *CallbackHandler) HandleCallbackQuery(bot *tgbotapi.BotAPI, callbackQuer
 		h.handleClearModelPreferenceCallback(bot, callbackQuery, chatID, messageID)
 	}
 
-	// Acknowledge the callback
-	_, err := bot.Request(tgbotapi.NewCallback(callbackQuery.ID, ""))
-	if err != nil {
+	// 处理函数已经带提示文字应答过的回调不能再次应答
+	h.mutex.Lock()
+	answered := h.answered[callbackQuery.ID]
+	delete(h.answered, callbackQuery.ID)
+	h.mutex.Unlock()
+	if answered {
+		return
+	}
+	if _, err := bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "")); err != nil {
 		log.Printf("Error acknowledging callback: %v", err)
 	}
 }
 
+// answer 带提示文字应答回调，并记录下来避免 HandleCallbackQuery 重复应答
+func (h *CallbackHandler) answer(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, text string) {
+	h.mutex.Lock()
+	h.answered[callbackQuery.ID] = true
+	h.mutex.Unlock()
+	if _, err := bot.Request(tgbotapi.NewCallback(callbackQuery.ID, text)); err != nil {
+		log.Printf("Error answering callback: %v", err)
+	}
+}
+
+// requireAdmin 修改条目的按钮只允许管理员使用，其他用户点击时提示无权限
+func (h *CallbackHandler) requireAdmin(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery) bool {
+	if IsAdminUser(callbackQuery.From.ID, h.conf) {
+		return true
+	}
+	h.answer(bot, callbackQuery, "无权限")
+	return false
+}
+
 func (h *CallbackHandler) handleListCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
 	pageStr := strings.TrimPrefix(data, "list_")
 	page, err := strconv.Atoi(pageStr)
@@ -95,14 +170,8 @@ func (h *CallbackHandler) handleListCallback(bot *tgbotapi.BotAPI, callbackQuery
 		return
 	}
 
-	state, exists := h.state.Get(callbackQuery.Message.Chat.ID)
-	if !exists || state.Stage != "listing" {
-		log.Printf("Conversation state not found for chat ID: %d", callbackQuery.Message.Chat.ID)
-		return
-	}
-	messageID := state.MessageID
-
-	h.listHandler.HandleListCommandEdit(bot, callbackQuery.Message, page, messageID)
+	// 筛选条件按列表消息保存，其他对话替换聊天状态后翻页仍沿用原筛选条件
+	h.listHandler.HandleListCommandEdit(bot, callbackQuery.Message, page, callbackQuery.Message.MessageID)
 }
 
 func (h *CallbackHandler) handleEntryCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
@@ -127,6 +196,279 @@ func (h *CallbackHandler) handleEntryCallback(bot *tgbotapi.BotAPI, callbackQuer
 	h.listHandler.HandleEntrySelection(bot, callbackQuery.Message, entryID, matchType)
 }
 
+// handleEntryButtonCallback 处理回答中的相关条目按钮，在原消息中显示相关条目
+func (h *CallbackHandler) handleEntryButtonCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+	// 回调数据: faqbtn_<entryID>_<matchType>_<row>_<col>
+	parts := strings.Split(strings.TrimPrefix(data, "faqbtn_"), "_")
+	if len(parts) != 4 {
+		log.Printf("Error parsing entry button: %s", data)
+		return
+	}
+	values := make([]int, len(parts))
+	for i, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			log.Printf("Error parsing entry button %s: %v", data, err)
+			return
+		}
+		values[i] = value
+	}
+	entryID, matchType, row, col := values[0], values[1], values[2], values[3]
+
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		log.Printf("Error parsing match type for entry button: %v", err)
+		return
+	}
+	now := time.Now()
+	source, err := database.QueryByIDActive(h.db, entryID, matchTypeValue)
+	if err != nil || source == nil || row >= len(source.Buttons) || col >= len(source.Buttons[row]) {
+		h.answer(bot, callbackQuery, "该按钮已失效")
+		return
+	}
+
+	button := source.Buttons[row][col]
+	target, err := findEntryByKeyInTypes(h.db, button.EntryKey, button.EntryType)
+	if err != nil {
+		log.Printf("Error querying related entry %s: %v", button.EntryKey, err)
+	}
+	if target == nil || !target.IsActive(now) {
+		h.answer(bot, callbackQuery, "相关条目不存在或已失效")
+		return
+	}
+	if !canViewEntry(h.conf, callbackQuery.From.ID, callbackQuery.Message.Chat.IsPrivate(), target) {
+		h.answer(bot, callbackQuery, "无权查看该条目")
+		return
+	}
+	target = h.translator.Localize(target, callbackQuery.From.LanguageCode)
+
+	// 原消息与目标都是文本时直接替换内容，否则发送新消息
+	if callbackQuery.Message.Text == "" || target.HasMedia() {
+		if err := SendEntryAnswer(bot, chatID, target); err != nil {
+			log.Printf("Error sending related entry %s: %v", target.Key, err)
+		}
+		return
+	}
+
+	text, parseMode := entryAnswerText(target)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ParseMode = parseMode
+	editMsg.ReplyMarkup = buildEntryKeyboard(bot, target)
+	if _, err := bot.Send(editMsg); err != nil {
+		log.Printf("Error showing related entry %s: %v", target.Key, err)
+	}
+}
+
+func (h *CallbackHandler) handleTranslationMenuCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(data, "trmenu_"), "_")
+	if len(parts) != 2 {
+		log.Printf("Error parsing entry ID and match type for translations: %s", data)
+		return
+	}
+
+	entryID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		log.Printf("Error parsing entry ID for translations: %v", err)
+		return
+	}
+
+	matchType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error parsing match type for translations: %v", err)
+		return
+	}
+
+	h.listHandler.HandleTranslationMenu(bot, callbackQuery.Message, entryID, matchType)
+}
+
+func (h *CallbackHandler) handleValidityCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(data, "validity_"), "_")
+	if len(parts) != 2 {
+		log.Printf("Error parsing entry ID and match type for validity: %s", data)
+		return
+	}
+
+	entryID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		log.Printf("Error parsing entry ID for validity: %v", err)
+		return
+	}
+
+	matchType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error parsing match type for validity: %v", err)
+		return
+	}
+
+	h.listHandler.HandleValidityPrompt(bot, callbackQuery.Message, entryID, matchType)
+}
+
+func (h *CallbackHandler) handlePriorityCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(data, "priority_"), "_")
+	if len(parts) != 2 {
+		log.Printf("Error parsing entry ID and match type for priority: %s", data)
+		return
+	}
+
+	entryID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		log.Printf("Error parsing entry ID for priority: %v", err)
+		return
+	}
+
+	matchType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error parsing match type for priority: %v", err)
+		return
+	}
+
+	h.listHandler.HandlePriorityPrompt(bot, callbackQuery.Message, entryID, matchType)
+}
+
+func (h *CallbackHandler) handleVisibilityMenuCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(data, "vismenu_"), "_")
+	if len(parts) != 2 {
+		log.Printf("Error parsing entry ID and match type for visibility: %s", data)
+		return
+	}
+
+	entryID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		log.Printf("Error parsing entry ID for visibility: %v", err)
+		return
+	}
+
+	matchType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error parsing match type for visibility: %v", err)
+		return
+	}
+
+	h.listHandler.HandleVisibilityMenu(bot, callbackQuery.Message, entryID, matchType)
+}
+
+func (h *CallbackHandler) handleVisibilitySetCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	// 回调数据: visset_<entryID>_<matchType>_<visibility>，visibility 中可能包含下划线
+	parts := strings.SplitN(strings.TrimPrefix(data, "visset_"), "_", 3)
+	if len(parts) != 3 {
+		log.Printf("Error parsing visibility callback: %s", data)
+		return
+	}
+
+	entryID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		log.Printf("Error parsing entry ID for visibility: %v", err)
+		return
+	}
+
+	matchType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error parsing match type for visibility: %v", err)
+		return
+	}
+
+	h.listHandler.HandleVisibilitySet(bot, callbackQuery.Message, callbackQuery.From, entryID, matchType, parts[2])
+}
+
+func (h *CallbackHandler) handleTranslationLanguageCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	// 回调数据: trlang_<entryID>_<matchType>_<lang>，lang 为空表示由用户输入语言
+	parts := strings.SplitN(strings.TrimPrefix(data, "trlang_"), "_", 3)
+	if len(parts) != 3 {
+		log.Printf("Error parsing translation callback: %s", data)
+		return
+	}
+
+	entryID, err := strconv.Atoi(parts[0])
+	if err != nil {
+		log.Printf("Error parsing entry ID for translation: %v", err)
+		return
+	}
+
+	matchType, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error parsing match type for translation: %v", err)
+		return
+	}
+	lang := parts[2]
+
+	h.state.Set(chatID, &Conversation{
+		Stage:     "awaiting_translation",
+		EntryID:   entryID,
+		OldType:   matchType,
+		Language:  lang,
+		MessageID: messageID,
+	})
+
+	var prompt string
+	if lang == "" {
+		prompt = "请输入语言代码和内容，例如：ja こんにちは\n发送「语言代码 -」删除该语言的翻译"
+	} else {
+		prompt = fmt.Sprintf("请输入 %s 的内容：\n发送 - 删除该语言的翻译", lang)
+	}
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, prompt)
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("trmenu_%d_%d", entryID, matchType)),
+			tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
+		},
+	}
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	bot.Send(editMsg)
+}
+
+func (h *CallbackHandler) handleUnansweredCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	// 回调数据: unans_page_<page>、unans_add_<missID>、unans_type_<missID>_<type>、unans_del_<missID>_<page>
+	parts := strings.Split(strings.TrimPrefix(data, "unans_"), "_")
+	if len(parts) < 2 {
+		log.Printf("Error parsing unanswered callback: %s", data)
+		return
+	}
+	values := make([]int64, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		value, err := strconv.ParseInt(part, 10, 64)
+		if err != nil {
+			log.Printf("Error parsing unanswered callback %s: %v", data, err)
+			return
+		}
+		values = append(values, value)
+	}
+
+	message := callbackQuery.Message
+	switch {
+	case parts[0] == "page":
+		h.unanswered.HandleUnansweredPage(bot, message, int(values[0]))
+	case parts[0] == "add":
+		h.unanswered.HandleAddStart(bot, message, values[0])
+	case parts[0] == "type" && len(values) == 2:
+		h.unanswered.HandleAddType(bot, message, values[0], int(values[1]))
+	case parts[0] == "del" && len(values) == 2:
+		h.unanswered.HandleDismiss(bot, message, values[0], int(values[1]))
+	default:
+		log.Printf("Unknown unanswered callback: %s", data)
+	}
+}
+
 func (h *CallbackHandler) handleShowUpdateTypesCallback(bot *tgbotapi.BotAPI, _ *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
 	parts := strings.Split(strings.TrimPrefix(data, "show_update_types_"), "_")
 	if len(parts) != 2 {
@@ -215,7 +557,7 @@ func (h *CallbackHandler) handleUpdateTypeCallback(bot *tgbotapi.BotAPI, _ *tgbo
 		currentInfo = fmt.Sprintf("\n\n📝 当前内容:\nKey: %s\nValue: %s", entry.Key, entry.Value)
 	}
 
-	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "请输入新的内容："+currentInfo)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "请输入新的内容："+currentInfo+"\n\n"+buttonHelp)
 	cancelButton := tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")
 	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{cancelButton}}}
 	bot.Send(editMsg)
@@ -273,7 +615,10 @@ func (h *CallbackHandler) handleDeleteCallback(bot *tgbotapi.BotAPI, _ *tgbotapi
 	bot.Send(editMsg)
 }
 
-func (h *CallbackHandler) handleConfirmDeleteCallback(bot *tgbotapi.BotAPI, _ *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+func (h *CallbackHandler) handleConfirmDeleteCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
 	parts := strings.Split(strings.TrimPrefix(data, "confirm_delete_"), "_")
 	if len(parts) != 2 {
 		log.Printf("Error parsing entry ID and match type for confirm delete: %s", data)
@@ -311,6 +656,17 @@ func (h *CallbackHandler) handleConfirmDeleteCallback(bot *tgbotapi.BotAPI, _ *t
 		return
 	}
 
+	if requiresReview(h.conf, callbackQuery.From.ID) {
+		change := database.PendingChange{Action: database.ChangeDelete, Key: entry.Key, MatchType: matchTypeValue}
+		if err := submitChange(bot, h.db, h.conf, callbackQuery.From, chatID, change); err != nil {
+			log.Printf("Error submitting change for entry %s: %v", entry.Key, err)
+			bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "提交审核失败"))
+			return
+		}
+		bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, reviewSubmitted(change)))
+		return
+	}
+
 	// 执行删除操作
 	err = h.db.DeleteEntry(entry.Key, matchTypeValue)
 	if err != nil {
@@ -328,7 +684,11 @@ func (h *CallbackHandler) handleConfirmDeleteCallback(bot *tgbotapi.BotAPI, _ *t
 	bot.Send(editMsg)
 }
 
-func (h *CallbackHandler) handleConfirmBatchDeleteCallback(bot *tgbotapi.BotAPI, _ *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+func (h *CallbackHandler) handleConfirmBatchDeleteCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+
 	// 解析回调数据: confirm_batch_delete_<matchType>_<pattern>
 	trimmed := strings.TrimPrefix(data, "confirm_batch_delete_")
 	parts := strings.SplitN(trimmed, "_", 2)
@@ -382,6 +742,25 @@ func (h *CallbackHandler) handleConfirmBatchDeleteCallback(bot *tgbotapi.BotAPI,
 		return
 	}
 
+	// 审核模式下每个条目提交一条删除请求，批准后才会删除
+	if requiresReview(h.conf, callbackQuery.From.ID) {
+		submitted := 0
+		for _, entry := range entries {
+			change := database.PendingChange{Action: database.ChangeDelete, Key: entry.Key, MatchType: entry.MatchType}
+			if err := submitChange(bot, h.db, h.conf, callbackQuery.From, chatID, change); err != nil {
+				log.Printf("Error submitting change for entry %s: %v", entry.Key, err)
+				continue
+			}
+			submitted++
+		}
+		text := fmt.Sprintf("📝 已提交 %d 个删除请求，超级管理员批准后生效", submitted)
+		if submitted < len(entries) {
+			text += fmt.Sprintf("\n%d 个提交失败", len(entries)-submitted)
+		}
+		bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+		return
+	}
+
 	// 执行批量删除
 	successCount := 0
 	var failedEntries []string
@@ -470,7 +849,14 @@ func (h *CallbackHandler) handleDelAdminCallback(bot *tgbotapi.BotAPI, _ *tgbota
 	bot.Send(editMsg)
 }
 
-func (h *CallbackHandler) handleConfirmDeleteAllCallback(bot *tgbotapi.BotAPI, _ *tgbotapi.CallbackQuery, chatID int64, messageID int) {
+func (h *CallbackHandler) handleConfirmDeleteAllCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, chatID int64, messageID int) {
+	if !h.requireAdmin(bot, callbackQuery) {
+		return
+	}
+	if requiresReview(h.conf, callbackQuery.From.ID) {
+		bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "审核模式下只有超级管理员可以删除所有条目"))
+		return
+	}
 	deleteErr := h.db.DeleteAllEntries()
 	if deleteErr != nil {
 		log.Printf("Error deleting entry: %v", deleteErr)
@@ -503,10 +889,7 @@ func (h *CallbackHandler) handleModelCallback(bot *tgbotapi.BotAPI, callbackQuer
 	// based on provider configuration and availability
 
 	// Acknowledge the callback
-	_, err := bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "模型设置已记录: "+modelName))
-	if err != nil {
-		log.Printf("Error acknowledging callback: %v", err)
-	}
+	h.answer(bot, callbackQuery, "模型设置已记录: "+modelName)
 
 	// Edit the message
 	msgText := fmt.Sprintf("模型偏好已记录: %s\n注意：实际使用的模型将根据当前可用的AI提供商自动选择", modelName)
@@ -523,16 +906,13 @@ func (h *CallbackHandler) handleModelsPageCallback(bot *tgbotapi.BotAPI, callbac
 	}
 
 	h.sendModelsPage(bot, chatID, messageID, page)
-
-	// 确认回调
-	bot.Request(tgbotapi.NewCallback(callbackQuery.ID, ""))
 }
 
 func (h *CallbackHandler) handleRefreshModelsCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, chatID int64, messageID int) {
 	h.sendModelsPage(bot, chatID, messageID, 1)
 
 	// 确认回调
-	bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "模型列表已刷新"))
+	h.answer(bot, callbackQuery, "模型列表已刷新")
 }
 
 func (h *CallbackHandler) sendModelsPage(bot *tgbotapi.BotAPI, chatID int64, messageID int, page int) {
@@ -695,7 +1075,7 @@ func (h *CallbackHandler) handleSelectModelCallback(bot *tgbotapi.BotAPI, callba
 	// 获取模型信息进行显示
 	allModels, err := h.db.GetAllModels()
 	if err != nil {
-		bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "获取模型信息失败"))
+		h.answer(bot, callbackQuery, "获取模型信息失败")
 		return
 	}
 
@@ -717,13 +1097,13 @@ func (h *CallbackHandler) handleSelectModelCallback(bot *tgbotapi.BotAPI, callba
 	}
 
 	if selectedModel == nil {
-		bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "未找到选中的模型"))
+		h.answer(bot, callbackQuery, "未找到选中的模型")
 		return
 	}
 
 	// 验证提供商是否可用
 	if !h.multichatMgr.IsProviderAvailable(selectedProvider) {
-		bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "该模型的提供商当前不可用"))
+		h.answer(bot, callbackQuery, "该模型的提供商当前不可用")
 
 		// 显示错误信息
 		msgText := fmt.Sprintf("❌ 模型选择失败\n\n🤖 模型：%s\n🏢 提供商：%s\n\n⚠️ 该提供商当前不可用，请选择其他模型",
@@ -766,7 +1146,7 @@ func (h *CallbackHandler) handleSelectModelCallback(bot *tgbotapi.BotAPI, callba
 	bot.Send(editMsg)
 
 	// 确认回调
-	bot.Request(tgbotapi.NewCallback(callbackQuery.ID, fmt.Sprintf("已选择：%s", selectedModel.Name)))
+	h.answer(bot, callbackQuery, fmt.Sprintf("已选择：%s", selectedModel.Name))
 }
 
 func (h *CallbackHandler) handleClearModelPreferenceCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, chatID int64, messageID int) {
@@ -787,5 +1167,15 @@ func (h *CallbackHandler) handleClearModelPreferenceCallback(bot *tgbotapi.BotAP
 	bot.Send(editMsg)
 
 	// 确认回调
-	bot.Request(tgbotapi.NewCallback(callbackQuery.ID, "已清除模型偏好"))
+	h.answer(bot, callbackQuery, "已清除模型偏好")
+}
+
+// handleStopCallback 停止正在生成的AI回复
+func (h *CallbackHandler) handleStopCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, chatID int64, messageID int) {
+	streamKey := fmt.Sprintf("%d_%d", chatID, messageID)
+	if err := h.streamer.StopStream(streamKey, callbackQuery.From.ID, IsAdminUser(callbackQuery.From.ID, h.conf)); err != nil {
+		h.answer(bot, callbackQuery, err.Error())
+		return
+	}
+	h.answer(bot, callbackQuery, "已停止")
 }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+)
+
+// fakeTelegram 记录机器人发出的请求，所有请求都返回成功
+type fakeTelegram struct {
+	mutex    sync.Mutex
+	requests []fakeRequest
+}
+
+type fakeRequest struct {
+	Method string
+	Params url.Values
+}
+
+// newTestBot 创建请求发往本地测试服务器的机器人
+func newTestBot(t *testing.T) (*tgbotapi.BotAPI, *fakeTelegram) {
+	t.Helper()
+	fake := &fakeTelegram{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		method := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+		fake.mutex.Lock()
+		fake.requests = append(fake.requests, fakeRequest{Method: method, Params: r.PostForm})
+		fake.mutex.Unlock()
+
+		switch method {
+		case "getMe":
+			w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"faq_bot"}}`))
+		case "answerCallbackQuery":
+			w.Write([]byte(`{"ok":true,"result":true}`))
+		default:
+			w.Write([]byte(`{"ok":true,"result":{"message_id":1,"chat":{"id":1}}}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	bot, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bot, fake
+}
+
+// calls 返回指定方法的请求
+func (f *fakeTelegram) calls(method string) []fakeRequest {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var calls []fakeRequest
+	for _, request := range f.requests {
+		if request.Method == method {
+			calls = append(calls, request)
+		}
+	}
+	return calls
+}
+
+func TestHandleCallbackQueryAnswersOnce(t *testing.T) {
+	const adminID, userID = 1, 2
+
+	tests := []struct {
+		name     string
+		from     int64
+		data     string
+		wantText string
+	}{
+		{name: "handler answers with text", from: userID, data: "faqbtn_999_1_0_0", wantText: "该按钮已失效"},
+		{name: "non admin is refused", from: userID, data: "confirm_deleteall", wantText: "无权限"},
+		{name: "handler without answer is acknowledged", from: adminID, data: "faqcat_1_0"},
+		{name: "unknown data is acknowledged", from: adminID, data: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, fake := newTestBot(t)
+			db := newTestDB(t)
+			conf := &config.Config{Admin: config.AdminConfig{SuperAdminIDs: []int64{adminID}}}
+			h := &CallbackHandler{db: db, conf: conf, answered: make(map[string]bool)}
+
+			h.HandleCallbackQuery(bot, &tgbotapi.CallbackQuery{
+				ID:      "cb-1",
+				From:    &tgbotapi.User{ID: tt.from},
+				Data:    tt.data,
+				Message: &tgbotapi.Message{MessageID: 10, Chat: &tgbotapi.Chat{ID: 100, Type: "private"}},
+			})
+
+			answers := fake.calls("answerCallbackQuery")
+			if len(answers) != 1 {
+				t.Fatalf("answered %d times, want once: %+v", len(answers), answers)
+			}
+			if got := answers[0].Params.Get("text"); got != tt.wantText {
+				t.Errorf("answer text = %q, want %q", got, tt.wantText)
+			}
+			if len(h.answered) != 0 {
+				t.Errorf("answered = %v, want cleared after dispatch", h.answered)
+			}
+		})
+	}
+}
@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -25,6 +28,9 @@ type CommandHandler struct {
 	state            *State
 	streamer         *StreamingManager
 	prefManager      *PreferenceManager
+	translator       *Translator
+	unanswered       *UnansweredHandler
+	commandsChanged  func() // 自定义命令变化后重新注册命令菜单
 }
 
 func NewCommandHandler(db database.Database, conf *config.Config, adminHandler *AdminHandler, listHandler *ListHandler, multichatManager *multichat.Manager, state *State, streamer *StreamingManager, prefManager *PreferenceManager) *CommandHandler {
@@ -38,6 +44,8 @@ func NewCommandHandler(db database.Database, conf *config.Config, adminHandler *
 		streamer:         streamer,
 		prefManager:      prefManager,
 		rateLimiter:      utils.NewRateLimiter(),
+		translator:       NewTranslator(db, conf, multichatManager),
+		unanswered:       NewUnansweredHandler(db, state),
 	}
 }
 
@@ -55,7 +63,7 @@ func (h *CommandHandler) HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.M
 
 	switch message.Command() {
 	case "start":
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.conf.Telegram.Introduction))
+		h.handleStartCommand(bot, message)
 	case "query":
 		h.handleQueryCommand(bot, message)
 	case "userinfo":
@@ -75,7 +83,9 @@ func (h *CommandHandler) HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.M
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
 		}
 	case "batchdelete":
-		if isAdmin {
+		if requiresReview(h.conf, message.From.ID) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "审核模式下只有超级管理员可以批量删除"))
+		} else if isAdmin {
 			h.handleBatchDeleteCommand(bot, message)
 		} else {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
@@ -86,6 +96,18 @@ func (h *CommandHandler) HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.M
 		} else {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
 		}
+	case "unanswered":
+		if isAdmin {
+			h.unanswered.HandleUnansweredCommand(bot, message)
+		} else {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
+		}
+	case "stats":
+		if isAdmin {
+			h.handleStatsCommand(bot, message)
+		} else {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
+		}
 	case "reload":
 		if isAdmin {
 			h.handleReloadCommand(bot, message)
@@ -93,7 +115,9 @@ func (h *CommandHandler) HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.M
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
 		}
 	case "deleteall":
-		if isAdmin {
+		if requiresReview(h.conf, message.From.ID) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "审核模式下只有超级管理员可以删除所有条目"))
+		} else if isAdmin {
 			h.handleDeleteAllCommand(bot, message)
 		} else {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
@@ -101,25 +125,55 @@ func (h *CommandHandler) HandleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.M
 	case "commands":
 		h.handleShowCommand(bot, message)
 	case "tgtext":
-		if isAdmin {
+		if requiresReview(h.conf, message.From.ID) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, telegraphReviewText))
+		} else if isAdmin {
 			h.handleTelegraphTextCommand(bot, message)
 		} else {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
 		}
 	case "tgimage":
-		if isAdmin {
+		if requiresReview(h.conf, message.From.ID) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, telegraphReviewText))
+		} else if isAdmin {
 			h.handleTelegraphImageCommand(bot, message)
 		} else {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
 		}
+	case "pending":
+		if isSuperAdmin {
+			h.handlePendingCommand(bot, message)
+		} else {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
+		}
 	case "addadmin", "deladmin", "addgroup", "delgroup", "listadmin":
 		if isSuperAdmin {
 			h.adminHandler.HandleSuperAdminCommand(bot, message)
 		} else {
 			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
 		}
+	case "setcmd":
+		if isSuperAdmin {
+			h.handleSetCommand(bot, message)
+		} else {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
+		}
+	case "delcmd":
+		if isSuperAdmin {
+			h.handleDeleteCommand(bot, message)
+		} else {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
+		}
+	case "keys":
+		if isSuperAdmin {
+			h.handleKeysCommand(bot, message)
+		} else {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无权限"))
+		}
 	default:
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未知命令"))
+		if !h.handleCustomCommand(bot, message) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未知命令"))
+		}
 	}
 }
 
@@ -137,15 +191,25 @@ func (h *CommandHandler) handleQueryCommand(bot *tgbotapi.BotAPI, message *tgbot
 		return
 	}
 
+	// 只有完全没有命中时才记为未回答的问题，命中但无权查看的不记录
+	matched := len(results) > 0
+	results = filterVisible(h.conf, message.From.ID, message.Chat.IsPrivate(), results)
 	if len(results) == 0 {
+		if !matched {
+			recordMiss(h.db, message, args)
+		}
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到匹配结果"))
 		return
 	}
 
-	for _, result := range results {
-		msg := tgbotapi.NewMessage(message.Chat.ID, result.Value)
-		msg.ParseMode = "HTML"
-		bot.Send(msg)
+	results = matchPolicy(h.conf).Resolve(results)
+	for i := range results {
+		entry := h.translator.Localize(&results[i], message.From.LanguageCode)
+		if err := SendEntryAnswer(bot, message.Chat.ID, entry); err != nil {
+			log.Printf("Error sending answer for entry %d: %v", results[i].ID, err)
+			continue
+		}
+		recordHit(h.db, &results[i], message.Chat.ID)
 	}
 }
 
@@ -237,13 +301,25 @@ func (h *CommandHandler) sendModelsPage(bot *tgbotapi.BotAPI, chatID int64, mess
 	var allModelsList []database.ModelInfo
 	var providerMap = make(map[string]string) // 模型ID到提供商的映射
 
-	for provider, models := range allModels {
-		for _, model := range models {
+	// 只包含当前可用提供商的模型，与对话使用的提供商保持一致
+	for _, provider := range h.multichatManager.GetAvailableProviders() {
+		for _, model := range allModels[provider] {
 			allModelsList = append(allModelsList, model)
 			providerMap[model.ID] = provider
 		}
 	}
 
+	if len(allModelsList) == 0 {
+		var msg tgbotapi.Chattable
+		if messageID > 0 {
+			msg = tgbotapi.NewEditMessageText(chatID, messageID, "📄 当前可用提供商没有模型，请刷新模型列表")
+		} else {
+			msg = tgbotapi.NewMessage(chatID, "📄 当前可用提供商没有模型，请刷新模型列表")
+		}
+		bot.Send(msg)
+		return
+	}
+
 	// 分页设置
 	const modelsPerPage = 20
 	totalModels := len(allModelsList)
@@ -371,7 +447,39 @@ func (h *CommandHandler) handleReloadCommand(bot *tgbotapi.BotAPI, message *tgbo
 	} else {
 		*h.conf = *newConfig
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "配置重新加载成功"))
+
+		// 按新配置添加、更新或移除AI提供商，无需重启
+		if h.multichatManager != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, formatProviderChanges(h.multichatManager.Reload())))
+		}
+	}
+}
+
+// formatProviderChanges 生成重新加载后AI提供商变化的说明
+func formatProviderChanges(changes multichat.ProviderChanges) string {
+	if changes.Empty() {
+		return "AI提供商无变化"
+	}
+
+	var lines []string
+	if len(changes.Added) > 0 {
+		lines = append(lines, "➕ 新增："+strings.Join(changes.Added, ", "))
+	}
+	if len(changes.Updated) > 0 {
+		lines = append(lines, "🔄 更新："+strings.Join(changes.Updated, ", "))
 	}
+	if len(changes.Removed) > 0 {
+		lines = append(lines, "➖ 移除："+strings.Join(changes.Removed, ", "))
+	}
+	var failed []string
+	for name := range changes.Failed {
+		failed = append(failed, name)
+	}
+	sort.Strings(failed)
+	for _, name := range failed {
+		lines = append(lines, fmt.Sprintf("❌ %s 初始化失败：%v", name, changes.Failed[name]))
+	}
+	return "AI提供商已更新：\n" + strings.Join(lines, "\n")
 }
 
 func (h *CommandHandler) handleDeleteAllCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
@@ -404,26 +512,37 @@ func (h *CommandHandler) handleShowCommand(bot *tgbotapi.BotAPI, message *tgbota
 	if isAdmin {
 		userType = "管理员"
 		commands = append(commands, []string{
-			"/add - 添加条目",
+			"/add - 添加条目（不带参数时进入向导，回复媒体消息可添加媒体回答）",
 			"/update - 更新条目",
 			"/delete - 删除条目",
-			"/list - 列出所有条目",
-			"/reload - 重新加载数据库",
+			"/list [类型] [active|scheduled|expired] [sort:key|type|recent] [by:用户ID] [搜索词] - 列出条目",
+			"/stats - 查看条目命中统计",
+			"/unanswered - 查看未回答的问题",
+			"/reload - 重新加载数据库和配置",
 			"/deleteall - 删除所有条目",
+			"发送 .csv、.json、.md 文件 - 批量导入条目",
 		}...)
 	}
 
 	if isSuperAdmin {
 		userType = "超级管理员"
 		commands = append(commands, []string{
+			"/pending - 查看待审核的修改",
+			"/setcmd - 设置自定义命令",
+			"/delcmd - 删除自定义命令",
 			"/addadmin - 添加管理员",
 			"/deladmin - 删除管理员",
 			"/listadmin - 列出管理员",
 			"/addgroup - 添加群组",
 			"/delgroup - 删除群组",
+			"/keys - 查看AI密钥使用情况",
 		}...)
 	}
 
+	for _, command := range CustomBotCommands(h.db) {
+		commands = append(commands, fmt.Sprintf("/%s - %s", command.Command, command.Description))
+	}
+
 	response := fmt.Sprintf("用户权限：%s\n可用指令：\n%s", userType, strings.Join(commands, "\n"))
 	bot.Send(tgbotapi.NewMessage(message.Chat.ID, response))
 }
@@ -576,7 +695,7 @@ func (h *CommandHandler) handleTelegraphTextCommand(bot *tgbotapi.BotAPI, messag
 
 	// 创建 Telegraph 处理器
 	telegraphHandler := NewTelegraphHandler(h.db)
-	err := telegraphHandler.HandleTextUpload(key, matchType, title, content)
+	err := telegraphHandler.HandleTextUpload(key, matchType, title, content, message.From.ID)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("❌ 创建 Telegraph 页面失败：%v", err)))
 		return
@@ -667,17 +786,29 @@ func (h *CommandHandler) handleRetryCommand(bot *tgbotapi.BotAPI, message *tgbot
 		preferredModel = pref.ModelID
 	}
 
-	// 发送"正在思考"消息
+	// 发送"正在思考"消息，附带停止按钮
 	thinkingMsg := tgbotapi.NewMessage(chatID, "🤔 正在重新生成回复...")
+	thinkingMsg.ReplyMarkup = stopKeyboard()
 	sentMsg, err := bot.Send(thinkingMsg)
 	if err != nil {
 		log.Printf("Error sending thinking message: %v", err)
 		return
 	}
 
-	// 创建流式更新管理器
+	// 创建流式更新管理器，停止按钮通过 cancel 中断请求
+	ctx, cancel := context.WithCancel(context.Background())
 	streamKey := fmt.Sprintf("%d_%d", chatID, sentMsg.MessageID)
-	h.streamer.CreateStream(streamKey, chatID, sentMsg.MessageID)
+	h.streamer.CreateStream(streamKey, chatID, sentMsg.MessageID, message.From.ID, cancel)
+
+	// 异步生成，避免阻塞更新处理，停止按钮的回调才能及时响应
+	go h.retryWithStreaming(ctx, cancel, bot, chatID, sentMsg.MessageID, streamKey, preferredProvider, preferredModel)
+}
+
+// retryWithStreaming 流式重新生成最后一条回复
+func (h *CommandHandler) retryWithStreaming(ctx context.Context, cancel context.CancelFunc, bot *tgbotapi.BotAPI, chatID int64, messageID int, streamKey string, preferredProvider string, preferredModel string) {
+	// 清理流式管理器
+	defer h.streamer.DeleteStream(streamKey)
+	defer cancel()
 
 	// 使用回调获取AI回复（重试）
 	callback := func(content string, isComplete bool) bool {
@@ -685,15 +816,20 @@ func (h *CommandHandler) handleRetryCommand(bot *tgbotapi.BotAPI, message *tgbot
 		return true // 继续接收更新
 	}
 
-	response, inputTokens, outputTokens, duration, remainingRounds, shouldReset, usedProvider, err := h.multichatManager.GetConversationManager().RetryLastMessageWithCallback(
-		chatID, preferredProvider, preferredModel, callback,
+	response, inputTokens, outputTokens, duration, remainingRounds, shouldReset, route, err := h.multichatManager.GetConversationManager().RetryLastMessageWithCallback(
+		ctx, chatID, preferredProvider, preferredModel, callback,
 	)
 
+	// 用户点击了停止，保留已生成的内容
+	if errors.Is(err, context.Canceled) {
+		h.streamer.ShowStopped(bot, streamKey, response)
+		return
+	}
+
 	if err != nil {
 		log.Printf("Error getting retry response: %v", err)
-		editMsg := tgbotapi.NewEditMessageText(chatID, sentMsg.MessageID, "❌ 重试失败，请稍后再试")
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, "❌ 重试失败，请稍后再试")
 		bot.Send(editMsg)
-		h.streamer.DeleteStream(streamKey)
 		return
 	}
 
@@ -703,27 +839,25 @@ func (h *CommandHandler) handleRetryCommand(bot *tgbotapi.BotAPI, message *tgbot
 		OutputTokens:    outputTokens,
 		RemainingRounds: remainingRounds,
 		Duration:        duration,
-		Provider:        usedProvider,
-		Model:           preferredModel,
+		Provider:        route.Provider,
+		Model:           route.Model,
+		Route:           route,
 		TTL:             24 * time.Hour, // 默认24小时TTL
 	}
 
 	// 追加统计信息
 	h.streamer.AppendStats(bot, streamKey, stats)
 
-	// 清理流式管理器
-	defer h.streamer.DeleteStream(streamKey)
-
 	// 记录重试操作
-	log.Printf("Retry completed - Chat ID: %d, Provider: %s, Model: %s, Input tokens: %d, Output tokens: %d, Duration: %v, Remaining rounds: %d",
-		chatID, usedProvider, preferredModel, inputTokens, outputTokens, duration, remainingRounds)
+	log.Printf("Retry completed - Chat ID: %d, Route: %s, Input tokens: %d, Output tokens: %d, Duration: %v, Remaining rounds: %d",
+		chatID, route, inputTokens, outputTokens, duration, remainingRounds)
 
 	// 如果达到对话上限，发送提示
 	if shouldReset {
 		resetMsg := fmt.Sprintf("\n\n⚠️ 已达到 %d 轮对话上限，会话将重置", h.conf.Chat.HistoryLength)
 		finalResponse := response + resetMsg
 
-		editMsg := tgbotapi.NewEditMessageText(chatID, sentMsg.MessageID, finalResponse)
+		editMsg := tgbotapi.NewEditMessageText(chatID, messageID, finalResponse)
 		// 尝试MarkdownV2格式
 		editMsg.ParseMode = "MarkdownV2"
 		if _, err := bot.Send(editMsg); err != nil {
@@ -18,6 +18,11 @@ type Conversation struct {
 	TelegraphKey    string             // Telegraph 内容的键名
 	TelegraphTitle  string             // Telegraph 页面标题
 	MatchType       database.MatchType // 匹配类型
+	Language        string             // 正在编辑的翻译语言
+	Key             string             // 新条目的 key
+	MissID          int64              // 来源于未回答问题时的问题 ID
+	Draft           *entryDraft        // /add 向导中的条目内容
+	Import          *importPlan        // 等待确认的批量导入
 }
 
 // State 对话状态管理器
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+)
+
+// customCommandPattern Telegram 命令名只允许小写字母、数字和下划线，最长 32 个字符
+var customCommandPattern = regexp.MustCompile(`^[a-z0-9_]{1,32}$`)
+
+// builtinCommands 内置命令，不能被自定义命令覆盖
+var builtinCommands = map[string]bool{
+	"start": true, "query": true, "userinfo": true, "groupinfo": true, "clearchat": true,
+	"models": true, "retry": true, "add": true, "update": true, "delete": true,
+	"batchdelete": true, "list": true, "unanswered": true, "stats": true, "reload": true,
+	"deleteall": true, "commands": true, "tgtext": true, "tgimage": true, "pending": true,
+	"addadmin": true, "deladmin": true, "addgroup": true, "delgroup": true, "listadmin": true,
+	"setcmd": true, "delcmd": true, "keys": true,
+}
+
+// setCommandUsage /setcmd 用法说明
+const setCommandUsage = `格式：/setcmd 命令 条目key [| 菜单说明]
+例如：/setcmd rules 群规 | 查看群规
+命令名只能包含小写字母、数字和下划线，设置后会出现在命令菜单中；同名命令会被覆盖，使用 /delcmd 命令 删除`
+
+// CustomBotCommands 返回需要注册到 Telegram 命令菜单的自定义命令
+func CustomBotCommands(db database.Database) []tgbotapi.BotCommand {
+	commands, err := db.ListCustomCommands()
+	if err != nil {
+		log.Printf("Error listing custom commands: %v", err)
+		return nil
+	}
+	botCommands := make([]tgbotapi.BotCommand, 0, len(commands))
+	for _, command := range commands {
+		botCommands = append(botCommands, tgbotapi.BotCommand{Command: command.Command, Description: command.Description})
+	}
+	return botCommands
+}
+
+// SetCommandsChangedHook 设置自定义命令变化后的回调，用于重新注册命令菜单
+func (h *CommandHandler) SetCommandsChangedHook(hook func()) {
+	h.commandsChanged = hook
+}
+
+// handleCustomCommand 执行自定义命令，命令不存在时返回 false
+func (h *CommandHandler) handleCustomCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) bool {
+	command, err := database.FindCustomCommand(h.db, strings.ToLower(message.Command()))
+	if err != nil {
+		log.Printf("Error finding custom command %s: %v", message.Command(), err)
+		return false
+	}
+	if command == nil {
+		return false
+	}
+
+	entry, err := findEntryByKeyInTypes(h.db, command.EntryKey, command.MatchType)
+	if err != nil || entry == nil || entry.MatchType != command.MatchType || !entry.IsActive(time.Now()) ||
+		!canViewEntry(h.conf, message.From.ID, message.Chat.IsPrivate(), entry) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "该命令对应的内容暂不可用"))
+		return true
+	}
+
+	localized := h.translator.Localize(entry, message.From.LanguageCode)
+	if err := SendEntryAnswer(bot, message.Chat.ID, localized); err != nil {
+		log.Printf("Error sending answer for command %s: %v", command.Command, err)
+		return true
+	}
+	recordHit(h.db, entry, message.Chat.ID)
+	return true
+}
+
+// handleSetCommand 设置自定义命令，不带参数时列出已有的自定义命令
+func (h *CommandHandler) handleSetCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	args := strings.TrimSpace(message.CommandArguments())
+	if args == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.customCommandList()+"\n\n"+setCommandUsage))
+		return
+	}
+
+	parts := strings.SplitN(args, " ", 2)
+	if len(parts) < 2 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, setCommandUsage))
+		return
+	}
+	name := strings.ToLower(strings.TrimPrefix(parts[0], "/"))
+	if !customCommandPattern.MatchString(name) {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "命令名只能包含小写字母、数字和下划线，最长 32 个字符"))
+		return
+	}
+	if builtinCommands[name] {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("/%s 是内置命令，不能覆盖", name)))
+		return
+	}
+
+	key, description, _ := strings.Cut(parts[1], "|")
+	key, description = strings.TrimSpace(key), strings.TrimSpace(description)
+	entry, err := findEntryByKeyInTypes(h.db, key, "")
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "查询失败"))
+		return
+	}
+	if entry == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到条目："+key))
+		return
+	}
+	if description == "" {
+		description = entry.Key
+	}
+	// Telegram 命令说明最长 256 个字符
+	if runes := []rune(description); len(runes) > 256 {
+		description = string(runes[:256])
+	}
+
+	command := database.CustomCommand{
+		Command:     name,
+		Description: description,
+		EntryKey:    entry.Key,
+		MatchType:   entry.MatchType,
+		CreatedBy:   message.From.ID,
+		CreatedAt:   time.Now(),
+	}
+	if err := h.db.SetCustomCommand(command); err != nil {
+		log.Printf("Error saving custom command %s: %v", name, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "保存命令失败"))
+		return
+	}
+	h.notifyCommandsChanged()
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已设置命令 /%s → %s\n说明：%s", name, entry.Key, description)))
+}
+
+// handleDeleteCommand 删除自定义命令
+func (h *CommandHandler) handleDeleteCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	name := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(message.CommandArguments()), "/"))
+	if name == "" {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/delcmd 命令"))
+		return
+	}
+	command, err := database.FindCustomCommand(h.db, name)
+	if err != nil {
+		log.Printf("Error finding custom command %s: %v", name, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "查询失败"))
+		return
+	}
+	if command == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "没有该自定义命令"))
+		return
+	}
+	if err := h.db.DeleteCustomCommand(name); err != nil {
+		log.Printf("Error deleting custom command %s: %v", name, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "删除命令失败"))
+		return
+	}
+	h.notifyCommandsChanged()
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("已删除命令 /%s", name)))
+}
+
+// customCommandList 列出已有的自定义命令
+func (h *CommandHandler) customCommandList() string {
+	commands, err := h.db.ListCustomCommands()
+	if err != nil {
+		log.Printf("Error listing custom commands: %v", err)
+		return "无法获取自定义命令"
+	}
+	if len(commands) == 0 {
+		return "还没有自定义命令"
+	}
+	lines := []string{"自定义命令："}
+	for _, command := range commands {
+		lines = append(lines, fmt.Sprintf("/%s → %s（%s）", command.Command, command.EntryKey, command.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (h *CommandHandler) notifyCommandsChanged() {
+	if h.commandsChanged != nil {
+		h.commandsChanged()
+	}
+}
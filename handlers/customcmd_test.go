@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+// commandMessage 构造带命令实体的消息
+func commandMessage(text string, from int64, private bool) *tgbotapi.Message {
+	chatType := "group"
+	if private {
+		chatType = "private"
+	}
+	command, _, _ := strings.Cut(text, " ")
+	return &tgbotapi.Message{
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+		From:     &tgbotapi.User{ID: from},
+		Chat:     &tgbotapi.Chat{ID: 100, Type: chatType},
+	}
+}
+
+// lastSentText 返回最后一条发送的消息文本
+func lastSentText(t *testing.T, fake *fakeTelegram) string {
+	t.Helper()
+	calls := fake.calls("sendMessage")
+	if len(calls) == 0 {
+		t.Fatal("no message sent")
+	}
+	return calls[len(calls)-1].Params.Get("text")
+}
+
+func TestHandleSetCommand(t *testing.T) {
+	long := strings.Repeat("长", 300)
+
+	tests := []struct {
+		name     string
+		text     string
+		wantText string
+		// wantCommand 为空表示不应保存命令
+		wantCommand database.CustomCommand
+	}{
+		{
+			name:        "description defaults to the entry key",
+			text:        "/setcmd Rules 群规",
+			wantText:    "已设置命令 /rules → 群规",
+			wantCommand: database.CustomCommand{Command: "rules", Description: "群规", EntryKey: "群规", MatchType: database.MatchExact},
+		},
+		{
+			name:        "explicit description and leading slash",
+			text:        "/setcmd /refund 退款 | 查看退款政策",
+			wantText:    "说明：查看退款政策",
+			wantCommand: database.CustomCommand{Command: "refund", Description: "查看退款政策", EntryKey: "退款", MatchType: database.MatchContains},
+		},
+		{
+			name:        "long description is truncated",
+			text:        "/setcmd rules 群规 | " + long,
+			wantText:    "已设置命令 /rules",
+			wantCommand: database.CustomCommand{Command: "rules", Description: long[:256*len("长")], EntryKey: "群规", MatchType: database.MatchExact},
+		},
+		{name: "no arguments lists commands", text: "/setcmd", wantText: "还没有自定义命令"},
+		{name: "missing entry key", text: "/setcmd rules", wantText: "格式：/setcmd"},
+		{name: "invalid name", text: "/setcmd 群规 群规", wantText: "命令名只能包含"},
+		{name: "builtin command", text: "/setcmd start 群规", wantText: "/start 是内置命令"},
+		{name: "unknown entry", text: "/setcmd rules 不存在", wantText: "未找到条目：不存在"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := newTestDB(t)
+			if err := db.AddEntry("群规", database.MatchExact, "禁止广告"); err != nil {
+				t.Fatal(err)
+			}
+			if err := db.AddEntry("退款", database.MatchContains, "7 天内可退款"); err != nil {
+				t.Fatal(err)
+			}
+			bot, fake := newTestBot(t)
+			h := &CommandHandler{db: db, conf: &config.Config{}}
+			changed := 0
+			h.SetCommandsChangedHook(func() { changed++ })
+
+			h.handleSetCommand(bot, commandMessage(tt.text, 1, true))
+
+			if got := lastSentText(t, fake); !strings.Contains(got, tt.wantText) {
+				t.Errorf("reply = %q, want it to contain %q", got, tt.wantText)
+			}
+			commands, err := db.ListCustomCommands()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantCommand.Command == "" {
+				if len(commands) != 0 || changed != 0 {
+					t.Errorf("commands = %+v, hook called %d times, want nothing saved", commands, changed)
+				}
+				return
+			}
+			if len(commands) != 1 || changed != 1 {
+				t.Fatalf("commands = %+v, hook called %d times, want one command and one call", commands, changed)
+			}
+			got := commands[0]
+			got.CreatedAt = time.Time{}
+			tt.wantCommand.CreatedBy = 1
+			if got != tt.wantCommand {
+				t.Errorf("command = %+v, want %+v", got, tt.wantCommand)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteCommand(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.SetCustomCommand(database.CustomCommand{Command: "rules", EntryKey: "群规", MatchType: database.MatchExact, CreatedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	bot, fake := newTestBot(t)
+	h := &CommandHandler{db: db, conf: &config.Config{}}
+	changed := 0
+	h.SetCommandsChangedHook(func() { changed++ })
+
+	for _, step := range []struct {
+		text     string
+		wantText string
+	}{
+		{"/delcmd", "格式错误"},
+		{"/delcmd faq", "没有该自定义命令"},
+		{"/delcmd /Rules", "已删除命令 /rules"},
+	} {
+		h.handleDeleteCommand(bot, commandMessage(step.text, 1, true))
+		if got := lastSentText(t, fake); !strings.Contains(got, step.wantText) {
+			t.Errorf("%s: reply = %q, want it to contain %q", step.text, got, step.wantText)
+		}
+	}
+	if commands, _ := db.ListCustomCommands(); len(commands) != 0 || changed != 1 {
+		t.Errorf("commands = %+v, hook called %d times, want deleted once", commands, changed)
+	}
+}
+
+func TestHandleCustomCommand(t *testing.T) {
+	const superAdmin, userID = 1, 2
+	conf := &config.Config{Admin: config.AdminConfig{SuperAdminIDs: []int64{superAdmin}}}
+	past := time.Now().Add(-time.Hour)
+
+	db := newTestDB(t)
+	for _, entry := range []struct {
+		key  string
+		meta database.EntryMeta
+	}{
+		{key: "群规"},
+		{key: "旧活动", meta: database.EntryMeta{ValidUntil: &past}},
+		{key: "内部", meta: database.EntryMeta{Visibility: database.VisibilitySuperAdmins}},
+	} {
+		if err := db.AddEntry(entry.key, database.MatchExact, entry.key+"内容"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetEntryMeta(entry.key, database.MatchExact, entry.meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, key := range map[string]string{"rules": "群规", "promo": "旧活动", "internal": "内部", "gone": "已删除"} {
+		if err := db.SetCustomCommand(database.CustomCommand{Command: name, Description: key, EntryKey: key, MatchType: database.MatchExact, CreatedAt: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &CommandHandler{db: db, conf: conf, translator: NewTranslator(db, conf, nil)}
+
+	tests := []struct {
+		name        string
+		text        string
+		from        int64
+		private     bool
+		wantHandled bool
+		wantText    string
+	}{
+		{name: "answers with the entry", text: "/rules", from: userID, wantHandled: true, wantText: "群规内容"},
+		{name: "command name is case insensitive", text: "/RULES@faq_bot", from: userID, wantHandled: true, wantText: "群规内容"},
+		{name: "unknown command is not handled", text: "/faq", from: userID},
+		{name: "expired entry", text: "/promo", from: userID, wantHandled: true, wantText: "该命令对应的内容暂不可用"},
+		{name: "deleted entry", text: "/gone", from: userID, wantHandled: true, wantText: "该命令对应的内容暂不可用"},
+		{name: "restricted entry in a group", text: "/internal", from: superAdmin, wantHandled: true, wantText: "该命令对应的内容暂不可用"},
+		{name: "restricted entry in private", text: "/internal", from: superAdmin, private: true, wantHandled: true, wantText: "内部内容"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, fake := newTestBot(t)
+			if handled := h.handleCustomCommand(bot, commandMessage(tt.text, tt.from, tt.private)); handled != tt.wantHandled {
+				t.Fatalf("handleCustomCommand() = %v, want %v", handled, tt.wantHandled)
+			}
+			if !tt.wantHandled {
+				if calls := fake.calls("sendMessage"); len(calls) != 0 {
+					t.Errorf("sent %d messages, want none", len(calls))
+				}
+				return
+			}
+			if got := lastSentText(t, fake); got != tt.wantText {
+				t.Errorf("reply = %q, want %q", got, tt.wantText)
+			}
+		})
+	}
+
+	botCommands := CustomBotCommands(db)
+	if len(botCommands) != 4 {
+		t.Fatalf("CustomBotCommands() = %+v, want 4 commands", botCommands)
+	}
+	for _, command := range botCommands {
+		if command.Description == "" {
+			t.Errorf("command %s has no description", command.Command)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// categoryPageSize 分类列表每页显示的条目数量
+const categoryPageSize = 10
+
+// 深链接 start 参数前缀：faq_<id>_<type> 打开条目，cat_<type> 打开某一匹配类型的条目列表
+const (
+	entryLinkPrefix    = "faq_"
+	categoryLinkPrefix = "cat_"
+)
+
+// entryDeepLink 返回打开条目的深链接
+func entryDeepLink(bot *tgbotapi.BotAPI, entry *database.Entry) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d_%d", bot.Self.UserName, entryLinkPrefix, entry.ID, entry.MatchType.ToInt())
+}
+
+// entryShareRow 返回分享按钮，点击后打开 Telegram 的转发界面并附上条目的深链接
+// 未保存的条目（如预览）、非公开条目和相册不显示分享按钮
+func entryShareRow(bot *tgbotapi.BotAPI, entry *database.Entry) []tgbotapi.InlineKeyboardButton {
+	if entry.ID == 0 || bot.Self.UserName == "" || len(entry.Media) > 1 {
+		return nil
+	}
+	if entry.Visibility != "" && entry.Visibility != database.VisibilityPublic {
+		return nil
+	}
+	shareURL := "https://t.me/share/url?url=" + url.QueryEscape(entryDeepLink(bot, entry)) + "&text=" + url.QueryEscape(entry.Key)
+	return []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonURL("🔗 分享", shareURL)}
+}
+
+// parseEntryLink 解析 faq_<id>_<type> 或 faq_<id>，省略类型时按匹配顺序查找
+// 只返回处于有效期内的条目
+func parseEntryLink(db database.Database, payload string) *database.Entry {
+	parts := strings.Split(strings.TrimPrefix(payload, entryLinkPrefix), "_")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 {
+		return nil
+	}
+	matchTypes := database.DefaultMatchOrder
+	if len(parts) == 2 {
+		typeValue, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil
+		}
+		matchType, err := database.MatchTypeFromInt(typeValue)
+		if err != nil {
+			return nil
+		}
+		matchTypes = []database.MatchType{matchType}
+	}
+	for _, matchType := range matchTypes {
+		if entry, err := database.QueryByIDActive(db, id, matchType); err == nil && entry != nil {
+			return entry
+		}
+	}
+	return nil
+}
+
+// handleStartCommand 处理 /start，带深链接参数时发送对应的条目或条目列表
+func (h *CommandHandler) handleStartCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	payload := strings.TrimSpace(message.CommandArguments())
+	switch {
+	case strings.HasPrefix(payload, entryLinkPrefix):
+		entry := parseEntryLink(h.db, payload)
+		if entry == nil || !canViewEntry(h.conf, message.From.ID, message.Chat.IsPrivate(), entry) {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "该条目不存在或已失效"))
+			return
+		}
+		localized := h.translator.Localize(entry, message.From.LanguageCode)
+		if err := SendEntryAnswer(bot, message.Chat.ID, localized); err != nil {
+			log.Printf("Error sending answer for deep link %s: %v", payload, err)
+			return
+		}
+		recordHit(h.db, entry, message.Chat.ID)
+	case strings.HasPrefix(payload, categoryLinkPrefix):
+		matchType, err := utils.ParseMatchType(strings.TrimPrefix(payload, categoryLinkPrefix))
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.conf.Telegram.Introduction))
+			return
+		}
+		text, markup := renderCategory(h.db, h.conf, message.From.ID, message.Chat.IsPrivate(), matchType, 0)
+		msg := tgbotapi.NewMessage(message.Chat.ID, text)
+		if markup != nil {
+			msg.ReplyMarkup = markup
+		}
+		bot.Send(msg)
+	default:
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, h.conf.Telegram.Introduction))
+	}
+}
+
+// renderCategory 生成某一匹配类型下用户可见的条目列表，点击按钮打开条目
+func renderCategory(db database.Database, conf *config.Config, userID int64, privateChat bool, matchType database.MatchType, page int) (string, *tgbotapi.InlineKeyboardMarkup) {
+	entries, err := db.ListSpecificEntries(matchType)
+	if err != nil {
+		log.Printf("Error listing entries for category %s: %v", matchType, err)
+		return "无法获取条目列表", nil
+	}
+	now := time.Now()
+	var visible []database.Entry
+	for i := range entries {
+		if entries[i].IsActive(now) && canViewEntry(conf, userID, privateChat, &entries[i]) {
+			visible = append(visible, entries[i])
+		}
+	}
+	if len(visible) == 0 {
+		return "该分类下没有条目", nil
+	}
+	sort.SliceStable(visible, func(i, j int) bool { return visible[i].Key < visible[j].Key })
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for _, entry := range utils.Paginate(visible, page, categoryPageSize) {
+		data := fmt.Sprintf("faqopen_%d_%d", entry.ID, entry.MatchType.ToInt())
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(entry.Key, data)})
+	}
+	buttons = append(buttons, utils.BuildPaginationButtons(page, len(visible), categoryPageSize, fmt.Sprintf("faqcat_%d", matchType.ToInt()), "")...)
+
+	text := fmt.Sprintf("📂 %s（共 %d 条），点击查看：", utils.GetMatchTypeText(matchType), len(visible))
+	return text, &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+}
+
+func (h *CallbackHandler) handleCategoryCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+	// 回调数据: faqcat_<type>_<page>
+	parts := strings.Split(strings.TrimPrefix(data, "faqcat_"), "_")
+	if len(parts) != 2 {
+		log.Printf("Error parsing category callback: %s", data)
+		return
+	}
+	typeValue, err1 := strconv.Atoi(parts[0])
+	page, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		log.Printf("Error parsing category callback: %s", data)
+		return
+	}
+	matchType, err := database.MatchTypeFromInt(typeValue)
+	if err != nil {
+		log.Printf("Error parsing match type for category: %v", err)
+		return
+	}
+
+	text, markup := renderCategory(h.db, h.conf, callbackQuery.From.ID, callbackQuery.Message.Chat.IsPrivate(), matchType, page)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	editMsg.ReplyMarkup = markup
+	bot.Send(editMsg)
+}
+
+func (h *CallbackHandler) handleCategoryOpenCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64) {
+	// 回调数据: faqopen_<id>_<type>
+	entry := parseEntryLink(h.db, entryLinkPrefix+strings.TrimPrefix(data, "faqopen_"))
+	if entry == nil {
+		h.answer(bot, callbackQuery, "该条目不存在或已失效")
+		return
+	}
+	if !canViewEntry(h.conf, callbackQuery.From.ID, callbackQuery.Message.Chat.IsPrivate(), entry) {
+		h.answer(bot, callbackQuery, "无权查看该条目")
+		return
+	}
+	localized := h.translator.Localize(entry, callbackQuery.From.LanguageCode)
+	if err := SendEntryAnswer(bot, chatID, localized); err != nil {
+		log.Printf("Error sending entry %s: %v", entry.Key, err)
+		return
+	}
+	recordHit(h.db, entry, chatID)
+}
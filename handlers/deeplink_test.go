@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestParseEntryLink(t *testing.T) {
+	db := newTestDB(t)
+	past := time.Now().Add(-time.Hour)
+	for _, key := range []string{"退款", "发票"} {
+		if err := db.AddEntry(key, database.MatchExact, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.SetEntryMeta("发票", database.MatchExact, database.EntryMeta{ValidUntil: &past}); err != nil {
+		t.Fatal(err)
+	}
+	active, _ := findEntryByKeyInTypes(db, "退款", database.MatchExact)
+	expired, _ := findEntryByKeyInTypes(db, "发票", database.MatchExact)
+	exactType := database.MatchExact.ToInt()
+
+	tests := []struct {
+		name    string
+		payload string
+		want    string
+	}{
+		{name: "id and type", payload: fmt.Sprintf("faq_%d_%d", active.ID, exactType), want: "退款"},
+		{name: "id only", payload: fmt.Sprintf("faq_%d", active.ID), want: "退款"},
+		{name: "expired entry", payload: fmt.Sprintf("faq_%d_%d", expired.ID, exactType)},
+		{name: "expired entry without type", payload: fmt.Sprintf("faq_%d", expired.ID)},
+		{name: "unknown id", payload: "faq_999"},
+		{name: "invalid id", payload: "faq_abc"},
+		{name: "invalid type", payload: fmt.Sprintf("faq_%d_99", active.ID)},
+		{name: "too many parts", payload: fmt.Sprintf("faq_%d_%d_1", active.ID, exactType)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := parseEntryLink(db, tt.payload)
+			var got string
+			if entry != nil {
+				got = entry.Key
+			}
+			if got != tt.want {
+				t.Errorf("parseEntryLink(%q) = %q, want %q", tt.payload, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntryShareRow(t *testing.T) {
+	bot := &tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "faq_bot"}}
+	entry := &database.Entry{ID: 7, Key: "退款 政策", MatchType: database.MatchContains}
+
+	if got, want := entryDeepLink(bot, entry), fmt.Sprintf("https://t.me/faq_bot?start=faq_7_%d", database.MatchContains.ToInt()); got != want {
+		t.Errorf("entryDeepLink() = %q, want %q", got, want)
+	}
+
+	row := entryShareRow(bot, entry)
+	if len(row) != 1 || row[0].URL == nil {
+		t.Fatalf("entryShareRow() = %+v, want one URL button", row)
+	}
+	shareURL, err := url.Parse(*row[0].URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shareURL.Query().Get("url"); got != entryDeepLink(bot, entry) {
+		t.Errorf("shared url = %q, want the deep link", got)
+	}
+	if got := shareURL.Query().Get("text"); got != entry.Key {
+		t.Errorf("shared text = %q, want %q", got, entry.Key)
+	}
+
+	tests := []struct {
+		name  string
+		bot   *tgbotapi.BotAPI
+		entry *database.Entry
+	}{
+		{name: "unsaved entry", bot: bot, entry: &database.Entry{Key: "预览"}},
+		{name: "bot without username", bot: &tgbotapi.BotAPI{}, entry: entry},
+		{name: "album", bot: bot, entry: &database.Entry{ID: 7, EntryMeta: database.EntryMeta{Media: []database.MediaItem{{FileID: "a"}, {FileID: "b"}}}}},
+		{name: "restricted entry", bot: bot, entry: &database.Entry{ID: 7, EntryMeta: database.EntryMeta{Visibility: database.VisibilityAdmins}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if row := entryShareRow(tt.bot, tt.entry); row != nil {
+				t.Errorf("entryShareRow() = %+v, want no share button", row)
+			}
+		})
+	}
+}
+
+func TestRenderCategory(t *testing.T) {
+	const admin, userID = 1, 2
+	conf := &config.Config{Admin: config.AdminConfig{AdminIDs: []int64{admin}}}
+	past := time.Now().Add(-time.Hour)
+
+	db := newTestDB(t)
+	for i := 0; i < categoryPageSize+1; i++ {
+		if err := db.AddEntry(fmt.Sprintf("问题%02d", i), database.MatchExact, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for key, meta := range map[string]database.EntryMeta{
+		"已过期": {ValidUntil: &past},
+		"管理员": {Visibility: database.VisibilityAdmins},
+	} {
+		if err := db.AddEntry(key, database.MatchExact, "v"); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetEntryMeta(key, database.MatchExact, meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		userID      int64
+		private     bool
+		page        int
+		wantTotal   int
+		wantEntries []string
+		wantNav     []string
+	}{
+		{name: "first page", userID: userID, wantTotal: 11, wantEntries: []string{"问题00", "问题09"}, wantNav: []string{"下一页"}},
+		{name: "last page", userID: userID, page: 1, wantTotal: 11, wantEntries: []string{"问题10"}, wantNav: []string{"上一页"}},
+		{name: "admin in a group", userID: admin, wantTotal: 11, wantEntries: []string{"问题00", "问题09"}, wantNav: []string{"下一页"}},
+		{name: "admin in private sees restricted entries", userID: admin, private: true, wantTotal: 12, wantEntries: []string{"管理员", "问题08"}, wantNav: []string{"下一页"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, markup := renderCategory(db, conf, tt.userID, tt.private, database.MatchExact, tt.page)
+			if !strings.Contains(text, fmt.Sprintf("共 %d 条", tt.wantTotal)) {
+				t.Errorf("text = %q, want total %d", text, tt.wantTotal)
+			}
+			if markup == nil {
+				t.Fatal("markup = nil")
+			}
+			rows := markup.InlineKeyboard
+			entries, nav := rows[:len(rows)-1], rows[len(rows)-1]
+			if first, last := entries[0][0].Text, entries[len(entries)-1][0].Text; first != tt.wantEntries[0] || last != tt.wantEntries[len(tt.wantEntries)-1] {
+				t.Errorf("entries = %s..%s, want %v", first, last, tt.wantEntries)
+			}
+			if !strings.HasPrefix(*entries[0][0].CallbackData, "faqopen_") {
+				t.Errorf("callback data = %q, want faqopen_", *entries[0][0].CallbackData)
+			}
+			var navTexts []string
+			for _, button := range nav {
+				navTexts = append(navTexts, button.Text)
+			}
+			if strings.Join(navTexts, ",") != strings.Join(tt.wantNav, ",") {
+				t.Errorf("navigation = %v, want %v", navTexts, tt.wantNav)
+			}
+		})
+	}
+
+	if text, markup := renderCategory(db, conf, userID, false, database.MatchRegex, 0); markup != nil || text != "该分类下没有条目" {
+		t.Errorf("empty category = %q, %v", text, markup)
+	}
+}
@@ -0,0 +1,623 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// importMaxSize 允许导入的文件大小上限
+const importMaxSize = 5 << 20
+
+// importClient 下载导入文件使用的 HTTP 客户端，避免下载卡住时处理过程一直等待
+var importClient = &http.Client{Timeout: 60 * time.Second}
+
+// importProgressInterval 导入过程中更新进度消息的最小间隔
+const importProgressInterval = 2 * time.Second
+
+// importPreviewLimit 汇总中每种操作最多列出的 key 数量
+const importPreviewLimit = 5
+
+// 导入行的处理方式
+const (
+	importAdd    = "add"
+	importUpdate = "update"
+	importSkip   = "skip"
+)
+
+// importMetaPattern 匹配 Markdown 条目开头的 type: / priority: 属性行
+var importMetaPattern = regexp.MustCompile(`^(?i)(type|类型|priority|优先级)\s*[:：]\s*(.+)$`)
+
+// importRecord 从文件中读取的原始条目，Line 为 CSV/Markdown 的行号或 JSON 数组中的序号
+type importRecord struct {
+	Line     int
+	Key      string
+	Type     string
+	Value    string
+	Priority string
+}
+
+// importRow 校验通过的条目及其处理方式
+type importRow struct {
+	Line       int
+	Key        string
+	MatchType  database.MatchType
+	Value      string
+	Buttons    [][]database.EntryButton
+	HasButtons bool
+	Priority   *int
+	Action     string
+}
+
+// importError 被拒绝或写入失败的条目
+type importError struct {
+	Line   int
+	Key    string
+	Reason string
+}
+
+// importPlan 等待确认的导入内容
+type importPlan struct {
+	FileName string
+	Rows     []importRow
+	Rejected []importError
+}
+
+// count 统计指定处理方式的条目数量
+func (p *importPlan) count(action string) int {
+	n := 0
+	for _, row := range p.Rows {
+		if row.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// ImportHandler 处理管理员上传的 .csv、.json、.md 文件，批量添加或更新条目
+type ImportHandler struct {
+	db    database.Database
+	conf  *config.Config
+	state *State
+}
+
+func NewImportHandler(db database.Database, conf *config.Config, state *State) *ImportHandler {
+	return &ImportHandler{
+		db:    db,
+		conf:  conf,
+		state: state,
+	}
+}
+
+// isImportFile 检查文件扩展名是否为支持导入的格式
+func isImportFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".json", ".md":
+		return true
+	}
+	return false
+}
+
+// HandleDocument 解析上传的文件，发送导入汇总和确认按钮
+func (h *ImportHandler) HandleDocument(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	chatID := message.Chat.ID
+	document := message.Document
+	if requiresReview(h.conf, message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(chatID, "审核模式下只有超级管理员可以批量导入"))
+		return
+	}
+	if document.FileSize > importMaxSize {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("文件过大，最多支持 %d MB", importMaxSize>>20)))
+		return
+	}
+
+	data, err := downloadFile(bot, document.FileID)
+	if err != nil {
+		log.Printf("Error downloading import file: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "下载文件失败"))
+		return
+	}
+	records, err := parseImportFile(document.FileName, data)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "解析文件失败："+err.Error()+"\n\n"+importHelp))
+		return
+	}
+	if len(records) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "文件中没有条目\n\n"+importHelp))
+		return
+	}
+
+	plan, err := h.buildPlan(document.FileName, records)
+	if err != nil {
+		log.Printf("Error building import plan: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "查询失败"))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, formatImportPlan(plan))
+	pending := plan.count(importAdd) + plan.count(importUpdate)
+	if pending > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			[]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ 确认导入 %d 条", pending), "import_confirm")},
+			[]tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")},
+		)
+	}
+	sent, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Error sending import summary: %v", err)
+		return
+	}
+	if len(plan.Rejected) > 0 {
+		sendImportReport(bot, chatID, "import_rejected.csv", "被拒绝的条目", plan.Rejected)
+	}
+	if pending > 0 {
+		h.state.Set(chatID, &Conversation{
+			Stage:     "import_confirm",
+			MessageID: sent.MessageID,
+			Import:    plan,
+		})
+	}
+}
+
+// HandleConfirm 确认后写入数据库，写入过程在后台进行并定期更新进度
+func (h *ImportHandler) HandleConfirm(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery) {
+	chatID := callbackQuery.Message.Chat.ID
+	state, exists := h.state.Get(chatID)
+	if !exists || state.Stage != "import_confirm" || state.Import == nil {
+		bot.Send(tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, "导入已取消或已过期，请重新发送文件"))
+		return
+	}
+	if !IsAdminUser(callbackQuery.From.ID, h.conf) || requiresReview(h.conf, callbackQuery.From.ID) {
+		bot.Send(tgbotapi.NewMessage(chatID, "无权限"))
+		return
+	}
+	// 先清除状态，避免重复点击导致重复写入
+	h.state.Delete(chatID)
+	go h.apply(bot, chatID, state.MessageID, state.Import, callbackQuery.From.ID)
+}
+
+func (h *ImportHandler) apply(bot *tgbotapi.BotAPI, chatID int64, messageID int, plan *importPlan, userID int64) {
+	total := plan.count(importAdd) + plan.count(importUpdate)
+	var failures []importError
+	added, updated, done := 0, 0, 0
+	lastProgress := time.Now()
+
+	bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⏳ 正在导入 %s：0/%d", plan.FileName, total)))
+	for _, row := range plan.Rows {
+		if row.Action == importSkip {
+			continue
+		}
+		if err := h.applyRow(row, userID); err != nil {
+			failures = append(failures, importError{Line: row.Line, Key: row.Key, Reason: err.Error()})
+		} else if row.Action == importAdd {
+			added++
+		} else {
+			updated++
+		}
+		done++
+		if time.Since(lastProgress) >= importProgressInterval && done < total {
+			bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("⏳ 正在导入 %s：%d/%d", plan.FileName, done, total)))
+			lastProgress = time.Now()
+		}
+	}
+
+	result := fmt.Sprintf("✅ 导入完成：%s\n新增 %d 条，更新 %d 条，跳过 %d 条，拒绝 %d 条，失败 %d 条",
+		plan.FileName, added, updated, plan.count(importSkip), len(plan.Rejected), len(failures))
+	bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, result))
+	if len(failures) > 0 {
+		sendImportReport(bot, chatID, "import_failed.csv", "写入失败的条目", failures)
+	}
+}
+
+// applyRow 写入一个条目
+func (h *ImportHandler) applyRow(row importRow, userID int64) error {
+	// 按钮可以指向同一文件中先导入的条目，写入时再查找目标
+	if err := resolveButtonEntries(h.db, row.Buttons); err != nil {
+		return err
+	}
+
+	switch row.Action {
+	case importAdd:
+		if err := h.db.AddEntry(row.Key, row.MatchType, row.Value); err != nil {
+			return err
+		}
+		meta := database.EntryMeta{Buttons: row.Buttons}
+		if row.Priority != nil {
+			meta.Priority = *row.Priority
+		}
+		if !meta.IsEmpty() {
+			if err := h.db.SetEntryMeta(row.Key, row.MatchType, meta); err != nil {
+				h.db.DeleteEntry(row.Key, row.MatchType)
+				return err
+			}
+		}
+		stampEntry(h.db, row.Key, row.MatchType, userID, true)
+	case importUpdate:
+		if err := h.db.UpdateEntry(row.Key, row.MatchType, row.MatchType, row.Value); err != nil {
+			return err
+		}
+		stampEntry(h.db, row.Key, row.MatchType, userID, false)
+		if !row.HasButtons && row.Priority == nil {
+			return nil
+		}
+		return h.db.UpdateEntryMeta(row.Key, row.MatchType, func(meta *database.EntryMeta) {
+			if row.HasButtons {
+				meta.Buttons = row.Buttons
+			}
+			if row.Priority != nil {
+				meta.Priority = *row.Priority
+			}
+		})
+	}
+	return nil
+}
+
+// buildPlan 校验条目并与数据库中的已有条目比较，确定每个条目的处理方式
+func (h *ImportHandler) buildPlan(fileName string, records []importRecord) (*importPlan, error) {
+	entries, err := h.db.ListAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]*database.Entry)
+	for i := range entries {
+		existing[string(entries[i].MatchType)+"\x00"+entries[i].Key] = &entries[i]
+	}
+
+	byKey := make(map[string]*database.Entry)
+	for i := range entries {
+		if _, ok := byKey[entries[i].Key]; !ok {
+			byKey[entries[i].Key] = &entries[i]
+		}
+	}
+
+	plan := &importPlan{FileName: fileName}
+	seen := make(map[string]int)
+	var rows []importRow
+	fileKeys := make(map[string]bool)
+	for _, record := range records {
+		row, err := validateImportRecord(record)
+		if err != nil {
+			plan.Rejected = append(plan.Rejected, importError{Line: record.Line, Key: record.Key, Reason: err.Error()})
+			continue
+		}
+		id := string(row.MatchType) + "\x00" + row.Key
+		if line, ok := seen[id]; ok {
+			plan.Rejected = append(plan.Rejected, importError{Line: row.Line, Key: row.Key, Reason: fmt.Sprintf("与第 %d 行重复", line)})
+			continue
+		}
+		seen[id] = row.Line
+		rows = append(rows, row)
+		fileKeys[row.Key] = true
+	}
+
+	for _, row := range rows {
+		if err := resolveImportButtons(row.Buttons, byKey, fileKeys); err != nil {
+			plan.Rejected = append(plan.Rejected, importError{Line: row.Line, Key: row.Key, Reason: err.Error()})
+			continue
+		}
+		id := string(row.MatchType) + "\x00" + row.Key
+		row.Action = importAdd
+		if entry, ok := existing[id]; ok {
+			row.Action = importUpdate
+			if entry.Value == row.Value &&
+				(!row.HasButtons || reflect.DeepEqual(entry.Buttons, row.Buttons)) &&
+				(row.Priority == nil || entry.Priority == *row.Priority) {
+				row.Action = importSkip
+			}
+		}
+		plan.Rows = append(plan.Rows, row)
+	}
+	return plan, nil
+}
+
+// resolveImportButtons 检查相关条目按钮的目标，byKey 为数据库中的条目
+// 目标只在同一文件中时留到写入时解析，两处都没有时返回错误
+func resolveImportButtons(rows [][]database.EntryButton, byKey map[string]*database.Entry, fileKeys map[string]bool) error {
+	for i := range rows {
+		for j := range rows[i] {
+			button := &rows[i][j]
+			if button.Type != database.ButtonEntry {
+				continue
+			}
+			if target, ok := byKey[button.EntryKey]; ok {
+				button.EntryType = target.MatchType
+				continue
+			}
+			if !fileKeys[button.EntryKey] {
+				return fmt.Errorf("按钮「%s」指向的条目不存在：%s", button.Text, button.EntryKey)
+			}
+		}
+	}
+	return nil
+}
+
+// validateImportRecord 校验条目，匹配类型为空时使用精确匹配
+func validateImportRecord(record importRecord) (importRow, error) {
+	row := importRow{Line: record.Line, Key: strings.TrimSpace(record.Key), MatchType: database.MatchExact}
+	if row.Key == "" {
+		return row, fmt.Errorf("key 为空")
+	}
+	if typeName := strings.TrimSpace(record.Type); typeName != "" {
+		matchType, err := utils.ParseMatchType(typeName)
+		if err != nil {
+			return row, fmt.Errorf("匹配类型错误：%s", typeName)
+		}
+		row.MatchType = matchType
+	}
+	if err := checkWizardKey(row.Key, row.MatchType); err != nil {
+		return row, err
+	}
+
+	value, buttons, hasButtons, err := splitButtonLayout(strings.TrimSpace(record.Value))
+	if err != nil {
+		return row, err
+	}
+	if value == "" {
+		return row, fmt.Errorf("内容为空")
+	}
+	row.Value, row.Buttons, row.HasButtons = value, buttons, hasButtons
+
+	if priority := strings.TrimSpace(record.Priority); priority != "" {
+		n, err := strconv.Atoi(priority)
+		if err != nil {
+			return row, fmt.Errorf("优先级不是整数：%s", priority)
+		}
+		row.Priority = &n
+	}
+	return row, nil
+}
+
+// importHelp 导入文件格式说明
+const importHelp = `支持的文件格式：
+• .csv：每行 key,type,value[,priority]，首行为 key 开头的表头时按表头识别列
+• .json：对象数组，字段为 key、type（或 match_type）、value、priority
+• .md：每个 "## key" 标题开始一个条目，之后可选 "type: 类型"、"priority: 数字" 行，其余为内容
+type 为空时使用 exact，内容末尾可按 /add 的格式定义按钮`
+
+// parseImportFile 按扩展名解析文件
+func parseImportFile(name string, data []byte) ([]importRecord, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		return parseImportCSV(data)
+	case ".json":
+		return parseImportJSON(data)
+	case ".md":
+		return parseImportMarkdown(data)
+	default:
+		return nil, fmt.Errorf("不支持的文件格式：%s", filepath.Ext(name))
+	}
+}
+
+func parseImportCSV(data []byte) ([]importRecord, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	columns := map[string]int{"key": 0, "type": 1, "value": 2, "priority": 3}
+	var records []importRecord
+	first := true
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		if first {
+			first = false
+			if strings.EqualFold(strings.TrimSpace(fields[0]), "key") {
+				columns = make(map[string]int)
+				for i, field := range fields {
+					name := strings.ToLower(strings.TrimSpace(field))
+					if name == "match_type" {
+						name = "type"
+					}
+					columns[name] = i
+				}
+				if _, ok := columns["value"]; !ok {
+					return nil, fmt.Errorf("表头缺少 value 列")
+				}
+				continue
+			}
+		}
+
+		column := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(fields) {
+				return fields[i]
+			}
+			return ""
+		}
+		records = append(records, importRecord{
+			Line:     line,
+			Key:      column("key"),
+			Type:     column("type"),
+			Value:    column("value"),
+			Priority: column("priority"),
+		})
+	}
+	return records, nil
+}
+
+func parseImportJSON(data []byte) ([]importRecord, error) {
+	var items []struct {
+		Key       string          `json:"key"`
+		Type      string          `json:"type"`
+		MatchType string          `json:"match_type"`
+		Value     string          `json:"value"`
+		Priority  json.RawMessage `json:"priority"`
+	}
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, err
+	}
+
+	records := make([]importRecord, 0, len(items))
+	for i, item := range items {
+		typeName := item.Type
+		if typeName == "" {
+			typeName = item.MatchType
+		}
+		// priority 可以是数字或字符串
+		priority := strings.Trim(string(item.Priority), `"`)
+		if priority == "null" {
+			priority = ""
+		}
+		records = append(records, importRecord{
+			Line:     i + 1,
+			Key:      item.Key,
+			Type:     typeName,
+			Value:    item.Value,
+			Priority: priority,
+		})
+	}
+	return records, nil
+}
+
+func parseImportMarkdown(data []byte) ([]importRecord, error) {
+	var records []importRecord
+	var current *importRecord
+	var value []string
+	inMeta := false
+
+	flush := func() {
+		if current != nil {
+			current.Value = strings.TrimSpace(strings.Join(value, "\n"))
+			records = append(records, *current)
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), importMaxSize)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if strings.HasPrefix(text, "## ") {
+			flush()
+			current = &importRecord{Line: line, Key: strings.TrimSpace(strings.TrimPrefix(text, "## "))}
+			value = nil
+			inMeta = true
+			continue
+		}
+		// 第一个条目之前的内容（如文档标题）忽略
+		if current == nil {
+			continue
+		}
+		if inMeta {
+			if strings.TrimSpace(text) == "" && len(value) == 0 {
+				continue
+			}
+			if match := importMetaPattern.FindStringSubmatch(strings.TrimSpace(text)); match != nil {
+				switch strings.ToLower(match[1]) {
+				case "type", "类型":
+					current.Type = match[2]
+				default:
+					current.Priority = match[2]
+				}
+				continue
+			}
+			inMeta = false
+		}
+		value = append(value, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return records, nil
+}
+
+// formatImportPlan 生成导入汇总
+func formatImportPlan(plan *importPlan) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📥 导入 %s\n新增 %d 条，更新 %d 条，跳过 %d 条（内容相同），拒绝 %d 条\n",
+		plan.FileName, plan.count(importAdd), plan.count(importUpdate), plan.count(importSkip), len(plan.Rejected)))
+
+	for _, section := range []struct {
+		action string
+		title  string
+	}{{importAdd, "新增"}, {importUpdate, "更新"}} {
+		var keys []string
+		for _, row := range plan.Rows {
+			if row.Action == section.action {
+				keys = append(keys, fmt.Sprintf("• %s(%s)", row.Key, utils.GetMatchTypeText(row.MatchType)))
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		b.WriteString("\n" + section.title + "：\n")
+		if len(keys) > importPreviewLimit {
+			keys = append(keys[:importPreviewLimit], fmt.Sprintf("... 还有 %d 条", len(keys)-importPreviewLimit))
+		}
+		b.WriteString(strings.Join(keys, "\n") + "\n")
+	}
+
+	if len(plan.Rejected) > 0 {
+		b.WriteString("\n被拒绝的条目见错误报告")
+	}
+	if plan.count(importAdd)+plan.count(importUpdate) == 0 {
+		b.WriteString("\n没有需要写入的条目")
+	}
+	return b.String()
+}
+
+// sendImportReport 以 CSV 文件发送错误报告
+func sendImportReport(bot *tgbotapi.BotAPI, chatID int64, fileName, title string, rows []importError) {
+	var buf bytes.Buffer
+	// 带 BOM 便于 Excel 正确识别中文
+	buf.WriteString("\xef\xbb\xbf")
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"行号", "key", "原因"})
+	for _, row := range rows {
+		writer.Write([]string{strconv.Itoa(row.Line), row.Key, row.Reason})
+	}
+	writer.Flush()
+
+	document := tgbotapi.NewDocument(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: buf.Bytes()})
+	document.Caption = fmt.Sprintf("%s：%d 条", title, len(rows))
+	if _, err := bot.Send(document); err != nil {
+		log.Printf("Error sending import report: %v", err)
+	}
+}
+
+// downloadFile 下载 Telegram 中的文件
+func downloadFile(bot *tgbotapi.BotAPI, fileID string) ([]byte, error) {
+	file, err := bot.GetFile(tgbotapi.FileConfig{FileID: fileID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file info: %w", err)
+	}
+	resp, err := importClient.Get(file.Link(bot.Token))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download file: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, importMaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	if len(data) > importMaxSize {
+		return nil, fmt.Errorf("file exceeds %d bytes", importMaxSize)
+	}
+	return data, nil
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"TGFaqBot/database"
+)
+
+// newTestDB 创建临时目录中的 JSON 数据库
+func newTestDB(t *testing.T) database.Database {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "faq.json")
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	db, err := database.NewJSONDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestParseImportFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		data    string
+		want    []importRecord
+		wantErr bool
+	}{
+		{
+			name: "csv without header",
+			file: "faq.csv",
+			data: "退款,exact,7 天内可退款\n营业时间,contains,9:00-18:00,2\n",
+			want: []importRecord{
+				{Line: 1, Key: "退款", Type: "exact", Value: "7 天内可退款"},
+				{Line: 2, Key: "营业时间", Type: "contains", Value: "9:00-18:00", Priority: "2"},
+			},
+		},
+		{
+			name: "csv header with bom and reordered columns",
+			file: "FAQ.CSV",
+			data: "\xef\xbb\xbfKey,value,match_type\n退款,\"多行\n内容\",regex\n",
+			want: []importRecord{{Line: 2, Key: "退款", Type: "regex", Value: "多行\n内容"}},
+		},
+		{
+			name:    "csv header without value column",
+			file:    "faq.csv",
+			data:    "key,type\n退款,exact\n",
+			wantErr: true,
+		},
+		{
+			name: "json with type, match_type and numeric or string priority",
+			file: "faq.json",
+			data: `[{"key":"退款","type":"exact","value":"v1","priority":3},{"key":"发票","match_type":"contains","value":"v2","priority":"-1"},{"key":"地址","value":"v3","priority":null}]`,
+			want: []importRecord{
+				{Line: 1, Key: "退款", Type: "exact", Value: "v1", Priority: "3"},
+				{Line: 2, Key: "发票", Type: "contains", Value: "v2", Priority: "-1"},
+				{Line: 3, Key: "地址", Value: "v3"},
+			},
+		},
+		{
+			name:    "json that is not an array",
+			file:    "faq.json",
+			data:    `{"key":"退款"}`,
+			wantErr: true,
+		},
+		{
+			name: "markdown with meta lines",
+			file: "faq.md",
+			data: "# 常见问题\n\n## 退款\ntype: contains\n优先级：2\n\n7 天内可退款\n\n第二段\n## 发票\n类型: exact\n开具发票\n",
+			want: []importRecord{
+				{Line: 3, Key: "退款", Type: "contains", Priority: "2", Value: "7 天内可退款\n\n第二段"},
+				{Line: 10, Key: "发票", Type: "exact", Value: "开具发票"},
+			},
+		},
+		{
+			name: "markdown meta only at the start of an entry",
+			file: "faq.md",
+			data: "## 退款\n说明\ntype: regex\n",
+			want: []importRecord{{Line: 1, Key: "退款", Value: "说明\ntype: regex"}},
+		},
+		{
+			name:    "unsupported extension",
+			file:    "faq.txt",
+			data:    "退款",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImportFile(tt.file, []byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseImportFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseImportFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	db := newTestDB(t)
+	for _, entry := range []struct {
+		key   string
+		value string
+	}{{"退款", "7 天内可退款"}, {"发票", "旧内容"}} {
+		if err := db.AddEntry(entry.key, database.MatchExact, entry.value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewImportHandler(db, nil, nil)
+
+	records := []importRecord{
+		{Line: 1, Key: "退款", Type: "exact", Value: "7 天内可退款"},
+		{Line: 2, Key: "发票", Type: "exact", Value: "新内容"},
+		{Line: 3, Key: "地址", Value: "上海\n---\n[退款](entry:退款) | [营业时间](entry:营业时间)"},
+		{Line: 4, Key: "营业时间", Type: "contains", Value: "9:00-18:00"},
+		{Line: 5, Key: "退款", Type: "exact", Value: "重复"},
+		{Line: 6, Key: "", Value: "没有 key"},
+		{Line: 7, Key: "([", Type: "regex", Value: "无效正则"},
+		{Line: 8, Key: "电话", Value: "v", Priority: "高"},
+		{Line: 9, Key: "邮箱", Value: "v\n---\n[不存在](entry:不存在)"},
+		{Line: 10, Key: "退款", Type: "exact", Value: "7 天内可退款", Priority: "1"},
+	}
+	// 第 10 行与第 1 行重复，被拒绝
+	plan, err := h.buildPlan("faq.csv", records)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wantActions := map[int]string{1: importSkip, 2: importUpdate, 3: importAdd, 4: importAdd}
+	if len(plan.Rows) != len(wantActions) {
+		t.Fatalf("plan has %d rows, want %d: %+v", len(plan.Rows), len(wantActions), plan.Rows)
+	}
+	for _, row := range plan.Rows {
+		if want := wantActions[row.Line]; row.Action != want {
+			t.Errorf("line %d action = %s, want %s", row.Line, row.Action, want)
+		}
+	}
+
+	var rejected []int
+	for _, r := range plan.Rejected {
+		rejected = append(rejected, r.Line)
+	}
+	if want := []int{5, 6, 7, 8, 10, 9}; !reflect.DeepEqual(rejected, want) {
+		t.Errorf("rejected lines = %v, want %v", rejected, want)
+	}
+
+	// 已有条目的按钮在预览时解析，同一文件中的目标留到写入时解析
+	buttons := plan.Rows[2].Buttons[0]
+	if buttons[0].EntryType != database.MatchExact || buttons[1].EntryType != "" {
+		t.Errorf("button types = %q, %q, want exact and unresolved", buttons[0].EntryType, buttons[1].EntryType)
+	}
+}
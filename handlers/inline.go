@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/multichat"
+)
+
+// inlinePageSize 每次返回的内联结果数量，Telegram 上限为 50
+const inlinePageSize = 20
+
+// inlineCacheTTL 内联查询结果在本地缓存的时间，翻页时直接使用缓存
+const inlineCacheTTL = 30 * time.Second
+
+// inlineCacheTime Telegram 端缓存内联结果的秒数
+const inlineCacheTime = 30
+
+// inlineDescriptionLength 结果描述的最大字符数
+const inlineDescriptionLength = 100
+
+// htmlTagPattern 匹配 HTML 标签，生成结果描述时去掉
+var htmlTagPattern = regexp.MustCompile(`<[^>]+>`)
+
+type inlineCacheEntry struct {
+	entries []database.Entry
+	expires time.Time
+}
+
+// InlineHandler 处理 @bot 关键词 形式的内联查询
+// 内联结果可能被发送到任何聊天，因此只返回公开条目，结果对所有用户相同
+type InlineHandler struct {
+	db         database.Database
+	conf       *config.Config
+	translator *Translator
+	cache      map[string]inlineCacheEntry
+	mutex      sync.Mutex
+}
+
+func NewInlineHandler(db database.Database, conf *config.Config, multichatMgr *multichat.Manager) *InlineHandler {
+	return &InlineHandler{
+		db:         db,
+		conf:       conf,
+		translator: NewTranslator(db, conf, multichatMgr),
+		cache:      make(map[string]inlineCacheEntry),
+	}
+}
+
+// HandleInlineQuery 返回排序后的条目，offset 为已返回的结果数量
+func (h *InlineHandler) HandleInlineQuery(bot *tgbotapi.BotAPI, query *tgbotapi.InlineQuery) {
+	offset, _ := strconv.Atoi(query.Offset)
+	if offset < 0 {
+		offset = 0
+	}
+
+	entries, err := h.search(query.Query)
+	if err != nil {
+		log.Printf("Error searching inline query %q: %v", query.Query, err)
+		entries = nil
+	}
+
+	var results []interface{}
+	end := offset + inlinePageSize
+	if end > len(entries) {
+		end = len(entries)
+	}
+	for i := offset; i < end; i++ {
+		// 只使用已有的翻译，避免内联查询等待 AI 翻译超时
+		results = append(results, inlineArticle(bot, h.translator.localizeStored(&entries[i], query.From.LanguageCode)))
+	}
+
+	answer := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+	}
+	if end < len(entries) {
+		answer.NextOffset = strconv.Itoa(end)
+	}
+	if _, err := bot.Request(answer); err != nil {
+		log.Printf("Error answering inline query: %v", err)
+	}
+}
+
+// search 返回与关键词相关的公开条目：先是按匹配策略排序的命中条目，
+// 再是 key 或内容包含关键词的条目，关键词为空时返回全部条目
+func (h *InlineHandler) search(text string) ([]database.Entry, error) {
+	text = strings.TrimSpace(text)
+	cacheKey := strings.ToLower(text)
+	if entries, ok := h.cached(cacheKey); ok {
+		return entries, nil
+	}
+
+	var matched []database.Entry
+	if text != "" {
+		results, err := h.db.Query(text)
+		if err != nil {
+			return nil, err
+		}
+		matched = filterVisible(h.conf, 0, false, results)
+		policy := matchPolicy(h.conf)
+		sort.SliceStable(matched, func(i, j int) bool { return policy.Less(&matched[i], &matched[j]) })
+	}
+
+	all, err := h.db.ListAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, entry := range matched {
+		seen[fmt.Sprintf("%s_%d", entry.MatchType, entry.ID)] = true
+	}
+	var related []database.Entry
+	now := time.Now()
+	for i := range all {
+		entry := &all[i]
+		if seen[fmt.Sprintf("%s_%d", entry.MatchType, entry.ID)] || !entry.IsActive(now) ||
+			!canViewEntry(h.conf, 0, false, entry) || !matchesSearch(entry, text) {
+			continue
+		}
+		related = append(related, *entry)
+	}
+	sort.SliceStable(related, func(i, j int) bool {
+		if related[i].Priority != related[j].Priority {
+			return related[i].Priority > related[j].Priority
+		}
+		return related[i].Key < related[j].Key
+	})
+
+	entries := append(matched, related...)
+	h.store(cacheKey, entries)
+	return entries, nil
+}
+
+func (h *InlineHandler) cached(key string) ([]database.Entry, bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	cached, ok := h.cache[key]
+	if !ok || time.Now().After(cached.expires) {
+		return nil, false
+	}
+	return cached.entries, true
+}
+
+// store 缓存查询结果，同时清理过期的缓存
+func (h *InlineHandler) store(key string, entries []database.Entry) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	now := time.Now()
+	for k, cached := range h.cache {
+		if now.After(cached.expires) {
+			delete(h.cache, k)
+		}
+	}
+	h.cache[key] = inlineCacheEntry{entries: entries, expires: now.Add(inlineCacheTTL)}
+}
+
+// inlineArticle 将条目转换为内联结果，媒体回答和过长的回答发送深链接
+func inlineArticle(bot *tgbotapi.BotAPI, entry *database.Entry) tgbotapi.InlineQueryResultArticle {
+	id := fmt.Sprintf("%d_%d", entry.MatchType.ToInt(), entry.ID)
+	text, parseMode := entryAnswerText(entry)
+	if entry.HasMedia() || len([]rune(text)) > 4096 {
+		text, parseMode = fmt.Sprintf("%s\n%s", html.EscapeString(entry.Key), entryDeepLink(bot, entry)), "HTML"
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticle(id, entry.Key, text)
+	article.InputMessageContent = tgbotapi.InputTextMessageContent{Text: text, ParseMode: parseMode}
+	article.Description = inlineDescription(entry)
+	article.ReplyMarkup = inlineKeyboard(bot, entry)
+	return article
+}
+
+// inlineDescription 返回去掉 HTML 标签后的回答摘要
+func inlineDescription(entry *database.Entry) string {
+	if entry.HasMedia() {
+		description := describeMedia(entry.EntryMeta)
+		if entry.Caption != "" {
+			description += " " + entry.Caption
+		}
+		return description
+	}
+	text := html.UnescapeString(htmlTagPattern.ReplaceAllString(entry.Value, ""))
+	text = strings.Join(strings.Fields(text), " ")
+	if runes := []rune(text); len(runes) > inlineDescriptionLength {
+		text = string(runes[:inlineDescriptionLength]) + "…"
+	}
+	return text
+}
+
+// inlineKeyboard 返回条目按钮中的链接按钮
+// 内联消息的回调不带原消息，回调类按钮（如相关条目）无法处理，因此去掉
+func inlineKeyboard(bot *tgbotapi.BotAPI, entry *database.Entry) *tgbotapi.InlineKeyboardMarkup {
+	keyboard := buildEntryKeyboard(bot, entry)
+	if keyboard == nil {
+		return nil
+	}
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, row := range keyboard.InlineKeyboard {
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, button := range row {
+			if button.URL != nil {
+				buttons = append(buttons, button)
+			}
+		}
+		if len(buttons) > 0 {
+			rows = append(rows, buttons)
+		}
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: rows}
+}
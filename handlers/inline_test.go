@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestInlineSearch(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	db := newTestDB(t)
+	for _, entry := range []struct {
+		key       string
+		matchType database.MatchType
+		value     string
+		meta      database.EntryMeta
+	}{
+		{key: "退", matchType: database.MatchContains, value: "退换货说明"},
+		{key: "退款", matchType: database.MatchExact, value: "7 天内可退款"},
+		{key: "发票", matchType: database.MatchExact, value: "开票后不支持退款"},
+		{key: "营业时间", matchType: database.MatchExact, value: "9:00-18:00", meta: database.EntryMeta{Priority: 1}},
+		{key: "退款流程", matchType: database.MatchExact, value: "内部流程", meta: database.EntryMeta{Visibility: database.VisibilityAdmins}},
+		{key: "旧退款政策", matchType: database.MatchExact, value: "已过期", meta: database.EntryMeta{ValidUntil: &past}},
+	} {
+		if err := db.AddEntry(entry.key, entry.matchType, entry.value); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.SetEntryMeta(entry.key, entry.matchType, entry.meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewInlineHandler(db, &config.Config{}, nil)
+
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		// 命中的条目按匹配策略排在前面，随后是内容提到关键词的条目；非公开和过期的条目不返回
+		{name: "matched entries come first", text: "退款", want: []string{"退款", "退", "发票"}},
+		{name: "empty query lists public entries by priority and key", text: " ", want: []string{"营业时间", "发票", "退", "退款"}},
+		{name: "no results", text: "地址"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := h.search(tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, entry := range entries {
+				keys = append(keys, entry.Key)
+			}
+			if strings.Join(keys, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("search(%q) = %v, want %v", tt.text, keys, tt.want)
+			}
+		})
+	}
+
+	// 缓存期内相同的关键词（忽略大小写）直接返回缓存的结果
+	if err := db.AddEntry("发货", database.MatchExact, "退款后不再发货"); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := h.search("退款"); len(entries) != 3 {
+		t.Errorf("cached search returned %d entries, want 3", len(entries))
+	}
+	h.mutex.Lock()
+	for key, cached := range h.cache {
+		cached.expires = time.Now().Add(-time.Second)
+		h.cache[key] = cached
+	}
+	h.mutex.Unlock()
+	if entries, _ := h.search("退款"); len(entries) != 4 {
+		t.Errorf("search after cache expiry returned %d entries, want 4", len(entries))
+	}
+}
+
+func TestHandleInlineQueryPaging(t *testing.T) {
+	db := newTestDB(t)
+	for i := 0; i < inlinePageSize+5; i++ {
+		if err := db.AddEntry(fmt.Sprintf("问题%02d", i), database.MatchExact, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewInlineHandler(db, &config.Config{}, nil)
+
+	tests := []struct {
+		offset         string
+		wantResults    int
+		wantNextOffset string
+	}{
+		{offset: "", wantResults: inlinePageSize, wantNextOffset: fmt.Sprint(inlinePageSize)},
+		{offset: fmt.Sprint(inlinePageSize), wantResults: 5},
+		{offset: "-3", wantResults: inlinePageSize, wantNextOffset: fmt.Sprint(inlinePageSize)},
+		{offset: "100", wantResults: 0},
+	}
+	for _, tt := range tests {
+		t.Run("offset "+tt.offset, func(t *testing.T) {
+			bot, fake := newTestBot(t)
+			h.HandleInlineQuery(bot, &tgbotapi.InlineQuery{ID: "q", From: &tgbotapi.User{ID: 1}, Query: "问题", Offset: tt.offset})
+
+			calls := fake.calls("answerInlineQuery")
+			if len(calls) != 1 {
+				t.Fatalf("answerInlineQuery called %d times, want 1", len(calls))
+			}
+			var results []map[string]interface{}
+			if raw := calls[0].Params.Get("results"); raw != "" && raw != "null" {
+				if err := json.Unmarshal([]byte(raw), &results); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(results) != tt.wantResults {
+				t.Errorf("got %d results, want %d", len(results), tt.wantResults)
+			}
+			if got := calls[0].Params.Get("next_offset"); got != tt.wantNextOffset {
+				t.Errorf("next_offset = %q, want %q", got, tt.wantNextOffset)
+			}
+		})
+	}
+}
+
+func TestInlineArticle(t *testing.T) {
+	bot := &tgbotapi.BotAPI{Self: tgbotapi.User{UserName: "faq_bot"}}
+
+	tests := []struct {
+		name            string
+		entry           *database.Entry
+		wantText        string
+		wantDescription string
+	}{
+		{
+			name:            "text answer with html",
+			entry:           &database.Entry{ID: 1, Key: "退款", MatchType: database.MatchExact, Value: "<b>7 天</b>内\n可退款 &amp; 换货"},
+			wantText:        "<b>7 天</b>内\n可退款 &amp; 换货",
+			wantDescription: "7 天内 可退款 & 换货",
+		},
+		{
+			name:            "long description is truncated",
+			entry:           &database.Entry{ID: 2, Key: "长", MatchType: database.MatchExact, Value: strings.Repeat("长", inlineDescriptionLength+1)},
+			wantText:        strings.Repeat("长", inlineDescriptionLength+1),
+			wantDescription: strings.Repeat("长", inlineDescriptionLength) + "…",
+		},
+		{
+			name:     "long answer is sent as a deep link",
+			entry:    &database.Entry{ID: 3, Key: "<条款>", MatchType: database.MatchExact, Value: strings.Repeat("a", 4097)},
+			wantText: fmt.Sprintf("&lt;条款&gt;\nhttps://t.me/faq_bot?start=faq_3_%d", database.MatchExact.ToInt()),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			article := inlineArticle(bot, tt.entry)
+			content, ok := article.InputMessageContent.(tgbotapi.InputTextMessageContent)
+			if !ok {
+				t.Fatalf("content = %T, want text content", article.InputMessageContent)
+			}
+			if content.Text != tt.wantText {
+				t.Errorf("text = %q, want %q", content.Text, tt.wantText)
+			}
+			if tt.wantDescription != "" && article.Description != tt.wantDescription {
+				t.Errorf("description = %q, want %q", article.Description, tt.wantDescription)
+			}
+		})
+	}
+}
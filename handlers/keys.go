@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleKeysCommand 显示各AI提供商每个API密钥的使用情况，仅超级管理员可用
+func (h *CommandHandler) handleKeysCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	usage := h.multichatManager.GetKeyUsage()
+	if len(usage) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "当前没有启用的AI提供商"))
+		return
+	}
+
+	var providers []string
+	for providerName := range usage {
+		providers = append(providers, providerName)
+	}
+	sort.Strings(providers)
+
+	now := time.Now()
+	var sections []string
+	for _, providerName := range providers {
+		rotation := "round_robin"
+		if providerConfig := h.multichatManager.GetProviderConfig(providerName); providerConfig != nil && providerConfig.KeyRotation != "" {
+			rotation = providerConfig.KeyRotation
+		}
+
+		lines := []string{fmt.Sprintf("%s（%s）", providerName, rotation)}
+		if len(usage[providerName]) == 0 {
+			lines = append(lines, "• 未配置密钥")
+		}
+		for _, key := range usage[providerName] {
+			line := fmt.Sprintf("• %s：请求 %d，失败 %d，限流 %d", key.Key, key.Requests, key.Failures, key.RateLimited)
+			if remaining := key.CooldownUntil.Sub(now); remaining > 0 {
+				line += fmt.Sprintf("，冷却中（剩余 %d 秒）", int(remaining.Seconds())+1)
+			} else if !key.LastRateLimited.IsZero() {
+				line += fmt.Sprintf("，上次限流 %s", key.LastRateLimited.Format("01-02 15:04:05"))
+			}
+			lines = append(lines, line)
+		}
+		sections = append(sections, strings.Join(lines, "\n"))
+	}
+
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, "🔑 API密钥使用情况（自启动以来）\n\n"+strings.Join(sections, "\n\n")))
+}
@@ -3,115 +3,362 @@ package handlers
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
+	"TGFaqBot/config"
 	"TGFaqBot/database"
 	"TGFaqBot/utils"
 )
 
 type ListHandler struct {
-	db    database.Database
-	state *State
+	db      database.Database
+	conf    *config.Config
+	state   *State
+	filters *listFilters
 }
 
-func NewListHandler(db database.Database, state *State) *ListHandler {
+func NewListHandler(db database.Database, conf *config.Config, state *State) *ListHandler {
 	return &ListHandler{
-		db:    db,
-		state: state,
+		db:      db,
+		conf:    conf,
+		state:   state,
+		filters: newListFilters(),
 	}
 }
 
-func (h *ListHandler) HandleListCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, page int) {
-	args := message.CommandArguments()
-	var matchTypes []database.MatchType
-	if args != "" {
-		typeStrings := strings.Split(args, " ")
-		for _, typeString := range typeStrings {
-			matchType, err := utils.ParseMatchType(typeString)
+// listPageSize /list 每页显示的条目数量
+const listPageSize = 8
+
+// listJumpStep 跳页按钮每次跳过的页数
+const listJumpStep = 10
+
+// listUsage /list 参数说明
+const listUsage = `/list 支持以下参数，可以组合使用：
+• exact, contains, regex, prefix, suffix：按匹配类型筛选
+• active, scheduled, expired：按有效期状态筛选
+• by:用户ID 或 by:me：只显示该用户创建或最后修改的条目
+• sort:key、sort:type、sort:recent（或 recent）：排序方式
+• 其他文字：搜索 key 和内容，例如 /list contains 退款`
+
+// 列表排序方式
+const (
+	listSortKey    = "key"
+	listSortType   = "type"
+	listSortRecent = "recent"
+)
+
+// listFilterTTL 列表消息的筛选条件保留时间，过期后翻页显示全部条目
+const listFilterTTL = 24 * time.Hour
+
+// listFilter /list 的筛选条件，按列表消息保存，翻页和返回列表时沿用
+type listFilter struct {
+	MatchTypes []database.MatchType
+	Statuses   []string
+	Authors    []int64
+	Search     string
+	Sort       string
+	Page       int
+}
+
+// listMessage 标识一条列表消息
+type listMessage struct {
+	chatID    int64
+	messageID int
+}
+
+// savedListFilter 保存的筛选条件和保存时间
+type savedListFilter struct {
+	filter  listFilter
+	savedAt time.Time
+}
+
+// listFilters 按列表消息保存筛选条件，不受同一聊天中其他对话状态的影响
+type listFilters struct {
+	filters map[listMessage]savedListFilter
+	mutex   sync.Mutex
+}
+
+func newListFilters() *listFilters {
+	return &listFilters{filters: make(map[listMessage]savedListFilter)}
+}
+
+// Get 返回列表消息的筛选条件
+func (l *listFilters) Get(chatID int64, messageID int) (listFilter, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	saved, exists := l.filters[listMessage{chatID, messageID}]
+	if !exists || time.Since(saved.savedAt) > listFilterTTL {
+		return listFilter{}, false
+	}
+	return saved.filter, true
+}
+
+// Set 保存列表消息的筛选条件，同时清理过期的记录
+func (l *listFilters) Set(chatID int64, messageID int, filter listFilter) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := time.Now()
+	for key, saved := range l.filters {
+		if now.Sub(saved.savedAt) > listFilterTTL {
+			delete(l.filters, key)
+		}
+	}
+	l.filters[listMessage{chatID, messageID}] = savedListFilter{filter: filter, savedAt: now}
+}
+
+// parseListFilter 解析 /list 参数，无法识别的参数作为搜索词
+func parseListFilter(args string, userID int64) (*listFilter, error) {
+	filter := &listFilter{}
+	var terms []string
+	for _, arg := range strings.Fields(args) {
+		lower := strings.ToLower(arg)
+		switch {
+		case lower == database.EntryActive || lower == database.EntryScheduled || lower == database.EntryExpired:
+			filter.Statuses = append(filter.Statuses, lower)
+		case lower == "recent":
+			filter.Sort = listSortRecent
+		case strings.HasPrefix(lower, "sort:"):
+			sortBy := strings.TrimPrefix(lower, "sort:")
+			if sortBy != listSortKey && sortBy != listSortType && sortBy != listSortRecent {
+				return nil, fmt.Errorf("不支持的排序方式：%s", sortBy)
+			}
+			filter.Sort = sortBy
+		case strings.HasPrefix(lower, "by:"):
+			author := strings.TrimPrefix(lower, "by:")
+			if author == "me" {
+				filter.Authors = append(filter.Authors, userID)
+				continue
+			}
+			id, err := strconv.ParseInt(author, 10, 64)
 			if err != nil {
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型错误，请使用 exact, contains, regex, prefix, suffix"))
-				return
+				return nil, fmt.Errorf("用户 ID 格式错误：%s", author)
+			}
+			filter.Authors = append(filter.Authors, id)
+		default:
+			if matchType, err := utils.ParseMatchType(lower); err == nil {
+				filter.MatchTypes = append(filter.MatchTypes, matchType)
+				continue
 			}
-			matchTypes = append(matchTypes, matchType)
+			terms = append(terms, arg)
 		}
 	}
-	entries, err := h.db.ListSpecificEntries(matchTypes...)
+	filter.Search = strings.Join(terms, " ")
+	return filter, nil
+}
+
+// describe 返回筛选条件的说明，没有筛选条件时返回空字符串
+func (f *listFilter) describe() string {
+	var parts []string
+	if f.Search != "" {
+		parts = append(parts, fmt.Sprintf("搜索「%s」", f.Search))
+	}
+	for _, matchType := range f.MatchTypes {
+		parts = append(parts, utils.GetMatchTypeText(matchType))
+	}
+	for _, status := range f.Statuses {
+		parts = append(parts, validityStatusText(status))
+	}
+	for _, author := range f.Authors {
+		parts = append(parts, fmt.Sprintf("作者 %d", author))
+	}
+	switch f.Sort {
+	case listSortKey:
+		parts = append(parts, "按 key 排序")
+	case listSortType:
+		parts = append(parts, "按类型排序")
+	case listSortRecent:
+		parts = append(parts, "按最近修改排序")
+	}
+	return strings.Join(parts, "，")
+}
+
+// matchesSearch 检查条目的 key 或内容是否包含搜索词（不区分大小写）
+func matchesSearch(entry *database.Entry, search string) bool {
+	if search == "" {
+		return true
+	}
+	search = strings.ToLower(search)
+	return strings.Contains(strings.ToLower(entry.Key), search) ||
+		strings.Contains(strings.ToLower(entry.AnswerText()), search)
+}
+
+func (h *ListHandler) HandleListCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, page int) {
+	filter, err := parseListFilter(message.CommandArguments(), message.From.ID)
 	if err != nil {
-		log.Printf("Error listing entries: %v", err)
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无法获取条目列表"))
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()+"\n\n"+listUsage))
 		return
 	}
-	const pageSize = 5
-	pageEntries := utils.Paginate(entries, page, pageSize)
-	if len(pageEntries) == 0 {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "没有任何条目"))
+	text, markup, err := h.renderList(filter, page)
+	if err != nil {
+		log.Printf("Error listing entries: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无法获取条目列表"))
 		return
 	}
-	var buttons [][]tgbotapi.InlineKeyboardButton
-	for _, entry := range pageEntries {
-		matchTypeText := utils.GetMatchTypeText(entry.MatchType)
-		buttonText := fmt.Sprintf("%s(%s)", entry.Key, matchTypeText)
-		callbackData := fmt.Sprintf("entry_%d_%d", entry.ID, entry.MatchType.ToInt())
-		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
-		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
-	}
-	// Add pagination and cancel buttons
-	buttons = append(buttons, utils.BuildPaginationButtons(page, len(entries), pageSize, "list", "取消")...)
-	msg := tgbotapi.NewMessage(message.Chat.ID, "选择一个条目进行操作：")
-	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(buttons...)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ReplyMarkup = markup
 	sentMessage, err := bot.Send(msg)
 	if err != nil {
 		log.Printf("Error sending message: %v", err)
 		return
 	}
+	h.filters.Set(message.Chat.ID, sentMessage.MessageID, *filter)
 	h.state.Set(message.Chat.ID, &Conversation{
 		Stage:     "listing",
 		MessageID: sentMessage.MessageID,
 	})
 }
 
+// HandleListCommandEdit 翻页，沿用该列表消息的筛选条件，筛选条件已过期时显示全部条目
 func (h *ListHandler) HandleListCommandEdit(bot *tgbotapi.BotAPI, message *tgbotapi.Message, page int, messageID int) {
-	args := message.CommandArguments()
-	var matchTypes []database.MatchType
-	if args != "" {
-		typeStrings := strings.Split(args, " ")
-		for _, typeString := range typeStrings {
-			matchType, err := utils.ParseMatchType(typeString)
-			if err != nil {
-				bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型错误，请使用 exact, contains, regex, prefix, suffix"))
-				return
-			}
-			matchTypes = append(matchTypes, matchType)
-		}
-	}
-	entries, err := h.db.ListSpecificEntries(matchTypes...)
+	filter, _ := h.filters.Get(message.Chat.ID, messageID)
+	text, markup, err := h.renderList(&filter, page)
 	if err != nil {
 		log.Printf("Error listing entries: %v", err)
 		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无法获取条目列表"))
 		return
 	}
-	const pageSize = 5
-	pageEntries := utils.Paginate(entries, page, pageSize)
-	if len(pageEntries) == 0 {
-		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "没有任何条目"))
-		return
+	h.filters.Set(message.Chat.ID, messageID, filter)
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, messageID, text)
+	editMsg.ReplyMarkup = markup
+	bot.Send(editMsg)
+}
+
+// listEntries 返回符合筛选条件的条目，并按指定方式排序
+// 作者筛选和按最近修改排序由数据库查询完成
+func (h *ListHandler) listEntries(filter *listFilter) ([]database.Entry, error) {
+	entries, err := h.db.ListEntriesByAuthor(filter.Authors, filter.Sort == listSortRecent, filter.MatchTypes...)
+	if err != nil {
+		return nil, err
+	}
+	entries = filterByValidity(entries, filter.Statuses, time.Now())
+	if filter.Search != "" {
+		var matched []database.Entry
+		for i := range entries {
+			if matchesSearch(&entries[i], filter.Search) {
+				matched = append(matched, entries[i])
+			}
+		}
+		entries = matched
+	}
+
+	switch filter.Sort {
+	case listSortKey:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return strings.ToLower(entries[i].Key) < strings.ToLower(entries[j].Key)
+		})
+	case listSortType:
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].MatchType.ToInt() < entries[j].MatchType.ToInt()
+		})
+	}
+	return entries, nil
+}
+
+// renderList 生成列表消息和按钮，page 超出范围时显示最后一页
+func (h *ListHandler) renderList(filter *listFilter, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	entries, err := h.listEntries(filter)
+	if err != nil {
+		return "", nil, err
 	}
+
+	cancelRow := []tgbotapi.InlineKeyboardButton{tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")}
+	if len(entries) == 0 {
+		text := "没有任何条目"
+		if desc := filter.describe(); desc != "" {
+			text = "没有符合条件的条目\n筛选：" + desc
+		}
+		return text, &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{cancelRow}}, nil
+	}
+
+	totalPages := (len(entries) + listPageSize - 1) / listPageSize
+	if page >= totalPages {
+		page = totalPages - 1
+	}
+	if page < 0 {
+		page = 0
+	}
+	filter.Page = page
+
 	var buttons [][]tgbotapi.InlineKeyboardButton
-	for _, entry := range pageEntries {
-		matchTypeText := utils.GetMatchTypeText(entry.MatchType)
-		buttonText := fmt.Sprintf("%s(%s)", entry.Key, matchTypeText)
+	for _, entry := range utils.Paginate(entries, page, listPageSize) {
+		buttonText := h.entryButtonText(&entry)
 		callbackData := fmt.Sprintf("entry_%d_%d", entry.ID, entry.MatchType.ToInt())
 		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
 	}
-	// Add pagination and cancel buttons
-	buttons = append(buttons, utils.BuildPaginationButtons(page, len(entries), pageSize, "list", "取消")...)
-	msgText := "选择一个条目进行操作："
-	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, messageID, msgText)
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
-	bot.Send(editMsg)
+	// Add pagination, jump and cancel buttons
+	buttons = append(buttons, utils.BuildPaginationButtons(page, len(entries), listPageSize, "list", "")...)
+	if jumps := listJumpButtons(page, totalPages); len(jumps) > 0 {
+		buttons = append(buttons, jumps)
+	}
+	buttons = append(buttons, cancelRow)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("共 %d 个条目（第 %d/%d 页）\n", len(entries), page+1, totalPages))
+	if counts := countByMatchType(entries); counts != "" {
+		b.WriteString(counts + "\n")
+	}
+	if desc := filter.describe(); desc != "" {
+		b.WriteString("筛选：" + desc + "\n")
+	}
+	b.WriteString(h.listTitle(entries))
+	return b.String(), &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}, nil
+}
+
+// countByMatchType 统计各匹配类型的条目数量
+func countByMatchType(entries []database.Entry) string {
+	counts := make(map[database.MatchType]int)
+	for _, entry := range entries {
+		counts[entry.MatchType]++
+	}
+	var parts []string
+	for _, matchType := range []database.MatchType{database.MatchExact, database.MatchContains, database.MatchRegex, database.MatchPrefix, database.MatchSuffix} {
+		if counts[matchType] > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d", utils.GetMatchTypeText(matchType), counts[matchType]))
+		}
+	}
+	return strings.Join(parts, " · ")
+}
+
+// listJumpButtons 生成跳转到首页、末页和前后若干页的按钮，与上一页、下一页重复的不再显示
+func listJumpButtons(page, totalPages int) []tgbotapi.InlineKeyboardButton {
+	if totalPages <= 3 {
+		return nil
+	}
+	var buttons []tgbotapi.InlineKeyboardButton
+	add := func(target int, label string) {
+		if target < 0 || target >= totalPages || target == page || target == page-1 || target == page+1 {
+			return
+		}
+		buttons = append(buttons, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("list_%d", target)))
+	}
+	add(0, "⏮ 1")
+	if page-listJumpStep > 0 {
+		add(page-listJumpStep, fmt.Sprintf("« %d", page-listJumpStep+1))
+	}
+	if page+listJumpStep < totalPages-1 {
+		add(page+listJumpStep, fmt.Sprintf("%d »", page+listJumpStep+1))
+	}
+	add(totalPages-1, fmt.Sprintf("%d ⏭", totalPages))
+	return buttons
+}
+
+// describeAuthors 返回条目的创建和修改记录，没有记录时返回空字符串
+func describeAuthors(entry *database.Entry) string {
+	var lines []string
+	if entry.CreatedAt != nil {
+		lines = append(lines, fmt.Sprintf("创建：%d 于 %s", entry.CreatedBy, entry.CreatedAt.Format("2006-01-02 15:04")))
+	}
+	if entry.UpdatedAt != nil && (entry.CreatedAt == nil || !entry.UpdatedAt.Equal(*entry.CreatedAt)) {
+		lines = append(lines, fmt.Sprintf("修改：%d 于 %s", entry.UpdatedBy, entry.UpdatedAt.Format("2006-01-02 15:04")))
+	}
+	return strings.Join(lines, "\n")
 }
 
 func (h *ListHandler) HandleEntrySelection(bot *tgbotapi.BotAPI, message *tgbotapi.Message, entryID int, matchType int) {
@@ -139,14 +386,80 @@ func (h *ListHandler) HandleEntrySelection(bot *tgbotapi.BotAPI, message *tgbota
 			tgbotapi.NewInlineKeyboardButtonData("删除", fmt.Sprintf("delete_%d_%d", entry.ID, matchType)),
 		},
 		{
-			tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("list_%d", 0)),
+			tgbotapi.NewInlineKeyboardButtonData("🌐 翻译", fmt.Sprintf("trmenu_%d_%d", entry.ID, matchType)),
+			tgbotapi.NewInlineKeyboardButtonData("⏰ 有效期", fmt.Sprintf("validity_%d_%d", entry.ID, matchType)),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("⚖️ 优先级", fmt.Sprintf("priority_%d_%d", entry.ID, matchType)),
+			tgbotapi.NewInlineKeyboardButtonData("👁 可见性", fmt.Sprintf("vismenu_%d_%d", entry.ID, matchType)),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("list_%d", h.currentListPage(message.Chat.ID, message.MessageID))),
 			tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
 		},
 	}
 
 	matchTypeText := utils.GetMatchTypeText(entry.MatchType)
 	msgText := fmt.Sprintf("选择操作：\nKey: %s\nValue: %s\n类型：%s", entry.Key, entry.Value, matchTypeText)
+	if desc := describeButtons(entry.Buttons); desc != "" {
+		msgText += "\n" + desc
+	}
+	if entry.Visibility != "" {
+		msgText += "\n可见性：" + visibilityText(entry)
+	}
+	if authors := describeAuthors(entry); authors != "" {
+		msgText += "\n" + authors
+	}
+	if entry.Priority != 0 {
+		msgText += fmt.Sprintf("\n优先级：%d", entry.Priority)
+	}
+	if desc := describeValidity(entry, time.Now()); desc != "" {
+		msgText += "\n" + desc
+	}
+	if missing := missingTranslations(h.conf, entry); len(missing) > 0 {
+		msgText += "\n缺少翻译：" + strings.Join(missing, ", ")
+	}
+	msgText += "\n链接：" + entryDeepLink(bot, entry)
 	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, msgText)
 	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
 	bot.Send(editMsg)
 }
+
+// currentListPage 返回列表消息所在的页码，用于从条目详情返回
+func (h *ListHandler) currentListPage(chatID int64, messageID int) int {
+	filter, _ := h.filters.Get(chatID, messageID)
+	return filter.Page
+}
+
+// entryButtonText 返回列表中条目按钮的文字，缺少翻译时标注缺失的语言
+func (h *ListHandler) entryButtonText(entry *database.Entry) string {
+	text := fmt.Sprintf("%s(%s)", entry.Key, utils.GetMatchTypeText(entry.MatchType))
+	if entry.Visibility != "" {
+		text += " 🔒"
+	}
+	if status := entry.ValidityStatus(time.Now()); status != database.EntryActive {
+		text += " " + validityStatusText(status)
+	}
+	if missing := missingTranslations(h.conf, entry); len(missing) > 0 {
+		text += " 🌐缺" + strings.Join(missing, ",")
+	}
+	return text
+}
+
+// listTitle 返回列表标题，配置了翻译语言时统计缺少翻译的条目数
+func (h *ListHandler) listTitle(entries []database.Entry) string {
+	title := "选择一个条目进行操作："
+	if len(h.conf.FAQ.Languages) == 0 {
+		return title
+	}
+	count := 0
+	for i := range entries {
+		if len(missingTranslations(h.conf, &entries[i])) > 0 {
+			count++
+		}
+	}
+	if count > 0 {
+		title = fmt.Sprintf("%d 个条目缺少翻译\n%s", count, title)
+	}
+	return title
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"TGFaqBot/database"
+)
+
+func TestParseListFilter(t *testing.T) {
+	const userID = 42
+
+	tests := []struct {
+		name    string
+		args    string
+		want    listFilter
+		wantErr bool
+	}{
+		{
+			name: "no arguments",
+			args: "",
+			want: listFilter{},
+		},
+		{
+			name: "match types",
+			args: "exact regex",
+			want: listFilter{MatchTypes: []database.MatchType{database.MatchExact, database.MatchRegex}},
+		},
+		{
+			name: "validity statuses are case insensitive",
+			args: "Active EXPIRED",
+			want: listFilter{Statuses: []string{database.EntryActive, database.EntryExpired}},
+		},
+		{
+			name: "authors",
+			args: "by:me by:1001",
+			want: listFilter{Authors: []int64{userID, 1001}},
+		},
+		{
+			name: "recent shorthand",
+			args: "recent",
+			want: listFilter{Sort: listSortRecent},
+		},
+		{
+			name: "explicit sort",
+			args: "sort:type",
+			want: listFilter{Sort: listSortType},
+		},
+		{
+			name: "remaining words form the search keeping their case",
+			args: "contains 退款 Policy",
+			want: listFilter{MatchTypes: []database.MatchType{database.MatchContains}, Search: "退款 Policy"},
+		},
+		{
+			name: "combined",
+			args: "prefix scheduled by:7 sort:key 优惠",
+			want: listFilter{
+				MatchTypes: []database.MatchType{database.MatchPrefix},
+				Statuses:   []string{database.EntryScheduled},
+				Authors:    []int64{7},
+				Sort:       listSortKey,
+				Search:     "优惠",
+			},
+		},
+		{
+			name:    "unknown sort",
+			args:    "sort:size",
+			wantErr: true,
+		},
+		{
+			name:    "invalid author",
+			args:    "by:alice",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseListFilter(tt.args, userID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseListFilter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("parseListFilter() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+)
+
+// mediaGroupTTL 相册消息在缓存中保留的时间
+const mediaGroupTTL = 10 * time.Minute
+
+// extractMedia 从消息中提取媒体文件
+func extractMedia(message *tgbotapi.Message) (database.MediaItem, bool) {
+	switch {
+	case len(message.Photo) > 0:
+		// 取最大尺寸的图片
+		return database.MediaItem{Type: database.MediaPhoto, FileID: message.Photo[len(message.Photo)-1].FileID}, true
+	case message.Animation != nil:
+		// GIF 消息同时带有 Document 字段，需先于 Document 判断
+		return database.MediaItem{Type: database.MediaAnimation, FileID: message.Animation.FileID}, true
+	case message.Document != nil:
+		return database.MediaItem{Type: database.MediaDocument, FileID: message.Document.FileID}, true
+	case message.Video != nil:
+		return database.MediaItem{Type: database.MediaVideo, FileID: message.Video.FileID}, true
+	case message.Voice != nil:
+		return database.MediaItem{Type: database.MediaVoice, FileID: message.Voice.FileID}, true
+	case message.Sticker != nil:
+		return database.MediaItem{Type: database.MediaSticker, FileID: message.Sticker.FileID}, true
+	default:
+		return database.MediaItem{}, false
+	}
+}
+
+type mediaGroupItem struct {
+	messageID int
+	item      database.MediaItem
+	caption   string
+}
+
+type mediaGroup struct {
+	items     []mediaGroupItem
+	updatedAt time.Time
+}
+
+// MediaGroupCache 缓存最近收到的相册消息
+// Telegram 将相册拆分为多条消息发送，回复其中一条时只能拿到单条消息，需要从缓存中还原整个相册
+type MediaGroupCache struct {
+	groups map[string]*mediaGroup
+	mutex  sync.Mutex
+}
+
+// NewMediaGroupCache 创建相册缓存
+func NewMediaGroupCache() *MediaGroupCache {
+	return &MediaGroupCache{
+		groups: make(map[string]*mediaGroup),
+	}
+}
+
+// Track 记录相册中的一条消息
+func (c *MediaGroupCache) Track(message *tgbotapi.Message) {
+	if message.MediaGroupID == "" {
+		return
+	}
+	item, ok := extractMedia(message)
+	if !ok {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for id, group := range c.groups {
+		if now.Sub(group.updatedAt) > mediaGroupTTL {
+			delete(c.groups, id)
+		}
+	}
+
+	group, exists := c.groups[message.MediaGroupID]
+	if !exists {
+		group = &mediaGroup{}
+		c.groups[message.MediaGroupID] = group
+	}
+	for _, existing := range group.items {
+		if existing.messageID == message.MessageID {
+			return
+		}
+	}
+	group.items = append(group.items, mediaGroupItem{
+		messageID: message.MessageID,
+		item:      item,
+		caption:   message.Caption,
+	})
+	group.updatedAt = now
+}
+
+// Collect 按消息顺序返回相册中的媒体及说明文字
+func (c *MediaGroupCache) Collect(mediaGroupID string) ([]database.MediaItem, string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	group, exists := c.groups[mediaGroupID]
+	if !exists {
+		return nil, ""
+	}
+
+	items := make([]mediaGroupItem, len(group.items))
+	copy(items, group.items)
+	sort.Slice(items, func(i, j int) bool { return items[i].messageID < items[j].messageID })
+
+	var media []database.MediaItem
+	var caption string
+	for _, item := range items {
+		media = append(media, item.item)
+		if caption == "" {
+			caption = item.caption
+		}
+	}
+	return media, caption
+}
+
+// mediaFromMessage 提取消息中的媒体，属于相册时返回整个相册
+func mediaFromMessage(message *tgbotapi.Message, cache *MediaGroupCache) ([]database.MediaItem, string) {
+	if message.MediaGroupID != "" && cache != nil {
+		cache.Track(message)
+		if media, caption := cache.Collect(message.MediaGroupID); len(media) > 0 {
+			return media, caption
+		}
+	}
+	item, ok := extractMedia(message)
+	if !ok {
+		return nil, ""
+	}
+	return []database.MediaItem{item}, message.Caption
+}
+
+// sendMediaEntry 按 file_id 重新发送媒体回答，keyboard 不为 nil 时附加内联按钮
+func sendMediaEntry(bot *tgbotapi.BotAPI, chatID int64, entry *database.Entry, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	if len(entry.Media) > 1 {
+		if err := sendMediaAlbum(bot, chatID, entry.Media, entry.Caption); err != nil {
+			return err
+		}
+		if keyboard == nil {
+			return nil
+		}
+		// 相册不支持内联按钮，单独发送一条带按钮的消息
+		msg := tgbotapi.NewMessage(chatID, "🔗 相关链接")
+		msg.ReplyMarkup = keyboard
+		_, err := bot.Send(msg)
+		return err
+	}
+
+	media := entry.Media[0]
+	file := tgbotapi.FileID(media.FileID)
+
+	var base *tgbotapi.BaseChat
+	var msg tgbotapi.Chattable
+	switch media.Type {
+	case database.MediaPhoto:
+		photo := tgbotapi.NewPhoto(chatID, file)
+		photo.Caption = entry.Caption
+		base, msg = &photo.BaseChat, &photo
+	case database.MediaDocument:
+		document := tgbotapi.NewDocument(chatID, file)
+		document.Caption = entry.Caption
+		base, msg = &document.BaseChat, &document
+	case database.MediaVideo:
+		video := tgbotapi.NewVideo(chatID, file)
+		video.Caption = entry.Caption
+		base, msg = &video.BaseChat, &video
+	case database.MediaVoice:
+		voice := tgbotapi.NewVoice(chatID, file)
+		voice.Caption = entry.Caption
+		base, msg = &voice.BaseChat, &voice
+	case database.MediaAnimation:
+		animation := tgbotapi.NewAnimation(chatID, file)
+		animation.Caption = entry.Caption
+		base, msg = &animation.BaseChat, &animation
+	case database.MediaSticker:
+		// 贴纸不支持说明文字，单独发送；有说明文字时按钮附在说明上
+		sticker := tgbotapi.NewSticker(chatID, file)
+		if entry.Caption == "" {
+			base, msg = &sticker.BaseChat, &sticker
+			break
+		}
+		if _, err := bot.Send(sticker); err != nil {
+			return err
+		}
+		text := tgbotapi.NewMessage(chatID, entry.Caption)
+		base, msg = &text.BaseChat, &text
+	default:
+		return fmt.Errorf("unsupported media type: %s", media.Type)
+	}
+
+	if keyboard != nil {
+		base.ReplyMarkup = keyboard
+	}
+	_, err := bot.Send(msg)
+	return err
+}
+
+// validateAlbum 检查相册能否发送：Telegram 只允许图片和视频混合，文件只能与文件组成相册
+func validateAlbum(items []database.MediaItem) error {
+	if len(items) < 2 {
+		return nil
+	}
+	documents := 0
+	for _, item := range items {
+		switch item.Type {
+		case database.MediaPhoto, database.MediaVideo:
+		case database.MediaDocument:
+			documents++
+		default:
+			return fmt.Errorf("相册只支持图片、视频和文件")
+		}
+	}
+	if documents > 0 && documents < len(items) {
+		return fmt.Errorf("文件不能与图片或视频放在同一个相册中，请只使用图片和视频，或者只使用文件")
+	}
+	return nil
+}
+
+// sendMediaAlbum 发送相册，说明文字附在第一个媒体上
+func sendMediaAlbum(bot *tgbotapi.BotAPI, chatID int64, items []database.MediaItem, caption string) error {
+	files := make([]interface{}, 0, len(items))
+	for i, media := range items {
+		file := tgbotapi.FileID(media.FileID)
+		itemCaption := ""
+		if i == 0 {
+			itemCaption = caption
+		}
+
+		switch media.Type {
+		case database.MediaPhoto:
+			input := tgbotapi.NewInputMediaPhoto(file)
+			input.Caption = itemCaption
+			files = append(files, input)
+		case database.MediaVideo:
+			input := tgbotapi.NewInputMediaVideo(file)
+			input.Caption = itemCaption
+			files = append(files, input)
+		case database.MediaDocument:
+			input := tgbotapi.NewInputMediaDocument(file)
+			input.Caption = itemCaption
+			files = append(files, input)
+		default:
+			return fmt.Errorf("media type %s cannot be sent in an album", media.Type)
+		}
+	}
+
+	_, err := bot.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, files))
+	return err
+}
+
+// describeMedia 返回媒体回答的简短描述，用于列表和确认消息
+func describeMedia(meta database.EntryMeta) string {
+	contentType := meta.MediaContentType()
+	var name string
+	switch contentType {
+	case database.MediaPhoto:
+		name = "图片"
+	case database.MediaDocument:
+		name = "文件"
+	case database.MediaVideo:
+		name = "视频"
+	case database.MediaVoice:
+		name = "语音"
+	case database.MediaSticker:
+		name = "贴纸"
+	case database.MediaAnimation:
+		name = "动图"
+	case database.ContentAlbum:
+		return fmt.Sprintf("[相册 %d 项]", len(meta.Media))
+	default:
+		name = contentType
+	}
+	return fmt.Sprintf("[%s]", name)
+}
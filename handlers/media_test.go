@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+)
+
+func TestExtractMedia(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		want    database.MediaItem
+		wantOK  bool
+	}{
+		{
+			name:    "largest photo size",
+			message: &tgbotapi.Message{Photo: []tgbotapi.PhotoSize{{FileID: "small"}, {FileID: "large"}}},
+			want:    database.MediaItem{Type: database.MediaPhoto, FileID: "large"},
+			wantOK:  true,
+		},
+		{
+			name:    "animation before document",
+			message: &tgbotapi.Message{Animation: &tgbotapi.Animation{FileID: "gif"}, Document: &tgbotapi.Document{FileID: "gif"}},
+			want:    database.MediaItem{Type: database.MediaAnimation, FileID: "gif"},
+			wantOK:  true,
+		},
+		{
+			name:    "voice",
+			message: &tgbotapi.Message{Voice: &tgbotapi.Voice{FileID: "v"}},
+			want:    database.MediaItem{Type: database.MediaVoice, FileID: "v"},
+			wantOK:  true,
+		},
+		{
+			name:    "text only",
+			message: &tgbotapi.Message{Text: "退款"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := extractMedia(tt.message)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("extractMedia() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestMediaGroupCache(t *testing.T) {
+	cache := NewMediaGroupCache()
+	photo := func(id int, fileID, caption string) *tgbotapi.Message {
+		return &tgbotapi.Message{MessageID: id, MediaGroupID: "g1", Caption: caption, Photo: []tgbotapi.PhotoSize{{FileID: fileID}}}
+	}
+	// 消息到达顺序与消息 ID 顺序不同，重复的消息只记录一次
+	cache.Track(photo(12, "b", ""))
+	cache.Track(photo(11, "a", "说明"))
+	cache.Track(photo(12, "b", ""))
+	cache.Track(&tgbotapi.Message{MessageID: 13, Text: "不属于相册"})
+
+	media, caption := mediaFromMessage(photo(13, "c", ""), cache)
+	want := []database.MediaItem{
+		{Type: database.MediaPhoto, FileID: "a"},
+		{Type: database.MediaPhoto, FileID: "b"},
+		{Type: database.MediaPhoto, FileID: "c"},
+	}
+	if !reflect.DeepEqual(media, want) || caption != "说明" {
+		t.Errorf("mediaFromMessage() = %+v, %q, want %+v and the first caption", media, caption, want)
+	}
+
+	if media, _ := cache.Collect("unknown"); media != nil {
+		t.Errorf("Collect(unknown) = %+v, want nil", media)
+	}
+	single, caption := mediaFromMessage(&tgbotapi.Message{Caption: "单张", Video: &tgbotapi.Video{FileID: "v"}}, cache)
+	if len(single) != 1 || single[0].Type != database.MediaVideo || caption != "单张" {
+		t.Errorf("mediaFromMessage(single) = %+v, %q", single, caption)
+	}
+}
+
+func TestValidateAlbum(t *testing.T) {
+	photo := database.MediaItem{Type: database.MediaPhoto}
+	video := database.MediaItem{Type: database.MediaVideo}
+	document := database.MediaItem{Type: database.MediaDocument}
+	voice := database.MediaItem{Type: database.MediaVoice}
+
+	tests := []struct {
+		name    string
+		items   []database.MediaItem
+		wantErr bool
+	}{
+		{name: "single item of any type", items: []database.MediaItem{voice}},
+		{name: "photos and videos", items: []database.MediaItem{photo, video, photo}},
+		{name: "documents only", items: []database.MediaItem{document, document}},
+		{name: "documents mixed with photos", items: []database.MediaItem{photo, document}, wantErr: true},
+		{name: "voice in an album", items: []database.MediaItem{photo, voice}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateAlbum(tt.items); (err != nil) != tt.wantErr {
+				t.Errorf("validateAlbum() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDescribeMedia(t *testing.T) {
+	tests := []struct {
+		media []database.MediaItem
+		want  string
+	}{
+		{media: []database.MediaItem{{Type: database.MediaPhoto}}, want: "[图片]"},
+		{media: []database.MediaItem{{Type: database.MediaSticker}}, want: "[贴纸]"},
+		{media: []database.MediaItem{{Type: database.MediaPhoto}, {Type: database.MediaVideo}}, want: "[相册 2 项]"},
+	}
+	for _, tt := range tests {
+		if got := describeMedia(database.EntryMeta{Media: tt.media}); got != tt.want {
+			t.Errorf("describeMedia(%+v) = %q, want %q", tt.media, got, tt.want)
+		}
+	}
+}
+
+func TestEntryAnswerText(t *testing.T) {
+	tests := []struct {
+		name      string
+		entry     database.Entry
+		wantText  string
+		wantParse string
+	}{
+		{name: "html text", entry: database.Entry{Value: "<b>退款</b>", ContentType: "text"}, wantText: "<b>退款</b>", wantParse: "HTML"},
+		{name: "telegraph link", entry: database.Entry{Value: "v", ContentType: "telegraph_text", TelegraphURL: "https://telegra.ph/a"}, wantText: "https://telegra.ph/a"},
+		{name: "telegraph without url", entry: database.Entry{Value: "v", ContentType: "telegraph_image"}, wantText: "v", wantParse: "HTML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, parseMode := entryAnswerText(&tt.entry)
+			if text != tt.wantText || parseMode != tt.wantParse {
+				t.Errorf("entryAnswerText() = %q, %q, want %q, %q", text, parseMode, tt.wantText, tt.wantParse)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
@@ -24,6 +26,8 @@ type MessageHandler struct {
 	multichatManager *multichat.Manager
 	telegraphHandler *TelegraphHandler
 	prefManager      *PreferenceManager
+	wizard           *AddWizard
+	importer         *ImportHandler
 }
 
 func NewMessageHandler(db database.Database, conf *config.Config, state *State, streamer *StreamingManager, multichatMgr *multichat.Manager, prefManager *PreferenceManager) *MessageHandler {
@@ -35,6 +39,8 @@ func NewMessageHandler(db database.Database, conf *config.Config, state *State,
 		multichatManager: multichatMgr,
 		telegraphHandler: NewTelegraphHandler(db),
 		prefManager:      prefManager,
+		wizard:           NewAddWizard(db, conf, state),
+		importer:         NewImportHandler(db, conf, state),
 	}
 }
 
@@ -45,8 +51,14 @@ func (h *MessageHandler) HandleMessage(bot *tgbotapi.BotAPI, message *tgbotapi.M
 	if exists {
 		h.handleConversationMessage(bot, message, state)
 	} else {
+		// 管理员发送的 .csv、.json、.md 文件用于批量导入条目
+		if message.Document != nil && isImportFile(message.Document.FileName) && IsAdminUser(message.From.ID, h.conf) {
+			h.importer.HandleDocument(bot, message)
+			return
+		}
 		// Handle other messages or commands
-		if !strings.HasPrefix(message.Text, "/") {
+		// 纯媒体消息（如相册）没有文本，不发送给AI
+		if message.Text != "" && !strings.HasPrefix(message.Text, "/") {
 			h.handleAIMessage(bot, message)
 		}
 	}
@@ -78,6 +90,27 @@ func (h *MessageHandler) handleConversationMessage(bot *tgbotapi.BotAPI, message
 		fakeMsg.Text = parts[1]
 		h.handleValueInput(bot, &fakeMsg, state)
 
+	case "awaiting_add_value":
+		h.handleAddValueInput(bot, message, state)
+
+	case "awaiting_translation":
+		h.handleTranslationInput(bot, message, state)
+
+	case "awaiting_validity":
+		h.handleValidityInput(bot, message, state)
+
+	case "awaiting_priority":
+		h.handlePriorityInput(bot, message, state)
+
+	case "awaiting_visibility_users":
+		h.handleVisibilityUsersInput(bot, message, state)
+
+	case wizardKey, wizardType, wizardValue, wizardConfirm:
+		h.wizard.HandleInput(bot, message, state)
+
+	case "import_confirm":
+		bot.Send(tgbotapi.NewMessage(chatID, "请点击按钮确认导入或取消"))
+
 	case "awaiting_telegraph_text_content":
 		// 处理 Telegraph 文本内容
 		h.handleTelegraphTextContent(bot, message, state)
@@ -119,12 +152,45 @@ func (h *MessageHandler) handleValueInput(bot *tgbotapi.BotAPI, message *tgbotap
 		return
 	}
 
+	newValue, buttons, hasButtons, err := splitButtonLayout(newValue)
+	if err != nil {
+		// 保留对话状态，允许重新输入
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()+"\n\n"+buttonHelp))
+		return
+	}
+	if err := resolveButtonEntries(h.db, buttons); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return
+	}
+
+	if requiresReview(h.conf, message.From.ID) {
+		change := database.PendingChange{
+			Action:     database.ChangeUpdate,
+			Key:        entry.Key,
+			MatchType:  oldTypeValue,
+			NewType:    newTypeValue,
+			Value:      newValue,
+			Buttons:    buttons,
+			SetButtons: hasButtons,
+		}
+		h.submitChange(bot, message, state, change)
+		return
+	}
+
 	err = h.db.UpdateEntry(entry.Key, oldTypeValue, newTypeValue, newValue)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "更新失败"))
 		h.state.Delete(chatID)
 		return
 	}
+	stampEntry(h.db, entry.Key, newTypeValue, message.From.ID, false)
+	// 未给出按钮定义时保留原有按钮
+	if hasButtons {
+		if err := saveEntryButtons(h.db, entry.Key, newTypeValue, buttons); err != nil {
+			log.Printf("Error saving buttons for entry %s: %v", entry.Key, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "内容已更新，但按钮保存失败"))
+		}
+	}
 
 	matchTypeText := utils.GetMatchTypeText(newTypeValue)
 
@@ -134,12 +200,189 @@ func (h *MessageHandler) handleValueInput(bot *tgbotapi.BotAPI, message *tgbotap
 
 	// Send a new message with the updated information
 	newMsgText := fmt.Sprintf("更新成功！\nKey: %s\nValue: %s\n类型：%s", entry.Key, newValue, matchTypeText)
+	if hasButtons {
+		if len(buttons) > 0 {
+			newMsgText += "\n" + describeButtons(buttons)
+		} else {
+			newMsgText += "\n已清除按钮"
+		}
+	}
 	newMsg := tgbotapi.NewMessage(message.Chat.ID, newMsgText)
 	bot.Send(newMsg)
 
 	h.state.Delete(chatID)
 }
 
+// resolveMiss 条目来自未回答问题时，移除同组的问题记录
+func (h *MessageHandler) resolveMiss(state *Conversation) {
+	if state.MissID == 0 {
+		return
+	}
+	if err := NewUnansweredHandler(h.db, h.state).ResolveGroup(state.MissID); err != nil {
+		log.Printf("Error resolving unanswered questions: %v", err)
+	}
+}
+
+// submitChange 审核模式下提交对话中的修改并结束对话
+func (h *MessageHandler) submitChange(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation, change database.PendingChange) {
+	chatID := message.Chat.ID
+	defer h.state.Delete(chatID)
+	if err := submitChange(bot, h.db, h.conf, message.From, chatID, change); err != nil {
+		log.Printf("Error submitting change for entry %s: %v", change.Key, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "提交审核失败"))
+		return
+	}
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+	bot.Send(tgbotapi.NewMessage(chatID, reviewSubmitted(change)))
+}
+
+// handleAddValueInput 使用对话中已确定的 key 和匹配类型添加条目
+func (h *MessageHandler) handleAddValueInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	if !IsAdminUser(message.From.ID, h.conf) {
+		return
+	}
+	if strings.TrimSpace(message.Text) == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "请输入文本内容"))
+		return
+	}
+
+	value, buttons, _, err := splitButtonLayout(message.Text)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()+"\n\n"+buttonHelp))
+		return
+	}
+	if err := resolveButtonEntries(h.db, buttons); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return
+	}
+
+	exists, err := entryExists(h.db, state.Key)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "查询失败"))
+		h.state.Delete(chatID)
+		return
+	}
+	if exists {
+		bot.Send(tgbotapi.NewMessage(chatID, "该条目已存在"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	if requiresReview(h.conf, message.From.ID) {
+		change := database.PendingChange{Action: database.ChangeAdd, Key: state.Key, MatchType: state.MatchType, Value: value}
+		if len(buttons) > 0 {
+			change.Meta = &database.EntryMeta{Buttons: buttons}
+		}
+		// 问题记录在批准后随条目一起处理，被拒绝时仍保留在未回答列表中
+		if state.MissID != 0 {
+			ids, err := NewUnansweredHandler(h.db, h.state).GroupMissIDs(state.MissID)
+			if err != nil {
+				log.Printf("Error finding unanswered questions: %v", err)
+			}
+			change.MissIDs = ids
+		}
+		h.submitChange(bot, message, state, change)
+		return
+	}
+
+	warning := overlapWarning(h.db, h.conf, state.Key, state.MatchType)
+	if err := h.db.AddEntry(state.Key, state.MatchType, value); err != nil {
+		log.Printf("Error adding entry: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "添加失败"))
+		h.state.Delete(chatID)
+		return
+	}
+	if len(buttons) > 0 {
+		if err := h.db.SetEntryMeta(state.Key, state.MatchType, database.EntryMeta{Buttons: buttons}); err != nil {
+			log.Printf("Error saving buttons for entry %s: %v", state.Key, err)
+			h.db.DeleteEntry(state.Key, state.MatchType)
+			bot.Send(tgbotapi.NewMessage(chatID, "添加失败"))
+			h.state.Delete(chatID)
+			return
+		}
+	}
+
+	stampEntry(h.db, state.Key, state.MatchType, message.From.ID, true)
+	h.resolveMiss(state)
+
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+	result := fmt.Sprintf("添加成功！\nKey: %s\nValue: %s\n类型：%s", state.Key, value, utils.GetMatchTypeText(state.MatchType))
+	if len(buttons) > 0 {
+		result += "\n" + describeButtons(buttons)
+	}
+	if warning != "" {
+		result += "\n\n" + warning
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, result))
+	h.state.Delete(chatID)
+}
+
+// handleTranslationInput 保存用户输入的条目翻译
+func (h *MessageHandler) handleTranslationInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	if !IsAdminUser(message.From.ID, h.conf) {
+		return
+	}
+	lang := state.Language
+	text := strings.TrimSpace(message.Text)
+	if lang == "" {
+		parts := strings.SplitN(text, " ", 2)
+		if len(parts) < 2 {
+			bot.Send(tgbotapi.NewMessage(chatID, "请输入语言代码和内容，例如：ja こんにちは"))
+			return
+		}
+		lang, text = parts[0], strings.TrimSpace(parts[1])
+	}
+	lang = normalizeLanguage(lang)
+	if lang == "" || text == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "内容不能为空"))
+		return
+	}
+	if lang == normalizeLanguage(h.conf.FAQ.DefaultLanguage) {
+		bot.Send(tgbotapi.NewMessage(chatID, "默认语言的内容请通过「更新」修改"))
+		return
+	}
+
+	matchType, err := database.MatchTypeFromInt(state.OldType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "类型转换错误"))
+		h.state.Delete(chatID)
+		return
+	}
+	entry, err := h.db.QueryByID(state.EntryID, matchType)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "未找到条目"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	// 输入 - 表示删除该语言的翻译
+	if text == "-" {
+		text = ""
+	}
+	patch := database.MetaPatch{Translation: &database.TranslationPatch{Language: lang, Text: text}}
+	if requiresReview(h.conf, message.From.ID) {
+		h.submitChange(bot, message, state, metaChange(entry, patch))
+		return
+	}
+	if err := saveEntryMeta(h.db, entry, message.From.ID, patch); err != nil {
+		log.Printf("Error saving translation for entry %s: %v", entry.Key, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "保存翻译失败"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+	result := fmt.Sprintf("已保存 %s 翻译\nKey: %s\n内容：%s", lang, entry.Key, text)
+	if text == "" {
+		result = fmt.Sprintf("已删除 %s 翻译\nKey: %s", lang, entry.Key)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, result))
+	h.state.Delete(chatID)
+}
+
 // handleAIMessage 处理AI对话消息，支持流式输出
 func (h *MessageHandler) handleAIMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 	chatID := message.Chat.ID
@@ -157,29 +400,38 @@ func (h *MessageHandler) handleAIMessage(bot *tgbotapi.BotAPI, message *tgbotapi
 		return
 	}
 
-	// 发送初始"正在输入"消息
+	// 发送初始"正在输入"消息，附带停止按钮
 	initialMsg := tgbotapi.NewMessage(chatID, "🤔 正在思考...")
+	initialMsg.ReplyMarkup = stopKeyboard()
 	sentMsg, err := bot.Send(initialMsg)
 	if err != nil {
 		log.Printf("Error sending initial message: %v", err)
 		return
 	}
 
-	// 创建流式消息管理器
+	// 创建流式消息管理器，停止按钮通过 cancel 中断请求
+	ctx, cancel := context.WithCancel(context.Background())
 	streamKey := fmt.Sprintf("%d_%d", chatID, sentMsg.MessageID)
-	h.streamer.CreateStream(streamKey, chatID, sentMsg.MessageID)
+	h.streamer.CreateStream(streamKey, chatID, sentMsg.MessageID, message.From.ID, cancel)
 
 	// 异步获取AI响应
 	go func() {
 		defer h.streamer.DeleteStream(streamKey)
+		defer cancel()
 
 		// 获取AI响应 - 使用真正的流式响应
-		_, shouldReset, stats, err := h.getAIReplyWithStreaming(userMessage, message, func(partialContent string, isComplete bool) bool {
+		reply, shouldReset, stats, err := h.getAIReplyWithStreaming(ctx, userMessage, message, func(partialContent string, isComplete bool) bool {
 			// 更新流式消息
 			h.streamer.UpdateStream(bot, streamKey, partialContent, isComplete, nil)
 			return true // 继续流式传输
 		})
 
+		// 用户点击了停止，保留已生成的内容
+		if errors.Is(err, context.Canceled) {
+			h.streamer.ShowStopped(bot, streamKey, reply)
+			return
+		}
+
 		// 在流式响应完成后，追加统计信息
 		if err == nil && stats != nil {
 			h.streamer.AppendStats(bot, streamKey, stats)
@@ -196,8 +448,9 @@ func (h *MessageHandler) handleAIMessage(bot *tgbotapi.BotAPI, message *tgbotapi
 				providerNames := h.multichatManager.GetProviderNames()
 				diagnosticInfo := h.multichatManager.GetDiagnosticInfo()
 
-				errorMsg = fmt.Sprintf("❌ AI服务错误（管理员详情）：\n\n🔍 错误详情：\n%s\n\n📊 系统诊断：\n• 可用提供商数量：%d\n• 提供商列表：%v\n• 详细信息：%s\n\n👤 用户信息：\n• 用户ID：%d\n• 聊天ID：%d\n• 时间：%s",
+				errorMsg = fmt.Sprintf("❌ AI服务错误（管理员详情）：\n\n🔍 错误详情：\n%s\n\n%s📊 系统诊断：\n• 可用提供商数量：%d\n• 提供商列表：%v\n• 详细信息：%s\n\n👤 用户信息：\n• 用户ID：%d\n• 聊天ID：%d\n• 时间：%s",
 					err.Error(),
+					formatAttempts(err),
 					providerCount,
 					providerNames,
 					diagnosticInfo,
@@ -224,8 +477,26 @@ func (h *MessageHandler) handleAIMessage(bot *tgbotapi.BotAPI, message *tgbotapi
 	}()
 }
 
+// formatAttempts 列出每个提供商失败的错误类型、HTTP状态码和错误代码，供管理员排查
+func formatAttempts(err error) string {
+	var completionErr *multichat.CompletionError
+	if !errors.As(err, &completionErr) || len(completionErr.Attempts) == 0 {
+		return ""
+	}
+	var lines []string
+	for _, attempt := range completionErr.Attempts {
+		line := fmt.Sprintf("• %s：%s", attempt.RouteStep, attempt.Class)
+		if detail := attempt.Detail(); detail != "" {
+			line += fmt.Sprintf("（%s）", detail)
+		}
+		lines = append(lines, line)
+	}
+	return "🔁 尝试记录：\n" + strings.Join(lines, "\n") + "\n\n"
+}
+
 // getAIReplyWithStreaming 获取AI回复，支持流式回调（支持所有提供商）
-func (h *MessageHandler) getAIReplyWithStreaming(userMessage string, message *tgbotapi.Message, callback func(string, bool) bool) (string, bool, *ChatStats, error) {
+// ctx 被取消时返回已生成的部分回复和 context.Canceled
+func (h *MessageHandler) getAIReplyWithStreaming(ctx context.Context, userMessage string, message *tgbotapi.Message, callback func(string, bool) bool) (string, bool, *ChatStats, error) {
 	chatID := message.Chat.ID
 
 	// 记录开始时间
@@ -240,16 +511,12 @@ func (h *MessageHandler) getAIReplyWithStreaming(userMessage string, message *tg
 	}
 
 	// 使用新的multichat系统获取响应，支持真正的流式回调
-	response, inputTokens, outputTokens, duration, remainingRounds, shouldReset, usedProvider, err := h.multichatManager.GetResponseWithCallback(chatID, userMessage, preferredProvider, preferredModel, callback)
-	if err != nil {
-		return "", false, nil, fmt.Errorf("failed to get AI response: %v", err)
+	response, inputTokens, outputTokens, duration, remainingRounds, shouldReset, route, err := h.multichatManager.GetResponseWithCallback(ctx, chatID, userMessage, preferredProvider, preferredModel, callback)
+	if errors.Is(err, context.Canceled) {
+		return response, false, nil, err
 	}
-
-	// 计算实际使用的模型
-	actualModel := preferredModel
-	if actualModel == "" {
-		// 获取实际使用的默认模型ID
-		actualModel = h.multichatManager.GetService().GetDefaultModel(usedProvider)
+	if err != nil {
+		return "", false, nil, fmt.Errorf("failed to get AI response: %w", err)
 	}
 
 	// 创建统计信息
@@ -258,8 +525,9 @@ func (h *MessageHandler) getAIReplyWithStreaming(userMessage string, message *tg
 		OutputTokens:    outputTokens,
 		RemainingRounds: remainingRounds,
 		Duration:        time.Since(startTime),
-		Provider:        usedProvider,
-		Model:           actualModel,
+		Provider:        route.Provider,
+		Model:           route.Model,
+		Route:           route,
 		TTL:             24 * time.Hour,                                                                // 默认24小时TTL，可以从配置读取
 		IsCachedReply:   inputTokens == 0 && outputTokens == 0 && strings.Contains(response, "💾 缓存回复"), // 判断是否为缓存回复
 	}
@@ -270,7 +538,7 @@ func (h *MessageHandler) getAIReplyWithStreaming(userMessage string, message *tg
 			Content:      response,
 			InputTokens:  inputTokens,
 			OutputTokens: outputTokens,
-			Provider:     usedProvider,
+			Provider:     route.Provider,
 		},
 		duration,
 		0, 0, // totalInput, totalOutput 由Manager内部管理
@@ -284,9 +552,14 @@ func (h *MessageHandler) getAIReplyWithStreaming(userMessage string, message *tg
 func (h *MessageHandler) handleTelegraphTextContent(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
 	chatID := message.Chat.ID
 	content := message.Text
+	if requiresReview(h.conf, message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(chatID, telegraphReviewText))
+		h.state.Delete(chatID)
+		return
+	}
 
 	// 创建 Telegraph 文本页面
-	err := h.telegraphHandler.HandleTextUpload(state.TelegraphKey, state.MatchType, state.TelegraphTitle, content)
+	err := h.telegraphHandler.HandleTextUpload(state.TelegraphKey, state.MatchType, state.TelegraphTitle, content, message.From.ID)
 	if err != nil {
 		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ 创建 Telegraph 页面失败：%v", err)))
 		h.state.Delete(chatID)
@@ -305,6 +578,11 @@ func (h *MessageHandler) handleTelegraphTextContent(bot *tgbotapi.BotAPI, messag
 // handleTelegraphImageContent 处理 Telegraph 图片内容
 func (h *MessageHandler) handleTelegraphImageContent(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
 	chatID := message.Chat.ID
+	if requiresReview(h.conf, message.From.ID) {
+		bot.Send(tgbotapi.NewMessage(chatID, telegraphReviewText))
+		h.state.Delete(chatID)
+		return
+	}
 
 	if message.Photo == nil {
 		bot.Send(tgbotapi.NewMessage(chatID, "❌ 请发送一张图片"))
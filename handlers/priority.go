@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// overlapWarningLimit 添加条目时最多列出的重叠条目数量
+const overlapWarningLimit = 5
+
+// matchPolicy 根据配置生成多条目命中时的处理策略
+func matchPolicy(conf *config.Config) database.MatchPolicy {
+	policy := database.MatchPolicy{BestOnly: conf.FAQ.MatchPolicy == config.MatchPolicyBest}
+	for _, name := range conf.FAQ.MatchOrder {
+		if mt := database.MatchType(strings.ToLower(name)); mt.IsValid() {
+			policy.Order = append(policy.Order, mt)
+		}
+	}
+	return policy
+}
+
+// overlapWarning 检查新条目与已有条目的重叠，返回提示文字，没有重叠时返回空字符串
+// 应在写入前调用，尚未保存的条目 ID 最大，同等条件下排在已有条目之后
+func overlapWarning(db database.Database, conf *config.Config, key string, matchType database.MatchType) string {
+	policy := matchPolicy(conf)
+	entry := database.Entry{ID: math.MaxInt, Key: key, MatchType: matchType}
+	if existing, err := findEntryByKeyInTypes(db, key, matchType); err == nil && existing != nil && existing.MatchType == matchType {
+		entry = *existing
+	}
+	overlaps, err := database.FindOverlaps(db, entry, policy)
+	if err != nil {
+		log.Printf("Error checking overlaps for entry %s: %v", key, err)
+		return ""
+	}
+	if len(overlaps) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("⚠️ 与 %d 个已有条目存在重叠：", len(overlaps)))
+	for i, overlap := range overlaps {
+		if i == overlapWarningLimit {
+			b.WriteString("\n…")
+			break
+		}
+		winner := "新条目优先"
+		if overlap.Shadowed {
+			winner = "已有条目优先"
+		}
+		b.WriteString(fmt.Sprintf("\n• %s(%s)：输入「%s」时%s", overlap.Entry.Key,
+			utils.GetMatchTypeText(overlap.Entry.MatchType), overlap.Sample, winner))
+	}
+	if policy.BestOnly {
+		b.WriteString("\n当前只回复优先级最高的条目，可在 /list 中调整优先级")
+	}
+	return b.String()
+}
+
+// HandlePriorityPrompt 提示输入条目的优先级
+func (h *ListHandler) HandlePriorityPrompt(bot *tgbotapi.BotAPI, message *tgbotapi.Message, entryID int, matchType int) {
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型转换错误"))
+		return
+	}
+	entry, err := h.db.QueryByID(entryID, matchTypeValue)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到条目"))
+		return
+	}
+
+	h.state.Set(message.Chat.ID, &Conversation{
+		Stage:     "awaiting_priority",
+		EntryID:   entryID,
+		OldType:   matchType,
+		MessageID: message.MessageID,
+	})
+
+	msgText := fmt.Sprintf("Key: %s\n当前优先级：%d\n\n请输入新的优先级（整数，默认 0）。多个条目同时命中时优先级高的排在前面，相同优先级按匹配类型顺序决定", entry.Key, entry.Priority)
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, msgText)
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("entry_%d_%d", entryID, matchType)),
+			tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
+		},
+	}
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	bot.Send(editMsg)
+}
+
+func (h *MessageHandler) handlePriorityInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	if !IsAdminUser(message.From.ID, h.conf) {
+		return
+	}
+	priority, err := strconv.Atoi(strings.TrimSpace(message.Text))
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "请输入整数，例如：10"))
+		return
+	}
+
+	matchType, err := database.MatchTypeFromInt(state.OldType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "类型转换错误"))
+		h.state.Delete(chatID)
+		return
+	}
+	entry, err := h.db.QueryByID(state.EntryID, matchType)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "未找到条目"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	patch := database.MetaPatch{Priority: &priority}
+	if requiresReview(h.conf, message.From.ID) {
+		h.submitChange(bot, message, state, metaChange(entry, patch))
+		return
+	}
+	if err := saveEntryMeta(h.db, entry, message.From.ID, patch); err != nil {
+		log.Printf("Error saving priority for entry %s: %v", entry.Key, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "保存优先级失败"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("已设置优先级\nKey: %s\n优先级：%d", entry.Key, priority)))
+	h.state.Delete(chatID)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestOverlapWarning(t *testing.T) {
+	db := newTestDB(t)
+	for _, key := range []string{"退款", "发票"} {
+		if err := db.AddEntry(key, database.MatchExact, "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	conf := &config.Config{}
+
+	if got := overlapWarning(db, conf, "地址", database.MatchContains); got != "" {
+		t.Errorf("overlapWarning() = %q, want empty", got)
+	}
+	got := overlapWarning(db, conf, "退", database.MatchContains)
+	if !strings.Contains(got, "与 1 个已有条目存在重叠") || !strings.Contains(got, "输入「退款」时已有条目优先") {
+		t.Errorf("overlapWarning() = %q, want the exact entry to win", got)
+	}
+
+	conf.FAQ.MatchPolicy = config.MatchPolicyBest
+	if got := overlapWarning(db, conf, "退", database.MatchContains); !strings.Contains(got, "只回复优先级最高的条目") {
+		t.Errorf("overlapWarning() = %q, want the best-only hint", got)
+	}
+}
+
+func TestSubmitChangeRecordsOverlap(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AddEntry("退款", database.MatchExact, "v"); err != nil {
+		t.Fatal(err)
+	}
+	// 没有超级管理员时不会发送通知
+	conf := &config.Config{FAQ: config.FAQConfig{ReviewMode: true}}
+	change := database.PendingChange{Action: database.ChangeAdd, Key: "退", MatchType: database.MatchContains, Value: "v"}
+	if err := submitChange(nil, db, conf, &tgbotapi.User{ID: 1}, 1, change); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := db.ListPendingChanges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 1 || !strings.Contains(changes[0].Warning, "退款") {
+		t.Fatalf("pending changes = %+v, want the overlap warning recorded", changes)
+	}
+	if text := formatChange(db, &changes[0]); !strings.Contains(text, changes[0].Warning) {
+		t.Errorf("formatChange() = %q, want the warning shown to the approver", text)
+	}
+}
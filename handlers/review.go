@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// requiresReview 审核模式下普通管理员的修改需要超级管理员批准
+func requiresReview(conf *config.Config, userID int64) bool {
+	return conf.FAQ.ReviewMode && !IsSuperAdminUser(userID, conf)
+}
+
+// telegraphReviewText Telegraph 页面创建后立即公开，无法等待审核，审核模式下只允许超级管理员发布
+const telegraphReviewText = "审核模式下只有超级管理员可以发布 Telegraph 条目"
+
+// userDisplayName 返回用户的显示名称
+func userDisplayName(user *tgbotapi.User) string {
+	if user == nil {
+		return ""
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.UserName != "" {
+		name += " @" + user.UserName
+	}
+	return strings.TrimSpace(name)
+}
+
+// changeActionText 返回修改操作的显示文字
+func changeActionText(action string) string {
+	switch action {
+	case database.ChangeAdd:
+		return "新增"
+	case database.ChangeUpdate:
+		return "更新"
+	case database.ChangeDelete:
+		return "删除"
+	case database.ChangeMeta:
+		return "修改属性"
+	default:
+		return action
+	}
+}
+
+// submitChange 保存待审核的修改并通知超级管理员
+func submitChange(bot *tgbotapi.BotAPI, db database.Database, conf *config.Config, from *tgbotapi.User, chatID int64, change database.PendingChange) error {
+	change.ID = time.Now().UnixNano()
+	change.RequestedBy = from.ID
+	change.RequesterName = userDisplayName(from)
+	change.ChatID = chatID
+	change.CreatedAt = time.Now()
+	if change.Action == database.ChangeAdd {
+		change.Warning = overlapWarning(db, conf, change.Key, change.MatchType)
+	}
+	if err := db.AddPendingChange(change); err != nil {
+		return err
+	}
+
+	text := formatChange(db, &change)
+	for _, adminID := range conf.Admin.SuperAdminIDs {
+		msg := tgbotapi.NewMessage(adminID, text)
+		msg.ReplyMarkup = reviewKeyboard(change.ID)
+		if _, err := bot.Send(msg); err != nil {
+			log.Printf("Error notifying super admin %d of change %d: %v", adminID, change.ID, err)
+		}
+	}
+	return nil
+}
+
+// metaChange 返回修改条目扩展属性的待审核修改
+func metaChange(entry *database.Entry, patch database.MetaPatch) database.PendingChange {
+	return database.PendingChange{Action: database.ChangeMeta, Key: entry.Key, MatchType: entry.MatchType, Patch: &patch}
+}
+
+// saveEntryMeta 直接保存扩展属性的修改并记录修改者
+func saveEntryMeta(db database.Database, entry *database.Entry, userID int64, patch database.MetaPatch) error {
+	if err := db.UpdateEntryMeta(entry.Key, entry.MatchType, patch.Apply); err != nil {
+		return err
+	}
+	stampEntry(db, entry.Key, entry.MatchType, userID, false)
+	return nil
+}
+
+// reviewSubmitted 提交审核后回复给管理员的提示
+func reviewSubmitted(change database.PendingChange) string {
+	return fmt.Sprintf("📝 已提交审核：%s %s(%s)\n超级管理员批准后生效", changeActionText(change.Action), change.Key, utils.GetMatchTypeText(change.MatchType))
+}
+
+func reviewKeyboard(id int64) *tgbotapi.InlineKeyboardMarkup {
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("✅ 批准", fmt.Sprintf("review_ok_%d", id)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ 拒绝", fmt.Sprintf("review_no_%d", id)),
+		},
+	}}
+}
+
+// formatChange 生成修改的说明和内容差异
+func formatChange(db database.Database, change *database.PendingChange) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("📝 待审核的修改\n提交者：%s (%d)\n时间：%s\n操作：%s\nKey: %s\n",
+		change.RequesterName, change.RequestedBy, change.CreatedAt.Format("2006-01-02 15:04"),
+		changeActionText(change.Action), change.Key))
+
+	var current *database.Entry
+	if change.Action != database.ChangeAdd {
+		if entry, err := findEntryByKeyInTypes(db, change.Key, change.MatchType); err == nil && entry != nil && entry.MatchType == change.MatchType {
+			current = entry
+		}
+	}
+
+	switch change.Action {
+	case database.ChangeAdd:
+		b.WriteString(fmt.Sprintf("类型：%s\n\n", utils.GetMatchTypeText(change.MatchType)))
+		if change.Warning != "" {
+			b.WriteString(change.Warning + "\n\n")
+		}
+		if change.Meta != nil && len(change.Meta.Media) > 0 {
+			b.WriteString(describeMedia(*change.Meta) + "\n")
+		}
+		b.WriteString(strings.Join(lineDiff("", change.Value), "\n"))
+		if change.Meta != nil && len(change.Meta.Buttons) > 0 {
+			b.WriteString("\n" + describeButtons(change.Meta.Buttons))
+		}
+	case database.ChangeUpdate:
+		if change.NewType != change.MatchType {
+			b.WriteString(fmt.Sprintf("类型：%s → %s\n\n", utils.GetMatchTypeText(change.MatchType), utils.GetMatchTypeText(change.NewType)))
+		} else {
+			b.WriteString(fmt.Sprintf("类型：%s\n\n", utils.GetMatchTypeText(change.MatchType)))
+		}
+		oldValue := ""
+		if current != nil {
+			oldValue = current.Value
+		} else {
+			b.WriteString("⚠️ 条目当前不存在\n")
+		}
+		b.WriteString(strings.Join(lineDiff(oldValue, change.Value), "\n"))
+		if change.SetButtons {
+			if len(change.Buttons) > 0 {
+				b.WriteString("\n新" + describeButtons(change.Buttons))
+			} else {
+				b.WriteString("\n清除按钮")
+			}
+		}
+	case database.ChangeDelete:
+		b.WriteString(fmt.Sprintf("类型：%s\n\n", utils.GetMatchTypeText(change.MatchType)))
+		if current == nil {
+			b.WriteString("⚠️ 条目当前不存在")
+		} else {
+			b.WriteString(strings.Join(lineDiff(current.Value, ""), "\n"))
+		}
+	case database.ChangeMeta:
+		b.WriteString(fmt.Sprintf("类型：%s\n\n", utils.GetMatchTypeText(change.MatchType)))
+		if current == nil {
+			b.WriteString("⚠️ 条目当前不存在\n")
+		}
+		b.WriteString(describePatch(current, change.Patch))
+	}
+
+	// Telegram 消息长度上限为 4096 个字符，审核按钮仍需保留
+	text := []rune(b.String())
+	if len(text) > 4000 {
+		return string(text[:4000]) + "\n…"
+	}
+	return string(text)
+}
+
+// describePatch 描述扩展属性修改前后的变化，current 为空时按没有扩展属性的条目比较
+func describePatch(current *database.Entry, patch *database.MetaPatch) string {
+	if patch == nil {
+		return ""
+	}
+	var before database.Entry
+	if current != nil {
+		before = *current
+	}
+	after := before
+	patch.Apply(&after.EntryMeta)
+
+	var lines []string
+	if patch.Translation != nil {
+		lang := patch.Translation.Language
+		lines = append(lines, fmt.Sprintf("翻译（%s）：", lang))
+		lines = append(lines, lineDiff(before.Translations[lang], after.Translations[lang])...)
+	}
+	if patch.Validity != nil {
+		now := time.Now()
+		validity := func(entry *database.Entry) string {
+			if desc := describeValidity(entry, now); desc != "" {
+				return desc
+			}
+			return "有效期：无"
+		}
+		lines = append(lines, "- "+validity(&before), "+ "+validity(&after))
+	}
+	if patch.Priority != nil {
+		lines = append(lines, fmt.Sprintf("优先级：%d → %d", before.Priority, after.Priority))
+	}
+	if patch.Visibility != nil {
+		lines = append(lines, fmt.Sprintf("可见性：%s → %s", visibilityText(&before), visibilityText(&after)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lineDiff 按行比较新旧内容，删除的行以 "- " 开头，新增的行以 "+ " 开头
+func lineDiff(oldText, newText string) []string {
+	var oldLines, newLines []string
+	if oldText != "" {
+		oldLines = strings.Split(oldText, "\n")
+	}
+	if newText != "" {
+		newLines = strings.Split(newText, "\n")
+	}
+
+	// 最长公共子序列
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			diff = append(diff, "  "+oldLines[i])
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+oldLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+newLines[j])
+			j++
+		}
+	}
+	for ; i < len(oldLines); i++ {
+		diff = append(diff, "- "+oldLines[i])
+	}
+	for ; j < len(newLines); j++ {
+		diff = append(diff, "+ "+newLines[j])
+	}
+	return diff
+}
+
+// handlePendingCommand 向超级管理员列出所有待审核的修改
+func (h *CommandHandler) handlePendingCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	changes, err := h.db.ListPendingChanges()
+	if err != nil {
+		log.Printf("Error listing pending changes: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无法获取待审核的修改"))
+		return
+	}
+	if len(changes) == 0 {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "✅ 没有待审核的修改"))
+		return
+	}
+	for i := range changes {
+		msg := tgbotapi.NewMessage(message.Chat.ID, formatChange(h.db, &changes[i]))
+		msg.ReplyMarkup = reviewKeyboard(changes[i].ID)
+		bot.Send(msg)
+	}
+}
+
+func (h *CallbackHandler) handleReviewCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string, chatID int64, messageID int) {
+	// 回调数据: review_ok_<id> 或 review_no_<id>
+	parts := strings.Split(strings.TrimPrefix(data, "review_"), "_")
+	if len(parts) != 2 {
+		log.Printf("Error parsing review callback: %s", data)
+		return
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		log.Printf("Error parsing change ID: %v", err)
+		return
+	}
+	if !IsSuperAdminUser(callbackQuery.From.ID, h.conf) {
+		bot.Send(tgbotapi.NewMessage(chatID, "无权限"))
+		return
+	}
+
+	change, err := database.FindPendingChange(h.db, id)
+	if err != nil {
+		log.Printf("Error finding pending change %d: %v", id, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "无法获取待审核的修改"))
+		return
+	}
+	if change == nil {
+		bot.Send(tgbotapi.NewEditMessageText(chatID, messageID, "该修改已被处理"))
+		return
+	}
+
+	approved := parts[0] == "ok"
+	if approved {
+		if err := database.ApplyChange(h.db, *change); err != nil {
+			log.Printf("Error applying change %d: %v", id, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "应用修改失败："+err.Error()))
+			return
+		}
+	}
+	if err := h.db.DeletePendingChange(id); err != nil {
+		log.Printf("Error deleting pending change %d: %v", id, err)
+	}
+
+	result := "❌ 已拒绝"
+	if approved {
+		result = "✅ 已批准"
+	}
+	reviewer := userDisplayName(callbackQuery.From)
+	editMsg := tgbotapi.NewEditMessageText(chatID, messageID, fmt.Sprintf("%s\n\n%s（%s）", callbackQuery.Message.Text, result, reviewer))
+	bot.Send(editMsg)
+
+	notice := fmt.Sprintf("%s你提交的修改：%s %s(%s)", result, changeActionText(change.Action), change.Key, utils.GetMatchTypeText(change.MatchType))
+	bot.Send(tgbotapi.NewMessage(change.ChatID, notice))
+}
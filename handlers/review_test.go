@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestLineDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{name: "both empty", want: nil},
+		{name: "added", new: "a\nb", want: []string{"+ a", "+ b"}},
+		{name: "removed", old: "a\nb", want: []string{"- a", "- b"}},
+		{name: "unchanged", old: "a\nb", new: "a\nb", want: []string{"  a", "  b"}},
+		{name: "changed middle line", old: "a\nb\nc", new: "a\nx\nc", want: []string{"  a", "- b", "+ x", "  c"}},
+		{name: "inserted and appended", old: "a\nc", new: "a\nb\nc\nd", want: []string{"  a", "+ b", "  c", "+ d"}},
+		{name: "moved line", old: "a\nb", new: "b\na", want: []string{"- a", "  b", "+ a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineDiff(tt.old, tt.new); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("lineDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatChange(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AddEntry("退款", database.MatchExact, "7 天内可退款\n联系客服"); err != nil {
+		t.Fatal(err)
+	}
+	priority := 3
+	created := time.Date(2026, 5, 1, 9, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		change database.PendingChange
+		want   []string
+	}{
+		{
+			name: "add shows the new value and buttons",
+			change: database.PendingChange{Action: database.ChangeAdd, Key: "发票", MatchType: database.MatchContains, Value: "开具发票",
+				Meta: &database.EntryMeta{Buttons: [][]database.EntryButton{{{Text: "官网", Type: database.ButtonURL, URL: "https://example.com"}}}}},
+			want: []string{"操作：新增", "Key: 发票", "+ 开具发票", "官网"},
+		},
+		{
+			name:   "update diffs against the current value",
+			change: database.PendingChange{Action: database.ChangeUpdate, Key: "退款", MatchType: database.MatchExact, NewType: database.MatchContains, Value: "30 天内可退款\n联系客服"},
+			want:   []string{"操作：更新", "→", "- 7 天内可退款", "+ 30 天内可退款", "  联系客服"},
+		},
+		{
+			name:   "update clears buttons",
+			change: database.PendingChange{Action: database.ChangeUpdate, Key: "退款", MatchType: database.MatchExact, NewType: database.MatchExact, Value: "7 天内可退款\n联系客服", SetButtons: true},
+			want:   []string{"清除按钮"},
+		},
+		{
+			name:   "update of a missing entry",
+			change: database.PendingChange{Action: database.ChangeUpdate, Key: "地址", MatchType: database.MatchExact, NewType: database.MatchExact, Value: "上海"},
+			want:   []string{"⚠️ 条目当前不存在", "+ 上海"},
+		},
+		{
+			name:   "delete shows the removed value",
+			change: database.PendingChange{Action: database.ChangeDelete, Key: "退款", MatchType: database.MatchExact},
+			want:   []string{"操作：删除", "- 7 天内可退款", "- 联系客服"},
+		},
+		{
+			name:   "meta shows the patched fields",
+			change: database.PendingChange{Action: database.ChangeMeta, Key: "退款", MatchType: database.MatchExact, Patch: &database.MetaPatch{Priority: &priority}},
+			want:   []string{"优先级：0 → 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change.RequesterName = "Alice"
+			tt.change.RequestedBy = 1001
+			tt.change.CreatedAt = created
+			got := formatChange(db, &tt.change)
+			for _, want := range append([]string{"提交者：Alice (1001)", "2026-05-01 09:30"}, tt.want...) {
+				if !strings.Contains(got, want) {
+					t.Errorf("formatChange() = %q, want it to contain %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatChangeTruncates(t *testing.T) {
+	db := newTestDB(t)
+	change := database.PendingChange{Action: database.ChangeAdd, Key: "长", MatchType: database.MatchExact, Value: strings.Repeat("退款说明\n", 2000)}
+	got := []rune(formatChange(db, &change))
+	if len(got) > 4096 || !strings.HasSuffix(string(got), "\n…") {
+		t.Errorf("formatChange() returned %d characters, want it truncated below the Telegram limit", len(got))
+	}
+}
+
+func TestRequiresReview(t *testing.T) {
+	conf := &config.Config{Admin: config.AdminConfig{SuperAdminIDs: []int64{1}, AdminIDs: []int64{2}}}
+	if requiresReview(conf, 2) {
+		t.Errorf("requiresReview() = true with review mode off")
+	}
+	conf.FAQ.ReviewMode = true
+	if requiresReview(conf, 1) {
+		t.Errorf("requiresReview() = true for a super admin")
+	}
+	if !requiresReview(conf, 2) {
+		t.Errorf("requiresReview() = false for an admin in review mode")
+	}
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// statsDefaultTop /stats 默认显示的热门条目数量
+const statsDefaultTop = 10
+
+// statsNeverHitLimit 报告中最多列出的从未命中条目数量
+const statsNeverHitLimit = 20
+
+// recordHit 记录一次条目命中
+func recordHit(db database.Database, entry *database.Entry, chatID int64) {
+	err := db.RecordHit(database.Hit{
+		EntryID:   entry.ID,
+		MatchType: entry.MatchType,
+		EntryKey:  entry.Key,
+		ChatID:    chatID,
+		Time:      time.Now(),
+	})
+	if err != nil {
+		log.Printf("Error recording hit for entry %d: %v", entry.ID, err)
+	}
+}
+
+func (h *CommandHandler) handleStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	top := statsDefaultTop
+	if args := strings.TrimSpace(message.CommandArguments()); args != "" {
+		n, err := strconv.Atoi(args)
+		if err != nil || n <= 0 {
+			bot.Send(tgbotapi.NewMessage(message.Chat.ID, "格式错误，请使用：/stats [热门条目数量]"))
+			return
+		}
+		top = n
+	}
+
+	report, err := database.BuildHitReport(h.db, time.Now(), top)
+	if err != nil {
+		log.Printf("Error building hit report: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "获取统计失败"))
+		return
+	}
+
+	text := FormatHitReport(report)
+	if h.multichatManager != nil {
+		if failures := formatErrorCounts(h.multichatManager.GetErrorCounts()); failures != "" {
+			text += "\n\n" + failures
+		}
+	}
+	bot.Send(tgbotapi.NewMessage(message.Chat.ID, text))
+}
+
+// formatErrorCounts 生成 AI 提供商失败次数的统计，没有失败时返回空字符串
+func formatErrorCounts(counts map[string]map[string]int64) string {
+	if len(counts) == 0 {
+		return ""
+	}
+	providers := make([]string, 0, len(counts))
+	for name := range counts {
+		providers = append(providers, name)
+	}
+	sort.Strings(providers)
+
+	var b strings.Builder
+	b.WriteString("⚠️ AI 提供商失败次数（自启动以来）")
+	for _, name := range providers {
+		classes := make([]string, 0, len(counts[name]))
+		for class := range counts[name] {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		items := make([]string, len(classes))
+		for i, class := range classes {
+			items[i] = fmt.Sprintf("%s %d", class, counts[name][class])
+		}
+		b.WriteString(fmt.Sprintf("\n• %s：%s", name, strings.Join(items, "，")))
+	}
+	return b.String()
+}
+
+// FormatHitReport 将命中统计报告格式化为文本
+func FormatHitReport(report *database.HitReport) string {
+	var b strings.Builder
+	b.WriteString("📊 FAQ 命中统计\n")
+	b.WriteString(fmt.Sprintf("总命中：%d 次\n", report.TotalHits))
+
+	b.WriteString("\n📈 趋势\n")
+	for _, period := range report.Periods {
+		b.WriteString(fmt.Sprintf("• 近 %d 天：%d 次（%d 个聊天）%s\n",
+			period.Days, period.Hits, period.Chats, formatTrend(period)))
+	}
+
+	b.WriteString(fmt.Sprintf("\n🔥 热门条目（前 %d）\n", len(report.Top)))
+	if len(report.Top) == 0 {
+		b.WriteString("暂无命中记录\n")
+	}
+	for i, count := range report.Top {
+		b.WriteString(fmt.Sprintf("%d. %s（%s）— %d 次，最近 %s\n",
+			i+1, count.Key, utils.GetMatchTypeText(count.MatchType), count.Count, count.LastHit.Format("2006-01-02 15:04")))
+	}
+
+	b.WriteString(fmt.Sprintf("\n💤 从未命中的条目（%d 个）\n", len(report.NeverHit)))
+	for i, entry := range report.NeverHit {
+		if i == statsNeverHitLimit {
+			b.WriteString(fmt.Sprintf("…等 %d 个\n", len(report.NeverHit)))
+			break
+		}
+		b.WriteString(fmt.Sprintf("• %s（%s）\n", entry.Key, utils.GetMatchTypeText(entry.MatchType)))
+	}
+
+	return b.String()
+}
+
+// formatTrend 与上一个同等长度的周期比较命中次数
+func formatTrend(period database.HitPeriod) string {
+	diff := period.Hits - period.PreviousHits
+	switch {
+	case diff > 0:
+		return fmt.Sprintf("↑ 较前 %d 天 +%d", period.Days, diff)
+	case diff < 0:
+		return fmt.Sprintf("↓ 较前 %d 天 %d", period.Days, diff)
+	default:
+		return "→ 持平"
+	}
+}
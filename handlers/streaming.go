@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"regexp"
@@ -10,6 +11,8 @@ import (
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	tg_markdown "github.com/zavitkov/tg-markdown"
+
+	"TGFaqBot/multichat"
 )
 
 const (
@@ -19,23 +22,26 @@ const (
 
 // ChatStats 聊天统计信息
 type ChatStats struct {
-	InputTokens     int           // 输入tokens
-	OutputTokens    int           // 输出tokens
-	RemainingRounds int           // 剩余对话轮数
-	Duration        time.Duration // 请求耗时
-	Provider        string        // 使用的提供商
-	Model           string        // 使用的模型
-	TTL             time.Duration // 对话历史TTL
-	IsCachedReply   bool          // 是否为缓存回复
+	InputTokens     int              // 输入tokens
+	OutputTokens    int              // 输出tokens
+	RemainingRounds int              // 剩余对话轮数
+	Duration        time.Duration    // 请求耗时
+	Provider        string           // 使用的提供商
+	Model           string           // 使用的模型
+	Route           *multichat.Route // 实际路由，发生回退时包含失败的尝试
+	TTL             time.Duration    // 对话历史TTL
+	IsCachedReply   bool             // 是否为缓存回复
 }
 
 // StreamingMessage 流式消息结构
 type StreamingMessage struct {
 	ChatID    int64
 	MessageID int
+	OwnerID   int64 // 提问的用户，只有提问者和管理员可以停止回复
 	Content   string
 	LastEdit  time.Time
 	Mutex     sync.Mutex
+	cancel    context.CancelFunc
 }
 
 // StreamingManager 流式输出管理器
@@ -53,17 +59,51 @@ func NewStreamingManager() *StreamingManager {
 	}
 }
 
-// CreateStream 创建流式消息
-func (m *StreamingManager) CreateStream(streamKey string, chatID int64, messageID int) {
+// CreateStream 创建流式消息，cancel 用于停止按钮取消进行中的AI请求
+func (m *StreamingManager) CreateStream(streamKey string, chatID int64, messageID int, ownerID int64, cancel context.CancelFunc) {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
 	m.messages[streamKey] = &StreamingMessage{
 		ChatID:    chatID,
 		MessageID: messageID,
+		OwnerID:   ownerID,
 		Content:   "",
 		LastEdit:  time.Now(),
+		cancel:    cancel,
+	}
+}
+
+// StopStream 取消流式消息对应的AI请求
+func (m *StreamingManager) StopStream(streamKey string, userID int64, isAdmin bool) error {
+	m.mutex.RLock()
+	streaming, exists := m.messages[streamKey]
+	m.mutex.RUnlock()
+
+	if !exists || streaming.cancel == nil {
+		return fmt.Errorf("回复已结束")
+	}
+	if streaming.OwnerID != userID && !isAdmin {
+		return fmt.Errorf("只有提问者可以停止回复")
 	}
+	streaming.cancel()
+	return nil
+}
+
+// ShowStopped 将流式消息替换为已停止时的部分内容，并移除停止按钮
+func (m *StreamingManager) ShowStopped(bot *tgbotapi.BotAPI, streamKey string, partialContent string) {
+	content := "⏹ 已停止"
+	if strings.TrimSpace(partialContent) != "" {
+		content = partialContent + "\n\n" + content
+	}
+	m.UpdateStream(bot, streamKey, content, true, nil)
+}
+
+// stopKeyboard 返回流式消息上的停止按钮
+func stopKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("⏹ 停止", "aistop"),
+	))
 }
 
 // UpdateStream 更新流式消息内容
@@ -110,6 +150,11 @@ func (m *StreamingManager) UpdateStream(bot *tgbotapi.BotAPI, streamKey string,
 		convertedContent := tg_markdown.ConvertMarkdownToTelegramMarkdownV2(displayContent)
 
 		editMsg := tgbotapi.NewEditMessageText(streaming.ChatID, streaming.MessageID, convertedContent)
+		// 生成过程中保留停止按钮，完成后编辑不带按钮即可移除
+		if !isComplete && streaming.cancel != nil {
+			keyboard := stopKeyboard()
+			editMsg.ReplyMarkup = &keyboard
+		}
 
 		// 首先尝试MarkdownV2格式
 		editMsg.ParseMode = "MarkdownV2"
@@ -161,6 +206,10 @@ func (m *StreamingManager) AppendStats(bot *tgbotapi.BotAPI, streamKey string, s
 		statsText += fmt.Sprintf("\n• 使用模型: %s", stats.Model)
 	}
 
+	if stats.Route != nil && len(stats.Route.Attempts) > 0 {
+		statsText += fmt.Sprintf("\n• 回退路径: %s", stats.Route)
+	}
+
 	if stats.TTL > 0 {
 		// 计算TTL的小时和分钟
 		hours := int(stats.TTL.Hours())
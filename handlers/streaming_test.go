@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+)
+
+func TestStopStream(t *testing.T) {
+	const owner, other = 1, 2
+
+	tests := []struct {
+		name       string
+		noCancel   bool
+		key        string
+		userID     int64
+		isAdmin    bool
+		wantErr    string
+		wantCancel bool
+	}{
+		{name: "owner stops", key: "100_1", userID: owner, wantCancel: true},
+		{name: "admin stops another user's reply", key: "100_1", userID: other, isAdmin: true, wantCancel: true},
+		{name: "other user is refused", key: "100_1", userID: other, wantErr: "只有提问者可以停止回复"},
+		{name: "finished reply", key: "100_2", userID: owner, wantErr: "回复已结束"},
+		{name: "stream without cancel", noCancel: true, key: "100_1", userID: owner, wantErr: "回复已结束"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewStreamingManager()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.noCancel {
+				m.CreateStream("100_1", 100, 1, owner, nil)
+			} else {
+				m.CreateStream("100_1", 100, 1, owner, cancel)
+			}
+
+			err := m.StopStream(tt.key, tt.userID, tt.isAdmin)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("StopStream() error = %v, want %q", err, tt.wantErr)
+			}
+			if cancelled := ctx.Err() != nil; cancelled != tt.wantCancel {
+				t.Errorf("request cancelled = %v, want %v", cancelled, tt.wantCancel)
+			}
+		})
+	}
+}
+
+func TestUpdateStreamStopButton(t *testing.T) {
+	bot, fake := newTestBot(t)
+	m := NewStreamingManager()
+	m.CreateStream("100_1", 100, 1, 1, func() {})
+	m.CreateStream("100_2", 100, 2, 1, nil)
+
+	// 生成中的回复带停止按钮，没有取消函数或已完成的回复不带
+	m.UpdateStream(bot, "100_1", strings.Repeat("a", MinEditThreshold*2), false, nil)
+	m.UpdateStream(bot, "100_2", strings.Repeat("a", MinEditThreshold*2), false, nil)
+	m.ShowStopped(bot, "100_1", "部分回答")
+
+	calls := fake.calls("editMessageText")
+	if len(calls) != 3 {
+		t.Fatalf("editMessageText called %d times, want 3", len(calls))
+	}
+	if markup := calls[0].Params.Get("reply_markup"); !strings.Contains(markup, `"aistop"`) {
+		t.Errorf("reply_markup = %q, want the stop button", markup)
+	}
+	for _, call := range calls[1:] {
+		if markup := call.Params.Get("reply_markup"); markup != "" {
+			t.Errorf("reply_markup = %q, want none", markup)
+		}
+	}
+	if text := calls[2].Params.Get("text"); !strings.Contains(text, "部分回答") || !strings.Contains(text, "已停止") {
+		t.Errorf("stopped text = %q, want the partial answer and the stopped note", text)
+	}
+}
+
+func TestHandleStopCallback(t *testing.T) {
+	const owner, admin, other = 1, 2, 3
+	conf := &config.Config{Admin: config.AdminConfig{AdminIDs: []int64{admin}}}
+
+	tests := []struct {
+		name     string
+		from     int64
+		wantText string
+	}{
+		{name: "owner", from: owner, wantText: "已停止"},
+		{name: "admin", from: admin, wantText: "已停止"},
+		{name: "other user", from: other, wantText: "只有提问者可以停止回复"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, fake := newTestBot(t)
+			h := NewCallbackHandler(nil, conf, nil, NewStreamingManager(), nil, nil)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			h.streamer.CreateStream("100_10", 100, 10, owner, cancel)
+
+			h.handleStopCallback(bot, &tgbotapi.CallbackQuery{ID: "cb", From: &tgbotapi.User{ID: tt.from}}, 100, 10)
+
+			calls := fake.calls("answerCallbackQuery")
+			if len(calls) != 1 || calls[0].Params.Get("text") != tt.wantText {
+				t.Fatalf("answers = %+v, want one answer %q", calls, tt.wantText)
+			}
+			if cancelled := ctx.Err() != nil; cancelled != (tt.wantText == "已停止") {
+				t.Errorf("request cancelled = %v", cancelled)
+			}
+		})
+	}
+}
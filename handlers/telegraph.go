@@ -74,11 +74,15 @@ func (th *TelegraphHandler) HandleImageUpload(bot *tgbotapi.BotAPI, message *tgb
 	}
 
 	// 保存到数据库
-	return th.db.AddTelegraphEntry(key, matchType, content, "telegraph_image", page.URL, page.Path)
+	if err := th.db.AddTelegraphEntry(key, matchType, content, "telegraph_image", page.URL, page.Path); err != nil {
+		return err
+	}
+	stampEntry(th.db, key, matchType, message.From.ID, true)
+	return nil
 }
 
 // HandleTextUpload 处理文本上传到 Telegraph
-func (th *TelegraphHandler) HandleTextUpload(key string, matchType database.MatchType, title, content string) error {
+func (th *TelegraphHandler) HandleTextUpload(key string, matchType database.MatchType, title, content string, createdBy int64) error {
 	// 创建 Telegraph 页面
 	page, err := th.telegraph.CreateTextPage(title, content)
 	if err != nil {
@@ -86,7 +90,11 @@ func (th *TelegraphHandler) HandleTextUpload(key string, matchType database.Matc
 	}
 
 	// 保存到数据库
-	return th.db.AddTelegraphEntry(key, matchType, content, "telegraph_text", page.URL, page.Path)
+	if err := th.db.AddTelegraphEntry(key, matchType, content, "telegraph_text", page.URL, page.Path); err != nil {
+		return err
+	}
+	stampEntry(th.db, key, matchType, createdBy, true)
+	return nil
 }
 
 // SendTelegraphContent 发送 Telegraph 内容
@@ -158,7 +166,7 @@ func (th *TelegraphHandler) HandleTelegraphTextCommand(bot *tgbotapi.BotAPI, mes
 		return fmt.Errorf("content is required for text Telegraph pages")
 	}
 
-	err = th.HandleTextUpload(key, matchType, title, content)
+	err = th.HandleTextUpload(key, matchType, title, content, message.From.ID)
 	if err != nil {
 		return fmt.Errorf("failed to create Telegraph text page: %w", err)
 	}
@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/multichat"
+)
+
+// normalizeLanguage 将 Telegram 的语言代码（如 en-US、zh-hans）转换为主语言代码
+func normalizeLanguage(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	return code
+}
+
+// entryLanguages 返回条目需要展示的语言：配置中的语言与已有翻译的语言，不含默认语言
+func entryLanguages(conf *config.Config, entry *database.Entry) []string {
+	defaultLang := normalizeLanguage(conf.FAQ.DefaultLanguage)
+	seen := map[string]bool{defaultLang: true}
+	var languages []string
+	for _, lang := range conf.FAQ.Languages {
+		lang = normalizeLanguage(lang)
+		if !seen[lang] {
+			seen[lang] = true
+			languages = append(languages, lang)
+		}
+	}
+	var extra []string
+	for lang := range entry.Translations {
+		if !seen[lang] {
+			seen[lang] = true
+			extra = append(extra, lang)
+		}
+	}
+	sort.Strings(extra)
+	return append(languages, extra...)
+}
+
+// missingTranslations 返回配置中要求但条目尚未人工翻译的语言
+func missingTranslations(conf *config.Config, entry *database.Entry) []string {
+	seen := map[string]bool{normalizeLanguage(conf.FAQ.DefaultLanguage): true}
+	var missing []string
+	for _, lang := range conf.FAQ.Languages {
+		lang = normalizeLanguage(lang)
+		if seen[lang] {
+			continue
+		}
+		seen[lang] = true
+		if _, ok := entry.Translations[lang]; !ok {
+			missing = append(missing, lang)
+		}
+	}
+	return missing
+}
+
+// Translator 根据用户语言选择条目内容，缺少翻译时可调用AI翻译并缓存
+type Translator struct {
+	db           database.Database
+	conf         *config.Config
+	multichatMgr *multichat.Manager
+	// inflight 进行中的翻译，避免同一条目的同一语言被并发重复翻译，翻译结束后移除
+	inflight map[string]*pendingTranslation
+	mutex    sync.Mutex
+}
+
+// pendingTranslation 进行中的AI翻译，done 关闭后 text 和 err 可读
+type pendingTranslation struct {
+	done chan struct{}
+	text string
+	err  error
+}
+
+// NewTranslator 创建翻译器
+func NewTranslator(db database.Database, conf *config.Config, multichatMgr *multichat.Manager) *Translator {
+	return &Translator{
+		db:           db,
+		conf:         conf,
+		multichatMgr: multichatMgr,
+		inflight:     make(map[string]*pendingTranslation),
+	}
+}
+
+// Localize 返回按用户语言替换内容后的条目副本，无对应翻译时返回原条目
+func (t *Translator) Localize(entry *database.Entry, languageCode string) *database.Entry {
+	return t.localize(entry, languageCode, true)
+}
+
+// localizeStored 与 Localize 相同，但只使用已有的翻译，不发起 AI 翻译，用于需要快速响应的场景
+func (t *Translator) localizeStored(entry *database.Entry, languageCode string) *database.Entry {
+	return t.localize(entry, languageCode, false)
+}
+
+func (t *Translator) localize(entry *database.Entry, languageCode string, translate bool) *database.Entry {
+	lang := normalizeLanguage(languageCode)
+	if lang == "" || lang == normalizeLanguage(t.conf.FAQ.DefaultLanguage) {
+		return entry
+	}
+	// Telegraph 回答以链接发送，不做翻译
+	if strings.HasPrefix(entry.ContentType, "telegraph_") {
+		return entry
+	}
+
+	text, ok := entry.Translations[lang]
+	if !ok {
+		text, ok = t.machineTranslation(entry, lang, translate)
+	}
+	if !ok {
+		return entry
+	}
+
+	localized := *entry
+	if localized.HasMedia() {
+		localized.Caption = text
+	} else {
+		localized.Value = text
+	}
+	return &localized
+}
+
+// machineTranslation 返回有效的AI翻译缓存，缓存缺失或过期且 translate 为 true 时按需翻译，
+// 最多等待 translateWait，超时后翻译继续在后台进行
+func (t *Translator) machineTranslation(entry *database.Entry, lang string, translate bool) (string, bool) {
+	source := entry.AnswerText()
+	if source == "" {
+		return "", false
+	}
+	if cached, ok := entry.MachineTranslations[lang]; ok && cached.Source == source {
+		return cached.Text, true
+	}
+	if !translate || !t.shouldTranslate(lang) {
+		return "", false
+	}
+
+	pending := t.startTranslation(entry, lang, source)
+	select {
+	case <-pending.done:
+		return pending.text, pending.err == nil
+	case <-time.After(translateWait):
+		// 翻译完成后写入缓存，下次回答时使用
+		log.Printf("Translation of entry %s to %s is still running, answering with the original text", entry.Key, lang)
+		return "", false
+	}
+}
+
+// startTranslation 在后台翻译条目并缓存结果，同一条目的同一语言正在翻译时返回进行中的翻译
+func (t *Translator) startTranslation(entry *database.Entry, lang, source string) *pendingTranslation {
+	id, key, matchType := entry.ID, entry.Key, entry.MatchType
+	inflightKey := fmt.Sprintf("%d_%s_%s", id, matchType, lang)
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if pending, exists := t.inflight[inflightKey]; exists {
+		return pending
+	}
+	pending := &pendingTranslation{done: make(chan struct{})}
+	t.inflight[inflightKey] = pending
+
+	go func() {
+		defer func() {
+			t.mutex.Lock()
+			delete(t.inflight, inflightKey)
+			t.mutex.Unlock()
+			close(pending.done)
+		}()
+
+		// 之前的翻译可能在条目读取之后才写入缓存
+		if latest, err := t.db.QueryByID(id, matchType); err == nil && latest != nil {
+			if cached, ok := latest.MachineTranslations[lang]; ok && cached.Source == source {
+				pending.text = cached.Text
+				return
+			}
+		}
+
+		pending.text, pending.err = t.translate(source, lang)
+		if pending.err != nil {
+			log.Printf("Error translating entry %s to %s: %v", key, lang, pending.err)
+			return
+		}
+		err := t.db.UpdateEntryMeta(key, matchType, func(meta *database.EntryMeta) {
+			machine := make(map[string]database.MachineTranslation, len(meta.MachineTranslations)+1)
+			for k, v := range meta.MachineTranslations {
+				machine[k] = v
+			}
+			machine[lang] = database.MachineTranslation{Text: pending.text, Source: source}
+			meta.MachineTranslations = machine
+		})
+		if err != nil {
+			log.Printf("Error caching translation for entry %s: %v", key, err)
+		}
+	}()
+	return pending
+}
+
+// shouldTranslate 检查是否允许对该语言使用AI翻译
+func (t *Translator) shouldTranslate(lang string) bool {
+	if !t.conf.FAQ.AITranslate || t.multichatMgr == nil || !t.multichatMgr.HasEnabledProviders() {
+		return false
+	}
+	// 配置了语言列表时只翻译列表中的语言
+	if len(t.conf.FAQ.Languages) == 0 {
+		return true
+	}
+	for _, l := range t.conf.FAQ.Languages {
+		if normalizeLanguage(l) == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// translateTimeout 单次AI翻译请求的最长时间
+const translateTimeout = 30 * time.Second
+
+// translateWait 回答用户前等待AI翻译的最长时间，超时后先发送原文
+const translateWait = 3 * time.Second
+
+// translate 使用AI提供商翻译文本
+func (t *Translator) translate(text, lang string) (string, error) {
+	messages := []multichat.Message{
+		{
+			Role:    "system",
+			Content: fmt.Sprintf("You are a translator. Translate the user's text into the language with code %q. Keep HTML tags, links and formatting unchanged. Reply with the translation only.", lang),
+		},
+		{Role: "user", Content: text},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), translateTimeout)
+	defer cancel()
+	response, _, _, _, err := t.multichatMgr.GetService().GetCompletion(ctx, messages, "", "")
+	if err != nil {
+		return "", err
+	}
+	response = strings.TrimSpace(response)
+	if response == "" {
+		return "", fmt.Errorf("empty translation")
+	}
+	return response, nil
+}
+
+// HandleTranslationMenu 显示条目的各语言翻译状态
+func (h *ListHandler) HandleTranslationMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message, entryID int, matchType int) {
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型转换错误"))
+		return
+	}
+	entry, err := h.db.QueryByID(entryID, matchTypeValue)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到条目"))
+		return
+	}
+
+	defaultLang := normalizeLanguage(h.conf.FAQ.DefaultLanguage)
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🌐 条目翻译：%s\n\n", entry.Key))
+	text.WriteString(fmt.Sprintf("%s（默认）：%s\n", defaultLang, entry.AnswerText()))
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, lang := range entryLanguages(h.conf, entry) {
+		status := "❌"
+		if value, ok := entry.Translations[lang]; ok {
+			status = "✅"
+			text.WriteString(fmt.Sprintf("%s：%s\n", lang, value))
+		} else if cached, ok := entry.MachineTranslations[lang]; ok && cached.Source == entry.AnswerText() {
+			status = "🤖"
+			text.WriteString(fmt.Sprintf("%s（AI）：%s\n", lang, cached.Text))
+		} else {
+			text.WriteString(fmt.Sprintf("%s：缺少翻译\n", lang))
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s", status, lang), fmt.Sprintf("trlang_%d_%d_%s", entry.ID, matchType, lang)))
+		if len(row) == 3 {
+			buttons = append(buttons, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		buttons = append(buttons, row)
+	}
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("➕ 其他语言", fmt.Sprintf("trlang_%d_%d_", entry.ID, matchType)),
+	})
+	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("entry_%d_%d", entry.ID, matchType)),
+		tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
+	})
+
+	text.WriteString("\n✅ 人工翻译  🤖 AI翻译  ❌ 缺少翻译\n点击语言编辑对应内容")
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text.String())
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	bot.Send(editMsg)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"reflect"
+	"testing"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestNormalizeLanguage(t *testing.T) {
+	tests := map[string]string{
+		"en":       "en",
+		"en-US":    "en",
+		"zh_hans":  "zh",
+		" PT-br ":  "pt",
+		"":         "",
+		"-invalid": "",
+	}
+	for code, want := range tests {
+		if got := normalizeLanguage(code); got != want {
+			t.Errorf("normalizeLanguage(%q) = %q, want %q", code, got, want)
+		}
+	}
+}
+
+func TestEntryLanguages(t *testing.T) {
+	conf := &config.Config{FAQ: config.FAQConfig{DefaultLanguage: "zh-CN", Languages: []string{"en-US", "zh", "ja", "EN"}}}
+
+	tests := []struct {
+		name         string
+		translations map[string]string
+		wantLangs    []string
+		wantMissing  []string
+	}{
+		{
+			name:        "no translations",
+			wantLangs:   []string{"en", "ja"},
+			wantMissing: []string{"en", "ja"},
+		},
+		{
+			name:         "configured translation present",
+			translations: map[string]string{"ja": "返金"},
+			wantLangs:    []string{"en", "ja"},
+			wantMissing:  []string{"en"},
+		},
+		{
+			name:         "extra languages follow configured ones in order",
+			translations: map[string]string{"ru": "возврат", "de": "Rückerstattung", "zh": "退款"},
+			wantLangs:    []string{"en", "ja", "de", "ru"},
+			wantMissing:  []string{"en", "ja"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &database.Entry{EntryMeta: database.EntryMeta{Translations: tt.translations}}
+			if got := entryLanguages(conf, entry); !reflect.DeepEqual(got, tt.wantLangs) {
+				t.Errorf("entryLanguages() = %v, want %v", got, tt.wantLangs)
+			}
+			if got := missingTranslations(conf, entry); !reflect.DeepEqual(got, tt.wantMissing) {
+				t.Errorf("missingTranslations() = %v, want %v", got, tt.wantMissing)
+			}
+		})
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	conf := &config.Config{FAQ: config.FAQConfig{DefaultLanguage: "zh"}}
+	translator := NewTranslator(nil, conf, nil)
+
+	text := &database.Entry{Key: "退款", Value: "7 天内可退款", EntryMeta: database.EntryMeta{
+		Translations: map[string]string{"en": "Refunds within 7 days"},
+		MachineTranslations: map[string]database.MachineTranslation{
+			"ja": {Text: "7日以内に返金", Source: "7 天内可退款"},
+			"ko": {Text: "오래된 번역", Source: "旧内容"},
+		},
+	}}
+	media := &database.Entry{Key: "地址", Value: "地图", EntryMeta: database.EntryMeta{
+		Media:        []database.MediaItem{{Type: "photo", FileID: "f"}},
+		Caption:      "公司地址",
+		Translations: map[string]string{"en": "Office address"},
+	}}
+	telegraph := &database.Entry{Key: "手册", Value: "https://telegra.ph/x", ContentType: "telegraph_text",
+		EntryMeta: database.EntryMeta{Translations: map[string]string{"en": "Manual"}}}
+
+	tests := []struct {
+		name        string
+		entry       *database.Entry
+		lang        string
+		wantValue   string
+		wantCaption string
+	}{
+		{name: "default language", entry: text, lang: "zh-hans", wantValue: "7 天内可退款"},
+		{name: "empty language", entry: text, lang: "", wantValue: "7 天内可退款"},
+		{name: "manual translation", entry: text, lang: "en-GB", wantValue: "Refunds within 7 days"},
+		{name: "cached machine translation", entry: text, lang: "ja", wantValue: "7日以内に返金"},
+		{name: "stale machine translation is ignored", entry: text, lang: "ko", wantValue: "7 天内可退款"},
+		{name: "missing translation without ai", entry: text, lang: "fr", wantValue: "7 天内可退款"},
+		{name: "media translates the caption", entry: media, lang: "en", wantValue: "地图", wantCaption: "Office address"},
+		{name: "telegraph is not translated", entry: telegraph, lang: "en", wantValue: "https://telegra.ph/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			original := *tt.entry
+			got := translator.Localize(tt.entry, tt.lang)
+			if got.Value != tt.wantValue || got.Caption != tt.wantCaption {
+				t.Errorf("Localize() value = %q caption = %q, want %q and %q", got.Value, got.Caption, tt.wantValue, tt.wantCaption)
+			}
+			if tt.entry.Value != original.Value || tt.entry.Caption != original.Caption {
+				t.Errorf("Localize() modified the original entry")
+			}
+		})
+	}
+}
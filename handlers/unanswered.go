@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+const (
+	// missSimilarityThreshold 相似度达到该值的问题归为一组
+	missSimilarityThreshold = 0.5
+	// unansweredPageSize /unanswered 每页显示的分组数量
+	unansweredPageSize = 5
+	// unansweredSimilarLimit 每组最多列出的相似问题数量
+	unansweredSimilarLimit = 3
+)
+
+// recordMiss 记录一次未找到答案的问题
+func recordMiss(db database.Database, message *tgbotapi.Message, text string) {
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	miss := database.Miss{
+		Text:     strings.TrimSpace(text),
+		ChatID:   message.Chat.ID,
+		LastSeen: time.Now(),
+	}
+	if message.From != nil {
+		miss.UserID = message.From.ID
+	}
+	if err := db.RecordMiss(miss); err != nil {
+		log.Printf("Error recording unanswered question: %v", err)
+	}
+}
+
+type UnansweredHandler struct {
+	db    database.Database
+	state *State
+}
+
+func NewUnansweredHandler(db database.Database, state *State) *UnansweredHandler {
+	return &UnansweredHandler{
+		db:    db,
+		state: state,
+	}
+}
+
+// HandleUnansweredCommand 列出未回答的问题
+func (h *UnansweredHandler) HandleUnansweredCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	text, markup, err := h.render(0)
+	if err != nil {
+		log.Printf("Error listing unanswered questions: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "无法获取未回答的问题"))
+		return
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	if markup != nil {
+		msg.ReplyMarkup = markup
+	}
+	bot.Send(msg)
+}
+
+// HandleUnansweredPage 翻页显示未回答的问题
+func (h *UnansweredHandler) HandleUnansweredPage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, page int) {
+	text, markup, err := h.render(page)
+	if err != nil {
+		log.Printf("Error listing unanswered questions: %v", err)
+		bot.Send(tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, "无法获取未回答的问题"))
+		return
+	}
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
+	editMsg.ReplyMarkup = markup
+	bot.Send(editMsg)
+}
+
+// HandleAddStart 为问题选择匹配类型
+func (h *UnansweredHandler) HandleAddStart(bot *tgbotapi.BotAPI, message *tgbotapi.Message, missID int64) {
+	group, err := h.findGroup(missID)
+	if err != nil || group == nil {
+		bot.Send(tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, "该问题已不存在"))
+		return
+	}
+
+	key := group.Representative().Text
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("精确", fmt.Sprintf("unans_type_%d_%d", missID, 1)),
+			tgbotapi.NewInlineKeyboardButtonData("模糊", fmt.Sprintf("unans_type_%d_%d", missID, 2)),
+			tgbotapi.NewInlineKeyboardButtonData("正则", fmt.Sprintf("unans_type_%d_%d", missID, 3)),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("前缀", fmt.Sprintf("unans_type_%d_%d", missID, 4)),
+			tgbotapi.NewInlineKeyboardButtonData("后缀", fmt.Sprintf("unans_type_%d_%d", missID, 5)),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("返回", "unans_page_0"),
+			tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
+		},
+	}
+
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, fmt.Sprintf("为「%s」添加条目，选择匹配类型：", key))
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	bot.Send(editMsg)
+}
+
+// HandleAddType 进入添加条目的对话，key 使用问题文本
+func (h *UnansweredHandler) HandleAddType(bot *tgbotapi.BotAPI, message *tgbotapi.Message, missID int64, matchType int) {
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型转换错误"))
+		return
+	}
+	group, err := h.findGroup(missID)
+	if err != nil || group == nil {
+		bot.Send(tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, "该问题已不存在"))
+		return
+	}
+
+	key := group.Representative().Text
+	h.state.Set(message.Chat.ID, &Conversation{
+		Stage:     "awaiting_add_value",
+		Key:       key,
+		MatchType: matchTypeValue,
+		MissID:    missID,
+		MessageID: message.MessageID,
+	})
+
+	msgText := fmt.Sprintf("请输入「%s」（%s）的回答内容：\n\n%s", key, utils.GetMatchTypeText(matchTypeValue), buttonHelp)
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, msgText)
+	cancelButton := tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{cancelButton}}}
+	bot.Send(editMsg)
+}
+
+// HandleDismiss 忽略一组问题
+func (h *UnansweredHandler) HandleDismiss(bot *tgbotapi.BotAPI, message *tgbotapi.Message, missID int64, page int) {
+	if err := h.ResolveGroup(missID); err != nil {
+		log.Printf("Error deleting unanswered questions: %v", err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "删除失败"))
+		return
+	}
+	h.HandleUnansweredPage(bot, message, page)
+}
+
+// ResolveGroup 删除与指定问题同组的所有记录
+func (h *UnansweredHandler) ResolveGroup(missID int64) error {
+	ids, err := h.GroupMissIDs(missID)
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	return h.db.DeleteMisses(ids...)
+}
+
+// GroupMissIDs 返回与指定问题同组的所有记录 ID
+func (h *UnansweredHandler) GroupMissIDs(missID int64) ([]int64, error) {
+	group, err := h.findGroup(missID)
+	if err != nil || group == nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(group.Misses))
+	for _, miss := range group.Misses {
+		ids = append(ids, miss.ID)
+	}
+	return ids, nil
+}
+
+// findGroup 查找包含指定问题的分组
+func (h *UnansweredHandler) findGroup(missID int64) (*database.MissGroup, error) {
+	misses, err := h.db.ListMisses()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range database.GroupMisses(misses, missSimilarityThreshold) {
+		for _, miss := range group.Misses {
+			if miss.ID == missID {
+				return &group, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// render 生成指定页的问题列表
+func (h *UnansweredHandler) render(page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	misses, err := h.db.ListMisses()
+	if err != nil {
+		return "", nil, err
+	}
+	groups := database.GroupMisses(misses, missSimilarityThreshold)
+	if len(groups) == 0 {
+		return "✅ 暂无未回答的问题", nil, nil
+	}
+
+	total := 0
+	for _, group := range groups {
+		total += group.Count
+	}
+
+	pageGroups := utils.Paginate(groups, page, unansweredPageSize)
+	if len(pageGroups) == 0 && page > 0 {
+		page = 0
+		pageGroups = utils.Paginate(groups, page, unansweredPageSize)
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("❓ 未回答的问题（%d 组，共 %d 次）\n", len(groups), total))
+
+	var buttons [][]tgbotapi.InlineKeyboardButton
+	for i, group := range pageGroups {
+		index := page*unansweredPageSize + i + 1
+		rep := group.Representative()
+		b.WriteString(fmt.Sprintf("\n%d. %s ×%d\n", index, rep.Text, group.Count))
+		b.WriteString(fmt.Sprintf("   最近：%s\n", group.LastSeen.Format("2006-01-02 15:04")))
+		if len(group.Misses) > 1 {
+			var similar []string
+			for _, miss := range group.Misses[1:] {
+				if len(similar) == unansweredSimilarLimit {
+					similar = append(similar, "…")
+					break
+				}
+				similar = append(similar, miss.Text)
+			}
+			b.WriteString(fmt.Sprintf("   相似：%s\n", strings.Join(similar, "；")))
+		}
+
+		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("➕ 添加 %d", index), fmt.Sprintf("unans_add_%d", rep.ID)),
+			tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("🗑 忽略 %d", index), fmt.Sprintf("unans_del_%d_%d", rep.ID, page)),
+		})
+	}
+	buttons = append(buttons, utils.BuildPaginationButtons(page, len(groups), unansweredPageSize, "unans_page", "取消")...)
+
+	return b.String(), &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}, nil
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"TGFaqBot/database"
+)
+
+func TestResolveGroup(t *testing.T) {
+	db := newTestDB(t)
+	for _, text := range []string{"怎么申请退款", "怎么申请退款？", "怎么申请退货", "营业时间"} {
+		if err := db.RecordMiss(database.Miss{Text: text, LastSeen: time.Now()}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := NewUnansweredHandler(db, nil)
+
+	ids, err := h.GroupMissIDs(database.MissID("怎么申请退货"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("GroupMissIDs() = %v, want both refund questions", ids)
+	}
+	if err := h.ResolveGroup(database.MissID("怎么申请退货")); err != nil {
+		t.Fatal(err)
+	}
+	misses, err := db.ListMisses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(misses) != 1 || misses[0].Text != "营业时间" {
+		t.Errorf("ListMisses() = %+v, want only the unrelated question", misses)
+	}
+	if ids, err := h.GroupMissIDs(12345); err != nil || ids != nil {
+		t.Errorf("GroupMissIDs(unknown) = %v, %v, want nil", ids, err)
+	}
+}
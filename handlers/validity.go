@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// validityLayouts 有效期支持的时间格式，使用服务器本地时区
+var validityLayouts = []string{"2006-01-02 15:04", "2006-01-02"}
+
+// validityHelp 设置有效期时的输入说明
+const validityHelp = `请输入有效期，格式：开始时间 ~ 结束时间
+时间格式：2006-01-02 15:04 或 2006-01-02
+不限制的一端填 -，例如：
+2026-10-20 08:00 ~ 2026-10-31 23:59
+- ~ 2026-12-31
+发送 - 清除有效期`
+
+// parseValidityTime 解析单个时间，- 表示不限制
+func parseValidityTime(text string) (*time.Time, error) {
+	text = strings.TrimSpace(text)
+	if text == "-" || text == "" {
+		return nil, nil
+	}
+	for _, layout := range validityLayouts {
+		if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return &t, nil
+		}
+	}
+	return nil, fmt.Errorf("无法识别的时间：%s", text)
+}
+
+// parseValidityInput 解析「开始 ~ 结束」格式的有效期
+func parseValidityInput(text string) (from, until *time.Time, err error) {
+	text = strings.TrimSpace(text)
+	if text == "-" {
+		return nil, nil, nil
+	}
+	parts := strings.Split(text, "~")
+	if len(parts) != 2 {
+		return nil, nil, fmt.Errorf("请使用「开始时间 ~ 结束时间」的格式")
+	}
+	if from, err = parseValidityTime(parts[0]); err != nil {
+		return nil, nil, err
+	}
+	if until, err = parseValidityTime(parts[1]); err != nil {
+		return nil, nil, err
+	}
+	if from != nil && until != nil && !until.After(*from) {
+		return nil, nil, fmt.Errorf("结束时间必须晚于开始时间")
+	}
+	return from, until, nil
+}
+
+// validityStatusText 返回有效期状态的显示文字
+func validityStatusText(status string) string {
+	switch status {
+	case database.EntryScheduled:
+		return "⏳ 未生效"
+	case database.EntryExpired:
+		return "⌛ 已过期"
+	default:
+		return "✅ 有效"
+	}
+}
+
+// describeValidity 返回条目有效期的说明，未设置有效期时返回空字符串
+func describeValidity(entry *database.Entry, now time.Time) string {
+	if entry.ValidFrom == nil && entry.ValidUntil == nil {
+		return ""
+	}
+	from, until := "-", "-"
+	if entry.ValidFrom != nil {
+		from = entry.ValidFrom.Format("2006-01-02 15:04")
+	}
+	if entry.ValidUntil != nil {
+		until = entry.ValidUntil.Format("2006-01-02 15:04")
+	}
+	return fmt.Sprintf("有效期：%s ~ %s（%s）", from, until, validityStatusText(entry.ValidityStatus(now)))
+}
+
+// filterByValidity 按有效期状态过滤条目，statuses 为空时不过滤
+func filterByValidity(entries []database.Entry, statuses []string, now time.Time) []database.Entry {
+	if len(statuses) == 0 {
+		return entries
+	}
+	var filtered []database.Entry
+	for _, entry := range entries {
+		status := entry.ValidityStatus(now)
+		for _, s := range statuses {
+			if s == status {
+				filtered = append(filtered, entry)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// HandleValidityPrompt 提示输入条目的有效期
+func (h *ListHandler) HandleValidityPrompt(bot *tgbotapi.BotAPI, message *tgbotapi.Message, entryID int, matchType int) {
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型转换错误"))
+		return
+	}
+	entry, err := h.db.QueryByID(entryID, matchTypeValue)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到条目"))
+		return
+	}
+
+	h.state.Set(message.Chat.ID, &Conversation{
+		Stage:     "awaiting_validity",
+		EntryID:   entryID,
+		OldType:   matchType,
+		MessageID: message.MessageID,
+	})
+
+	msgText := fmt.Sprintf("Key: %s\n", entry.Key)
+	if desc := describeValidity(entry, time.Now()); desc != "" {
+		msgText += desc + "\n"
+	}
+	msgText += "\n" + validityHelp
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, msgText)
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("entry_%d_%d", entryID, matchType)),
+			tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
+		},
+	}
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	bot.Send(editMsg)
+}
+
+func (h *MessageHandler) handleValidityInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	if !IsAdminUser(message.From.ID, h.conf) {
+		return
+	}
+	from, until, err := parseValidityInput(message.Text)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%v\n\n%s", err, validityHelp)))
+		return
+	}
+
+	matchType, err := database.MatchTypeFromInt(state.OldType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "类型转换错误"))
+		h.state.Delete(chatID)
+		return
+	}
+	entry, err := h.db.QueryByID(state.EntryID, matchType)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "未找到条目"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	patch := database.MetaPatch{Validity: &database.ValidityPatch{From: from, Until: until}}
+	if requiresReview(h.conf, message.From.ID) {
+		h.submitChange(bot, message, state, metaChange(entry, patch))
+		return
+	}
+	if err := saveEntryMeta(h.db, entry, message.From.ID, patch); err != nil {
+		log.Printf("Error saving validity for entry %s: %v", entry.Key, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "保存有效期失败"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+	entry.ValidFrom, entry.ValidUntil = from, until
+	result := fmt.Sprintf("已清除有效期\nKey: %s", entry.Key)
+	if desc := describeValidity(entry, time.Now()); desc != "" {
+		result = fmt.Sprintf("已设置有效期\nKey: %s\n%s", entry.Key, desc)
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, result))
+	h.state.Delete(chatID)
+}
+
+// ExpiringEntries 返回将在 window 内过期且尚未提醒的条目
+func ExpiringEntries(db database.Database, now time.Time, window time.Duration) ([]database.Entry, error) {
+	entries, err := db.ListAllEntries()
+	if err != nil {
+		return nil, err
+	}
+	var expiring []database.Entry
+	for _, entry := range entries {
+		if entry.ValidUntil == nil || !entry.IsActive(now) || entry.ValidUntil.Sub(now) > window {
+			continue
+		}
+		if entry.ExpiryNotifiedFor != nil && entry.ExpiryNotifiedFor.Equal(*entry.ValidUntil) {
+			continue
+		}
+		expiring = append(expiring, entry)
+	}
+	return expiring, nil
+}
+
+// MarkExpiryNotified 记录已为当前有效期发送过提醒
+func MarkExpiryNotified(db database.Database, entry *database.Entry) error {
+	notified := *entry.ValidUntil
+	return db.UpdateEntryMeta(entry.Key, entry.MatchType, func(meta *database.EntryMeta) {
+		meta.ExpiryNotifiedFor = &notified
+	})
+}
+
+// FormatExpiryNotice 生成过期提醒消息
+func FormatExpiryNotice(entries []database.Entry, now time.Time) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("⏰ 以下 %d 个条目即将过期：\n", len(entries)))
+	for _, entry := range entries {
+		remaining := entry.ValidUntil.Sub(now).Round(time.Minute)
+		b.WriteString(fmt.Sprintf("\n• %s（%s）\n  到期：%s（剩余 %s）", entry.Key, utils.GetMatchTypeText(entry.MatchType),
+			entry.ValidUntil.Format("2006-01-02 15:04"), remaining))
+	}
+	b.WriteString("\n\n可在 /list 中选择条目并点击「⏰ 有效期」延长")
+	return b.String()
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+// canViewEntry 检查用户是否可以查看条目。超级管理员可以查看所有条目，
+// 非公开条目只在私聊中发送，避免在群组中泄露给其他成员
+func canViewEntry(conf *config.Config, userID int64, privateChat bool, entry *database.Entry) bool {
+	switch entry.Visibility {
+	case "", database.VisibilityPublic:
+		return true
+	}
+	if !privateChat {
+		return false
+	}
+	switch entry.Visibility {
+	case database.VisibilityAdmins:
+		return IsAdminUser(userID, conf)
+	case database.VisibilitySuperAdmins:
+		return IsSuperAdminUser(userID, conf)
+	case database.VisibilityUsers:
+		if IsSuperAdminUser(userID, conf) {
+			return true
+		}
+		for _, id := range entry.VisibleTo {
+			if id == userID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// filterVisible 过滤掉用户无权查看的条目
+func filterVisible(conf *config.Config, userID int64, privateChat bool, entries []database.Entry) []database.Entry {
+	var visible []database.Entry
+	for i := range entries {
+		if canViewEntry(conf, userID, privateChat, &entries[i]) {
+			visible = append(visible, entries[i])
+		}
+	}
+	return visible
+}
+
+// visibilityText 返回可见性的显示文字
+func visibilityText(entry *database.Entry) string {
+	switch entry.Visibility {
+	case database.VisibilityAdmins:
+		return "仅管理员"
+	case database.VisibilitySuperAdmins:
+		return "仅超级管理员"
+	case database.VisibilityUsers:
+		ids := make([]string, len(entry.VisibleTo))
+		for i, id := range entry.VisibleTo {
+			ids[i] = strconv.FormatInt(id, 10)
+		}
+		return "指定用户：" + strings.Join(ids, ", ")
+	default:
+		return "公开"
+	}
+}
+
+// HandleVisibilityMenu 显示条目可见性选项
+func (h *ListHandler) HandleVisibilityMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message, entryID int, matchType int) {
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型转换错误"))
+		return
+	}
+	entry, err := h.db.QueryByID(entryID, matchTypeValue)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到条目"))
+		return
+	}
+
+	option := func(text, visibility string) tgbotapi.InlineKeyboardButton {
+		return tgbotapi.NewInlineKeyboardButtonData(text, fmt.Sprintf("visset_%d_%d_%s", entryID, matchType, visibility))
+	}
+	buttons := [][]tgbotapi.InlineKeyboardButton{
+		{
+			option("公开", database.VisibilityPublic),
+			option("仅管理员", database.VisibilityAdmins),
+		},
+		{
+			option("仅超级管理员", database.VisibilitySuperAdmins),
+			option("指定用户", database.VisibilityUsers),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("返回", fmt.Sprintf("entry_%d_%d", entryID, matchType)),
+			tgbotapi.NewInlineKeyboardButtonData("取消", "cancel"),
+		},
+	}
+
+	msgText := fmt.Sprintf("Key: %s\n当前可见性：%s\n\n选择谁可以查看该条目，非公开条目只在私聊中回复", entry.Key, visibilityText(entry))
+	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, msgText)
+	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
+	bot.Send(editMsg)
+}
+
+// HandleVisibilitySet 设置条目可见性，指定用户时进入输入用户 ID 的对话
+func (h *ListHandler) HandleVisibilitySet(bot *tgbotapi.BotAPI, message *tgbotapi.Message, from *tgbotapi.User, entryID int, matchType int, visibility string) {
+	matchTypeValue, err := database.MatchTypeFromInt(matchType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "匹配类型转换错误"))
+		return
+	}
+	entry, err := h.db.QueryByID(entryID, matchTypeValue)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "未找到条目"))
+		return
+	}
+
+	if visibility == database.VisibilityUsers {
+		h.state.Set(message.Chat.ID, &Conversation{
+			Stage:     "awaiting_visibility_users",
+			EntryID:   entryID,
+			OldType:   matchType,
+			MessageID: message.MessageID,
+		})
+		editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID,
+			fmt.Sprintf("Key: %s\n请输入可以查看该条目的用户 ID，多个 ID 用空格或逗号分隔", entry.Key))
+		cancelButton := tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")
+		editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{{cancelButton}}}
+		bot.Send(editMsg)
+		return
+	}
+
+	patch := database.MetaPatch{Visibility: &database.VisibilityPatch{Visibility: visibility}}
+	if requiresReview(h.conf, from.ID) {
+		change := metaChange(entry, patch)
+		if err := submitChange(bot, h.db, h.conf, from, message.Chat.ID, change); err != nil {
+			log.Printf("Error submitting change for entry %s: %v", entry.Key, err)
+			bot.Send(tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, "提交审核失败"))
+			return
+		}
+		bot.Send(tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, reviewSubmitted(change)))
+		return
+	}
+	if err := saveEntryMeta(h.db, entry, from.ID, patch); err != nil {
+		log.Printf("Error saving visibility for entry %s: %v", entry.Key, err)
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "保存可见性失败"))
+		return
+	}
+	h.HandleEntrySelection(bot, message, entryID, matchType)
+}
+
+func (h *MessageHandler) handleVisibilityUsersInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	if !IsAdminUser(message.From.ID, h.conf) {
+		return
+	}
+	fields := strings.FieldsFunc(message.Text, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '，' || r == '\n'
+	})
+	var users []int64
+	for _, field := range fields {
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("用户 ID 格式错误：%s", field)))
+			return
+		}
+		users = append(users, id)
+	}
+	if len(users) == 0 {
+		bot.Send(tgbotapi.NewMessage(chatID, "请输入至少一个用户 ID"))
+		return
+	}
+
+	matchType, err := database.MatchTypeFromInt(state.OldType)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "类型转换错误"))
+		h.state.Delete(chatID)
+		return
+	}
+	entry, err := h.db.QueryByID(state.EntryID, matchType)
+	if err != nil || entry == nil {
+		bot.Send(tgbotapi.NewMessage(chatID, "未找到条目"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	patch := database.MetaPatch{Visibility: &database.VisibilityPatch{Visibility: database.VisibilityUsers, Users: users}}
+	if requiresReview(h.conf, message.From.ID) {
+		h.submitChange(bot, message, state, metaChange(entry, patch))
+		return
+	}
+	if err := saveEntryMeta(h.db, entry, message.From.ID, patch); err != nil {
+		log.Printf("Error saving visibility for entry %s: %v", entry.Key, err)
+		bot.Send(tgbotapi.NewMessage(chatID, "保存可见性失败"))
+		h.state.Delete(chatID)
+		return
+	}
+
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+	entry.Visibility, entry.VisibleTo = database.VisibilityUsers, users
+	bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("已设置可见性\nKey: %s\n%s", entry.Key, visibilityText(entry))))
+	h.state.Delete(chatID)
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"testing"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestCanViewEntry(t *testing.T) {
+	const superAdmin, admin, listed, stranger = 1, 2, 3, 4
+	conf := &config.Config{Admin: config.AdminConfig{SuperAdminIDs: []int64{superAdmin}, AdminIDs: []int64{admin}}}
+
+	tests := []struct {
+		name       string
+		visibility string
+		private    bool
+		// want 依次为超级管理员、管理员、指定用户、其他用户能否查看
+		want [4]bool
+	}{
+		{name: "unset is public in groups", visibility: "", want: [4]bool{true, true, true, true}},
+		{name: "public in private chats", visibility: database.VisibilityPublic, private: true, want: [4]bool{true, true, true, true}},
+		{name: "admins in private chats", visibility: database.VisibilityAdmins, private: true, want: [4]bool{true, true, false, false}},
+		{name: "admins in groups", visibility: database.VisibilityAdmins, want: [4]bool{false, false, false, false}},
+		{name: "super admins in private chats", visibility: database.VisibilitySuperAdmins, private: true, want: [4]bool{true, false, false, false}},
+		{name: "listed users in private chats", visibility: database.VisibilityUsers, private: true, want: [4]bool{true, false, true, false}},
+		{name: "listed users in groups", visibility: database.VisibilityUsers, want: [4]bool{false, false, false, false}},
+		{name: "unknown visibility", visibility: "friends", private: true, want: [4]bool{false, false, false, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := &database.Entry{EntryMeta: database.EntryMeta{Visibility: tt.visibility, VisibleTo: []int64{listed}}}
+			for i, userID := range []int64{superAdmin, admin, listed, stranger} {
+				if got := canViewEntry(conf, userID, tt.private, entry); got != tt.want[i] {
+					t.Errorf("canViewEntry(user %d) = %v, want %v", userID, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFilterVisible(t *testing.T) {
+	conf := &config.Config{}
+	entries := []database.Entry{
+		{Key: "公开"},
+		{Key: "内部", EntryMeta: database.EntryMeta{Visibility: database.VisibilityAdmins}},
+		{Key: "指定", EntryMeta: database.EntryMeta{Visibility: database.VisibilityUsers, VisibleTo: []int64{7}}},
+	}
+
+	got := filterVisible(conf, 7, true, entries)
+	if len(got) != 2 || got[0].Key != "公开" || got[1].Key != "指定" {
+		t.Errorf("filterVisible() = %+v, want the public and listed entries", got)
+	}
+	if got := filterVisible(conf, 7, false, entries); len(got) != 1 {
+		t.Errorf("filterVisible() in a group = %+v, want only the public entry", got)
+	}
+}
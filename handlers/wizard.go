@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+	"TGFaqBot/utils"
+)
+
+// /add 向导的对话阶段
+const (
+	wizardKey     = "wizard_key"
+	wizardType    = "wizard_type"
+	wizardValue   = "wizard_value"
+	wizardConfirm = "wizard_confirm"
+)
+
+// wizardAlbumWait 收到相册的第一条消息后等待其余消息的时间
+const wizardAlbumWait = 1500 * time.Millisecond
+
+// entryDraft /add 向导中尚未保存的回答内容
+type entryDraft struct {
+	Value    string
+	Meta     database.EntryMeta
+	HasValue bool
+
+	// 相册会拆成多条消息并发处理，收集期间需要加锁
+	mutex        sync.Mutex
+	mediaGroupID string
+	album        []mediaGroupItem
+}
+
+// AddWizard 按步骤引导管理员添加条目：输入 key、选择匹配类型、发送内容、预览确认
+type AddWizard struct {
+	db    database.Database
+	conf  *config.Config
+	state *State
+}
+
+func NewAddWizard(db database.Database, conf *config.Config, state *State) *AddWizard {
+	return &AddWizard{
+		db:    db,
+		conf:  conf,
+		state: state,
+	}
+}
+
+// Start 开始添加条目的向导
+func (w *AddWizard) Start(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+	msg := tgbotapi.NewMessage(message.Chat.ID, "➕ 添加条目（1/3）\n请输入条目的 key：")
+	msg.ReplyMarkup = wizardCancelKeyboard()
+	sent, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Error starting add wizard: %v", err)
+		return
+	}
+	w.state.Set(message.Chat.ID, &Conversation{
+		Stage:     wizardKey,
+		MessageID: sent.MessageID,
+		Draft:     &entryDraft{},
+	})
+}
+
+// HandleInput 处理向导中管理员发送的消息
+func (w *AddWizard) HandleInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	switch state.Stage {
+	case wizardKey:
+		w.handleKeyInput(bot, message, state)
+	case wizardValue:
+		w.handleValueInput(bot, message, state)
+	case wizardType:
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "请点击按钮选择匹配类型"))
+	case wizardConfirm:
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, "请点击按钮确认添加或修改"))
+	}
+}
+
+// HandleCallback 处理向导中的按钮
+func (w *AddWizard) HandleCallback(bot *tgbotapi.BotAPI, callbackQuery *tgbotapi.CallbackQuery, data string) {
+	// 回调数据: addwiz_type_<type>、addwiz_key、addwiz_type、addwiz_value、addwiz_confirm
+	chatID := callbackQuery.Message.Chat.ID
+	state, exists := w.state.Get(chatID)
+	if !exists || state.Draft == nil {
+		bot.Send(tgbotapi.NewEditMessageText(chatID, callbackQuery.Message.MessageID, "向导已结束，请重新使用 /add"))
+		return
+	}
+	if !IsAdminUser(callbackQuery.From.ID, w.conf) {
+		return
+	}
+	state.MessageID = callbackQuery.Message.MessageID
+
+	action := strings.TrimPrefix(data, "addwiz_")
+	switch {
+	case strings.HasPrefix(action, "type_"):
+		matchType, err := strconv.Atoi(strings.TrimPrefix(action, "type_"))
+		if err != nil {
+			log.Printf("Error parsing match type: %v", err)
+			return
+		}
+		matchTypeValue, err := database.MatchTypeFromInt(matchType)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, "匹配类型转换错误"))
+			return
+		}
+		if err := checkWizardKey(state.Key, matchTypeValue); err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return
+		}
+		state.MatchType = matchTypeValue
+		bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID,
+			fmt.Sprintf("Key: %s\n类型：%s", state.Key, utils.GetMatchTypeText(matchTypeValue))))
+		w.next(bot, chatID, state, callbackQuery.From.ID)
+	case action == "key":
+		state.Key = ""
+		w.next(bot, chatID, state, callbackQuery.From.ID)
+	case action == "type":
+		state.MatchType = ""
+		w.next(bot, chatID, state, callbackQuery.From.ID)
+	case action == "value":
+		state.Draft.HasValue = false
+		w.next(bot, chatID, state, callbackQuery.From.ID)
+	case action == "confirm":
+		w.save(bot, callbackQuery.From, chatID, state)
+	}
+}
+
+func (w *AddWizard) handleKeyInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	key := strings.TrimSpace(message.Text)
+	if key == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "key 不能为空，请重新输入"))
+		return
+	}
+	if strings.Contains(key, "\n") {
+		bot.Send(tgbotapi.NewMessage(chatID, "key 不能包含换行，请重新输入"))
+		return
+	}
+	if err := checkWizardKey(key, state.MatchType); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()+"，请重新输入"))
+		return
+	}
+	exists, err := entryExists(w.db, key)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "查询失败"))
+		return
+	}
+	if exists {
+		bot.Send(tgbotapi.NewMessage(chatID, "该条目已存在，请输入其他 key"))
+		return
+	}
+
+	state.Key = key
+	w.next(bot, chatID, state, message.From.ID)
+}
+
+func (w *AddWizard) handleValueInput(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation) {
+	chatID := message.Chat.ID
+	draft := state.Draft
+
+	if item, ok := extractMedia(message); ok {
+		if message.MediaGroupID != "" {
+			w.collectAlbum(bot, message, state, item)
+			return
+		}
+		meta, err := w.mediaMeta([]database.MediaItem{item}, message.Caption)
+		if err != nil {
+			bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return
+		}
+		w.setMediaValue(draft, meta)
+		w.next(bot, chatID, state, message.From.ID)
+		return
+	}
+
+	if strings.TrimSpace(message.Text) == "" {
+		bot.Send(tgbotapi.NewMessage(chatID, "请发送回答内容"))
+		return
+	}
+	value, buttons, _, err := splitButtonLayout(message.Text)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()+"\n\n"+buttonHelp))
+		return
+	}
+	if err := resolveButtonEntries(w.db, buttons); err != nil {
+		bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return
+	}
+	// 没有格式时保留原文，管理员仍可像 /add 一样直接输入 HTML 标签
+	if hasFormatting(message.Entities) {
+		value = entitiesToHTML(value, message.Entities)
+	}
+
+	draft.Value = value
+	draft.Meta = database.EntryMeta{Buttons: buttons}
+	draft.HasValue = true
+	w.next(bot, chatID, state, message.From.ID)
+}
+
+// collectAlbum 收集相册中的各条消息，第一条消息等待其余消息到达后进入预览
+func (w *AddWizard) collectAlbum(bot *tgbotapi.BotAPI, message *tgbotapi.Message, state *Conversation, item database.MediaItem) {
+	draft := state.Draft
+	draft.mutex.Lock()
+	first := draft.mediaGroupID != message.MediaGroupID
+	if first {
+		draft.mediaGroupID = message.MediaGroupID
+		draft.album = nil
+	}
+	draft.album = append(draft.album, mediaGroupItem{messageID: message.MessageID, item: item, caption: message.Caption})
+	draft.mutex.Unlock()
+	if !first {
+		return
+	}
+
+	// 消息处理本身在独立的 goroutine 中进行，可以直接等待
+	time.Sleep(wizardAlbumWait)
+	if current, exists := w.state.Get(message.Chat.ID); !exists || current != state || state.Stage != wizardValue {
+		return
+	}
+
+	draft.mutex.Lock()
+	items := make([]mediaGroupItem, len(draft.album))
+	copy(items, draft.album)
+	draft.mutex.Unlock()
+	sort.Slice(items, func(i, j int) bool { return items[i].messageID < items[j].messageID })
+
+	var media []database.MediaItem
+	var caption string
+	for _, albumItem := range items {
+		media = append(media, albumItem.item)
+		if caption == "" {
+			caption = albumItem.caption
+		}
+	}
+	if err := validateAlbum(media); err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+		return
+	}
+
+	meta, err := w.mediaMeta(media, caption)
+	if err != nil {
+		bot.Send(tgbotapi.NewMessage(message.Chat.ID, err.Error()))
+		return
+	}
+	w.setMediaValue(draft, meta)
+	w.next(bot, message.Chat.ID, state, message.From.ID)
+}
+
+// mediaMeta 生成媒体回答的扩展属性，说明文字中可以定义按钮
+func (w *AddWizard) mediaMeta(media []database.MediaItem, caption string) (database.EntryMeta, error) {
+	caption, buttons, _, err := splitButtonLayout(caption)
+	if err != nil {
+		return database.EntryMeta{}, fmt.Errorf("%s\n\n%s", err.Error(), buttonHelp)
+	}
+	if err := resolveButtonEntries(w.db, buttons); err != nil {
+		return database.EntryMeta{}, err
+	}
+	return database.EntryMeta{Media: media, Caption: caption, Buttons: buttons}, nil
+}
+
+// setMediaValue 保存媒体回答，value 与 /add 一样使用说明文字或媒体描述
+func (w *AddWizard) setMediaValue(draft *entryDraft, meta database.EntryMeta) {
+	draft.Value = meta.Caption
+	if draft.Value == "" {
+		draft.Value = describeMedia(meta)
+	}
+	draft.Meta = meta
+	draft.HasValue = true
+}
+
+// next 进入第一个尚未完成的步骤，全部完成时显示预览
+func (w *AddWizard) next(bot *tgbotapi.BotAPI, chatID int64, state *Conversation, userID int64) {
+	switch {
+	case state.Key == "":
+		state.Stage = wizardKey
+		w.prompt(bot, chatID, state, "➕ 添加条目（1/3）\n请输入条目的 key：", wizardCancelKeyboard())
+	case state.MatchType == "":
+		state.Stage = wizardType
+		w.prompt(bot, chatID, state, fmt.Sprintf("➕ 添加条目（2/3）\nKey: %s\n请选择匹配类型：", state.Key), wizardTypeKeyboard())
+	case !state.Draft.HasValue:
+		state.Stage = wizardValue
+		text := fmt.Sprintf("➕ 添加条目（3/3）\nKey: %s\n类型：%s\n\n请发送回答内容，支持多行文本、粗体和链接等格式，也可以发送图片、视频、文件、语音、贴纸或相册（说明文字作为回答文字）。\n\n%s",
+			state.Key, utils.GetMatchTypeText(state.MatchType), buttonHelp)
+		w.prompt(bot, chatID, state, text, wizardCancelKeyboard())
+	default:
+		w.preview(bot, chatID, state, userID)
+	}
+	w.state.Set(chatID, state)
+}
+
+// prompt 发送下一步的提示，并移除上一条提示中的按钮
+func (w *AddWizard) prompt(bot *tgbotapi.BotAPI, chatID int64, state *Conversation, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if state.MessageID != 0 {
+		empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		bot.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, state.MessageID, empty))
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	sent, err := bot.Send(msg)
+	if err != nil {
+		log.Printf("Error sending wizard prompt: %v", err)
+		return
+	}
+	state.MessageID = sent.MessageID
+}
+
+// preview 按实际回答的方式发送内容，再附上确认和修改按钮
+func (w *AddWizard) preview(bot *tgbotapi.BotAPI, chatID int64, state *Conversation, userID int64) {
+	entry := database.Entry{Key: state.Key, MatchType: state.MatchType, Value: state.Draft.Value, EntryMeta: state.Draft.Meta}
+	if err := SendEntryAnswer(bot, chatID, &entry); err != nil {
+		log.Printf("Error sending wizard preview: %v", err)
+		state.Stage = wizardValue
+		state.Draft.HasValue = false
+		w.prompt(bot, chatID, state, "预览发送失败，请检查内容格式后重新发送：\n"+err.Error(), wizardCancelKeyboard())
+		return
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("👆 回答预览\nKey: %s\n类型：%s", state.Key, utils.GetMatchTypeText(state.MatchType)))
+	if len(entry.Buttons) > 0 {
+		b.WriteString("\n" + describeButtons(entry.Buttons))
+	}
+	if warning := overlapWarning(w.db, w.conf, state.Key, state.MatchType); warning != "" {
+		b.WriteString("\n\n" + warning)
+	}
+	if requiresReview(w.conf, userID) {
+		b.WriteString("\n\n确认后将提交给超级管理员审核")
+	}
+
+	keyboard := &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("✅ 确认添加", "addwiz_confirm")},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("✏️ 修改 key", "addwiz_key"),
+			tgbotapi.NewInlineKeyboardButtonData("🔤 修改类型", "addwiz_type"),
+			tgbotapi.NewInlineKeyboardButtonData("📝 修改内容", "addwiz_value"),
+		},
+		{tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")},
+	}}
+	state.Stage = wizardConfirm
+	w.prompt(bot, chatID, state, b.String(), keyboard)
+}
+
+// save 保存向导中的条目，审核模式下提交审核
+func (w *AddWizard) save(bot *tgbotapi.BotAPI, from *tgbotapi.User, chatID int64, state *Conversation) {
+	defer w.state.Delete(chatID)
+	bot.Send(tgbotapi.NewEditMessageText(chatID, state.MessageID, "操作结束"))
+
+	exists, err := entryExists(w.db, state.Key)
+	if err != nil {
+		log.Printf("Error querying database: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "查询失败"))
+		return
+	}
+	if exists {
+		bot.Send(tgbotapi.NewMessage(chatID, "该条目已存在"))
+		return
+	}
+
+	key, matchType, value, meta := state.Key, state.MatchType, state.Draft.Value, state.Draft.Meta
+	if requiresReview(w.conf, from.ID) {
+		change := database.PendingChange{Action: database.ChangeAdd, Key: key, MatchType: matchType, Value: value}
+		if !meta.IsEmpty() {
+			change.Meta = &meta
+		}
+		if err := submitChange(bot, w.db, w.conf, from, chatID, change); err != nil {
+			log.Printf("Error submitting change for entry %s: %v", key, err)
+			bot.Send(tgbotapi.NewMessage(chatID, "提交审核失败"))
+			return
+		}
+		bot.Send(tgbotapi.NewMessage(chatID, reviewSubmitted(change)))
+		return
+	}
+
+	warning := overlapWarning(w.db, w.conf, key, matchType)
+	if err := w.db.AddEntry(key, matchType, value); err != nil {
+		log.Printf("Error adding entry: %v", err)
+		bot.Send(tgbotapi.NewMessage(chatID, "添加失败"))
+		return
+	}
+	if !meta.IsEmpty() {
+		if err := w.db.SetEntryMeta(key, matchType, meta); err != nil {
+			log.Printf("Error saving meta for entry %s: %v", key, err)
+			w.db.DeleteEntry(key, matchType)
+			bot.Send(tgbotapi.NewMessage(chatID, "添加失败"))
+			return
+		}
+	}
+	stampEntry(w.db, key, matchType, from.ID, true)
+
+	result := fmt.Sprintf("添加成功！\nKey: %s\n类型：%s", key, utils.GetMatchTypeText(matchType))
+	if warning != "" {
+		result += "\n\n" + warning
+	}
+	bot.Send(tgbotapi.NewMessage(chatID, result))
+}
+
+// checkWizardKey 正则匹配的 key 必须是有效的正则表达式
+func checkWizardKey(key string, matchType database.MatchType) error {
+	if matchType != database.MatchRegex || key == "" {
+		return nil
+	}
+	if _, err := regexp.Compile(key); err != nil {
+		return fmt.Errorf("key 不是有效的正则表达式：%v", err)
+	}
+	return nil
+}
+
+func wizardCancelKeyboard() *tgbotapi.InlineKeyboardMarkup {
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+		{tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")},
+	}}
+}
+
+func wizardTypeKeyboard() *tgbotapi.InlineKeyboardMarkup {
+	return &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{
+		{
+			tgbotapi.NewInlineKeyboardButtonData("精确", "addwiz_type_1"),
+			tgbotapi.NewInlineKeyboardButtonData("模糊", "addwiz_type_2"),
+			tgbotapi.NewInlineKeyboardButtonData("正则", "addwiz_type_3"),
+		},
+		{
+			tgbotapi.NewInlineKeyboardButtonData("前缀", "addwiz_type_4"),
+			tgbotapi.NewInlineKeyboardButtonData("后缀", "addwiz_type_5"),
+		},
+		{tgbotapi.NewInlineKeyboardButtonData("取消", "cancel")},
+	}}
+}
+
+// entityTags 消息格式对应的 HTML 标签
+var entityTags = map[string]string{
+	"bold":          "b",
+	"italic":        "i",
+	"underline":     "u",
+	"strikethrough": "s",
+	"spoiler":       "tg-spoiler",
+	"code":          "code",
+	"pre":           "pre",
+	"text_link":     "a",
+	"text_mention":  "a",
+}
+
+// hasFormatting 检查消息是否带有需要转换为 HTML 的格式
+func hasFormatting(entities []tgbotapi.MessageEntity) bool {
+	for _, entity := range entities {
+		if _, ok := entityTags[entity.Type]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// entitiesToHTML 将消息文本及其格式转换为 Telegram HTML，超出 text 范围的格式会被截断
+// 格式的偏移量以 UTF-16 代码单元计算
+func entitiesToHTML(text string, entities []tgbotapi.MessageEntity) string {
+	units := utf16.Encode([]rune(text))
+	opens := make(map[int][]string)
+	closes := make(map[int][]string)
+
+	sorted := make([]tgbotapi.MessageEntity, len(entities))
+	copy(sorted, entities)
+	// 外层格式先打开、后关闭
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Offset != sorted[j].Offset {
+			return sorted[i].Offset < sorted[j].Offset
+		}
+		return sorted[i].Length > sorted[j].Length
+	})
+	for _, entity := range sorted {
+		tag, ok := entityTags[entity.Type]
+		if !ok || entity.Offset >= len(units) {
+			continue
+		}
+		end := entity.Offset + entity.Length
+		if end > len(units) {
+			end = len(units)
+		}
+
+		open := "<" + tag + ">"
+		switch entity.Type {
+		case "text_link":
+			open = fmt.Sprintf(`<a href="%s">`, html.EscapeString(entity.URL))
+		case "text_mention":
+			if entity.User == nil {
+				continue
+			}
+			open = fmt.Sprintf(`<a href="tg://user?id=%d">`, entity.User.ID)
+		case "pre":
+			if entity.Language != "" {
+				open = fmt.Sprintf(`<pre><code class="language-%s">`, html.EscapeString(entity.Language))
+				tag = "code></pre"
+			}
+		}
+		opens[entity.Offset] = append(opens[entity.Offset], open)
+		closes[end] = append([]string{"</" + tag + ">"}, closes[end]...)
+	}
+
+	var b strings.Builder
+	for i := 0; i <= len(units); i++ {
+		for _, tag := range closes[i] {
+			b.WriteString(tag)
+		}
+		if i == len(units) {
+			break
+		}
+		for _, tag := range opens[i] {
+			b.WriteString(tag)
+		}
+		// 代理对占两个代码单元，作为一个字符输出
+		if utf16.IsSurrogate(rune(units[i])) && i+1 < len(units) {
+			b.WriteString(html.EscapeString(string(utf16.DecodeRune(rune(units[i]), rune(units[i+1])))))
+			i++
+			continue
+		}
+		b.WriteString(html.EscapeString(string(rune(units[i]))))
+	}
+	return b.String()
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"TGFaqBot/config"
+	"TGFaqBot/database"
+)
+
+func TestEntitiesToHTML(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		entities []tgbotapi.MessageEntity
+		want     string
+	}{
+		{name: "plain text is escaped", text: "a < b & c", want: "a &lt; b &amp; c"},
+		{
+			name:     "bold and link",
+			text:     "退款 请看官网",
+			entities: []tgbotapi.MessageEntity{{Type: "bold", Offset: 0, Length: 2}, {Type: "text_link", Offset: 5, Length: 2, URL: "https://example.com/?a=1&b=2"}},
+			want:     `<b>退款</b> 请看<a href="https://example.com/?a=1&amp;b=2">官网</a>`,
+		},
+		{
+			name:     "nested formats close in reverse order",
+			text:     "abc",
+			entities: []tgbotapi.MessageEntity{{Type: "italic", Offset: 0, Length: 2}, {Type: "bold", Offset: 0, Length: 3}},
+			want:     "<b><i>ab</i>c</b>",
+		},
+		{
+			name:     "offsets count utf-16 units",
+			text:     "😀ok",
+			entities: []tgbotapi.MessageEntity{{Type: "code", Offset: 2, Length: 2}},
+			want:     "😀<code>ok</code>",
+		},
+		{
+			name:     "pre with language",
+			text:     "x := 1",
+			entities: []tgbotapi.MessageEntity{{Type: "pre", Offset: 0, Length: 6, Language: "go"}},
+			want:     `<pre><code class="language-go">x := 1</code></pre>`,
+		},
+		{
+			name:     "entity past the end is truncated",
+			text:     "ab",
+			entities: []tgbotapi.MessageEntity{{Type: "underline", Offset: 1, Length: 5}, {Type: "bold", Offset: 9, Length: 1}},
+			want:     "a<u>b</u>",
+		},
+		{
+			name:     "unsupported entities are ignored",
+			text:     "#tag @user",
+			entities: []tgbotapi.MessageEntity{{Type: "hashtag", Offset: 0, Length: 4}, {Type: "text_mention", Offset: 5, Length: 5}},
+			want:     "#tag @user",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entitiesToHTML(tt.text, tt.entities); got != tt.want {
+				t.Errorf("entitiesToHTML() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if hasFormatting([]tgbotapi.MessageEntity{{Type: "hashtag"}, {Type: "url"}}) {
+		t.Errorf("hasFormatting() = true for entities without HTML tags")
+	}
+	if !hasFormatting([]tgbotapi.MessageEntity{{Type: "url"}, {Type: "spoiler"}}) {
+		t.Errorf("hasFormatting() = false for a spoiler")
+	}
+}
+
+func TestCheckWizardKey(t *testing.T) {
+	if err := checkWizardKey("([", database.MatchContains); err != nil {
+		t.Errorf("checkWizardKey() = %v for a non-regex type", err)
+	}
+	if err := checkWizardKey("([", database.MatchRegex); err == nil {
+		t.Errorf("checkWizardKey() accepted an invalid regex")
+	}
+	if err := checkWizardKey("^退款$", database.MatchRegex); err != nil {
+		t.Errorf("checkWizardKey() = %v for a valid regex", err)
+	}
+}
+
+func TestAddWizard(t *testing.T) {
+	const adminID, chatID = 1, 100
+	tests := []struct {
+		name       string
+		reviewMode bool
+	}{
+		{name: "super admin saves directly"},
+		{name: "admin submits for review", reviewMode: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot, _ := newTestBot(t)
+			db := newTestDB(t)
+			conf := &config.Config{Admin: config.AdminConfig{AdminIDs: []int64{adminID}}}
+			if !tt.reviewMode {
+				conf.Admin.SuperAdminIDs = []int64{adminID}
+			}
+			conf.FAQ.ReviewMode = true
+			state := NewState()
+			w := NewAddWizard(db, conf, state)
+			from := &tgbotapi.User{ID: adminID}
+			chat := &tgbotapi.Chat{ID: chatID, Type: "private"}
+			send := func(text string, entities ...tgbotapi.MessageEntity) {
+				conv, _ := state.Get(chatID)
+				w.HandleInput(bot, &tgbotapi.Message{From: from, Chat: chat, Text: text, Entities: entities}, conv)
+			}
+			press := func(data string) {
+				w.HandleCallback(bot, &tgbotapi.CallbackQuery{From: from, Data: data, Message: &tgbotapi.Message{MessageID: 5, Chat: chat}}, data)
+			}
+
+			w.Start(bot, &tgbotapi.Message{From: from, Chat: chat})
+			send("退款")
+			press(fmt.Sprintf("addwiz_type_%d", database.MatchContains.ToInt()))
+			send("7 天内可退款", tgbotapi.MessageEntity{Type: "bold", Offset: 0, Length: 3})
+			if conv, _ := state.Get(chatID); conv == nil || conv.Stage != wizardConfirm || conv.Draft.Value != "<b>7 天</b>内可退款" {
+				t.Fatalf("conversation = %+v, want the formatted draft waiting for confirmation", conv)
+			}
+			press("addwiz_confirm")
+
+			if _, exists := state.Get(chatID); exists {
+				t.Errorf("conversation still exists after confirming")
+			}
+			entry, _ := findEntryByKeyInTypes(db, "退款", database.MatchContains)
+			changes, _ := db.ListPendingChanges()
+			if tt.reviewMode {
+				if entry != nil || len(changes) != 1 || changes[0].Value != "<b>7 天</b>内可退款" {
+					t.Errorf("entry = %+v, pending = %+v, want only a pending change", entry, changes)
+				}
+				return
+			}
+			if entry == nil || entry.Value != "<b>7 天</b>内可退款" || entry.CreatedBy != adminID || len(changes) != 0 {
+				t.Errorf("entry = %+v, pending = %+v, want the entry saved with its author", entry, changes)
+			}
+		})
+	}
+}
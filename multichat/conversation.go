@@ -1,6 +1,7 @@
 package multichat
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
@@ -12,6 +13,9 @@ import (
 	"TGFaqBot/database"
 )
 
+// stoppedNote 追加在被用户停止的回复之后，让模型在后续对话中知道上一条回复不完整
+const stoppedNote = "\n\n[回复已被用户停止]"
+
 // ConversationManager 管理多渠道对话
 type ConversationManager struct {
 	conversations      map[int64]*Conversation
@@ -125,7 +129,7 @@ func (cm *ConversationManager) GetLastUserInput(chatID int64) string {
 }
 
 // GetResponse 获取AI响应
-func (cm *ConversationManager) GetResponse(chatID int64, userMessage string, preferredProvider string, preferredModel string) (string, int, int, time.Duration, int, bool, string, error) {
+func (cm *ConversationManager) GetResponse(ctx context.Context, chatID int64, userMessage string, preferredProvider string, preferredModel string) (string, int, int, time.Duration, int, bool, *Route, error) {
 	start := time.Now()
 
 	// 初始化对话
@@ -213,9 +217,9 @@ func (cm *ConversationManager) GetResponse(chatID int64, userMessage string, pre
 	}
 
 	// 调用多渠道服务
-	response, inputTokens, outputTokens, usedProvider, err := cm.multiChatService.GetCompletion(apiMessages, preferredProvider, preferredModel)
+	response, inputTokens, outputTokens, route, err := cm.multiChatService.GetCompletion(ctx, apiMessages, preferredProvider, preferredModel)
 	if err != nil {
-		return "", 0, 0, time.Since(start), remainingRounds, shouldReset, "", err
+		return "", 0, 0, time.Since(start), remainingRounds, shouldReset, route, err
 	}
 
 	// 更新对话
@@ -226,7 +230,7 @@ func (cm *ConversationManager) GetResponse(chatID int64, userMessage string, pre
 		Time:    time.Now(),
 	})
 	convo.LastUpdated = time.Now()
-	convo.Provider = usedProvider
+	convo.Provider = route.Provider
 
 	// 更新token计数
 	if _, exists := cm.totalInputTokens[chatID]; !exists {
@@ -240,19 +244,20 @@ func (cm *ConversationManager) GetResponse(chatID int64, userMessage string, pre
 
 	totalInputCount := cm.totalInputTokens[chatID]
 	totalOutputCount := cm.totalOutputTokens[chatID]
-	log.Printf("Chat ID %d: Request tokens: %d, Response tokens: %d, Total input: %d, Total output: %d, Rounds: %d/%d, Provider: %s",
-		chatID, inputTokens, outputTokens, totalInputCount, totalOutputCount, currentRound, cm.config.HistoryLength, usedProvider)
+	log.Printf("Chat ID %d: Request tokens: %d, Response tokens: %d, Total input: %d, Total output: %d, Rounds: %d/%d, Route: %s",
+		chatID, inputTokens, outputTokens, totalInputCount, totalOutputCount, currentRound, cm.config.HistoryLength, route)
 
 	// 保存对话到Redis
 	cm.saveConversationToRedis(chatID, convo)
 
 	cm.conversationsMutex.Unlock()
 
-	return response, inputTokens, outputTokens, time.Since(start), remainingRounds, shouldReset, usedProvider, nil
+	return response, inputTokens, outputTokens, time.Since(start), remainingRounds, shouldReset, route, nil
 }
 
 // GetResponseWithCallback 获取AI响应，支持流式回调
-func (cm *ConversationManager) GetResponseWithCallback(chatID int64, userMessage string, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, time.Duration, int, bool, string, error) {
+// ctx 被取消时返回已生成的部分回复和 ctx.Err()，部分回复会标记为已停止并记入历史
+func (cm *ConversationManager) GetResponseWithCallback(ctx context.Context, chatID int64, userMessage string, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, time.Duration, int, bool, *Route, error) {
 	start := time.Now()
 
 	// 初始化对话
@@ -373,14 +378,33 @@ func (cm *ConversationManager) GetResponseWithCallback(chatID int64, userMessage
 			cm.conversationsMutex.Unlock()
 
 			// 返回缓存标识，tokens为0，不影响轮数
-			return responseWithCacheNote, 0, 0, time.Since(start), remainingRounds + 1, false, actualProvider, nil
+			return responseWithCacheNote, 0, 0, time.Since(start), remainingRounds + 1, false, &Route{RouteStep: RouteStep{Provider: actualProvider, Model: actualModel}}, nil
 		}
 	}
 
 	// 调用多渠道服务的流式方法
-	response, inputTokens, outputTokens, usedProvider, err := cm.multiChatService.GetCompletionWithCallback(apiMessages, preferredProvider, preferredModel, callback)
+	response, inputTokens, outputTokens, route, err := cm.multiChatService.GetCompletionWithCallback(ctx, apiMessages, preferredProvider, preferredModel, callback)
 	if err != nil {
-		return "", 0, 0, time.Since(start), remainingRounds, shouldReset, "", err
+		if ctx.Err() == nil {
+			return "", 0, 0, time.Since(start), remainingRounds, shouldReset, route, err
+		}
+		// 用户停止了生成，保留部分回复，避免下一轮对话缺少上下文
+		cm.conversationsMutex.Lock()
+		convo.History = append(convo.History, Message{
+			Role:    "assistant",
+			Content: response + stoppedNote,
+			Time:    time.Now(),
+		})
+		convo.LastUpdated = time.Now()
+		if route.Provider != "" {
+			convo.Provider = route.Provider
+		}
+		cm.totalInputTokens[chatID] += inputTokens
+		cm.totalOutputTokens[chatID] += outputTokens
+		cm.saveConversationToRedis(chatID, convo)
+		cm.conversationsMutex.Unlock()
+		log.Printf("Chat ID %d: response stopped by user after %d characters, Route: %s", chatID, len(response), route)
+		return response, inputTokens, outputTokens, time.Since(start), remainingRounds, shouldReset, route, err
 	}
 
 	// 更新对话
@@ -391,7 +415,7 @@ func (cm *ConversationManager) GetResponseWithCallback(chatID int64, userMessage
 		Time:    time.Now(),
 	})
 	convo.LastUpdated = time.Now()
-	convo.Provider = usedProvider
+	convo.Provider = route.Provider
 
 	// 更新token计数
 	if _, exists := cm.totalInputTokens[chatID]; !exists {
@@ -405,31 +429,25 @@ func (cm *ConversationManager) GetResponseWithCallback(chatID int64, userMessage
 
 	totalInputCount := cm.totalInputTokens[chatID]
 	totalOutputCount := cm.totalOutputTokens[chatID]
-	log.Printf("Chat ID %d: Request tokens: %d, Response tokens: %d, Total input: %d, Total output: %d, Rounds: %d/%d, Provider: %s",
-		chatID, inputTokens, outputTokens, totalInputCount, totalOutputCount, currentRound, cm.config.HistoryLength, usedProvider)
+	log.Printf("Chat ID %d: Request tokens: %d, Response tokens: %d, Total input: %d, Total output: %d, Rounds: %d/%d, Route: %s",
+		chatID, inputTokens, outputTokens, totalInputCount, totalOutputCount, currentRound, cm.config.HistoryLength, route)
 
 	// 保存对话到Redis
 	cm.saveConversationToRedis(chatID, convo)
 
 	// 将AI响应存储到缓存
 	if cm.redisClient != nil && cm.redisClient.IsAICacheEnabled() {
-		// 获取实际使用的模型
-		actualModel := preferredModel
-		if actualModel == "" {
-			actualModel = cm.multiChatService.GetDefaultModel(usedProvider)
-		}
-
 		// 存储到AI缓存
-		if cacheErr := cm.redisClient.SetAICache(usedProvider, actualModel, userMessage, response); cacheErr != nil {
+		if cacheErr := cm.redisClient.SetAICache(route.Provider, route.Model, userMessage, response); cacheErr != nil {
 			log.Printf("Failed to cache AI response for chat %d: %v", chatID, cacheErr)
 		} else {
-			log.Printf("AI response cached for chat %d: %s/%s", chatID, usedProvider, actualModel)
+			log.Printf("AI response cached for chat %d: %s", chatID, route.RouteStep)
 		}
 	}
 
 	cm.conversationsMutex.Unlock()
 
-	return response, inputTokens, outputTokens, time.Since(start), remainingRounds, shouldReset, usedProvider, nil
+	return response, inputTokens, outputTokens, time.Since(start), remainingRounds, shouldReset, route, nil
 }
 
 // ClearConversation 清除对话历史
@@ -502,10 +520,10 @@ func (cm *ConversationManager) GetRemainingTime(chatID int64) (int, int) {
 }
 
 // RetryLastMessage 重试最后一条用户消息
-func (cm *ConversationManager) RetryLastMessage(chatID int64, preferredProvider string, preferredModel string) (string, int, int, time.Duration, int, bool, string, error) {
+func (cm *ConversationManager) RetryLastMessage(ctx context.Context, chatID int64, preferredProvider string, preferredModel string) (string, int, int, time.Duration, int, bool, *Route, error) {
 	lastInput := cm.GetLastUserInput(chatID)
 	if lastInput == "" {
-		return "", 0, 0, 0, 0, false, "", fmt.Errorf("没有找到可重试的消息")
+		return "", 0, 0, 0, 0, false, nil, fmt.Errorf("没有找到可重试的消息")
 	}
 
 	// 移除最后的AI回复（如果存在）
@@ -548,14 +566,14 @@ func (cm *ConversationManager) RetryLastMessage(chatID int64, preferredProvider
 	}
 
 	// 使用保存的用户输入重新获取响应
-	return cm.GetResponse(chatID, lastInput, preferredProvider, preferredModel)
+	return cm.GetResponse(ctx, chatID, lastInput, preferredProvider, preferredModel)
 }
 
 // RetryLastMessageWithCallback 重试最后一条用户消息（带回调）
-func (cm *ConversationManager) RetryLastMessageWithCallback(chatID int64, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, time.Duration, int, bool, string, error) {
+func (cm *ConversationManager) RetryLastMessageWithCallback(ctx context.Context, chatID int64, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, time.Duration, int, bool, *Route, error) {
 	lastInput := cm.GetLastUserInput(chatID)
 	if lastInput == "" {
-		return "", 0, 0, 0, 0, false, "", fmt.Errorf("没有找到可重试的消息")
+		return "", 0, 0, 0, 0, false, nil, fmt.Errorf("没有找到可重试的消息")
 	}
 
 	// 移除最后的AI回复（如果存在）
@@ -598,5 +616,5 @@ func (cm *ConversationManager) RetryLastMessageWithCallback(chatID int64, prefer
 	}
 
 	// 使用保存的用户输入重新获取响应
-	return cm.GetResponseWithCallback(chatID, lastInput, preferredProvider, preferredModel, callback)
+	return cm.GetResponseWithCallback(ctx, chatID, lastInput, preferredProvider, preferredModel, callback)
 }
@@ -0,0 +1,152 @@
+package multichat
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"TGFaqBot/multichat/provider"
+)
+
+// defaultFallbackRules 各错误类型失败后是否尝试回退链中的下一项
+// 内容审核拦截换一个提供商通常也会被拦截，且可能绕过审核，默认不回退
+var defaultFallbackRules = map[string]bool{
+	ErrorClassNetwork:       true,
+	ErrorClassTimeout:       true,
+	ErrorClassAuth:          true,
+	ErrorClassRateLimit:     true,
+	ErrorClassQuota:         true,
+	ErrorClassContentFilter: false,
+	ErrorClassBadRequest:    true,
+	ErrorClassServer:        true,
+	ErrorClassParse:         true,
+	ErrorClassUnknown:       true,
+}
+
+// RouteStep 回退链中的一项
+type RouteStep struct {
+	Provider string
+	Model    string
+}
+
+func (s RouteStep) String() string {
+	return s.Provider + "/" + s.Model
+}
+
+// Attempt 一次失败的尝试
+type Attempt struct {
+	RouteStep
+	Class string // 错误类型
+	Err   error
+}
+
+// Detail 返回错误的HTTP状态码和提供商错误代码，例如 HTTP 429，insufficient_quota
+func (a Attempt) Detail() string {
+	var providerErr *provider.Error
+	if !errors.As(a.Err, &providerErr) {
+		return ""
+	}
+	var parts []string
+	if providerErr.StatusCode != 0 {
+		parts = append(parts, fmt.Sprintf("HTTP %d", providerErr.StatusCode))
+	}
+	if providerErr.Code != "" {
+		parts = append(parts, providerErr.Code)
+	}
+	return strings.Join(parts, "，")
+}
+
+// CompletionError 回退链中的所有提供商都失败，Attempts 记录每一次尝试的错误
+type CompletionError struct {
+	Attempts []Attempt
+}
+
+func (e *CompletionError) Error() string {
+	if len(e.Attempts) == 0 {
+		return "all providers failed: No providers available"
+	}
+	var errs []string
+	for _, attempt := range e.Attempts {
+		errs = append(errs, fmt.Sprintf("Provider %s: [%s] %v", attempt.RouteStep, attempt.Class, attempt.Err))
+	}
+	return "all providers failed: " + strings.Join(errs, "; ")
+}
+
+// Unwrap 返回每一次尝试的错误，errors.As 可以取得具体的提供商错误
+func (e *CompletionError) Unwrap() []error {
+	var errs []error
+	for _, attempt := range e.Attempts {
+		errs = append(errs, attempt.Err)
+	}
+	return errs
+}
+
+// Route 一次请求实际使用的提供商和模型，以及之前失败的尝试
+type Route struct {
+	RouteStep
+	Attempts []Attempt
+}
+
+// String 返回路由说明，例如 openai/gpt-4o（server）→ anthropic/claude-3-haiku-20240307
+func (r *Route) String() string {
+	if r == nil {
+		return ""
+	}
+	var parts []string
+	for _, attempt := range r.Attempts {
+		parts = append(parts, fmt.Sprintf("%s（%s）", attempt.RouteStep, attempt.Class))
+	}
+	if r.Provider != "" {
+		parts = append(parts, r.RouteStep.String())
+	}
+	return strings.Join(parts, " → ")
+}
+
+// shouldFallback 判断某类错误后是否继续尝试下一项，配置中的规则优先
+func (s *MultiChatService) shouldFallback(class string) bool {
+	if allow, exists := s.config.FallbackRules[class]; exists {
+		return allow
+	}
+	if allow, exists := defaultFallbackRules[class]; exists {
+		return allow
+	}
+	return true
+}
+
+// routes 返回按顺序尝试的提供商和模型：先是首选组合，再是配置的回退链；
+// 未配置回退链时按固定顺序使用所有启用提供商的默认模型
+func (s *MultiChatService) routes(preferredProvider string, preferredModel string) []RouteStep {
+	var steps []RouteStep
+	seen := make(map[RouteStep]bool)
+	add := func(providerName string, model string) {
+		if _, exists := s.Provider(providerName); !exists {
+			return
+		}
+		if model == "" {
+			model = s.GetDefaultModel(providerName)
+		}
+		step := RouteStep{Provider: providerName, Model: model}
+		if !seen[step] {
+			seen[step] = true
+			steps = append(steps, step)
+		}
+	}
+
+	if preferredProvider != "" {
+		if _, exists := s.Provider(preferredProvider); !exists {
+			log.Printf("Preferred provider %s not found", preferredProvider)
+		}
+		add(preferredProvider, preferredModel)
+	}
+	if len(s.config.Fallback) > 0 {
+		for _, route := range s.config.Fallback {
+			add(route.Provider, route.Model)
+		}
+		return steps
+	}
+	for _, providerName := range s.config.EnabledProviderNames() {
+		add(providerName, "")
+	}
+	return steps
+}
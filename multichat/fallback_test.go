@@ -0,0 +1,220 @@
+package multichat
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"TGFaqBot/config"
+	"TGFaqBot/multichat/provider"
+)
+
+// fakeProviderType 测试用的提供商类型，请求结果由 fakeResults 按实例名称决定
+const fakeProviderType = "fake"
+
+var (
+	fakeMutex   sync.Mutex
+	fakeResults = make(map[string]error)
+	fakeCalls   []string
+)
+
+// fakeProvider 返回 fakeResults 中设置的错误，没有设置时回复实例名称和模型
+type fakeProvider struct {
+	settings provider.Settings
+}
+
+func (p *fakeProvider) GetName() string { return p.settings.Name }
+
+func (p *fakeProvider) GetModels(ctx context.Context) ([]provider.Model, error) { return nil, nil }
+
+func (p *fakeProvider) Chat(ctx context.Context, messages []Message, opts provider.ChatOptions) (*ChatResponse, error) {
+	fakeMutex.Lock()
+	defer fakeMutex.Unlock()
+	fakeCalls = append(fakeCalls, p.settings.Name+"/"+opts.Model)
+	if err := fakeResults[p.settings.Name]; err != nil {
+		return nil, err
+	}
+	return &ChatResponse{Content: p.settings.Name + "/" + opts.Model}, nil
+}
+
+// useFakeProviders 注册测试用的提供商类型，设置各实例的请求结果并清空调用记录
+func useFakeProviders(t *testing.T, results map[string]error) {
+	t.Helper()
+	provider.Register(fakeProviderType, provider.Type{
+		New:          func(settings provider.Settings) provider.Provider { return &fakeProvider{settings: settings} },
+		DefaultURL:   "http://fake.invalid",
+		DefaultModel: "fake-model",
+	})
+	fakeMutex.Lock()
+	fakeResults, fakeCalls = results, nil
+	fakeMutex.Unlock()
+}
+
+// fakeChatConfig 返回包含指定名称的测试提供商的配置，不重试
+func fakeChatConfig(names ...string) *config.ChatConfig {
+	chatConfig := &config.ChatConfig{Retry: &config.RetryConfig{MaxAttempts: 1}}
+	for _, name := range names {
+		chatConfig.Providers = append(chatConfig.Providers, config.NamedProvider{
+			Name:           name,
+			Type:           fakeProviderType,
+			ProviderConfig: config.ProviderConfig{Enabled: true},
+		})
+	}
+	return chatConfig
+}
+
+func TestRoutes(t *testing.T) {
+	useFakeProviders(t, nil)
+
+	tests := []struct {
+		name      string
+		fallback  []config.FallbackRoute
+		provider  string
+		model     string
+		wantSteps []RouteStep
+	}{
+		{
+			name:      "enabled providers with default models",
+			wantSteps: []RouteStep{{"a", "fake-model"}, {"b", "fake-model"}, {"c", "fake-model"}},
+		},
+		{
+			name:      "preferred provider first without duplicates",
+			provider:  "b",
+			wantSteps: []RouteStep{{"b", "fake-model"}, {"a", "fake-model"}, {"c", "fake-model"}},
+		},
+		{
+			name:      "configured chain replaces the default order",
+			fallback:  []config.FallbackRoute{{Provider: "c", Model: "big"}, {Provider: "missing"}, {Provider: "a"}, {Provider: "c", Model: "big"}},
+			provider:  "a",
+			model:     "small",
+			wantSteps: []RouteStep{{"a", "small"}, {"c", "big"}, {"a", "fake-model"}},
+		},
+		{
+			name:      "unknown preferred provider is skipped",
+			fallback:  []config.FallbackRoute{{Provider: "b"}},
+			provider:  "missing",
+			wantSteps: []RouteStep{{"b", "fake-model"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chatConfig := fakeChatConfig("a", "b", "c")
+			chatConfig.Fallback = tt.fallback
+			s := NewMultiChatService(chatConfig, nil)
+			if got := s.routes(tt.provider, tt.model); !reflect.DeepEqual(got, tt.wantSteps) {
+				t.Errorf("routes() = %v, want %v", got, tt.wantSteps)
+			}
+		})
+	}
+}
+
+func TestCompleteFallback(t *testing.T) {
+	serverErr := &provider.Error{Kind: provider.ErrorServer, StatusCode: 503, Message: "overloaded"}
+	filtered := &provider.Error{Kind: provider.ErrorContentFilter, StatusCode: 400, Code: "content_policy_violation", Message: "blocked"}
+
+	tests := []struct {
+		name         string
+		results      map[string]error
+		rules        map[string]bool
+		wantContent  string
+		wantCalls    []string
+		wantAttempts []string
+		wantRoute    string
+	}{
+		{
+			name:        "first provider succeeds",
+			wantContent: "a/fake-model",
+			wantCalls:   []string{"a/fake-model"},
+			wantRoute:   "a/fake-model",
+		},
+		{
+			name:         "server error falls back",
+			results:      map[string]error{"a": serverErr},
+			wantContent:  "b/fake-model",
+			wantCalls:    []string{"a/fake-model", "b/fake-model"},
+			wantAttempts: []string{provider.ErrorServer},
+			wantRoute:    "a/fake-model（server） → b/fake-model",
+		},
+		{
+			name:         "content filter does not fall back by default",
+			results:      map[string]error{"a": filtered},
+			wantCalls:    []string{"a/fake-model"},
+			wantAttempts: []string{provider.ErrorContentFilter},
+		},
+		{
+			name:         "configured rules override the defaults",
+			results:      map[string]error{"a": filtered, "b": serverErr},
+			rules:        map[string]bool{ErrorClassContentFilter: true, ErrorClassServer: false},
+			wantCalls:    []string{"a/fake-model", "b/fake-model"},
+			wantAttempts: []string{provider.ErrorContentFilter, provider.ErrorServer},
+		},
+		{
+			name:         "all providers fail",
+			results:      map[string]error{"a": serverErr, "b": errors.New("boom")},
+			wantCalls:    []string{"a/fake-model", "b/fake-model"},
+			wantAttempts: []string{provider.ErrorServer, ErrorClassUnknown},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFakeProviders(t, tt.results)
+			chatConfig := fakeChatConfig("a", "b")
+			chatConfig.FallbackRules = tt.rules
+			s := NewMultiChatService(chatConfig, nil)
+
+			content, _, _, route, err := s.GetCompletion(context.Background(), []Message{{Role: "user", Content: "hi"}}, "", "")
+			if content != tt.wantContent {
+				t.Errorf("content = %q, want %q", content, tt.wantContent)
+			}
+			if !reflect.DeepEqual(fakeCalls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", fakeCalls, tt.wantCalls)
+			}
+			var classes []string
+			for _, attempt := range route.Attempts {
+				classes = append(classes, attempt.Class)
+			}
+			if !reflect.DeepEqual(classes, tt.wantAttempts) {
+				t.Errorf("attempts = %v, want %v", classes, tt.wantAttempts)
+			}
+
+			if tt.wantContent != "" {
+				if err != nil {
+					t.Fatalf("GetCompletion() error = %v", err)
+				}
+				if got := route.String(); got != tt.wantRoute {
+					t.Errorf("route = %q, want %q", got, tt.wantRoute)
+				}
+				return
+			}
+			var completionErr *CompletionError
+			if !errors.As(err, &completionErr) || len(completionErr.Attempts) != len(tt.wantAttempts) {
+				t.Fatalf("GetCompletion() error = %v, want a CompletionError with every attempt", err)
+			}
+			var providerErr *provider.Error
+			if !errors.As(err, &providerErr) || providerErr != tt.results["a"] {
+				t.Errorf("errors.As() = %v, want the first provider error", providerErr)
+			}
+		})
+	}
+}
+
+func TestAttemptDetail(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{&provider.Error{Kind: provider.ErrorQuota, StatusCode: 429, Code: "insufficient_quota"}, "HTTP 429，insufficient_quota"},
+		{&provider.Error{Kind: provider.ErrorServer, StatusCode: 500}, "HTTP 500"},
+		{&provider.Error{Kind: provider.ErrorNetwork}, ""},
+		{errors.New("boom"), ""},
+	}
+	for _, tt := range tests {
+		if got := (Attempt{Err: tt.err}).Detail(); got != tt.want {
+			t.Errorf("Detail(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package multichat
+
+import (
+	"errors"
+	"time"
+
+	"TGFaqBot/multichat/provider"
+	"TGFaqBot/utils"
+)
+
+// 重试和断路器的默认设置
+const (
+	defaultRetryAttempts       = 3
+	defaultRetryInitialWait    = time.Second
+	defaultRetryMaxWait        = 10 * time.Second
+	defaultBreakerMaxFailures  = 5
+	defaultBreakerResetTimeout = time.Minute
+)
+
+// retryableClasses 同一提供商值得重试的错误类型，其余错误重试也不会成功
+var retryableClasses = map[string]bool{
+	ErrorClassNetwork:   true,
+	ErrorClassTimeout:   true,
+	ErrorClassRateLimit: true,
+	ErrorClassServer:    true,
+}
+
+// unhealthyClasses 计入断路器失败次数的错误类型，请求本身有问题的错误不影响提供商的健康状态
+var unhealthyClasses = map[string]bool{
+	ErrorClassNetwork:   true,
+	ErrorClassTimeout:   true,
+	ErrorClassAuth:      true,
+	ErrorClassRateLimit: true,
+	ErrorClassQuota:     true,
+	ErrorClassServer:    true,
+}
+
+// retryConfig 根据配置生成重试设置
+func (s *MultiChatService) retryConfig() utils.RetryConfig {
+	retry := utils.RetryConfig{
+		MaxAttempts: defaultRetryAttempts,
+		InitialWait: defaultRetryInitialWait,
+		MaxWait:     defaultRetryMaxWait,
+		Multiplier:  2.0,
+		RetryAfter: func(err error) time.Duration {
+			var providerErr *provider.Error
+			if errors.As(err, &providerErr) {
+				return providerErr.RetryAfter
+			}
+			return 0
+		},
+	}
+	if cfg := s.config.Retry; cfg != nil {
+		if cfg.MaxAttempts > 0 {
+			retry.MaxAttempts = cfg.MaxAttempts
+		}
+		if cfg.InitialWaitMs > 0 {
+			retry.InitialWait = time.Duration(cfg.InitialWaitMs) * time.Millisecond
+		}
+		if cfg.MaxWaitMs > 0 {
+			retry.MaxWait = time.Duration(cfg.MaxWaitMs) * time.Millisecond
+		}
+	}
+	return retry
+}
+
+// SetStateChangeHook 设置提供商断路器状态变化时的回调
+func (s *MultiChatService) SetStateChangeHook(hook func(providerName, from, to string)) {
+	s.breakerMutex.Lock()
+	defer s.breakerMutex.Unlock()
+	s.onStateChange = hook
+}
+
+// breaker 返回提供商的断路器，首次使用时创建
+func (s *MultiChatService) breaker(providerName string) *utils.CircuitBreaker {
+	s.breakerMutex.Lock()
+	defer s.breakerMutex.Unlock()
+
+	if breaker, exists := s.breakers[providerName]; exists {
+		return breaker
+	}
+
+	maxFailures := defaultBreakerMaxFailures
+	resetTimeout := defaultBreakerResetTimeout
+	if cfg := s.config.CircuitBreaker; cfg != nil {
+		if cfg.MaxFailures > 0 {
+			maxFailures = cfg.MaxFailures
+		}
+		if cfg.ResetSeconds > 0 {
+			resetTimeout = time.Duration(cfg.ResetSeconds) * time.Second
+		}
+	}
+
+	breaker := utils.NewCircuitBreaker(maxFailures, resetTimeout)
+	breaker.OnStateChange = func(from, to string) {
+		s.breakerMutex.Lock()
+		hook := s.onStateChange
+		s.breakerMutex.Unlock()
+		if hook != nil {
+			hook(providerName, from, to)
+		}
+	}
+	s.breakers[providerName] = breaker
+	return breaker
+}
+
+// ProviderState 返回提供商断路器的当前状态
+func (s *MultiChatService) ProviderState(providerName string) string {
+	return s.breaker(providerName).State()
+}
@@ -0,0 +1,40 @@
+package multichat
+
+import (
+	"time"
+
+	"TGFaqBot/multichat/provider"
+)
+
+// KeyPool 返回提供商的密钥池，首次使用时创建
+// 密钥和轮换设置每次请求时从配置读取，/reload 后无需重启即可生效
+func (s *MultiChatService) KeyPool(providerName string) *provider.KeyPool {
+	s.keyMutex.Lock()
+	defer s.keyMutex.Unlock()
+
+	if pool, exists := s.keyPools[providerName]; exists {
+		return pool
+	}
+	pool := provider.NewKeyPool(func() provider.KeySettings {
+		providerConfig, exists := s.config.GetEnabledProviders()[providerName]
+		if !exists {
+			return provider.KeySettings{}
+		}
+		return provider.KeySettings{
+			Keys:     providerConfig.Keys(),
+			Rotation: providerConfig.KeyRotation,
+			Cooldown: time.Duration(providerConfig.KeyCooldown) * time.Second,
+		}
+	})
+	s.keyPools[providerName] = pool
+	return pool
+}
+
+// KeyUsage 返回各提供商每个密钥的使用情况
+func (s *MultiChatService) KeyUsage() map[string][]provider.KeyUsage {
+	usage := make(map[string][]provider.KeyUsage)
+	for _, providerName := range s.ProviderNames() {
+		usage[providerName] = s.KeyPool(providerName).Usage()
+	}
+	return usage
+}
@@ -0,0 +1,171 @@
+package multichat
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"strings"
+	"time"
+
+	"TGFaqBot/config"
+)
+
+// providerEntry 已创建的提供商实例和创建时使用的设置
+type providerEntry struct {
+	provider Provider
+	settings providerSettings
+}
+
+// providerSettings 创建提供商实例时使用的设置，变化后需要重新创建实例
+// 密钥、模型和采样参数每次请求时从配置读取，修改后不需要重新创建
+type providerSettings struct {
+	Type    string
+	APIURL  string
+	Timeout time.Duration
+	Headers map[string]string
+	HasKeys bool
+
+	APIVersion  string
+	Deployments map[string]string
+}
+
+// ProviderChanges 一次同步配置后提供商的变化
+type ProviderChanges struct {
+	Added   []string
+	Updated []string
+	Removed []string
+	Failed  map[string]error // 创建失败的提供商及原因
+}
+
+// Empty 提供商是否没有任何变化
+func (c ProviderChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Updated) == 0 && len(c.Removed) == 0 && len(c.Failed) == 0
+}
+
+// String 返回变化的摘要，用于日志
+func (c ProviderChanges) String() string {
+	if c.Empty() {
+		return "no changes"
+	}
+	var parts []string
+	if len(c.Added) > 0 {
+		parts = append(parts, "added: "+strings.Join(c.Added, ", "))
+	}
+	if len(c.Updated) > 0 {
+		parts = append(parts, "updated: "+strings.Join(c.Updated, ", "))
+	}
+	if len(c.Removed) > 0 {
+		parts = append(parts, "removed: "+strings.Join(c.Removed, ", "))
+	}
+	for name, err := range c.Failed {
+		parts = append(parts, fmt.Sprintf("failed %s: %v", name, err))
+	}
+	return strings.Join(parts, "; ")
+}
+
+// instanceSettings 返回实例的创建设置，超时优先使用提供商自己的设置
+func (s *MultiChatService) instanceSettings(instance config.ProviderInstance) providerSettings {
+	return providerSettings{
+		Type:    instance.Type,
+		APIURL:  instance.APIURL,
+		Timeout: time.Duration(instance.GetTimeout(s.config.Timeout)) * time.Second,
+		Headers: instance.Headers,
+		HasKeys: len(instance.Keys()) > 0,
+
+		APIVersion:  instance.APIVersion,
+		Deployments: instance.Deployments,
+	}
+}
+
+// Reload 按当前配置同步提供商：创建新启用的，重新创建设置有变化的，移除已停用或删除的
+// 设置没有变化的提供商保持原实例，进行中的请求不受影响
+func (s *MultiChatService) Reload() ProviderChanges {
+	s.providerMutex.Lock()
+	defer s.providerMutex.Unlock()
+
+	changes := ProviderChanges{Failed: make(map[string]error)}
+	enabled := make(map[string]bool)
+
+	for _, instance := range s.config.EnabledInstances() {
+		enabled[instance.Name] = true
+		settings := s.instanceSettings(instance)
+
+		current, exists := s.providers[instance.Name]
+		if exists && reflect.DeepEqual(current.settings, settings) {
+			continue
+		}
+
+		p, err := newProvider(instance, settings.Timeout, s.KeyPool(instance.Name))
+		if err != nil {
+			log.Printf("Failed to initialize provider %s: %v", instance.Name, err)
+			changes.Failed[instance.Name] = err
+			if exists {
+				s.removeProvider(instance.Name)
+				changes.Removed = append(changes.Removed, instance.Name)
+			}
+			continue
+		}
+		s.providers[instance.Name] = providerEntry{provider: p, settings: settings}
+
+		if exists {
+			// 设置变化后给提供商重新开始的机会，之前的失败可能正是旧设置造成的
+			s.resetBreaker(instance.Name)
+			changes.Updated = append(changes.Updated, instance.Name)
+			log.Printf("Reconfigured provider %s (%s)", instance.Name, instance.Type)
+		} else {
+			changes.Added = append(changes.Added, instance.Name)
+			log.Printf("Initialized provider %s (%s)", instance.Name, instance.Type)
+		}
+	}
+
+	for name := range s.providers {
+		if !enabled[name] {
+			s.removeProvider(name)
+			changes.Removed = append(changes.Removed, name)
+			log.Printf("Removed provider %s", name)
+		}
+	}
+
+	return changes
+}
+
+// removeProvider 移除提供商实例以及它的密钥池和断路器，调用方需持有 providerMutex
+func (s *MultiChatService) removeProvider(name string) {
+	delete(s.providers, name)
+
+	s.keyMutex.Lock()
+	delete(s.keyPools, name)
+	s.keyMutex.Unlock()
+
+	s.resetBreaker(name)
+}
+
+// resetBreaker 丢弃提供商的断路器，下次使用时按当前配置重新创建
+func (s *MultiChatService) resetBreaker(name string) {
+	s.breakerMutex.Lock()
+	defer s.breakerMutex.Unlock()
+	delete(s.breakers, name)
+}
+
+// Provider 返回已创建的提供商实例
+func (s *MultiChatService) Provider(name string) (Provider, bool) {
+	s.providerMutex.RLock()
+	defer s.providerMutex.RUnlock()
+	entry, exists := s.providers[name]
+	return entry.provider, exists
+}
+
+// ProviderNames 按配置顺序返回已创建的提供商名称
+// 模型列表、对话和诊断都使用这个列表，保证看到的提供商一致
+func (s *MultiChatService) ProviderNames() []string {
+	s.providerMutex.RLock()
+	defer s.providerMutex.RUnlock()
+
+	var names []string
+	for _, name := range s.config.EnabledProviderNames() {
+		if _, exists := s.providers[name]; exists {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,101 @@
+package multichat
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"TGFaqBot/config"
+)
+
+func TestReload(t *testing.T) {
+	useFakeProviders(t, nil)
+	chatConfig := fakeChatConfig("a", "b", "c")
+	chatConfig.Providers[0].APIKey = "k1"
+	s := NewMultiChatService(chatConfig, nil)
+	if got := s.ProviderNames(); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("ProviderNames() = %v, want a, b, c", got)
+	}
+	unchanged, _ := s.Provider("a")
+	updated, _ := s.Provider("b")
+
+	// b 的地址变化，c 被停用，新增 d，e 的类型不存在
+	chatConfig.Providers[1].APIURL = "http://other.invalid"
+	chatConfig.Providers[2].Enabled = false
+	chatConfig.Providers = append(chatConfig.Providers,
+		config.NamedProvider{Name: "d", Type: fakeProviderType, ProviderConfig: config.ProviderConfig{Enabled: true}},
+		config.NamedProvider{Name: "e", Type: "missing", ProviderConfig: config.ProviderConfig{Enabled: true}},
+	)
+	// 采样参数和密钥每次请求时读取，不需要重新创建实例
+	temperature := 0.5
+	chatConfig.Providers[0].Temperature = &temperature
+	chatConfig.Providers[0].APIKey = "k2"
+
+	changes := s.Reload()
+	sort.Strings(changes.Removed)
+	if !reflect.DeepEqual(changes.Added, []string{"d"}) || !reflect.DeepEqual(changes.Updated, []string{"b"}) || !reflect.DeepEqual(changes.Removed, []string{"c"}) {
+		t.Errorf("changes = %+v, want d added, b updated and c removed", changes)
+	}
+	if len(changes.Failed) != 1 || changes.Failed["e"] == nil {
+		t.Errorf("failed = %v, want e", changes.Failed)
+	}
+
+	if p, _ := s.Provider("a"); p != unchanged {
+		t.Error("unchanged provider was recreated")
+	}
+	if p, _ := s.Provider("b"); p == updated || p.(*fakeProvider).settings.APIURL != "http://other.invalid" {
+		t.Error("provider with new settings was not recreated")
+	}
+	if got := s.ProviderNames(); !reflect.DeepEqual(got, []string{"a", "b", "d"}) {
+		t.Errorf("ProviderNames() = %v, want a, b, d", got)
+	}
+
+	if changes := s.Reload(); len(changes.Added)+len(changes.Updated)+len(changes.Removed) > 0 {
+		t.Errorf("second Reload() = %+v, want no changes besides the failing provider", changes)
+	}
+}
+
+func TestReloadRemovesFailingProvider(t *testing.T) {
+	useFakeProviders(t, nil)
+	chatConfig := fakeChatConfig("a")
+	s := NewMultiChatService(chatConfig, nil)
+	pool := s.KeyPool("a")
+
+	// 新的设置无法创建实例时移除旧实例，而不是继续使用旧的设置
+	chatConfig.Providers[0].Type = "missing"
+	changes := s.Reload()
+	if !reflect.DeepEqual(changes.Removed, []string{"a"}) || changes.Failed["a"] == nil {
+		t.Errorf("changes = %+v, want a removed and failed", changes)
+	}
+	if _, exists := s.Provider("a"); exists {
+		t.Error("provider a still exists")
+	}
+	if s.KeyPool("a") == pool {
+		t.Error("key pool of the removed provider was kept")
+	}
+}
+
+func TestProviderChangesString(t *testing.T) {
+	tests := []struct {
+		changes ProviderChanges
+		want    string
+	}{
+		{ProviderChanges{}, "no changes"},
+		{ProviderChanges{Failed: map[string]error{}}, "no changes"},
+		{
+			ProviderChanges{Added: []string{"a", "b"}, Removed: []string{"c"}, Failed: map[string]error{"d": errors.New("boom")}},
+			"added: a, b; removed: c; failed d: boom",
+		},
+		{ProviderChanges{Updated: []string{"a"}}, "updated: a"},
+	}
+	for _, tt := range tests {
+		if got := tt.changes.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+		if empty := tt.changes.Empty(); empty != strings.HasPrefix(tt.want, "no changes") {
+			t.Errorf("Empty() = %v for %q", empty, tt.want)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package multichat
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"sync"
@@ -15,7 +16,6 @@ import (
 // Manager 多渠道聊天管理器
 type Manager struct {
 	config       *config.Config
-	providers    map[string]provider.Provider
 	mu           sync.RWMutex
 	configFile   string
 	db           database.Database
@@ -27,12 +27,12 @@ type Manager struct {
 func NewManager(cfg *config.Config, configFile string, db database.Database) *Manager {
 	manager := &Manager{
 		config:     cfg,
-		providers:  make(map[string]provider.Provider),
 		configFile: configFile,
 		db:         db,
 	}
 
-	manager.initializeProviders()
+	// 提供商由对话服务统一创建和管理，模型列表、对话和诊断使用同一组实例
+	manager.service = NewMultiChatService(&cfg.Chat, db)
 	manager.updateCachedModels()
 
 	// 初始化Redis客户端（如果启用）
@@ -46,79 +46,24 @@ func NewManager(cfg *config.Config, configFile string, db database.Database) *Ma
 	}
 
 	// 初始化对话系统
-	manager.service = NewMultiChatService(&cfg.Chat, db)
 	manager.conversation = NewConversationManager(manager.service, &cfg.Chat, redisClient)
 
 	return manager
 }
 
-// initializeProviders 初始化所有启用的提供商
-func (m *Manager) initializeProviders() {
-	timeout := time.Duration(m.config.Chat.Timeout) * time.Second
-
-	// OpenAI
-	if m.config.Chat.OpenAI != nil && m.config.Chat.OpenAI.Enabled {
-		providerTimeout := timeout
-		if m.config.Chat.OpenAI.Timeout > 0 {
-			providerTimeout = time.Duration(m.config.Chat.OpenAI.Timeout) * time.Second
-		}
-		m.providers["openai"] = provider.NewOpenAICompatibleProvider(
-			"OpenAI",
-			m.config.Chat.OpenAI.APIKey,
-			m.config.Chat.OpenAI.APIURL,
-			providerTimeout,
-		)
-		log.Printf("Initialized OpenAI provider")
-	}
-
-	// Anthropic
-	if m.config.Chat.Anthropic != nil && m.config.Chat.Anthropic.Enabled {
-		providerTimeout := timeout
-		if m.config.Chat.Anthropic.Timeout > 0 {
-			providerTimeout = time.Duration(m.config.Chat.Anthropic.Timeout) * time.Second
-		}
-		m.providers["anthropic"] = provider.NewAnthropicProvider(
-			m.config.Chat.Anthropic.APIKey,
-			m.config.Chat.Anthropic.APIURL,
-			providerTimeout,
-		)
-		log.Printf("Initialized Anthropic provider")
-	}
-
-	// Gemini
-	if m.config.Chat.Gemini != nil && m.config.Chat.Gemini.Enabled {
-		providerTimeout := timeout
-		if m.config.Chat.Gemini.Timeout > 0 {
-			providerTimeout = time.Duration(m.config.Chat.Gemini.Timeout) * time.Second
-		}
-		m.providers["gemini"] = provider.NewGeminiProvider(
-			m.config.Chat.Gemini.APIKey,
-			m.config.Chat.Gemini.APIURL,
-			providerTimeout,
-		)
-		log.Printf("Initialized Gemini provider")
-	}
-
-	// Ollama
-	if m.config.Chat.Ollama != nil && m.config.Chat.Ollama.Enabled {
-		providerTimeout := timeout
-		if m.config.Chat.Ollama.Timeout > 0 {
-			providerTimeout = time.Duration(m.config.Chat.Ollama.Timeout) * time.Second
-		}
-		m.providers["ollama"] = provider.NewOllamaProvider(
-			m.config.Chat.Ollama.APIKey,
-			m.config.Chat.Ollama.APIURL,
-			providerTimeout,
-		)
-		log.Printf("Initialized Ollama provider")
+// Reload 按重新加载后的配置同步提供商，有变化时在后台刷新模型列表
+func (m *Manager) Reload() ProviderChanges {
+	changes := m.service.Reload()
+	log.Printf("Providers reloaded: %s", changes)
+	if len(changes.Added) > 0 || len(changes.Updated) > 0 {
+		go m.updateCachedModels()
 	}
+	return changes
 }
 
 // HasEnabledProviders 检查是否有任何启用的AI提供商
 func (m *Manager) HasEnabledProviders() bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-	return len(m.providers) > 0
+	return len(m.service.ProviderNames()) > 0
 }
 
 // updateCachedModels 更新缓存的模型列表
@@ -129,9 +74,13 @@ func (m *Manager) updateCachedModels() {
 	allSuccessful := true
 	var allModels []config.Model
 
-	for name, provider := range m.providers {
+	for _, name := range m.service.ProviderNames() {
+		provider, exists := m.service.Provider(name)
+		if !exists {
+			continue
+		}
 		log.Printf("Fetching models for provider: %s", name)
-		models, err := provider.GetModels()
+		models, err := provider.GetModels(context.Background())
 		if err != nil {
 			log.Printf("Failed to fetch models for %s: %v", name, err)
 			allSuccessful = false
@@ -154,7 +103,7 @@ func (m *Manager) updateCachedModels() {
 
 			// 如果没有有效缓存，使用默认模型作为fallback
 			log.Printf("No valid cached models found, using default models for %s", name)
-			defaultModels := getDefaultModels(name)
+			defaultModels := m.getDefaultModels(name)
 			if len(defaultModels) > 0 {
 				if err := m.db.SaveModels(name, defaultModels); err != nil {
 					log.Printf("Failed to save default models for %s: %v", name, err)
@@ -257,36 +206,23 @@ func (m *Manager) updateCachedModels() {
 }
 
 // Chat 使用指定提供商进行对话
-func (m *Manager) Chat(providerName string, messages []provider.Message, model string) (*provider.ChatResponse, error) {
-	m.mu.RLock()
-	p, exists := m.providers[providerName]
-	m.mu.RUnlock()
-
+func (m *Manager) Chat(ctx context.Context, providerName string, messages []provider.Message, opts provider.ChatOptions) (*provider.ChatResponse, error) {
+	p, exists := m.service.Provider(providerName)
 	if !exists {
 		return nil, fmt.Errorf("provider %s not found or not enabled", providerName)
 	}
 
-	return p.Chat(messages, model)
+	return p.Chat(ctx, messages, opts)
 }
 
 // GetAvailableProviders 获取当前可用的提供商列表
 func (m *Manager) GetAvailableProviders() []string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var providers []string
-	for name := range m.providers {
-		providers = append(providers, name)
-	}
-	return providers
+	return m.service.ProviderNames()
 }
 
 // IsProviderAvailable 检查指定提供商是否可用
 func (m *Manager) IsProviderAvailable(provider string) bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	_, exists := m.providers[provider]
+	_, exists := m.service.Provider(provider)
 	return exists
 }
 
@@ -316,7 +252,7 @@ func (m *Manager) GetCachedModels(providerName string) []config.Model {
 	}
 
 	// 如果没有有效缓存，使用默认模型
-	defaultModels := getDefaultModels(providerName)
+	defaultModels := m.getDefaultModels(providerName)
 	if len(defaultModels) > 0 {
 		log.Printf("Using default models for %s (%d models)", providerName, len(defaultModels))
 		// 转换为配置格式
@@ -343,7 +279,7 @@ func (m *Manager) GetAllCachedModels() map[string][]config.Model {
 	result := make(map[string][]config.Model)
 
 	// 为每个提供商获取有效的模型列表
-	for providerName := range m.providers {
+	for _, providerName := range m.service.ProviderNames() {
 		// 首先尝试从数据库获取缓存的模型
 		dbModels, err := m.db.GetModels(providerName)
 		if err == nil && len(dbModels) > 0 {
@@ -365,7 +301,7 @@ func (m *Manager) GetAllCachedModels() map[string][]config.Model {
 		}
 
 		// 如果没有有效缓存，使用默认模型
-		defaultModels := getDefaultModels(providerName)
+		defaultModels := m.getDefaultModels(providerName)
 		if len(defaultModels) > 0 {
 			log.Printf("Using default models for %s (%d models)", providerName, len(defaultModels))
 			configModels := make([]config.Model, len(defaultModels))
@@ -383,42 +319,20 @@ func (m *Manager) GetAllCachedModels() map[string][]config.Model {
 	return result
 }
 
-// GetDefaultProvider 获取默认提供商（第一个启用的）
+// GetDefaultProvider 获取默认提供商（按配置顺序第一个可用的）
 func (m *Manager) GetDefaultProvider() string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	// 按优先级顺序返回第一个可用的提供商
-	preferredOrder := []string{"openai", "anthropic", "gemini", "groq", "azure", "openrouter", "ollama"}
-
-	for _, name := range preferredOrder {
-		if _, exists := m.providers[name]; exists {
-			return name
-		}
+	if names := m.service.ProviderNames(); len(names) > 0 {
+		return names[0]
 	}
-
-	// 如果没有找到优先的，返回第一个可用的
-	for name := range m.providers {
-		return name
-	}
-
 	return ""
 }
 
 // GetProviderConfig 获取提供商配置
 func (m *Manager) GetProviderConfig(providerName string) *config.ProviderConfig {
-	switch providerName {
-	case "openai":
-		return m.config.Chat.OpenAI
-	case "anthropic":
-		return m.config.Chat.Anthropic
-	case "gemini":
-		return m.config.Chat.Gemini
-	case "ollama":
-		return m.config.Chat.Ollama
-	default:
-		return nil
+	if instance, exists := m.config.Chat.FindInstance(providerName); exists {
+		return instance.ProviderConfig
 	}
+	return nil
 }
 
 // GetSystemPrompt 获取系统提示词（提供商特定或全局）
@@ -456,13 +370,13 @@ func (m *Manager) FormatResponse(response *provider.ChatResponse, duration time.
 }
 
 // GetResponse 获取AI响应（通过ConversationManager）
-func (m *Manager) GetResponse(chatID int64, userMessage string, preferredProvider string, preferredModel string) (string, int, int, time.Duration, int, bool, string, error) {
-	return m.conversation.GetResponse(chatID, userMessage, preferredProvider, preferredModel)
+func (m *Manager) GetResponse(ctx context.Context, chatID int64, userMessage string, preferredProvider string, preferredModel string) (string, int, int, time.Duration, int, bool, *Route, error) {
+	return m.conversation.GetResponse(ctx, chatID, userMessage, preferredProvider, preferredModel)
 }
 
 // GetResponseWithCallback 获取AI响应，支持流式回调（通过ConversationManager）
-func (m *Manager) GetResponseWithCallback(chatID int64, userMessage string, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, time.Duration, int, bool, string, error) {
-	return m.conversation.GetResponseWithCallback(chatID, userMessage, preferredProvider, preferredModel, callback)
+func (m *Manager) GetResponseWithCallback(ctx context.Context, chatID int64, userMessage string, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, time.Duration, int, bool, *Route, error) {
+	return m.conversation.GetResponseWithCallback(ctx, chatID, userMessage, preferredProvider, preferredModel, callback)
 }
 
 // ClearConversation 清除对话历史
@@ -483,28 +397,12 @@ func (m *Manager) GetRemainingTime(chatID int64) (int, int) {
 
 // GetProviderCount 获取可用提供商数量（用于诊断）
 func (m *Manager) GetProviderCount() int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	count := 0
-	for _, provider := range m.providers {
-		if provider != nil {
-			count++
-		}
-	}
-	return count
+	return len(m.service.ProviderNames())
 }
 
 // GetProviderNames 获取提供商名称列表（用于诊断）
 func (m *Manager) GetProviderNames() []string {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	var names []string
-	for name := range m.providers {
-		names = append(names, name)
-	}
-	return names
+	return m.service.ProviderNames()
 }
 
 // GetDiagnosticInfo 获取详细诊断信息（用于管理员错误显示）
@@ -515,6 +413,29 @@ func (m *Manager) GetDiagnosticInfo() string {
 	return "Service not initialized"
 }
 
+// SetProviderStateHook 设置提供商断路器状态变化时的回调，用于通知管理员
+func (m *Manager) SetProviderStateHook(hook func(providerName, from, to string)) {
+	if m.service != nil {
+		m.service.SetStateChangeHook(hook)
+	}
+}
+
+// GetKeyUsage 返回各提供商每个密钥的使用情况
+func (m *Manager) GetKeyUsage() map[string][]provider.KeyUsage {
+	if m.service == nil {
+		return nil
+	}
+	return m.service.KeyUsage()
+}
+
+// GetErrorCounts 返回自启动以来各提供商按错误类型统计的失败次数
+func (m *Manager) GetErrorCounts() map[string]map[string]int64 {
+	if m.service == nil {
+		return nil
+	}
+	return m.service.ErrorCounts()
+}
+
 // GetConversationManager 获取对话管理器
 func (m *Manager) GetConversationManager() *ConversationManager {
 	return m.conversation
@@ -549,7 +470,25 @@ func isCachedModelValid(models []database.ModelInfo) bool {
 }
 
 // getDefaultModels 返回默认的模型列表（当API调用失败时使用）
-func getDefaultModels(providerName string) []database.ModelInfo {
+// 命名的提供商实例没有内置列表，使用配置的默认模型
+func (m *Manager) getDefaultModels(providerName string) []database.ModelInfo {
+	if models := builtinDefaultModels(providerName); len(models) > 0 {
+		return models
+	}
+	providerConfig := m.GetProviderConfig(providerName)
+	if providerConfig == nil || providerConfig.DefaultModel == "" {
+		return nil
+	}
+	return []database.ModelInfo{{
+		ID:        providerConfig.DefaultModel,
+		Name:      providerConfig.DefaultModel,
+		Provider:  providerName,
+		UpdatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	}}
+}
+
+// builtinDefaultModels 返回内置提供商的默认模型列表
+func builtinDefaultModels(providerName string) []database.ModelInfo {
 	now := time.Now().Format("2006-01-02 15:04:05")
 
 	switch providerName {
@@ -595,7 +534,7 @@ func (m *Manager) GetValidModels(providerName string) ([]database.ModelInfo, err
 	}
 
 	// 如果没有有效缓存，返回默认模型
-	defaultModels := getDefaultModels(providerName)
+	defaultModels := m.getDefaultModels(providerName)
 	if len(defaultModels) > 0 {
 		log.Printf("Using default models for %s (%d models)", providerName, len(defaultModels))
 		// 保存默认模型到数据库
@@ -0,0 +1,71 @@
+package multichat
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// errorStats 按提供商和错误类型统计失败次数，用于诊断信息
+type errorStats struct {
+	mu     sync.Mutex
+	counts map[string]map[string]int64
+}
+
+func newErrorStats() *errorStats {
+	return &errorStats{counts: make(map[string]map[string]int64)}
+}
+
+func (e *errorStats) record(providerName string, class string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.counts[providerName] == nil {
+		e.counts[providerName] = make(map[string]int64)
+	}
+	e.counts[providerName][class]++
+}
+
+// snapshot 返回统计的副本
+func (e *errorStats) snapshot() map[string]map[string]int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	result := make(map[string]map[string]int64, len(e.counts))
+	for providerName, counts := range e.counts {
+		result[providerName] = make(map[string]int64, len(counts))
+		for class, count := range counts {
+			result[providerName][class] = count
+		}
+	}
+	return result
+}
+
+// String 返回排序后的统计，例如 anthropic(server=1) openai(rate_limit=3, timeout=1)
+func (e *errorStats) String() string {
+	counts := e.snapshot()
+	var providers []string
+	for providerName := range counts {
+		providers = append(providers, providerName)
+	}
+	sort.Strings(providers)
+
+	var parts []string
+	for _, providerName := range providers {
+		var classes []string
+		for class := range counts[providerName] {
+			classes = append(classes, class)
+		}
+		sort.Strings(classes)
+		var items []string
+		for _, class := range classes {
+			items = append(items, fmt.Sprintf("%s=%d", class, counts[providerName][class]))
+		}
+		parts = append(parts, fmt.Sprintf("%s(%s)", providerName, strings.Join(items, ", ")))
+	}
+	return strings.Join(parts, " ")
+}
+
+// ErrorCounts 返回自启动以来各提供商按错误类型统计的失败次数
+func (s *MultiChatService) ErrorCounts() map[string]map[string]int64 {
+	return s.errorStats.snapshot()
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,9 +15,10 @@ import (
 
 // AnthropicProvider Anthropic API提供商
 type AnthropicProvider struct {
-	APIKey  string
+	Keys    *KeyPool
 	APIURL  string
 	Timeout time.Duration
+	Headers map[string]string
 	client  *http.Client
 }
 
@@ -28,30 +30,59 @@ type AnthropicMessage struct {
 
 // AnthropicRequest Anthropic请求格式
 type AnthropicRequest struct {
-	Model     string             `json:"model"`
-	MaxTokens int                `json:"max_tokens"`
-	Messages  []AnthropicMessage `json:"messages"`
+	Model         string             `json:"model"`
+	MaxTokens     int                `json:"max_tokens"`
+	Messages      []AnthropicMessage `json:"messages"`
+	Temperature   *float64           `json:"temperature,omitempty"`
+	TopP          *float64           `json:"top_p,omitempty"`
+	StopSequences []string           `json:"stop_sequences,omitempty"`
+	Stream        bool               `json:"stream"`
 }
 
-// AnthropicResponse Anthropic响应格式
-type AnthropicResponse struct {
-	Content []struct {
-		Text string `json:"text"`
+// AnthropicStreamEvent Anthropic流式响应事件，不同类型的事件使用不同字段
+type AnthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
 		Type string `json:"type"`
-	} `json:"content"`
+		Text string `json:"text"`
+	} `json:"delta"`
 	Usage struct {
-		InputTokens  int `json:"input_tokens"`
 		OutputTokens int `json:"output_tokens"`
 	} `json:"usage"`
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// anthropicDefaultMaxTokens Anthropic要求必须指定 max_tokens
+const anthropicDefaultMaxTokens = 4096
+
+func init() {
+	Register("anthropic", Type{
+		New: func(settings Settings) Provider {
+			p := NewAnthropicProvider(settings.Keys, settings.APIURL, settings.Timeout)
+			p.Headers = settings.Headers
+			return p
+		},
+		DefaultURL:   "https://api.anthropic.com",
+		DefaultModel: "claude-3-haiku-20240307",
+		RequiresKey:  true,
+	})
 }
 
 // NewAnthropicProvider 创建Anthropic提供商
-func NewAnthropicProvider(apiKey, apiURL string, timeout time.Duration) *AnthropicProvider {
+func NewAnthropicProvider(keys *KeyPool, apiURL string, timeout time.Duration) *AnthropicProvider {
 	return &AnthropicProvider{
-		APIKey:  apiKey,
+		Keys:    keys,
 		APIURL:  apiURL,
 		Timeout: timeout,
-		client:  utils.GetEnhancedClientWithTimeout(timeout),
+		client:  utils.GetEnhancedClientWithTimeout(0),
 	}
 }
 
@@ -61,7 +92,7 @@ func (p *AnthropicProvider) GetName() string {
 }
 
 // GetModels 获取可用模型列表
-func (p *AnthropicProvider) GetModels() ([]Model, error) {
+func (p *AnthropicProvider) GetModels(ctx context.Context) ([]Model, error) {
 	// Anthropic没有公开的模型列表API，返回预定义的模型
 	models := []Model{
 		{
@@ -99,7 +130,23 @@ func (p *AnthropicProvider) GetModels() ([]Model, error) {
 }
 
 // Chat 进行对话
-func (p *AnthropicProvider) Chat(messages []Message, model string) (*ChatResponse, error) {
+func (p *AnthropicProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return p.ChatWithCallback(ctx, messages, opts, nil)
+}
+
+// ChatWithCallback 进行对话，支持流式回调
+func (p *AnthropicProvider) ChatWithCallback(ctx context.Context, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	key := p.Keys.Acquire()
+	response, err := p.chat(ctx, key, messages, opts, callback)
+	p.Keys.Report(key, err)
+	return response, err
+}
+
+// chat 使用指定密钥发送流式请求
+func (p *AnthropicProvider) chat(ctx context.Context, key string, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.Timeout)
+	defer cancel()
+
 	// 转换消息格式
 	anthropicMessages := make([]AnthropicMessage, 0, len(messages))
 	for _, msg := range messages {
@@ -122,78 +169,101 @@ func (p *AnthropicProvider) Chat(messages []Message, model string) (*ChatRespons
 		}
 	}
 
+	maxTokens := opts.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = anthropicDefaultMaxTokens
+	}
 	requestBody, err := json.Marshal(AnthropicRequest{
-		Model:     model,
-		MaxTokens: 4096,
-		Messages:  anthropicMessages,
+		Model:         opts.Model,
+		MaxTokens:     maxTokens,
+		Messages:      anthropicMessages,
+		Temperature:   opts.Temperature,
+		TopP:          opts.TopP,
+		StopSequences: opts.Stop,
+		Stream:        true,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", p.APIURL+"/messages", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.APIURL+"/messages", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", p.APIKey)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("x-api-key", key)
 	req.Header.Set("anthropic-version", "2023-06-01")
+	setHeaders(req, p.Headers)
 
 	resp, err := utils.DoRequest(p.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, requestError(err, "error sending request")
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status code: %d, body: %s", resp.StatusCode, string(body))
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, requestError(err, "error reading response")
+		}
+		return nil, newAPIError(resp, body, fmt.Sprintf("API returned status code: %d, body: %s", resp.StatusCode, string(body)))
 	}
 
-	var chatResp AnthropicResponse
-	err = json.Unmarshal(body, &chatResp)
+	// 处理流式响应：message_start 带输入token，content_block_delta 带文本增量，message_delta 带输出token
+	var content strings.Builder
+	var inputTokens, outputTokens int
+	var streamErr error
+	err = scanStream(ctx, resp.Body, func(line string) bool {
+		if !strings.HasPrefix(line, "data:") {
+			return true
+		}
+		var event AnthropicStreamEvent
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &event); err != nil {
+			return true
+		}
+		switch event.Type {
+		case "message_start":
+			inputTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				content.WriteString(event.Delta.Text)
+				if callback != nil && !callback(content.String(), false) {
+					return false
+				}
+			}
+		case "message_delta":
+			outputTokens = event.Usage.OutputTokens
+		case "message_stop":
+			return false
+		case "error":
+			streamErr = newStreamError(event.Error.Type, fmt.Sprintf("API error: %s: %s", event.Error.Type, event.Error.Message))
+			return false
+		}
+		return true
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %v", err)
+		return nil, err
+	}
+	if streamErr != nil {
+		return nil, streamErr
 	}
 
-	if len(chatResp.Content) == 0 {
-		return nil, fmt.Errorf("no response from Anthropic")
+	if content.Len() == 0 {
+		return nil, newError(ErrorServer, nil, "no response from Anthropic")
 	}
 
-	// 提取文本内容
-	var content strings.Builder
-	for _, c := range chatResp.Content {
-		if c.Type == "text" {
-			content.WriteString(c.Text)
-		}
+	// 最终回调，标记完成
+	if callback != nil {
+		callback(content.String(), true)
 	}
 
 	return &ChatResponse{
 		Content:      content.String(),
-		InputTokens:  chatResp.Usage.InputTokens,
-		OutputTokens: chatResp.Usage.OutputTokens,
-		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        opts.Model,
 		Provider:     "Anthropic",
 	}, nil
 }
-
-// ChatWithCallback 进行对话，支持流式回调
-func (p *AnthropicProvider) ChatWithCallback(messages []Message, model string, callback func(string, bool) bool) (*ChatResponse, error) {
-	// Anthropic目前不支持真正的流式响应，回退到普通方式
-	response, err := p.Chat(messages, model)
-	if err != nil {
-		return nil, err
-	}
-
-	// 模拟流式回调
-	if callback != nil {
-		callback(response.Content, true)
-	}
-
-	return response, nil
-}
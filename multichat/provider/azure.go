@@ -0,0 +1,177 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"TGFaqBot/utils"
+)
+
+// DefaultAzureAPIVersion 未配置 api_version 时使用的 Azure OpenAI API 版本
+const DefaultAzureAPIVersion = "2024-06-01"
+
+// azureDeploymentsAPIVersion 列出部署的接口只在这个版本中提供
+const azureDeploymentsAPIVersion = "2022-12-01"
+
+// AzureOpenAIProvider Azure OpenAI 提供商
+// 请求发往 {api_url}/openai/deployments/{部署名称}，密钥放在 api-key 请求头中，
+// 请求体和流式响应与 OpenAI 相同
+type AzureOpenAIProvider struct {
+	*OpenAICompatibleProvider
+	apiVersion  string
+	deployments map[string]string // 模型ID到部署名称的映射
+}
+
+func init() {
+	Register("azure", Type{
+		New: func(settings Settings) Provider {
+			p := NewAzureOpenAIProvider(settings.Name, settings.Keys, settings.APIURL, settings.APIVersion, settings.Deployments, settings.Timeout)
+			p.headers = settings.Headers
+			return p
+		},
+		RequiresKey: true,
+	})
+}
+
+// NewAzureOpenAIProvider 创建 Azure OpenAI 提供商，apiURL 为资源地址，如 https://my-resource.openai.azure.com
+// deployments 为模型ID到部署名称的映射，没有映射的模型ID直接作为部署名称使用
+func NewAzureOpenAIProvider(name string, keys *KeyPool, apiURL string, apiVersion string, deployments map[string]string, timeout time.Duration) *AzureOpenAIProvider {
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+	return &AzureOpenAIProvider{
+		OpenAICompatibleProvider: NewOpenAICompatibleProvider(name, keys, apiURL, timeout),
+		apiVersion:               apiVersion,
+		deployments:              deployments,
+	}
+}
+
+// deployment 返回模型ID对应的部署名称
+func (p *AzureOpenAIProvider) deployment(model string) string {
+	if deployment, exists := p.deployments[model]; exists && deployment != "" {
+		return deployment
+	}
+	return model
+}
+
+// Chat 发送聊天请求
+func (p *AzureOpenAIProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return p.ChatWithCallback(ctx, messages, opts, nil)
+}
+
+// ChatWithCallback 发送聊天请求，支持流式回调
+func (p *AzureOpenAIProvider) ChatWithCallback(ctx context.Context, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	key := p.keys.Acquire()
+	response, err := p.chat(ctx, key, messages, opts, callback)
+	p.keys.Report(key, err)
+	return response, err
+}
+
+// chat 使用指定密钥向模型对应的部署发送流式请求
+func (p *AzureOpenAIProvider) chat(ctx context.Context, key string, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.timeout)
+	defer cancel()
+
+	jsonData, err := chatRequestBody(messages, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := fmt.Sprintf("%s/openai/deployments/%s/chat/completions?api-version=%s",
+		p.apiURL, url.PathEscape(p.deployment(opts.Model)), url.QueryEscape(p.apiVersion))
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("api-key", key)
+
+	return p.streamChat(ctx, req, messages, opts, callback)
+}
+
+// GetModels 返回可用模型，配置了 deployments 时直接使用映射中的模型ID，
+// 否则从 Azure 列出资源下的部署，以部署名称作为模型ID
+func (p *AzureOpenAIProvider) GetModels(ctx context.Context) ([]Model, error) {
+	if len(p.deployments) > 0 {
+		var models []Model
+		for model, deployment := range p.deployments {
+			models = append(models, Model{
+				ID:          model,
+				Name:        model,
+				Provider:    p.name,
+				Description: "deployment: " + deployment,
+			})
+		}
+		sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+		p.models = models
+		return models, nil
+	}
+
+	key := p.keys.Acquire()
+	models, err := p.listDeployments(ctx, key)
+	p.keys.Report(key, err)
+	return models, err
+}
+
+// listDeployments 列出资源下已成功部署的模型
+func (p *AzureOpenAIProvider) listDeployments(ctx context.Context, key string) ([]Model, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.timeout)
+	defer cancel()
+
+	endpoint := fmt.Sprintf("%s/openai/deployments?api-version=%s", p.apiURL, azureDeploymentsAPIVersion)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
+	}
+	req.Header.Set("api-key", key)
+	setHeaders(req, p.headers)
+
+	resp, err := utils.DoRequestWithCompression(p.httpClient, req)
+	if err != nil {
+		return nil, requestError(err, "failed to send request")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, requestError(err, "failed to read response")
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, body, fmt.Sprintf("API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	var deploymentsResp struct {
+		Data []struct {
+			ID     string `json:"id"`
+			Model  string `json:"model"`
+			Status string `json:"status"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &deploymentsResp); err != nil {
+		return nil, newError(ErrorParse, err, "failed to parse response: %v. Response body (first 500 chars): %s", err, truncateString(string(body), 500))
+	}
+
+	var models []Model
+	for _, d := range deploymentsResp.Data {
+		if d.Status != "" && !strings.EqualFold(d.Status, "succeeded") {
+			continue
+		}
+		models = append(models, Model{
+			ID:          d.ID,
+			Name:        fmt.Sprintf("%s (%s)", d.ID, d.Model),
+			Provider:    p.name,
+			Description: "model: " + d.Model,
+		})
+	}
+
+	p.models = models
+	return models, nil
+}
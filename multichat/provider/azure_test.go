@@ -0,0 +1,98 @@
+package provider
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAzureChatEndpoint(t *testing.T) {
+	tests := []struct {
+		name           string
+		apiVersion     string
+		deployments    map[string]string
+		model          string
+		wantPath       string
+		wantAPIVersion string
+	}{
+		{
+			name:           "mapped deployment and default api version",
+			deployments:    map[string]string{"gpt-4o": "prod-gpt4o"},
+			model:          "gpt-4o",
+			wantPath:       "/openai/deployments/prod-gpt4o/chat/completions",
+			wantAPIVersion: DefaultAzureAPIVersion,
+		},
+		{
+			name:           "unmapped model is used as the deployment name",
+			apiVersion:     "2025-01-01-preview",
+			deployments:    map[string]string{"gpt-4o": "prod-gpt4o"},
+			model:          "my deployment",
+			wantPath:       "/openai/deployments/my deployment/chat/completions",
+			wantAPIVersion: "2025-01-01-preview",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotPath, gotVersion, gotKey, gotAuth string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotPath, gotVersion = r.URL.Path, r.URL.Query().Get("api-version")
+				gotKey, gotAuth = r.Header.Get("api-key"), r.Header.Get("Authorization")
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Write([]byte("data: {\"choices\":[{\"delta\":{\"content\":\"你好\"}}]}\n\ndata: [DONE]\n\n"))
+			}))
+			defer server.Close()
+
+			p := NewAzureOpenAIProvider("azure", NewKeyPool(staticKeys(KeyRotationRoundRobin, "secret")), server.URL, tt.apiVersion, tt.deployments, time.Minute)
+			resp, err := p.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, ChatOptions{Model: tt.model})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.Content != "你好" || resp.Model != tt.model {
+				t.Errorf("response = %+v, want content 你好 and model %q", resp, tt.model)
+			}
+			if gotPath != tt.wantPath || gotVersion != tt.wantAPIVersion {
+				t.Errorf("request = %s?api-version=%s, want %s?api-version=%s", gotPath, gotVersion, tt.wantPath, tt.wantAPIVersion)
+			}
+			if gotKey != "secret" || gotAuth != "" {
+				t.Errorf("api-key = %q, Authorization = %q, want the key only in api-key", gotKey, gotAuth)
+			}
+		})
+	}
+}
+
+func TestAzureGetModels(t *testing.T) {
+	t.Run("configured deployments", func(t *testing.T) {
+		p := NewAzureOpenAIProvider("azure", nil, "http://unused.invalid", "", map[string]string{"gpt-4o": "prod", "gpt-35-turbo": "legacy"}, time.Minute)
+		models, err := p.GetModels(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(models) != 2 || models[0].ID != "gpt-35-turbo" || models[1].ID != "gpt-4o" || models[1].Description != "deployment: prod" {
+			t.Errorf("GetModels() = %+v, want the mapped models sorted by ID", models)
+		}
+	})
+
+	t.Run("listed deployments", func(t *testing.T) {
+		var gotPath, gotVersion string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotPath, gotVersion = r.URL.Path, r.URL.Query().Get("api-version")
+			w.Write([]byte(`{"data":[{"id":"prod","model":"gpt-4o","status":"succeeded"},{"id":"new","model":"gpt-4o-mini","status":"running"},{"id":"old","model":"gpt-35-turbo"}]}`))
+		}))
+		defer server.Close()
+
+		p := NewAzureOpenAIProvider("azure", NewKeyPool(staticKeys(KeyRotationRoundRobin, "secret")), server.URL, "", nil, time.Minute)
+		models, err := p.GetModels(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gotPath != "/openai/deployments" || gotVersion != azureDeploymentsAPIVersion {
+			t.Errorf("request = %s?api-version=%s, want the deployments endpoint", gotPath, gotVersion)
+		}
+		if len(models) != 2 || models[0].ID != "prod" || models[1].ID != "old" {
+			t.Errorf("GetModels() = %+v, want only succeeded deployments", models)
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 提供商错误类型，用于回退、重试、断路器和错误统计
+const (
+	ErrorNetwork       = "network"        // 连接失败、读取响应中断
+	ErrorTimeout       = "timeout"        // 请求超时
+	ErrorAuth          = "auth"           // API密钥无效或没有权限
+	ErrorRateLimit     = "rate_limit"     // 请求过于频繁
+	ErrorQuota         = "quota"          // 额度或余额不足
+	ErrorContentFilter = "content_filter" // 内容审核拦截
+	ErrorBadRequest    = "bad_request"    // 请求参数有误，如模型不存在、上下文过长
+	ErrorServer        = "server"         // 服务端错误或过载
+	ErrorParse         = "parse"          // 响应无法解析
+)
+
+// Error 提供商请求失败的错误
+type Error struct {
+	Kind       string        // 错误类型
+	StatusCode int           // HTTP状态码，流式响应中途出错或未收到响应时为0
+	Code       string        // 提供商返回的错误代码，如 insufficient_quota、overloaded_error、RESOURCE_EXHAUSTED
+	RetryAfter time.Duration // 服务端要求的等待时间，来自 Retry-After 或限流响应头
+	Message    string
+	Err        error // 底层错误
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError 创建指定类型的错误
+func newError(kind string, err error, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+// requestError 包装发送请求或读取响应时的错误，区分超时和网络错误
+func requestError(err error, message string) *Error {
+	kind := ErrorNetwork
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = ErrorTimeout
+	}
+	return newError(kind, err, "%s: %v", message, err)
+}
+
+// newAPIError 根据非200响应创建错误，body 为响应内容，用于提取提供商的错误代码
+func newAPIError(resp *http.Response, body []byte, message string) *Error {
+	code := errorCode(body)
+	kind := errorKind(resp.StatusCode, code)
+	return &Error{
+		Kind:       kind,
+		StatusCode: resp.StatusCode,
+		Code:       code,
+		RetryAfter: retryAfter(resp.Header, kind),
+		Message:    message,
+	}
+}
+
+// newStreamError 创建流式响应中途返回的错误，此时HTTP状态码已经是200
+func newStreamError(code string, message string) *Error {
+	kind := errorKind(0, code)
+	if kind == "" {
+		kind = ErrorServer
+	}
+	return &Error{Kind: kind, Code: code, Message: message}
+}
+
+// errorCode 从错误响应中提取错误代码，兼容以下格式：
+// OpenAI {"error":{"type":"...","code":"..."}}、Anthropic {"error":{"type":"..."}}、
+// Gemini {"error":{"code":429,"status":"..."}}
+func errorCode(body []byte) string {
+	var resp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || len(resp.Error) == 0 {
+		return ""
+	}
+	var detail struct {
+		Code   json.RawMessage `json:"code"`
+		Type   string          `json:"type"`
+		Status string          `json:"status"`
+	}
+	if err := json.Unmarshal(resp.Error, &detail); err != nil {
+		return "" // Ollama 的 error 是字符串，没有错误代码
+	}
+	var code string
+	if json.Unmarshal(detail.Code, &code) == nil && code != "" {
+		return code
+	}
+	if detail.Status != "" {
+		return detail.Status
+	}
+	return detail.Type
+}
+
+// errorKind 根据HTTP状态码和错误代码判断错误类型，错误代码更具体时优先使用
+func errorKind(status int, code string) string {
+	lower := strings.ToLower(code)
+	switch {
+	case strings.Contains(lower, "content_filter") || strings.Contains(lower, "content_policy") || strings.Contains(lower, "safety"):
+		return ErrorContentFilter
+	case strings.Contains(lower, "quota") || strings.Contains(lower, "billing") || strings.Contains(lower, "insufficient") || status == http.StatusPaymentRequired:
+		return ErrorQuota
+	case status == http.StatusUnauthorized || status == http.StatusForbidden ||
+		lower == "authentication_error" || lower == "permission_error" || lower == "unauthenticated" || lower == "permission_denied":
+		return ErrorAuth
+	case status == http.StatusTooManyRequests || strings.Contains(lower, "rate_limit") || lower == "resource_exhausted":
+		return ErrorRateLimit
+	case status == http.StatusRequestTimeout || status == http.StatusGatewayTimeout || lower == "deadline_exceeded":
+		return ErrorTimeout
+	case status >= 500 || lower == "overloaded_error" || lower == "api_error" || lower == "unavailable" || lower == "internal":
+		return ErrorServer
+	case status >= 400 || lower == "invalid_request_error" || lower == "invalid_argument" || lower == "not_found_error":
+		return ErrorBadRequest
+	}
+	return ""
+}
+
+// retryAfter 解析响应头中的等待时间，按优先级依次为：
+// retry-after-ms、Retry-After（秒数或HTTP日期）、OpenAI 的 x-ratelimit-reset-*、Anthropic 的 anthropic-ratelimit-*-reset
+// 限额重置时间在每个响应中都会返回，只有限流和额度错误时才表示需要等待，其他错误只使用 Retry-After
+func retryAfter(header http.Header, kind string) time.Duration {
+	if ms, err := strconv.Atoi(strings.TrimSpace(header.Get("retry-after-ms"))); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond
+	}
+	if value := strings.TrimSpace(header.Get("Retry-After")); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+			return time.Duration(seconds) * time.Second
+		}
+		if at, err := http.ParseTime(value); err == nil {
+			return positive(time.Until(at))
+		}
+	}
+
+	if kind != ErrorRateLimit && kind != ErrorQuota {
+		return 0
+	}
+
+	// 请求数和token数两种限制同时存在时，以需要等待更久的为准
+	var wait time.Duration
+	for _, name := range []string{"x-ratelimit-reset-requests", "x-ratelimit-reset-tokens"} {
+		if d, err := time.ParseDuration(strings.TrimSpace(header.Get(name))); err == nil && d > wait {
+			wait = d
+		}
+	}
+	for _, name := range []string{"anthropic-ratelimit-requests-reset", "anthropic-ratelimit-tokens-reset"} {
+		if at, err := time.Parse(time.RFC3339, strings.TrimSpace(header.Get(name))); err == nil {
+			if d := positive(time.Until(at)); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+func positive(d time.Duration) time.Duration {
+	if d < 0 {
+		return 0
+	}
+	return d
+}
@@ -0,0 +1,119 @@
+package provider
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	// 依赖当前时间的用例允许一定误差
+	const tolerance = 2 * time.Second
+	future := time.Now().Add(30 * time.Second)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		kind    string
+		want    time.Duration
+	}{
+		{name: "no headers", kind: ErrorRateLimit, want: 0},
+		{
+			name:    "retry-after-ms takes precedence",
+			headers: map[string]string{"retry-after-ms": "1500", "Retry-After": "10"},
+			kind:    ErrorRateLimit,
+			want:    1500 * time.Millisecond,
+		},
+		{
+			name:    "retry after seconds",
+			headers: map[string]string{"Retry-After": "7"},
+			kind:    ErrorRateLimit,
+			want:    7 * time.Second,
+		},
+		{
+			name:    "retry after applies to server errors",
+			headers: map[string]string{"Retry-After": "5"},
+			kind:    ErrorServer,
+			want:    5 * time.Second,
+		},
+		{
+			name:    "retry after http date",
+			headers: map[string]string{"Retry-After": future.UTC().Format(http.TimeFormat)},
+			kind:    ErrorServer,
+			want:    30 * time.Second,
+		},
+		{
+			name:    "retry after date in the past",
+			headers: map[string]string{"Retry-After": time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)},
+			kind:    ErrorRateLimit,
+			want:    0,
+		},
+		{
+			name:    "invalid retry after falls back to reset headers",
+			headers: map[string]string{"Retry-After": "soon", "x-ratelimit-reset-requests": "2s"},
+			kind:    ErrorRateLimit,
+			want:    2 * time.Second,
+		},
+		{
+			name:    "openai reset headers use the longer wait",
+			headers: map[string]string{"x-ratelimit-reset-requests": "1s", "x-ratelimit-reset-tokens": "6m0s"},
+			kind:    ErrorRateLimit,
+			want:    6 * time.Minute,
+		},
+		{
+			name:    "anthropic reset headers",
+			headers: map[string]string{"anthropic-ratelimit-tokens-reset": future.UTC().Format(time.RFC3339)},
+			kind:    ErrorQuota,
+			want:    30 * time.Second,
+		},
+		{
+			name:    "reset headers are ignored for other errors",
+			headers: map[string]string{"x-ratelimit-reset-requests": "20s", "anthropic-ratelimit-requests-reset": future.UTC().Format(time.RFC3339)},
+			kind:    ErrorServer,
+			want:    0,
+		},
+		{
+			name:    "reset headers are ignored for bad requests",
+			headers: map[string]string{"x-ratelimit-reset-tokens": "20s"},
+			kind:    ErrorBadRequest,
+			want:    0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tt.headers {
+				header.Set(name, value)
+			}
+			got := retryAfter(header, tt.kind)
+			if got > tt.want || got < tt.want-tolerance {
+				t.Errorf("retryAfter() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorKind(t *testing.T) {
+	tests := []struct {
+		status int
+		code   string
+		want   string
+	}{
+		{http.StatusTooManyRequests, "", ErrorRateLimit},
+		{http.StatusTooManyRequests, "insufficient_quota", ErrorQuota},
+		{http.StatusBadRequest, "content_policy_violation", ErrorContentFilter},
+		{http.StatusUnauthorized, "", ErrorAuth},
+		{0, "RESOURCE_EXHAUSTED", ErrorRateLimit},
+		{http.StatusGatewayTimeout, "", ErrorTimeout},
+		{529, "overloaded_error", ErrorServer},
+		{http.StatusNotFound, "", ErrorBadRequest},
+		{0, "", ""},
+	}
+
+	for _, tt := range tests {
+		if got := errorKind(tt.status, tt.code); got != tt.want {
+			t.Errorf("errorKind(%d, %q) = %q, want %q", tt.status, tt.code, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -14,9 +15,10 @@ import (
 
 // GeminiProvider Google Gemini API提供商
 type GeminiProvider struct {
-	APIKey  string
+	Keys    *KeyPool
 	APIURL  string
 	Timeout time.Duration
+	Headers map[string]string
 	client  *http.Client
 }
 
@@ -30,10 +32,19 @@ type GeminiContent struct {
 
 // GeminiRequest Gemini请求格式
 type GeminiRequest struct {
-	Contents []GeminiContent `json:"contents"`
+	Contents         []GeminiContent         `json:"contents"`
+	GenerationConfig *GeminiGenerationConfig `json:"generationConfig,omitempty"`
 }
 
-// GeminiResponse Gemini响应格式
+// GeminiGenerationConfig Gemini生成参数
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+}
+
+// GeminiResponse Gemini响应格式，流式响应的每个数据块也使用该格式
 type GeminiResponse struct {
 	Candidates []struct {
 		Content struct {
@@ -41,7 +52,11 @@ type GeminiResponse struct {
 				Text string `json:"text"`
 			} `json:"parts"`
 		} `json:"content"`
+		FinishReason string `json:"finishReason"`
 	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
 	UsageMetadata struct {
 		PromptTokenCount     int `json:"promptTokenCount"`
 		CandidatesTokenCount int `json:"candidatesTokenCount"`
@@ -49,13 +64,26 @@ type GeminiResponse struct {
 	} `json:"usageMetadata"`
 }
 
+func init() {
+	Register("gemini", Type{
+		New: func(settings Settings) Provider {
+			p := NewGeminiProvider(settings.Keys, settings.APIURL, settings.Timeout)
+			p.Headers = settings.Headers
+			return p
+		},
+		DefaultURL:   "https://generativelanguage.googleapis.com/v1beta",
+		DefaultModel: "gemini-pro",
+		RequiresKey:  true,
+	})
+}
+
 // NewGeminiProvider 创建Gemini提供商
-func NewGeminiProvider(apiKey, apiURL string, timeout time.Duration) *GeminiProvider {
+func NewGeminiProvider(keys *KeyPool, apiURL string, timeout time.Duration) *GeminiProvider {
 	return &GeminiProvider{
-		APIKey:  apiKey,
+		Keys:    keys,
 		APIURL:  apiURL,
 		Timeout: timeout,
-		client:  utils.GetEnhancedClientWithTimeout(timeout),
+		client:  utils.GetEnhancedClientWithTimeout(0),
 	}
 }
 
@@ -65,7 +93,7 @@ func (p *GeminiProvider) GetName() string {
 }
 
 // GetModels 获取可用模型列表
-func (p *GeminiProvider) GetModels() ([]Model, error) {
+func (p *GeminiProvider) GetModels(ctx context.Context) ([]Model, error) {
 	// Gemini API模型列表
 	models := []Model{
 		{
@@ -97,7 +125,23 @@ func (p *GeminiProvider) GetModels() ([]Model, error) {
 }
 
 // Chat 进行对话
-func (p *GeminiProvider) Chat(messages []Message, model string) (*ChatResponse, error) {
+func (p *GeminiProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return p.ChatWithCallback(ctx, messages, opts, nil)
+}
+
+// ChatWithCallback 进行对话，支持流式回调
+func (p *GeminiProvider) ChatWithCallback(ctx context.Context, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	key := p.Keys.Acquire()
+	response, err := p.chat(ctx, key, messages, opts, callback)
+	p.Keys.Report(key, err)
+	return response, err
+}
+
+// chat 使用指定密钥发送流式请求
+func (p *GeminiProvider) chat(ctx context.Context, key string, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.Timeout)
+	defer cancel()
+
 	// 转换消息格式
 	contents := make([]GeminiContent, 0, len(messages))
 
@@ -136,73 +180,100 @@ func (p *GeminiProvider) Chat(messages []Message, model string) (*ChatResponse,
 		})
 	}
 
-	requestBody, err := json.Marshal(GeminiRequest{
-		Contents: contents,
-	})
+	request := GeminiRequest{Contents: contents}
+	if opts.Temperature != nil || opts.TopP != nil || opts.MaxTokens > 0 || len(opts.Stop) > 0 {
+		request.GenerationConfig = &GeminiGenerationConfig{
+			Temperature:     opts.Temperature,
+			TopP:            opts.TopP,
+			MaxOutputTokens: opts.MaxTokens,
+			StopSequences:   opts.Stop,
+		}
+	}
+	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %v", err)
 	}
 
-	url := fmt.Sprintf("%s/models/%s:generateContent?key=%s", p.APIURL, model, p.APIKey)
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	url := fmt.Sprintf("%s/models/%s:streamGenerateContent?alt=sse&key=%s", p.APIURL, opts.Model, key)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	setHeaders(req, p.Headers)
 
 	resp, err := utils.DoRequest(p.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, requestError(err, "error sending request")
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status code: %d, body: %s", resp.StatusCode, string(body))
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, requestError(err, "error reading response")
+		}
+		return nil, newAPIError(resp, body, fmt.Sprintf("API returned status code: %d, body: %s", resp.StatusCode, string(body)))
 	}
 
-	var chatResp GeminiResponse
-	err = json.Unmarshal(body, &chatResp)
+	// 处理流式响应：每个数据块包含文本增量，token 统计以最后一个数据块为准
+	var content strings.Builder
+	var inputTokens, outputTokens int
+	var blockReason string
+	err = scanStream(ctx, resp.Body, func(line string) bool {
+		if !strings.HasPrefix(line, "data:") {
+			return true
+		}
+		var chunk GeminiResponse
+		if err := json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(line, "data:"))), &chunk); err != nil {
+			return true
+		}
+		if chunk.UsageMetadata.PromptTokenCount > 0 {
+			inputTokens = chunk.UsageMetadata.PromptTokenCount
+			outputTokens = chunk.UsageMetadata.CandidatesTokenCount
+		}
+		if chunk.PromptFeedback.BlockReason != "" {
+			blockReason = chunk.PromptFeedback.BlockReason
+		}
+		if len(chunk.Candidates) == 0 {
+			return true
+		}
+		if reason := chunk.Candidates[0].FinishReason; reason == "SAFETY" || reason == "PROHIBITED_CONTENT" || reason == "BLOCKLIST" {
+			blockReason = reason
+		}
+		var delta strings.Builder
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			delta.WriteString(part.Text)
+		}
+		if delta.Len() == 0 {
+			return true
+		}
+		content.WriteString(delta.String())
+		return callback == nil || callback(content.String(), false)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %v", err)
+		return nil, err
 	}
 
-	if len(chatResp.Candidates) == 0 || len(chatResp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no response from Gemini")
+	if content.Len() == 0 {
+		if blockReason != "" {
+			return nil, &Error{Kind: ErrorContentFilter, Code: blockReason, Message: fmt.Sprintf("response blocked by Gemini: %s", blockReason)}
+		}
+		return nil, newError(ErrorServer, nil, "no response from Gemini")
 	}
 
-	// 提取文本内容
-	var content strings.Builder
-	for _, part := range chatResp.Candidates[0].Content.Parts {
-		content.WriteString(part.Text)
+	// 最终回调，标记完成
+	if callback != nil {
+		callback(content.String(), true)
 	}
 
 	return &ChatResponse{
 		Content:      content.String(),
-		InputTokens:  chatResp.UsageMetadata.PromptTokenCount,
-		OutputTokens: chatResp.UsageMetadata.CandidatesTokenCount,
-		Model:        model,
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		Model:        opts.Model,
 		Provider:     "Gemini",
 	}, nil
 }
-
-// ChatWithCallback 进行对话，支持流式回调
-func (p *GeminiProvider) ChatWithCallback(messages []Message, model string, callback func(string, bool) bool) (*ChatResponse, error) {
-	// Gemini目前不支持真正的流式响应，回退到普通方式
-	response, err := p.Chat(messages, model)
-	if err != nil {
-		return nil, err
-	}
-
-	// 模拟流式回调
-	if callback != nil {
-		callback(response.Content, true)
-	}
-
-	return response, nil
-}
@@ -0,0 +1,211 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// 密钥轮换策略
+const (
+	KeyRotationRoundRobin       = "round_robin"        // 依次使用每个密钥
+	KeyRotationLeastRateLimited = "least_rate_limited" // 优先使用最久没有被限流的密钥
+)
+
+// DefaultKeyCooldown 密钥被限流或额度不足后默认停用的时间
+const DefaultKeyCooldown = time.Minute
+
+// KeySettings 密钥池的当前设置
+type KeySettings struct {
+	Keys     []string
+	Rotation string
+	Cooldown time.Duration
+}
+
+// KeyUsage 单个密钥的使用情况
+type KeyUsage struct {
+	Key             string // 脱敏后的密钥
+	Requests        int64
+	Failures        int64
+	RateLimited     int64 // 被限流或额度不足的次数
+	LastRateLimited time.Time
+	CooldownUntil   time.Time
+}
+
+type keyStats struct {
+	requests        int64
+	failures        int64
+	rateLimited     int64
+	lastRateLimited time.Time
+	cooldownUntil   time.Time
+}
+
+// KeyPool 管理同一提供商的多个API密钥，被限流或额度不足的密钥暂时停用
+// 设置在每次请求时从 source 读取，重新加载配置后立即生效
+type KeyPool struct {
+	source func() KeySettings
+	mu     sync.Mutex
+	next   int
+	stats  map[string]*keyStats
+}
+
+// NewKeyPool 创建密钥池
+func NewKeyPool(source func() KeySettings) *KeyPool {
+	return &KeyPool{
+		source: source,
+		stats:  make(map[string]*keyStats),
+	}
+}
+
+// Acquire 按轮换策略选择一个密钥，所有密钥都在冷却时使用最先恢复的密钥
+// 没有配置密钥时返回空字符串（如本地的 Ollama）
+func (k *KeyPool) Acquire() string {
+	if k == nil {
+		return ""
+	}
+	settings := k.source()
+	if len(settings.Keys) == 0 {
+		return ""
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	chosen := -1
+	switch settings.Rotation {
+	case KeyRotationLeastRateLimited:
+		for i, key := range settings.Keys {
+			stats := k.statsFor(key)
+			if now.Before(stats.cooldownUntil) {
+				continue
+			}
+			if chosen < 0 {
+				chosen = i
+				continue
+			}
+			best := k.statsFor(settings.Keys[chosen])
+			if stats.lastRateLimited.Before(best.lastRateLimited) ||
+				(stats.lastRateLimited.Equal(best.lastRateLimited) && stats.requests < best.requests) {
+				chosen = i
+			}
+		}
+	default:
+		for offset := range settings.Keys {
+			i := (k.next + offset) % len(settings.Keys)
+			if !now.Before(k.statsFor(settings.Keys[i]).cooldownUntil) {
+				chosen = i
+				break
+			}
+		}
+		if chosen >= 0 {
+			k.next = (chosen + 1) % len(settings.Keys)
+		}
+	}
+
+	if chosen < 0 {
+		for i, key := range settings.Keys {
+			if chosen < 0 || k.statsFor(key).cooldownUntil.Before(k.statsFor(settings.Keys[chosen]).cooldownUntil) {
+				chosen = i
+			}
+		}
+	}
+
+	key := settings.Keys[chosen]
+	k.statsFor(key).requests++
+	return key
+}
+
+// Report 记录请求结果，限流和额度不足的密钥在冷却时间内不再使用
+// 冷却时间取配置值和服务端要求的等待时间中较长的一个
+func (k *KeyPool) Report(key string, err error) {
+	if k == nil || key == "" || err == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	cooldown := k.source().Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultKeyCooldown
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	stats := k.statsFor(key)
+	stats.failures++
+
+	var providerErr *Error
+	if !errors.As(err, &providerErr) || (providerErr.Kind != ErrorRateLimit && providerErr.Kind != ErrorQuota) {
+		return
+	}
+	if providerErr.RetryAfter > cooldown {
+		cooldown = providerErr.RetryAfter
+	}
+	stats.rateLimited++
+	stats.lastRateLimited = time.Now()
+	stats.cooldownUntil = stats.lastRateLimited.Add(cooldown)
+}
+
+// Available 返回当前不在冷却中的密钥数量
+func (k *KeyPool) Available() int {
+	if k == nil {
+		return 0
+	}
+	settings := k.source()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	count := 0
+	for _, key := range settings.Keys {
+		if !now.Before(k.statsFor(key).cooldownUntil) {
+			count++
+		}
+	}
+	return count
+}
+
+// Usage 按配置顺序返回每个密钥的使用情况
+func (k *KeyPool) Usage() []KeyUsage {
+	if k == nil {
+		return nil
+	}
+	settings := k.source()
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var usage []KeyUsage
+	for _, key := range settings.Keys {
+		stats := k.statsFor(key)
+		usage = append(usage, KeyUsage{
+			Key:             MaskKey(key),
+			Requests:        stats.requests,
+			Failures:        stats.failures,
+			RateLimited:     stats.rateLimited,
+			LastRateLimited: stats.lastRateLimited,
+			CooldownUntil:   stats.cooldownUntil,
+		})
+	}
+	return usage
+}
+
+// statsFor 返回密钥的统计，调用方需持有锁
+func (k *KeyPool) statsFor(key string) *keyStats {
+	stats, exists := k.stats[key]
+	if !exists {
+		stats = &keyStats{}
+		k.stats[key] = stats
+	}
+	return stats
+}
+
+// MaskKey 返回脱敏后的密钥，只保留首尾各4个字符
+func MaskKey(key string) string {
+	if len(key) <= 12 {
+		return "****"
+	}
+	return key[:4] + "…" + key[len(key)-4:]
+}
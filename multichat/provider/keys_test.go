@@ -0,0 +1,155 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// staticKeys 返回固定设置的密钥池来源
+func staticKeys(rotation string, keys ...string) func() KeySettings {
+	return func() KeySettings {
+		return KeySettings{Keys: keys, Rotation: rotation, Cooldown: time.Hour}
+	}
+}
+
+func TestKeyPoolAcquire(t *testing.T) {
+	rateLimited := &Error{Kind: ErrorRateLimit}
+
+	tests := []struct {
+		name     string
+		rotation string
+		keys     []string
+		// setup 在选择密钥前对密钥池的操作
+		setup func(pool *KeyPool)
+		want  []string
+	}{
+		{
+			name: "no keys",
+			want: []string{"", ""},
+		},
+		{
+			name:     "round robin cycles through keys",
+			rotation: KeyRotationRoundRobin,
+			keys:     []string{"a", "b", "c"},
+			want:     []string{"a", "b", "c", "a"},
+		},
+		{
+			name:     "unknown rotation falls back to round robin",
+			rotation: "random",
+			keys:     []string{"a", "b"},
+			want:     []string{"a", "b", "a"},
+		},
+		{
+			name:     "round robin skips keys in cooldown",
+			rotation: KeyRotationRoundRobin,
+			keys:     []string{"a", "b", "c"},
+			setup:    func(pool *KeyPool) { pool.Report("b", rateLimited) },
+			want:     []string{"a", "c", "a"},
+		},
+		{
+			name:     "other failures do not start a cooldown",
+			rotation: KeyRotationRoundRobin,
+			keys:     []string{"a", "b"},
+			setup: func(pool *KeyPool) {
+				pool.Report("a", &Error{Kind: ErrorServer})
+				pool.Report("a", context.Canceled)
+			},
+			want: []string{"a", "b", "a"},
+		},
+		{
+			name:     "all keys in cooldown use the first to recover",
+			rotation: KeyRotationRoundRobin,
+			keys:     []string{"a", "b"},
+			setup: func(pool *KeyPool) {
+				pool.Report("a", &Error{Kind: ErrorQuota, RetryAfter: 3 * time.Hour})
+				pool.Report("b", rateLimited)
+			},
+			want: []string{"b", "b"},
+		},
+		{
+			name:     "least rate limited prefers the fewest requests",
+			rotation: KeyRotationLeastRateLimited,
+			keys:     []string{"a", "b"},
+			want:     []string{"a", "b", "a", "b"},
+		},
+		{
+			name:     "least rate limited avoids keys in cooldown",
+			rotation: KeyRotationLeastRateLimited,
+			keys:     []string{"a", "b", "c"},
+			setup:    func(pool *KeyPool) { pool.Report("a", rateLimited) },
+			want:     []string{"b", "c", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewKeyPool(staticKeys(tt.rotation, tt.keys...))
+			if tt.setup != nil {
+				tt.setup(pool)
+			}
+			for i, want := range tt.want {
+				if got := pool.Acquire(); got != want {
+					t.Fatalf("Acquire() #%d = %q, want %q", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKeyPoolReportCooldown(t *testing.T) {
+	tests := []struct {
+		name          string
+		err           error
+		wantFailures  int64
+		wantLimited   int64
+		wantAvailable int
+		wantCooldown  time.Duration
+	}{
+		{name: "success", err: nil, wantAvailable: 2},
+		{name: "cancelled", err: context.Canceled, wantAvailable: 2},
+		{name: "server error", err: &Error{Kind: ErrorServer}, wantFailures: 1, wantAvailable: 2},
+		{name: "plain error", err: errors.New("boom"), wantFailures: 1, wantAvailable: 2},
+		{name: "rate limit uses configured cooldown", err: &Error{Kind: ErrorRateLimit, RetryAfter: time.Second},
+			wantFailures: 1, wantLimited: 1, wantAvailable: 1, wantCooldown: time.Hour},
+		{name: "longer retry after wins", err: &Error{Kind: ErrorQuota, RetryAfter: 2 * time.Hour},
+			wantFailures: 1, wantLimited: 1, wantAvailable: 1, wantCooldown: 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := NewKeyPool(staticKeys(KeyRotationRoundRobin, "key-a", "key-b"))
+			before := time.Now()
+			pool.Report("key-a", tt.err)
+
+			if got := pool.Available(); got != tt.wantAvailable {
+				t.Errorf("Available() = %d, want %d", got, tt.wantAvailable)
+			}
+			usage := pool.Usage()[0]
+			if usage.Failures != tt.wantFailures || usage.RateLimited != tt.wantLimited {
+				t.Errorf("usage = %+v, want %d failures and %d rate limited", usage, tt.wantFailures, tt.wantLimited)
+			}
+			if tt.wantCooldown == 0 {
+				if !usage.CooldownUntil.IsZero() {
+					t.Errorf("CooldownUntil = %v, want zero", usage.CooldownUntil)
+				}
+				return
+			}
+			if cooldown := usage.CooldownUntil.Sub(before); cooldown < tt.wantCooldown || cooldown > tt.wantCooldown+time.Minute {
+				t.Errorf("cooldown = %v, want about %v", cooldown, tt.wantCooldown)
+			}
+		})
+	}
+}
+
+func TestNilKeyPool(t *testing.T) {
+	var pool *KeyPool
+	if got := pool.Acquire(); got != "" {
+		t.Errorf("Acquire() = %q, want empty", got)
+	}
+	pool.Report("a", &Error{Kind: ErrorRateLimit})
+	if got := pool.Available(); got != 0 {
+		t.Errorf("Available() = %d, want 0", got)
+	}
+}
@@ -2,10 +2,12 @@ package provider
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"TGFaqBot/utils"
@@ -13,9 +15,10 @@ import (
 
 // OllamaProvider Ollama API提供商（使用Ollama原生格式）
 type OllamaProvider struct {
-	APIKey  string
+	Keys    *KeyPool
 	APIURL  string
 	Timeout time.Duration
+	Headers map[string]string
 	client  *http.Client
 }
 
@@ -30,14 +33,26 @@ type OllamaRequest struct {
 	Model    string          `json:"model"`
 	Messages []OllamaMessage `json:"messages"`
 	Stream   bool            `json:"stream"`
+	Options  *OllamaOptions  `json:"options,omitempty"`
 }
 
-// OllamaResponse Ollama原生响应格式
+// OllamaOptions Ollama生成参数
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// OllamaResponse Ollama原生响应格式，流式响应每行一个，最后一行 done 为 true 并带有token统计
 type OllamaResponse struct {
-	Model     string        `json:"model"`
-	CreatedAt string        `json:"created_at"`
-	Message   OllamaMessage `json:"message"`
-	Done      bool          `json:"done"`
+	Model           string        `json:"model"`
+	CreatedAt       string        `json:"created_at"`
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
 }
 
 // OllamaModelsResponse Ollama模型列表响应
@@ -49,13 +64,25 @@ type OllamaModelsResponse struct {
 	} `json:"models"`
 }
 
+func init() {
+	Register("ollama", Type{
+		New: func(settings Settings) Provider {
+			p := NewOllamaProvider(settings.Keys, settings.APIURL, settings.Timeout)
+			p.Headers = settings.Headers
+			return p
+		},
+		DefaultURL:   "http://localhost:11434",
+		DefaultModel: "llama2",
+	})
+}
+
 // NewOllamaProvider 创建Ollama提供商
-func NewOllamaProvider(apiKey, apiURL string, timeout time.Duration) *OllamaProvider {
+func NewOllamaProvider(keys *KeyPool, apiURL string, timeout time.Duration) *OllamaProvider {
 	return &OllamaProvider{
-		APIKey:  apiKey,
+		Keys:    keys,
 		APIURL:  apiURL,
 		Timeout: timeout,
-		client:  utils.GetEnhancedClientWithTimeout(timeout),
+		client:  utils.GetEnhancedClientWithTimeout(0),
 	}
 }
 
@@ -65,32 +92,36 @@ func (p *OllamaProvider) GetName() string {
 }
 
 // GetModels 获取可用模型列表
-func (p *OllamaProvider) GetModels() ([]Model, error) {
-	req, err := http.NewRequest("GET", p.APIURL+"/api/tags", nil)
+func (p *OllamaProvider) GetModels(ctx context.Context) ([]Model, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.APIURL+"/api/tags", nil)
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	setHeaders(req, p.Headers)
 
 	resp, err := utils.DoRequest(p.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching models: %v", err)
+		return nil, requestError(err, "error fetching models")
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status code: %d", resp.StatusCode)
+		return nil, newAPIError(resp, nil, fmt.Sprintf("API returned status code: %d", resp.StatusCode))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return nil, requestError(err, "error reading response body")
 	}
 
 	var modelsResp OllamaModelsResponse
 	if err := json.Unmarshal(body, &modelsResp); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+		return nil, newError(ErrorParse, err, "error parsing response: %v", err)
 	}
 
 	models := make([]Model, 0, len(modelsResp.Models))
@@ -107,7 +138,23 @@ func (p *OllamaProvider) GetModels() ([]Model, error) {
 }
 
 // Chat 进行对话
-func (p *OllamaProvider) Chat(messages []Message, model string) (*ChatResponse, error) {
+func (p *OllamaProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return p.ChatWithCallback(ctx, messages, opts, nil)
+}
+
+// ChatWithCallback 进行对话，支持流式回调
+func (p *OllamaProvider) ChatWithCallback(ctx context.Context, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	key := p.Keys.Acquire()
+	response, err := p.chat(ctx, key, messages, opts, callback)
+	p.Keys.Report(key, err)
+	return response, err
+}
+
+// chat 使用指定密钥发送流式请求
+func (p *OllamaProvider) chat(ctx context.Context, key string, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.Timeout)
+	defer cancel()
+
 	// 转换消息格式为Ollama原生格式
 	ollamaMessages := make([]OllamaMessage, len(messages))
 	for i, msg := range messages {
@@ -117,72 +164,108 @@ func (p *OllamaProvider) Chat(messages []Message, model string) (*ChatResponse,
 		}
 	}
 
-	requestBody, err := json.Marshal(OllamaRequest{
-		Model:    model,
+	request := OllamaRequest{
+		Model:    opts.Model,
 		Messages: ollamaMessages,
-		Stream:   false,
-	})
+		Stream:   true,
+	}
+	if opts.Temperature != nil || opts.TopP != nil || opts.MaxTokens > 0 || len(opts.Stop) > 0 {
+		request.Options = &OllamaOptions{
+			Temperature: opts.Temperature,
+			TopP:        opts.TopP,
+			NumPredict:  opts.MaxTokens,
+			Stop:        opts.Stop,
+		}
+	}
+	requestBody, err := json.Marshal(request)
 	if err != nil {
 		return nil, fmt.Errorf("error marshaling request: %v", err)
 	}
 
-	req, err := http.NewRequest("POST", p.APIURL+"/api/chat", bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", p.APIURL+"/api/chat", bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("error creating request: %v", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		// Ollama 本身不校验密钥，配置后用于前置的鉴权代理
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	setHeaders(req, p.Headers)
 
 	resp, err := utils.DoRequest(p.client, req)
 	if err != nil {
-		return nil, fmt.Errorf("error sending request: %v", err)
+		return nil, requestError(err, "error sending request")
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
-	}
-
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status code: %d, body: %s", resp.StatusCode, string(body))
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, requestError(err, "error reading response")
+		}
+		return nil, newAPIError(resp, body, fmt.Sprintf("API returned status code: %d, body: %s", resp.StatusCode, string(body)))
 	}
 
-	var chatResp OllamaResponse
-	err = json.Unmarshal(body, &chatResp)
+	// 处理流式响应，每行是一个JSON对象
+	var content strings.Builder
+	var inputTokens, outputTokens int
+	var done bool
+	var streamErr error
+	err = scanStream(ctx, resp.Body, func(line string) bool {
+		var chunk OllamaResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return true
+		}
+		if chunk.Error != "" {
+			streamErr = newStreamError("", fmt.Sprintf("API error: %s", chunk.Error))
+			return false
+		}
+		if chunk.Message.Content != "" {
+			content.WriteString(chunk.Message.Content)
+			if callback != nil && !callback(content.String(), false) {
+				return false
+			}
+		}
+		if chunk.Done {
+			done = true
+			inputTokens = chunk.PromptEvalCount
+			outputTokens = chunk.EvalCount
+			return false
+		}
+		return true
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error unmarshaling response: %v", err)
+		return nil, err
+	}
+	if streamErr != nil {
+		return nil, streamErr
 	}
 
-	if !chatResp.Done {
-		return nil, fmt.Errorf("incomplete response from Ollama")
+	if content.Len() == 0 {
+		if !done {
+			return nil, newError(ErrorNetwork, nil, "incomplete response from Ollama")
+		}
+		return nil, newError(ErrorServer, nil, "no response from Ollama")
 	}
 
-	// Ollama原生API不提供token使用信息，使用估算
-	inputTokens := EstimateTokens(messages)
-	outputTokens := EstimateTokensFromText(chatResp.Message.Content)
+	// 旧版本Ollama不返回token统计，使用估算
+	if inputTokens == 0 && outputTokens == 0 {
+		inputTokens = EstimateTokens(messages)
+		outputTokens = EstimateTokensFromText(content.String())
+	}
+
+	// 最终回调，标记完成
+	if callback != nil {
+		callback(content.String(), true)
+	}
 
 	return &ChatResponse{
-		Content:      chatResp.Message.Content,
+		Content:      content.String(),
 		InputTokens:  inputTokens,
 		OutputTokens: outputTokens,
-		Model:        model,
+		Model:        opts.Model,
 		Provider:     "Ollama",
 	}, nil
 }
-
-// ChatWithCallback 进行对话，支持流式回调
-func (p *OllamaProvider) ChatWithCallback(messages []Message, model string, callback func(string, bool) bool) (*ChatResponse, error) {
-	// Ollama目前不支持真正的流式响应，回退到普通方式
-	response, err := p.Chat(messages, model)
-	if err != nil {
-		return nil, err
-	}
-
-	// 模拟流式回调
-	if callback != nil {
-		callback(response.Content, true)
-	}
-
-	return response, nil
-}
@@ -1,13 +1,12 @@
 package provider
 
 import (
-	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"regexp"
 	"strings"
@@ -17,22 +16,38 @@ import (
 )
 
 // OpenAICompatibleProvider 兼容OpenAI API格式的提供商
-// 支持：OpenAI、Groq、OpenRouter、Azure OpenAI等
+// 支持：OpenAI、Groq、OpenRouter等，Azure OpenAI 见 AzureOpenAIProvider
 type OpenAICompatibleProvider struct {
 	name       string
-	apiKey     string
+	keys       *KeyPool
 	apiURL     string
+	headers    map[string]string
+	timeout    time.Duration
 	httpClient *http.Client
 	models     []Model
 }
 
+func init() {
+	Register("openai", Type{
+		New: func(settings Settings) Provider {
+			p := NewOpenAICompatibleProvider(settings.Name, settings.Keys, settings.APIURL, settings.Timeout)
+			p.headers = settings.Headers
+			return p
+		},
+		DefaultURL:   "https://api.openai.com/v1",
+		DefaultModel: "gpt-3.5-turbo",
+	})
+}
+
 // NewOpenAICompatibleProvider 创建OpenAI兼容格式的提供商
-func NewOpenAICompatibleProvider(name, apiKey, apiURL string, timeout time.Duration) *OpenAICompatibleProvider {
+// timeout 在调用方的 ctx 没有截止时间时生效，流式响应不受 http.Client 的整体超时限制
+func NewOpenAICompatibleProvider(name string, keys *KeyPool, apiURL string, timeout time.Duration) *OpenAICompatibleProvider {
 	return &OpenAICompatibleProvider{
 		name:       name,
-		apiKey:     apiKey,
+		keys:       keys,
 		apiURL:     strings.TrimSuffix(apiURL, "/"),
-		httpClient: utils.GetEnhancedClientWithTimeout(timeout),
+		timeout:    timeout,
+		httpClient: utils.GetEnhancedClientWithTimeout(0),
 	}
 }
 
@@ -42,88 +57,48 @@ func (p *OpenAICompatibleProvider) GetName() string {
 }
 
 // Chat 发送聊天请求
-func (p *OpenAICompatibleProvider) Chat(messages []Message, model string) (*ChatResponse, error) {
-	// 构建请求体 - 启用流式响应
-	reqBody := map[string]interface{}{
-		"model":    model,
-		"messages": messages,
-		"stream":   true, // 启用流式响应
-	}
-
-	jsonData, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %v", err)
-	}
-
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", p.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
-	req.Header.Set("Accept", "text/event-stream")
-	req.Header.Set("Cache-Control", "no-cache")
-
-	// 发送请求
-	resp, err := utils.DoRequest(p.httpClient, req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		// 对于错误响应，读取完整内容
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read error response: %v", err)
-		}
-
-		cleanBody := cleanResponseBody(body)
-
-		var errorResp struct {
-			Error struct {
-				Message string `json:"message"`
-				Type    string `json:"type"`
-			} `json:"error"`
-		}
-
-		if err := json.Unmarshal(cleanBody, &errorResp); err == nil && errorResp.Error.Message != "" {
-			return nil, fmt.Errorf("API error: %s", errorResp.Error.Message)
-		}
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(cleanBody))
-	}
-
-	// 处理流式响应
-	return p.handleStreamResponse(resp)
+func (p *OpenAICompatibleProvider) Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error) {
+	return p.ChatWithCallback(ctx, messages, opts, nil)
 }
 
 // GetModels 获取可用模型列表
-func (p *OpenAICompatibleProvider) GetModels() ([]Model, error) {
-	req, err := http.NewRequest("GET", p.apiURL+"/models", nil)
+func (p *OpenAICompatibleProvider) GetModels(ctx context.Context) ([]Model, error) {
+	key := p.keys.Acquire()
+	models, err := p.getModels(ctx, key)
+	p.keys.Report(key, err)
+	return models, err
+}
+
+func (p *OpenAICompatibleProvider) getModels(ctx context.Context, key string) ([]Model, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", p.apiURL+"/models", nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key) // 本地部署的兼容服务（如 vLLM）可以不配置密钥
+	}
+	setHeaders(req, p.headers)
 
 	resp, err := utils.DoRequestWithCompression(p.httpClient, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		return nil, requestError(err, "failed to send request")
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %v", err)
+		return nil, requestError(err, "failed to read response")
 	}
 
 	// 清理响应体中的ANSI转义字符和控制字符
 	cleanBody := cleanResponseBody(body)
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(cleanBody))
+		return nil, newAPIError(resp, cleanBody, fmt.Sprintf("API returned status %d: %s", resp.StatusCode, string(cleanBody)))
 	}
 
 	var modelsResp struct {
@@ -136,7 +111,7 @@ func (p *OpenAICompatibleProvider) GetModels() ([]Model, error) {
 	}
 
 	if err := json.Unmarshal(cleanBody, &modelsResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %v. Response body (first 500 chars): %s", err, truncateString(string(cleanBody), 500))
+		return nil, newError(ErrorParse, err, "failed to parse response: %v. Response body (first 500 chars): %s", err, truncateString(string(cleanBody), 500))
 	}
 
 	var models []Model
@@ -183,116 +158,74 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen] + "..."
 }
 
-// handleStreamResponse 处理流式响应
-func (p *OpenAICompatibleProvider) handleStreamResponse(resp *http.Response) (*ChatResponse, error) {
-	var fullContent strings.Builder
-	var inputTokens, outputTokens int
-
-	// 检查是否是gzip压缩的响应
-	var reader io.Reader = resp.Body
-	if resp.Header.Get("Content-Encoding") == "gzip" {
-		gzipReader, err := gzip.NewReader(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
-		}
-		defer gzipReader.Close()
-		reader = gzipReader
-	}
-
-	scanner := bufio.NewScanner(reader)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// 跳过空行和非data行
-		if line == "" || !strings.HasPrefix(line, "data: ") {
-			continue
-		}
-
-		// 移除"data: "前缀
-		data := strings.TrimPrefix(line, "data: ")
-
-		// 检查是否是结束标记
-		if data == "[DONE]" {
-			break
-		}
-
-		// 解析JSON数据
-		var streamResp struct {
-			Choices []struct {
-				Delta struct {
-					Content string `json:"content"`
-				} `json:"delta"`
-			} `json:"choices"`
-			Usage *struct {
-				PromptTokens     int `json:"prompt_tokens"`
-				CompletionTokens int `json:"completion_tokens"`
-			} `json:"usage"`
-		}
-
-		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
-			// 忽略解析错误，继续处理下一行
-			continue
-		}
+// ChatWithCallback 发送聊天请求，支持流式回调
+func (p *OpenAICompatibleProvider) ChatWithCallback(ctx context.Context, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	key := p.keys.Acquire()
+	response, err := p.chat(ctx, key, messages, opts, callback)
+	p.keys.Report(key, err)
+	return response, err
+}
 
-		// 累积内容
-		if len(streamResp.Choices) > 0 {
-			fullContent.WriteString(streamResp.Choices[0].Delta.Content)
-		}
+// chat 使用指定密钥发送流式请求
+func (p *OpenAICompatibleProvider) chat(ctx context.Context, key string, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
+	ctx, cancel := withDefaultTimeout(ctx, p.timeout)
+	defer cancel()
 
-		// 获取token使用情况
-		if streamResp.Usage != nil {
-			inputTokens = streamResp.Usage.PromptTokens
-			outputTokens = streamResp.Usage.CompletionTokens
-		}
+	jsonData, err := chatRequestBody(messages, opts)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading stream: %v", err)
+	// 创建HTTP请求
+	req, err := http.NewRequestWithContext(ctx, "POST", p.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
-
-	content := fullContent.String()
-	if content == "" {
-		return nil, fmt.Errorf("no content received from stream")
+	if key != "" {
+		req.Header.Set("Authorization", "Bearer "+key)
 	}
 
-	return &ChatResponse{
-		Content:      content,
-		Provider:     p.name,
-		InputTokens:  inputTokens,
-		OutputTokens: outputTokens,
-	}, nil
+	return p.streamChat(ctx, req, messages, opts, callback)
 }
 
-// ChatWithCallback 发送聊天请求，支持流式回调
-func (p *OpenAICompatibleProvider) ChatWithCallback(messages []Message, model string, callback StreamingCallback) (*ChatResponse, error) {
-	// 构建请求体 - 启用流式响应
+// chatRequestBody 构建 chat/completions 的流式请求体
+func chatRequestBody(messages []Message, opts ChatOptions) ([]byte, error) {
 	reqBody := map[string]interface{}{
-		"model":    model,
+		"model":    opts.Model,
 		"messages": messages,
 		"stream":   true, // 启用流式响应
 	}
+	if opts.Temperature != nil {
+		reqBody["temperature"] = *opts.Temperature
+	}
+	if opts.TopP != nil {
+		reqBody["top_p"] = *opts.TopP
+	}
+	if opts.MaxTokens > 0 {
+		reqBody["max_tokens"] = opts.MaxTokens
+	}
+	if len(opts.Stop) > 0 {
+		reqBody["stop"] = opts.Stop
+	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %v", err)
 	}
+	return jsonData, nil
+}
 
-	// 创建HTTP请求
-	req, err := http.NewRequest("POST", p.apiURL+"/chat/completions", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %v", err)
-	}
-
+// streamChat 发送已设置好地址和认证的请求并处理流式响应
+func (p *OpenAICompatibleProvider) streamChat(ctx context.Context, req *http.Request, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error) {
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
 	req.Header.Set("Accept", "text/event-stream")
 	req.Header.Set("Cache-Control", "no-cache")
+	setHeaders(req, p.headers)
 
 	// 发送请求
 	resp, err := utils.DoRequest(p.httpClient, req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %v", err)
+		return nil, requestError(err, "failed to send request")
 	}
 	defer resp.Body.Close()
 
@@ -300,7 +233,7 @@ func (p *OpenAICompatibleProvider) ChatWithCallback(messages []Message, model st
 		// 对于错误响应，读取完整内容
 		body, err := io.ReadAll(resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to read error response: %v", err)
+			return nil, requestError(err, "failed to read error response")
 		}
 
 		cleanBody := cleanResponseBody(body)
@@ -313,54 +246,49 @@ func (p *OpenAICompatibleProvider) ChatWithCallback(messages []Message, model st
 		}
 
 		if err := json.Unmarshal(cleanBody, &errorResp); err == nil && errorResp.Error.Message != "" {
-			return nil, fmt.Errorf("API error: %s", errorResp.Error.Message)
+			return nil, newAPIError(resp, cleanBody, fmt.Sprintf("API error: %s", errorResp.Error.Message))
 		}
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(cleanBody))
+		return nil, newAPIError(resp, cleanBody, fmt.Sprintf("API returned status %d: %s", resp.StatusCode, string(cleanBody)))
 	}
 
 	// 处理流式响应
-	result, err := p.handleStreamResponseWithCallback(resp, messages, callback)
+	result, err := p.handleStreamResponseWithCallback(ctx, resp, callback)
 	if err != nil {
 		return nil, err
 	}
+	result.Model = opts.Model
 
 	// 如果没有收到token使用信息，使用估算
 	if result.InputTokens == 0 && result.OutputTokens == 0 {
 		result.InputTokens = EstimateTokens(messages)
 		result.OutputTokens = EstimateTokensFromText(result.Content)
-		log.Printf("DEBUG: Using estimated tokens for ChatWithCallback - Input: %d, Output: %d", result.InputTokens, result.OutputTokens)
 	}
 
 	return result, nil
 }
 
 // handleStreamResponseWithCallback 处理流式响应并调用回调
-func (p *OpenAICompatibleProvider) handleStreamResponseWithCallback(resp *http.Response, messages []Message, callback StreamingCallback) (*ChatResponse, error) {
+func (p *OpenAICompatibleProvider) handleStreamResponseWithCallback(ctx context.Context, resp *http.Response, callback StreamingCallback) (*ChatResponse, error) {
 	var fullContent strings.Builder
 	var inputTokens, outputTokens int
-	var chunkCount int
-
-	log.Printf("DEBUG: Starting stream response processing for %s", p.name)
+	var finishReason string
+	var streamErr error
 
 	// 检查是否是gzip压缩的响应
 	var reader io.Reader = resp.Body
 	if resp.Header.Get("Content-Encoding") == "gzip" {
 		gzipReader, err := gzip.NewReader(resp.Body)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create gzip reader: %v", err)
+			return nil, newError(ErrorParse, err, "failed to create gzip reader: %v", err)
 		}
 		defer gzipReader.Close()
 		reader = gzipReader
 	}
 
-	scanner := bufio.NewScanner(reader)
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-
-		// 跳过空行和非data行
-		if line == "" || !strings.HasPrefix(line, "data: ") {
-			continue
+	err := scanStream(ctx, reader, func(line string) bool {
+		// 跳过非data行
+		if !strings.HasPrefix(line, "data: ") {
+			return true
 		}
 
 		// 移除"data: "前缀
@@ -368,8 +296,7 @@ func (p *OpenAICompatibleProvider) handleStreamResponseWithCallback(resp *http.R
 
 		// 检查是否是结束标记
 		if data == "[DONE]" {
-			log.Printf("DEBUG: Stream ended with [DONE] for %s, total chunks: %d", p.name, chunkCount)
-			break
+			return false
 		}
 
 		// 解析JSON数据
@@ -378,69 +305,69 @@ func (p *OpenAICompatibleProvider) handleStreamResponseWithCallback(resp *http.R
 				Delta struct {
 					Content string `json:"content"`
 				} `json:"delta"`
+				FinishReason string `json:"finish_reason"`
 			} `json:"choices"`
 			Usage *struct {
 				PromptTokens     int `json:"prompt_tokens"`
 				CompletionTokens int `json:"completion_tokens"`
 			} `json:"usage"`
+			Error *struct {
+				Message string `json:"message"`
+			} `json:"error"`
 		}
 
 		if err := json.Unmarshal([]byte(data), &streamResp); err != nil {
 			// 忽略解析错误，继续处理下一行
-			log.Printf("DEBUG: Failed to parse stream chunk for %s: %v, data: %s", p.name, err, data)
-			continue
+			return true
 		}
 
-		// 累积内容
-		if len(streamResp.Choices) > 0 && streamResp.Choices[0].Delta.Content != "" {
-			chunkContent := streamResp.Choices[0].Delta.Content
-			fullContent.WriteString(chunkContent)
-			chunkCount++
-
-			// 调用回调函数，传递当前累积的内容
-			if callback != nil {
-				currentContent := fullContent.String()
-				log.Printf("DEBUG: Streaming chunk %d for %s, chunk: %q, total length: %d", chunkCount, p.name, chunkContent, len(currentContent))
-				if !callback(currentContent, false) {
-					// 回调返回false，停止流式传输
-					log.Printf("DEBUG: Callback returned false, stopping stream for %s", p.name)
-					break
-				}
-			}
+		// 部分兼容服务在流中途返回错误对象
+		if streamResp.Error != nil {
+			streamErr = newStreamError(errorCode([]byte(data)), fmt.Sprintf("API error: %s", streamResp.Error.Message))
+			return false
 		}
 
 		// 获取token使用情况
 		if streamResp.Usage != nil {
 			inputTokens = streamResp.Usage.PromptTokens
 			outputTokens = streamResp.Usage.CompletionTokens
-			log.Printf("DEBUG: Got token usage for %s - Input: %d, Output: %d", p.name, inputTokens, outputTokens)
 		}
-	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading stream: %v", err)
+		if len(streamResp.Choices) > 0 && streamResp.Choices[0].FinishReason != "" {
+			finishReason = streamResp.Choices[0].FinishReason
+		}
+
+		// 累积内容
+		if len(streamResp.Choices) > 0 && streamResp.Choices[0].Delta.Content != "" {
+			fullContent.WriteString(streamResp.Choices[0].Delta.Content)
+
+			// 调用回调函数，传递当前累积的内容，回调返回false时停止流式传输
+			if callback != nil && !callback(fullContent.String(), false) {
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	if streamErr != nil {
+		return nil, streamErr
 	}
 
 	content := fullContent.String()
 	if content == "" {
-		return nil, fmt.Errorf("no content received from stream")
+		if finishReason == "content_filter" {
+			return nil, &Error{Kind: ErrorContentFilter, Code: finishReason, Message: "response blocked by content_filter"}
+		}
+		return nil, newError(ErrorServer, nil, "no content received from stream")
 	}
 
-	log.Printf("DEBUG: Stream completed for %s - Total chunks: %d, Content length: %d", p.name, chunkCount, len(content))
-
 	// 最终回调，标记完成
 	if callback != nil {
-		log.Printf("DEBUG: Making final callback for %s", p.name)
 		callback(content, true)
 	}
 
-	// 如果没有收到token使用信息，使用估算
-	if inputTokens == 0 && outputTokens == 0 {
-		inputTokens = EstimateTokens(messages)
-		outputTokens = EstimateTokensFromText(content)
-		log.Printf("DEBUG: Using estimated tokens in handleStreamResponseWithCallback - Input: %d, Output: %d", inputTokens, outputTokens)
-	}
-
 	return &ChatResponse{
 		Content:      content,
 		Provider:     p.name,
@@ -0,0 +1,83 @@
+package provider
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Settings 创建提供商实例所需的设置
+type Settings struct {
+	Name    string            // 实例名称，如 openai、groq
+	APIURL  string            // API地址，为空时使用类型的默认地址
+	Keys    *KeyPool          // API密钥池
+	Timeout time.Duration     // 调用方没有设置截止时间时的超时
+	Headers map[string]string // 每个请求额外附带的请求头
+
+	APIVersion  string            // Azure OpenAI 的 api-version 参数
+	Deployments map[string]string // Azure OpenAI 模型ID到部署名称的映射
+}
+
+// Type 一种提供商类型，新的类型在各自文件的 init 中调用 Register 注册
+type Type struct {
+	New          func(settings Settings) Provider
+	DefaultURL   string // 未配置 api_url 时使用
+	DefaultModel string // 未配置 default_model 时使用
+	RequiresKey  bool   // 是否必须配置API密钥
+}
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[string]Type)
+)
+
+// Register 注册提供商类型，重复注册同名类型会覆盖之前的注册
+func Register(typeName string, t Type) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	types[typeName] = t
+}
+
+// LookupType 返回已注册的提供商类型
+func LookupType(typeName string) (Type, bool) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	t, exists := types[typeName]
+	return t, exists
+}
+
+// Types 返回所有已注册的类型名称
+func Types() []string {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	var names []string
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New 根据类型创建提供商实例
+func New(typeName string, settings Settings) (Provider, error) {
+	t, exists := LookupType(typeName)
+	if !exists {
+		return nil, fmt.Errorf("unknown provider type: %s", typeName)
+	}
+	if settings.APIURL == "" {
+		settings.APIURL = t.DefaultURL
+	}
+	if settings.APIURL == "" {
+		return nil, fmt.Errorf("api_url is required for provider type %s", typeName)
+	}
+	return t.New(settings), nil
+}
+
+// setHeaders 设置配置中的额外请求头，可以覆盖默认的请求头
+func setHeaders(req *http.Request, headers map[string]string) {
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+}
@@ -0,0 +1,77 @@
+package provider
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	var got Settings
+	Register("test", Type{
+		New: func(settings Settings) Provider {
+			got = settings
+			return NewOpenAICompatibleProvider(settings.Name, settings.Keys, settings.APIURL, settings.Timeout)
+		},
+		DefaultURL:   "https://api.test.example/v1",
+		DefaultModel: "test-model",
+	})
+	Register("test-no-url", Type{New: func(settings Settings) Provider { return nil }})
+	t.Cleanup(func() {
+		typesMu.Lock()
+		defer typesMu.Unlock()
+		delete(types, "test")
+		delete(types, "test-no-url")
+	})
+
+	tests := []struct {
+		name     string
+		typeName string
+		settings Settings
+		wantURL  string
+		wantErr  bool
+	}{
+		{name: "default url", typeName: "test", settings: Settings{Name: "groq"}, wantURL: "https://api.test.example/v1"},
+		{name: "configured url", typeName: "test", settings: Settings{Name: "groq", APIURL: "http://localhost:8080"}, wantURL: "http://localhost:8080"},
+		{name: "missing url", typeName: "test-no-url", settings: Settings{Name: "x"}, wantErr: true},
+		{name: "unknown type", typeName: "nope", settings: Settings{Name: "x"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = Settings{}
+			tt.settings.Timeout = time.Minute
+			p, err := New(tt.typeName, tt.settings)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.APIURL != tt.wantURL || got.Name != tt.settings.Name || got.Timeout != time.Minute {
+				t.Errorf("settings = %+v, want url %q and the given name and timeout", got, tt.wantURL)
+			}
+			if p.GetName() != tt.settings.Name {
+				t.Errorf("GetName() = %q, want %q", p.GetName(), tt.settings.Name)
+			}
+		})
+	}
+}
+
+func TestBuiltinTypes(t *testing.T) {
+	for _, name := range []string{"openai", "anthropic", "gemini", "ollama", "azure"} {
+		tp, exists := LookupType(name)
+		if !exists || tp.New == nil {
+			t.Errorf("LookupType(%q) = %+v, %v, want a registered type", name, tp, exists)
+		}
+	}
+	if tp, _ := LookupType("azure"); tp.DefaultURL != "" || !tp.RequiresKey {
+		t.Errorf("azure type = %+v, want no default url and a required key", tp)
+	}
+
+	names := Types()
+	for i := 1; i < len(names); i++ {
+		if names[i-1] >= names[i] {
+			t.Fatalf("Types() = %v, want sorted unique names", names)
+		}
+	}
+}
@@ -1,18 +1,34 @@
 package provider
 
-import "time"
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"time"
+)
 
 // Provider AI提供商接口
+// ctx 被取消或超时时，进行中的请求（包括读取流式响应）会立即中断并返回 ctx.Err()
 type Provider interface {
 	GetName() string
-	GetModels() ([]Model, error)
-	Chat(messages []Message, model string) (*ChatResponse, error)
+	GetModels(ctx context.Context) ([]Model, error)
+	Chat(ctx context.Context, messages []Message, opts ChatOptions) (*ChatResponse, error)
 }
 
 // StreamingProvider 支持流式回调的AI提供商接口
 type StreamingProvider interface {
 	Provider
-	ChatWithCallback(messages []Message, model string, callback StreamingCallback) (*ChatResponse, error)
+	ChatWithCallback(ctx context.Context, messages []Message, opts ChatOptions, callback StreamingCallback) (*ChatResponse, error)
+}
+
+// ChatOptions 对话请求参数，除 Model 外的零值表示使用提供商的默认值
+type ChatOptions struct {
+	Model       string
+	Temperature *float64
+	TopP        *float64
+	MaxTokens   int
+	Stop        []string
 }
 
 // Message 消息结构
@@ -57,3 +73,37 @@ func EstimateTokensFromText(text string) int {
 	// 粗略估算：1个token约等于4个字符
 	return len(text) / 4
 }
+
+// withDefaultTimeout ctx 没有截止时间时使用提供商配置的超时时间
+func withDefaultTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// scanStream 逐行读取流式响应，handle 返回 false 时停止读取
+// ctx 被取消或超时时连接会被关闭，此时返回 ctx.Err() 而不是读取错误
+func scanStream(ctx context.Context, body io.Reader, handle func(line string) bool) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !handle(line) {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return requestError(err, "error reading stream")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestChatOptionsInRequest(t *testing.T) {
+	temperature, topP := 0.2, 0.9
+	full := ChatOptions{Model: "m", Temperature: &temperature, TopP: &topP, MaxTokens: 256, Stop: []string{"END"}}
+	keys := NewKeyPool(staticKeys(KeyRotationRoundRobin, "k"))
+
+	providers := []struct {
+		name   string
+		stream string
+		create func(url string) StreamingProvider
+		// params 从请求体中取出生成参数所在的对象
+		params func(body map[string]interface{}) map[string]interface{}
+		// names 为 temperature、top_p、max_tokens、stop 在该提供商中的字段名
+		names [4]string
+		// defaults 为不设置参数时请求体中应有的字段
+		defaults map[string]interface{}
+	}{
+		{
+			name:   "openai",
+			stream: "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"}}]}\n\ndata: [DONE]\n\n",
+			create: func(url string) StreamingProvider {
+				return NewOpenAICompatibleProvider("openai", keys, url, time.Minute)
+			},
+			params: func(body map[string]interface{}) map[string]interface{} { return body },
+			names:  [4]string{"temperature", "top_p", "max_tokens", "stop"},
+		},
+		{
+			name:   "anthropic",
+			stream: "data: {\"type\":\"content_block_delta\",\"delta\":{\"type\":\"text_delta\",\"text\":\"ok\"}}\n\ndata: {\"type\":\"message_stop\"}\n\n",
+			create: func(url string) StreamingProvider {
+				return NewAnthropicProvider(keys, url, time.Minute)
+			},
+			params:   func(body map[string]interface{}) map[string]interface{} { return body },
+			names:    [4]string{"temperature", "top_p", "max_tokens", "stop_sequences"},
+			defaults: map[string]interface{}{"max_tokens": float64(anthropicDefaultMaxTokens)},
+		},
+		{
+			name:   "gemini",
+			stream: "data: {\"candidates\":[{\"content\":{\"parts\":[{\"text\":\"ok\"}]}}]}\n\n",
+			create: func(url string) StreamingProvider {
+				return NewGeminiProvider(keys, url, time.Minute)
+			},
+			params: func(body map[string]interface{}) map[string]interface{} {
+				config, _ := body["generationConfig"].(map[string]interface{})
+				return config
+			},
+			names: [4]string{"temperature", "topP", "maxOutputTokens", "stopSequences"},
+		},
+		{
+			name:   "ollama",
+			stream: "{\"message\":{\"role\":\"assistant\",\"content\":\"ok\"},\"done\":true}\n",
+			create: func(url string) StreamingProvider {
+				return NewOllamaProvider(keys, url, time.Minute)
+			},
+			params: func(body map[string]interface{}) map[string]interface{} {
+				options, _ := body["options"].(map[string]interface{})
+				return options
+			},
+			names: [4]string{"temperature", "top_p", "num_predict", "stop"},
+		},
+	}
+
+	for _, p := range providers {
+		for _, opts := range []ChatOptions{full, {Model: "m"}} {
+			name := p.name + "/defaults"
+			if opts.Temperature != nil {
+				name = p.name + "/all options"
+			}
+			t.Run(name, func(t *testing.T) {
+				var body map[string]interface{}
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					data, _ := io.ReadAll(r.Body)
+					if err := json.Unmarshal(data, &body); err != nil {
+						t.Errorf("request body is not JSON: %s", data)
+					}
+					w.Write([]byte(p.stream))
+				}))
+				defer server.Close()
+
+				resp, err := p.create(server.URL).Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.Content != "ok" {
+					t.Errorf("content = %q, want ok", resp.Content)
+				}
+
+				params := p.params(body)
+				if opts.Temperature == nil {
+					for _, field := range p.names {
+						got, ok := params[field]
+						if want, isDefault := p.defaults[field]; isDefault {
+							if got != want {
+								t.Errorf("%s = %v, want default %v", field, got, want)
+							}
+						} else if ok {
+							t.Errorf("%s = %v, want it omitted", field, got)
+						}
+					}
+					return
+				}
+				want := []interface{}{temperature, topP, float64(256), []interface{}{"END"}}
+				for i, field := range p.names {
+					if !reflect.DeepEqual(params[field], want[i]) {
+						t.Errorf("%s = %v, want %v", field, params[field], want[i])
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestChatHonoursContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p := NewOpenAICompatibleProvider("openai", NewKeyPool(staticKeys(KeyRotationRoundRobin)), server.URL, time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := p.Chat(ctx, []Message{{Role: "user", Content: "hi"}}, ChatOptions{Model: "m"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Chat() error = %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Chat() returned after %v, want it to stop with the context", elapsed)
+	}
+}
@@ -1,21 +1,32 @@
 package multichat
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"strings"
+	"sync"
 	"time"
 
 	"TGFaqBot/config"
 	"TGFaqBot/database"
 	"TGFaqBot/multichat/provider"
+	"TGFaqBot/utils"
 )
 
 // MultiChatService 多渠道聊天服务
 type MultiChatService struct {
-	config    *config.ChatConfig
-	providers map[string]provider.Provider
-	db        database.Database
+	config *config.ChatConfig
+	db     database.Database
+
+	providers     map[string]providerEntry
+	providerMutex sync.RWMutex
+	errorStats    *errorStats
+	keyPools      map[string]*provider.KeyPool
+	keyMutex      sync.Mutex
+	breakers      map[string]*utils.CircuitBreaker
+	breakerMutex  sync.Mutex
+	onStateChange func(providerName, from, to string)
 }
 
 // Provider 重新导出provider.Provider以保持兼容性
@@ -30,327 +41,223 @@ type ChatResponse = provider.ChatResponse
 // NewMultiChatService 创建新的多渠道聊天服务
 func NewMultiChatService(chatConfig *config.ChatConfig, db database.Database) *MultiChatService {
 	service := &MultiChatService{
-		config:    chatConfig,
-		providers: make(map[string]Provider),
-		db:        db,
+		config:     chatConfig,
+		providers:  make(map[string]providerEntry),
+		db:         db,
+		breakers:   make(map[string]*utils.CircuitBreaker),
+		errorStats: newErrorStats(),
+		keyPools:   make(map[string]*provider.KeyPool),
 	}
 
 	// 初始化启用的提供商
-	enabledProviders := chatConfig.GetEnabledProviders()
-
-	for name, providerConfig := range enabledProviders {
-		provider, err := service.createProvider(name, providerConfig)
-		if err != nil {
-			log.Printf("Failed to create provider %s: %v", name, err)
-			continue
-		}
-		service.providers[name] = provider
-		log.Printf("Initialized provider: %s", name)
-	}
+	service.Reload()
 
 	return service
 }
 
-// createProvider 创建具体的提供商实例
-func (s *MultiChatService) createProvider(name string, config *config.ProviderConfig) (Provider, error) {
-	timeout := time.Duration(s.config.Timeout) * time.Second
-
-	switch name {
-	case "openai":
-		return provider.NewOpenAICompatibleProvider("OpenAI", config.APIKey, config.APIURL, timeout), nil
-	case "anthropic":
-		return provider.NewAnthropicProvider(config.APIKey, config.APIURL, timeout), nil
-	case "gemini":
-		return provider.NewGeminiProvider(config.APIKey, config.APIURL, timeout), nil
-	case "ollama":
-		return provider.NewOllamaProvider(config.APIKey, config.APIURL, timeout), nil
-	default:
-		return nil, fmt.Errorf("unknown provider: %s", name)
+// newProvider 通过提供商注册表按类型创建实例，新的提供商类型只需在 provider 包中注册
+func newProvider(instance config.ProviderInstance, timeout time.Duration, keys *provider.KeyPool) (Provider, error) {
+	providerType, exists := provider.LookupType(instance.Type)
+	if !exists {
+		return nil, fmt.Errorf("unknown provider type %q, available types: %s", instance.Type, strings.Join(provider.Types(), ", "))
 	}
-}
-
-// GetCompletion 获取聊天完成，自动选择第一个可用的提供商
-func (s *MultiChatService) GetCompletion(messages []Message, preferredProvider string, preferredModel string) (string, int, int, string, error) {
-	var errors []string
-
-	// 如果同时指定了首选提供商和模型，先尝试使用指定的组合
-	if preferredProvider != "" && preferredModel != "" {
-		if provider, exists := s.providers[preferredProvider]; exists {
-			// 尝试使用指定的模型
-			response, err := provider.Chat(messages, preferredModel)
-			if err == nil {
-				return response.Content, response.InputTokens, response.OutputTokens, preferredProvider, nil
-			}
-
-			// 分类错误类型
-			errorType := "Unknown"
-			errorType = ClassifyProviderError(err)
-
-			errorMsg := fmt.Sprintf("Provider %s (preferred model %s): [%s] %v", preferredProvider, preferredModel, errorType, err)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-		} else {
-			errorMsg := fmt.Sprintf("Preferred provider %s not found", preferredProvider)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-		}
+	if providerType.RequiresKey && len(instance.Keys()) == 0 {
+		return nil, fmt.Errorf("API key is required for provider type %s", instance.Type)
 	}
+	return provider.New(instance.Type, provider.Settings{
+		Name:    instance.Name,
+		APIURL:  instance.APIURL,
+		Keys:    keys,
+		Timeout: timeout,
+		Headers: instance.Headers,
+
+		APIVersion:  instance.APIVersion,
+		Deployments: instance.Deployments,
+	})
+}
 
-	// 如果只指定了首选提供商，尝试使用默认模型
-	if preferredProvider != "" && preferredModel == "" {
-		if provider, exists := s.providers[preferredProvider]; exists {
-			// 获取该提供商的默认模型
-			model := s.GetDefaultModel(preferredProvider)
-			response, err := provider.Chat(messages, model)
-			if err == nil {
-				return response.Content, response.InputTokens, response.OutputTokens, preferredProvider, nil
-			}
+// GetCompletion 获取聊天完成，按回退链依次尝试提供商
+func (s *MultiChatService) GetCompletion(ctx context.Context, messages []Message, preferredProvider string, preferredModel string) (string, int, int, *Route, error) {
+	return s.complete(ctx, messages, preferredProvider, preferredModel, nil)
+}
 
-			// 分类错误类型
-			errorType := "Unknown"
-			errorType = ClassifyProviderError(err)
+// GetCompletionWithCallback 获取聊天完成，支持流式回调
+// ctx 被取消时不再尝试其他提供商，返回已收到的部分内容和 ctx.Err()
+func (s *MultiChatService) GetCompletionWithCallback(ctx context.Context, messages []Message, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, *Route, error) {
+	return s.complete(ctx, messages, preferredProvider, preferredModel, callback)
+}
 
-			errorMsg := fmt.Sprintf("Provider %s (%s): [%s] %v", preferredProvider, model, errorType, err)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-		} else {
-			errorMsg := fmt.Sprintf("Preferred provider %s not found", preferredProvider)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
+// complete 按回退链依次尝试，某类错误不允许回退时立即返回
+// 每一项在可重试的错误后按退避策略重试，断路器打开的提供商直接跳过
+// 返回的 Route 记录实际使用的提供商和模型以及之前失败的尝试
+func (s *MultiChatService) complete(ctx context.Context, messages []Message, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, *Route, error) {
+	route := &Route{}
+
+	// 记录最近一次回调的内容，停止时作为部分回复返回
+	var partial string
+	if callback != nil {
+		userCallback := callback
+		callback = func(content string, isComplete bool) bool {
+			partial = content
+			return userCallback(content, isComplete)
 		}
 	}
 
-	// 遍历所有可用提供商
-	for name, provider := range s.providers {
-		if name == preferredProvider {
-			continue // 已经尝试过了
+	steps := s.routes(preferredProvider, preferredModel)
+	for _, step := range steps {
+		breaker := s.breaker(step.Provider)
+		if !breaker.Allow() {
+			route.Attempts = append(route.Attempts, Attempt{RouteStep: step, Class: ErrorClassCircuitOpen, Err: utils.ErrCircuitOpen})
+			s.errorStats.record(step.Provider, ErrorClassCircuitOpen)
+			log.Printf("Skipping %s: circuit breaker is open", step)
+			continue
 		}
 
-		// 获取该提供商的默认模型
-		model := s.GetDefaultModel(name)
-
-		response, err := provider.Chat(messages, model)
+		response, err := s.chatWithRetry(ctx, step, messages, callback, &partial)
 		if err == nil {
-			return response.Content, response.InputTokens, response.OutputTokens, name, nil
+			breaker.Success()
+			route.RouteStep = step
+			if len(route.Attempts) > 0 {
+				log.Printf("Completion succeeded via fallback route: %s", route)
+			}
+			return response.Content, response.InputTokens, response.OutputTokens, route, nil
 		}
 
-		// 分类错误类型
-		errorType := "Unknown"
-		errorType = ClassifyProviderError(err)
+		// 请求被停止或超时，不再尝试其他提供商
+		if ctx.Err() != nil {
+			breaker.Release()
+			route.RouteStep = step
+			log.Printf("Completion stopped for %s after %d characters", step, len(partial))
+			return partial, provider.EstimateTokens(messages), provider.EstimateTokensFromText(partial), route, ctx.Err()
+		}
 
-		errorMsg := fmt.Sprintf("Provider %s (%s): [%s] %v", name, model, errorType, err)
-		log.Printf("%s", errorMsg)
-		errors = append(errors, errorMsg)
-	}
+		class := ClassifyProviderError(err)
+		if unhealthyClasses[class] {
+			breaker.Failure()
+		} else {
+			// 提供商正常返回了错误（如请求参数错误），说明提供商本身可用
+			breaker.Success()
+		}
+		route.Attempts = append(route.Attempts, Attempt{RouteStep: step, Class: class, Err: err})
+		s.errorStats.record(step.Provider, class)
+		log.Printf("Provider %s: [%s] %v", step, class, err)
 
-	// 如果没有提供商，添加相应错误信息
-	if len(s.providers) == 0 {
-		errors = append(errors, "No providers available")
+		if !s.shouldFallback(class) {
+			log.Printf("Not falling back after %s error from %s", class, step)
+			break
+		}
 	}
 
-	// 返回包含所有错误详情的错误
-	detailedError := fmt.Sprintf("all providers failed: %s", strings.Join(errors, "; "))
-	return "", 0, 0, "", fmt.Errorf("%s", detailedError)
+	return "", 0, 0, route, &CompletionError{Attempts: route.Attempts}
 }
 
-// GetCompletionWithCallback 获取聊天完成，支持流式回调
-func (s *MultiChatService) GetCompletionWithCallback(messages []Message, preferredProvider string, preferredModel string, callback func(string, bool) bool) (string, int, int, string, error) {
-	var errors []string
-
-	log.Printf("DEBUG: GetCompletionWithCallback called - preferredProvider: %s, preferredModel: %s", preferredProvider, preferredModel)
-
-	// 如果同时指定了首选提供商和模型，先尝试使用指定的组合
-	if preferredProvider != "" && preferredModel != "" {
-		if providerInstance, exists := s.providers[preferredProvider]; exists {
-			// 检查provider是否支持流式回调
-			if streamProvider, ok := providerInstance.(provider.StreamingProvider); ok {
-				log.Printf("DEBUG: Using streaming callback for %s with model %s", preferredProvider, preferredModel)
-				// 尝试使用流式回调
-				response, err := streamProvider.ChatWithCallback(messages, preferredModel, callback)
-				if err == nil {
-					log.Printf("DEBUG: Streaming callback successful for %s", preferredProvider)
-					return response.Content, response.InputTokens, response.OutputTokens, preferredProvider, nil
-				}
-
-				errorMsg := fmt.Sprintf("Provider %s (streaming, preferred model %s): %v", preferredProvider, preferredModel, err)
-				log.Printf("%s", errorMsg)
-				errors = append(errors, errorMsg)
-			} else {
-				log.Printf("DEBUG: Provider %s does not support streaming callback, falling back to normal mode", preferredProvider)
-				// 回退到普通方式
-				response, err := providerInstance.Chat(messages, preferredModel)
-				if err == nil {
-					// 模拟流式回调
-					if callback != nil {
-						log.Printf("DEBUG: Simulating streaming callback for %s (fallback mode)", preferredProvider)
-						callback(response.Content, true)
-					}
-					return response.Content, response.InputTokens, response.OutputTokens, preferredProvider, nil
-				}
-
-				errorMsg := fmt.Sprintf("Provider %s (fallback, preferred model %s): %v", preferredProvider, preferredModel, err)
-				log.Printf("%s", errorMsg)
-				errors = append(errors, errorMsg)
-			}
-		} else {
-			errorMsg := fmt.Sprintf("Preferred provider %s not found", preferredProvider)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
+// chatWithRetry 使用回退链中的一项发送请求，可重试的错误按退避策略重试
+// 已经向用户输出内容后不再重试，避免回复重新开始
+func (s *MultiChatService) chatWithRetry(ctx context.Context, step RouteStep, messages []Message, callback func(string, bool) bool, partial *string) (*ChatResponse, error) {
+	var response *ChatResponse
+	streamed := *partial
+
+	retry := s.retryConfig()
+	pool := s.KeyPool(step.Provider)
+	retryAfter := retry.RetryAfter
+	retry.RetryAfter = func(err error) time.Duration {
+		// 还有未冷却的密钥时换一个密钥重试，不必等待被限流的密钥恢复
+		if pool.Available() > 0 {
+			return 0
 		}
+		return retryAfter(err)
 	}
-
-	// 如果只指定了首选提供商，尝试使用默认模型
-	if preferredProvider != "" && preferredModel == "" {
-		if providerInstance, exists := s.providers[preferredProvider]; exists {
-			model := s.GetDefaultModel(preferredProvider)
-
-			// 检查provider是否支持流式回调
-			if streamProvider, ok := providerInstance.(provider.StreamingProvider); ok {
-				// 尝试使用流式回调
-				response, err := streamProvider.ChatWithCallback(messages, model, callback)
-				if err == nil {
-					return response.Content, response.InputTokens, response.OutputTokens, preferredProvider, nil
-				}
-
-				errorMsg := fmt.Sprintf("Provider %s (streaming, %s): %v", preferredProvider, model, err)
-				log.Printf("%s", errorMsg)
-				errors = append(errors, errorMsg)
-			} else {
-				// 回退到普通方式
-				response, err := providerInstance.Chat(messages, model)
-				if err == nil {
-					// 模拟流式回调
-					if callback != nil {
-						callback(response.Content, true)
-					}
-					return response.Content, response.InputTokens, response.OutputTokens, preferredProvider, nil
-				}
-
-				errorMsg := fmt.Sprintf("Provider %s (fallback, %s): %v", preferredProvider, model, err)
-				log.Printf("%s", errorMsg)
-				errors = append(errors, errorMsg)
-			}
-		} else {
-			errorMsg := fmt.Sprintf("Preferred provider %s not found", preferredProvider)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-		}
+	retry.Retryable = func(err error) bool {
+		return *partial == streamed && retryableClasses[ClassifyProviderError(err)]
 	}
 
-	// 遍历所有提供商，寻找可用的模型
-	for providerName, providerInstance := range s.providers {
-		// 跳过首选提供商，因为已经尝试过了
-		if providerName == preferredProvider {
-			continue
+	attempt := 0
+	err := utils.WithRetry(ctx, retry, func() error {
+		attempt++
+		if attempt > 1 {
+			log.Printf("Retrying %s (attempt %d/%d)", step, attempt, retry.MaxAttempts)
 		}
+		var err error
+		response, err = s.chat(ctx, step, messages, callback)
+		return err
+	})
+	return response, err
+}
 
-		// 检查provider是否支持流式回调
-		if streamProvider, ok := providerInstance.(provider.StreamingProvider); ok {
-			// 如果指定了首选模型，先尝试使用首选模型
-			if preferredModel != "" {
-				response, err := streamProvider.ChatWithCallback(messages, preferredModel, callback)
-				if err == nil {
-					return response.Content, response.InputTokens, response.OutputTokens, providerName, nil
-				}
-
-				errorMsg := fmt.Sprintf("Provider %s (streaming, preferred model %s): %v", providerName, preferredModel, err)
-				log.Printf("%s", errorMsg)
-				errors = append(errors, errorMsg)
-			}
-
-			// 尝试使用默认模型
-			model := s.GetDefaultModel(providerName)
-			response, err := streamProvider.ChatWithCallback(messages, model, callback)
-			if err == nil {
-				return response.Content, response.InputTokens, response.OutputTokens, providerName, nil
-			}
-
-			errorMsg := fmt.Sprintf("Provider %s (streaming, %s): %v", providerName, model, err)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-		} else {
-			// 如果指定了首选模型，先尝试使用首选模型
-			if preferredModel != "" {
-				response, err := providerInstance.Chat(messages, preferredModel)
-				if err == nil {
-					// 模拟流式回调
-					if callback != nil {
-						callback(response.Content, true)
-					}
-					return response.Content, response.InputTokens, response.OutputTokens, providerName, nil
-				}
-
-				errorMsg := fmt.Sprintf("Provider %s (fallback, preferred model %s): %v", providerName, preferredModel, err)
-				log.Printf("%s", errorMsg)
-				errors = append(errors, errorMsg)
-			}
+// chat 使用回退链中的一项发送请求，不支持流式回调的提供商在完成后模拟一次回调
+func (s *MultiChatService) chat(ctx context.Context, step RouteStep, messages []Message, callback func(string, bool) bool) (*ChatResponse, error) {
+	providerInstance, exists := s.Provider(step.Provider)
+	if !exists {
+		return nil, fmt.Errorf("provider %s not found or not enabled", step.Provider)
+	}
+	opts := s.chatOptions(step.Provider, step.Model)
 
-			// 尝试使用默认模型
-			model := s.GetDefaultModel(providerName)
-			response, err := providerInstance.Chat(messages, model)
-			if err == nil {
-				// 模拟流式回调
-				if callback != nil {
-					callback(response.Content, true)
-				}
-				return response.Content, response.InputTokens, response.OutputTokens, providerName, nil
-			}
+	if streamProvider, ok := providerInstance.(provider.StreamingProvider); ok && callback != nil {
+		return streamProvider.ChatWithCallback(ctx, messages, opts, callback)
+	}
 
-			errorMsg := fmt.Sprintf("Provider %s (fallback, %s): %v", providerName, model, err)
-			log.Printf("%s", errorMsg)
-			errors = append(errors, errorMsg)
-		}
+	response, err := providerInstance.Chat(ctx, messages, opts)
+	if err != nil {
+		return nil, err
+	}
+	if callback != nil {
+		callback(response.Content, true)
 	}
+	return response, nil
+}
 
-	return "", 0, 0, "", fmt.Errorf("failed to get AI response: all providers failed: %s", strings.Join(errors, "; "))
+// chatOptions 根据提供商配置生成请求参数
+func (s *MultiChatService) chatOptions(providerName string, model string) provider.ChatOptions {
+	opts := provider.ChatOptions{Model: model}
+	if providerConfig, exists := s.config.GetEnabledProviders()[providerName]; exists {
+		opts.Temperature = providerConfig.Temperature
+		opts.TopP = providerConfig.TopP
+		opts.MaxTokens = providerConfig.MaxTokens
+	}
+	return opts
 }
 
-// GetDefaultProviderAndModel 获取默认的提供商和模型
+// GetDefaultProviderAndModel 获取默认的提供商和模型，即回退链的第一项
 func (s *MultiChatService) GetDefaultProviderAndModel() (string, string) {
-	// 获取第一个启用的提供商
-	enabledProviders := s.config.GetEnabledProviders()
-	for providerName := range enabledProviders {
-		defaultModel := s.GetDefaultModel(providerName)
-		return providerName, defaultModel
+	if steps := s.routes("", ""); len(steps) > 0 {
+		return steps[0].Provider, steps[0].Model
 	}
 
 	// 如果没有启用的提供商，返回默认值
 	return "openai", "gpt-3.5-turbo"
 }
 
-// GetDefaultModel 获取指定提供商的默认模型
+// GetDefaultModel 获取指定提供商的默认模型，未配置时使用提供商类型的默认模型
 func (s *MultiChatService) GetDefaultModel(providerName string) string {
-	enabledProviders := s.config.GetEnabledProviders()
-	if providerConfig, exists := enabledProviders[providerName]; exists {
-		if providerConfig.DefaultModel != "" {
-			return providerConfig.DefaultModel
+	if instance, exists := s.config.FindInstance(providerName); exists {
+		if instance.DefaultModel != "" {
+			return instance.DefaultModel
+		}
+		if providerType, exists := provider.LookupType(instance.Type); exists && providerType.DefaultModel != "" {
+			return providerType.DefaultModel
 		}
 	}
-
-	// 根据提供商提供默认值
-	switch providerName {
-	case "openai":
-		return "gpt-3.5-turbo"
-	case "anthropic":
-		return "claude-3-haiku-20240307"
-	case "gemini":
-		return "gemini-pro"
-	case "ollama":
-		return "llama2"
-	default:
-		return "default"
-	}
+	return "default"
 }
 
 // GetDiagnosticInfo 获取诊断信息（用于管理员错误显示）
 func (s *MultiChatService) GetDiagnosticInfo() string {
 	var info []string
 
-	info = append(info, fmt.Sprintf("Total providers configured: %d", len(s.providers)))
+	names := s.ProviderNames()
+	info = append(info, fmt.Sprintf("Total providers configured: %d", len(names)))
 
-	for name := range s.providers {
+	for _, name := range names {
+		if state := s.ProviderState(name); state != utils.CircuitClosed {
+			info = append(info, fmt.Sprintf("Provider %s: circuit %s", name, state))
+			continue
+		}
 		info = append(info, fmt.Sprintf("Provider %s: available", name))
 	}
 
+	if counts := s.errorStats.String(); counts != "" {
+		info = append(info, "Errors: "+counts)
+	}
+
 	return strings.Join(info, "; ")
 }
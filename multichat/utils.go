@@ -1,23 +1,39 @@
 package multichat
 
-import "strings"
+import (
+	"context"
+	"errors"
+
+	"TGFaqBot/multichat/provider"
+)
+
+// 提供商错误类型，用于回退规则和错误诊断
+const (
+	ErrorClassNetwork       = provider.ErrorNetwork
+	ErrorClassTimeout       = provider.ErrorTimeout
+	ErrorClassAuth          = provider.ErrorAuth
+	ErrorClassRateLimit     = provider.ErrorRateLimit
+	ErrorClassQuota         = provider.ErrorQuota
+	ErrorClassContentFilter = provider.ErrorContentFilter
+	ErrorClassBadRequest    = provider.ErrorBadRequest
+	ErrorClassServer        = provider.ErrorServer
+	ErrorClassParse         = provider.ErrorParse
+	ErrorClassUnknown       = "unknown"
+	ErrorClassCircuitOpen   = "circuit_open" // 提供商连续失败被断路器暂时跳过
+)
 
 // ClassifyProviderError returns a string representing the error type for provider errors.
 func ClassifyProviderError(err error) string {
 	if err == nil {
 		return ""
 	}
-	errMsg := err.Error()
-	switch {
-	case strings.Contains(errMsg, "failed to send request"):
-		return "Network"
-	case strings.Contains(errMsg, "API returned status"):
-		return "API"
-	case strings.Contains(errMsg, "failed to parse response"):
-		return "Parse"
-	case strings.Contains(errMsg, "API error"):
-		return "Service"
-	default:
-		return "Unknown"
+	var providerErr *provider.Error
+	if errors.As(err, &providerErr) && providerErr.Kind != "" {
+		return providerErr.Kind
+	}
+	// 提供商内部的超时在读取流时以 ctx.Err() 返回
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
 	}
+	return ErrorClassUnknown
 }
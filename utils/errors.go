@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 )
 
@@ -72,6 +73,10 @@ type RetryConfig struct {
 	InitialWait time.Duration
 	MaxWait     time.Duration
 	Multiplier  float64
+	// Retryable 判断错误是否值得重试，为空时所有错误都重试
+	Retryable func(error) bool
+	// RetryAfter 返回服务端要求的等待时间（如 Retry-After 响应头），超过 MaxWait 时不再重试
+	RetryAfter func(error) time.Duration
 }
 
 func DefaultRetryConfig() RetryConfig {
@@ -83,7 +88,7 @@ func DefaultRetryConfig() RetryConfig {
 	}
 }
 
-// WithRetry 执行带重试的函数
+// WithRetry 执行带重试的函数，等待期间 ctx 被取消时立即返回
 func WithRetry(ctx context.Context, config RetryConfig, fn func() error) error {
 	var lastErr error
 	wait := config.InitialWait
@@ -101,8 +106,28 @@ func WithRetry(ctx context.Context, config RetryConfig, fn func() error) error {
 			lastErr = err
 		}
 
+		if config.Retryable != nil && !config.Retryable(lastErr) {
+			return lastErr
+		}
+
 		if attempt < config.MaxAttempts-1 {
-			time.Sleep(wait)
+			delay := wait
+			if config.RetryAfter != nil {
+				if retryAfter := config.RetryAfter(lastErr); retryAfter > config.MaxWait {
+					return lastErr
+				} else if retryAfter > delay {
+					delay = retryAfter
+				}
+			}
+
+			timer := time.NewTimer(delay)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+
 			wait = time.Duration(float64(wait) * config.Multiplier)
 			if wait > config.MaxWait {
 				wait = config.MaxWait
@@ -110,9 +135,19 @@ func WithRetry(ctx context.Context, config RetryConfig, fn func() error) error {
 		}
 	}
 
-	return fmt.Errorf("failed after %d attempts: %v", config.MaxAttempts, lastErr)
+	return fmt.Errorf("failed after %d attempts: %w", config.MaxAttempts, lastErr)
 }
 
+// 断路器状态
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// ErrCircuitOpen 断路器打开时拒绝调用
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
 // 断路器模式
 type CircuitBreaker struct {
 	maxFailures  int
@@ -120,43 +155,115 @@ type CircuitBreaker struct {
 	failures     int
 	lastFailTime time.Time
 	state        string // "closed", "open", "half-open"
+	probing      bool   // 半开状态下是否已有试探请求在进行
+	mu           sync.Mutex
+
+	// OnStateChange 状态变化时调用，在锁外执行
+	OnStateChange func(from, to string)
 }
 
 func NewCircuitBreaker(maxFailures int, resetTimeout time.Duration) *CircuitBreaker {
 	return &CircuitBreaker{
 		maxFailures:  maxFailures,
 		resetTimeout: resetTimeout,
-		state:        "closed",
+		state:        CircuitClosed,
 	}
 }
 
 func (cb *CircuitBreaker) Call(fn func() error) error {
-	switch cb.state {
-	case "open":
-		if time.Since(cb.lastFailTime) > cb.resetTimeout {
-			cb.state = "half-open"
-			cb.failures = 0
-		} else {
-			return errors.New("circuit breaker is open")
-		}
+	if !cb.Allow() {
+		return ErrCircuitOpen
 	}
 
 	err := fn()
 	if err != nil {
-		cb.failures++
-		cb.lastFailTime = time.Now()
-
-		if cb.failures >= cb.maxFailures {
-			cb.state = "open"
-		}
+		cb.Failure()
 		return err
 	}
 
 	// 成功执行
-	if cb.state == "half-open" {
-		cb.state = "closed"
+	cb.Success()
+	return nil
+}
+
+// Allow 判断是否允许调用，打开状态超过 resetTimeout 后进入半开状态，
+// 半开状态下只放行一个试探请求，试探结束前其他调用仍被拒绝
+// 允许调用后必须以 Success、Failure 或 Release 之一结束
+func (cb *CircuitBreaker) Allow() bool {
+	cb.mu.Lock()
+	switch cb.state {
+	case CircuitClosed:
+		cb.mu.Unlock()
+		return true
+	case CircuitHalfOpen:
+		allowed := !cb.probing
+		cb.probing = true
+		cb.mu.Unlock()
+		return allowed
+	}
+	if time.Since(cb.lastFailTime) <= cb.resetTimeout {
+		cb.mu.Unlock()
+		return false
 	}
 	cb.failures = 0
+	cb.probing = true
+	from := cb.setState(CircuitHalfOpen)
+	cb.mu.Unlock()
+	cb.notify(from, CircuitHalfOpen)
+	return true
+}
 
-	return nil
+// Release 结束一次无法判断健康状态的调用（如请求被取消），半开状态下允许下一个调用重新试探
+func (cb *CircuitBreaker) Release() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.probing = false
+}
+
+// Success 记录一次成功调用，半开状态下恢复为关闭
+func (cb *CircuitBreaker) Success() {
+	cb.mu.Lock()
+	cb.failures = 0
+	cb.probing = false
+	if cb.state != CircuitHalfOpen {
+		cb.mu.Unlock()
+		return
+	}
+	from := cb.setState(CircuitClosed)
+	cb.mu.Unlock()
+	cb.notify(from, CircuitClosed)
+}
+
+// Failure 记录一次失败调用，连续失败达到上限或半开状态下失败时打开
+func (cb *CircuitBreaker) Failure() {
+	cb.mu.Lock()
+	cb.failures++
+	cb.lastFailTime = time.Now()
+	cb.probing = false
+	if cb.state == CircuitOpen || (cb.state == CircuitClosed && cb.failures < cb.maxFailures) {
+		cb.mu.Unlock()
+		return
+	}
+	from := cb.setState(CircuitOpen)
+	cb.mu.Unlock()
+	cb.notify(from, CircuitOpen)
+}
+
+// State 返回当前状态
+func (cb *CircuitBreaker) State() string {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	return cb.state
+}
+
+func (cb *CircuitBreaker) setState(state string) string {
+	from := cb.state
+	cb.state = state
+	return from
+}
+
+func (cb *CircuitBreaker) notify(from, to string) {
+	if from != to && cb.OnStateChange != nil {
+		cb.OnStateChange(from, to)
+	}
 }
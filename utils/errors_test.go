@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	const resetTimeout = 20 * time.Millisecond
+
+	// 每个步骤为一次操作：allow/deny 检查 Allow 的结果，success、failure、release 记录调用结果，wait 等待断路器超时
+	tests := []struct {
+		name        string
+		maxFailures int
+		steps       []string
+		wantState   string
+		wantChanges []string
+	}{
+		{
+			name:        "stays closed below the failure limit",
+			maxFailures: 3,
+			steps:       []string{"allow", "failure", "allow", "failure", "allow"},
+			wantState:   CircuitClosed,
+		},
+		{
+			name:        "success resets the failure count",
+			maxFailures: 2,
+			steps:       []string{"failure", "success", "failure", "allow"},
+			wantState:   CircuitClosed,
+		},
+		{
+			name:        "opens after consecutive failures",
+			maxFailures: 2,
+			steps:       []string{"failure", "failure", "deny"},
+			wantState:   CircuitOpen,
+			wantChanges: []string{"closed>open"},
+		},
+		{
+			name:        "half-open after the reset timeout",
+			maxFailures: 1,
+			steps:       []string{"failure", "deny", "wait", "allow"},
+			wantState:   CircuitHalfOpen,
+			wantChanges: []string{"closed>open", "open>half-open"},
+		},
+		{
+			name:        "half-open lets a single probe through",
+			maxFailures: 1,
+			steps:       []string{"failure", "wait", "allow", "deny", "deny"},
+			wantState:   CircuitHalfOpen,
+			wantChanges: []string{"closed>open", "open>half-open"},
+		},
+		{
+			name:        "successful probe closes",
+			maxFailures: 1,
+			steps:       []string{"failure", "wait", "allow", "success", "allow", "allow"},
+			wantState:   CircuitClosed,
+			wantChanges: []string{"closed>open", "open>half-open", "half-open>closed"},
+		},
+		{
+			name:        "failed probe opens again",
+			maxFailures: 3,
+			steps:       []string{"failure", "failure", "failure", "wait", "allow", "failure", "deny"},
+			wantState:   CircuitOpen,
+			wantChanges: []string{"closed>open", "open>half-open", "half-open>open"},
+		},
+		{
+			name:        "released probe allows another probe",
+			maxFailures: 1,
+			steps:       []string{"failure", "wait", "allow", "release", "allow", "deny"},
+			wantState:   CircuitHalfOpen,
+			wantChanges: []string{"closed>open", "open>half-open"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cb := NewCircuitBreaker(tt.maxFailures, resetTimeout)
+			var changes []string
+			cb.OnStateChange = func(from, to string) {
+				changes = append(changes, from+">"+to)
+			}
+
+			for i, step := range tt.steps {
+				switch step {
+				case "allow", "deny":
+					if got := cb.Allow(); got != (step == "allow") {
+						t.Fatalf("step %d: Allow() = %v in state %s", i, got, cb.State())
+					}
+				case "success":
+					cb.Success()
+				case "failure":
+					cb.Failure()
+				case "release":
+					cb.Release()
+				case "wait":
+					time.Sleep(resetTimeout + 10*time.Millisecond)
+				default:
+					t.Fatalf("unknown step %q", step)
+				}
+			}
+
+			if got := cb.State(); got != tt.wantState {
+				t.Errorf("State() = %s, want %s", got, tt.wantState)
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("state changes = %v, want %v", changes, tt.wantChanges)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerCall(t *testing.T) {
+	cb := NewCircuitBreaker(1, time.Hour)
+	failure := errors.New("boom")
+
+	if err := cb.Call(func() error { return failure }); !errors.Is(err, failure) {
+		t.Fatalf("Call() = %v, want %v", err, failure)
+	}
+	called := false
+	err := cb.Call(func() error {
+		called = true
+		return nil
+	})
+	if !errors.Is(err, ErrCircuitOpen) || called {
+		t.Errorf("Call() on open breaker = %v (called %v), want ErrCircuitOpen without calling", err, called)
+	}
+}
@@ -186,6 +186,10 @@ func cdo(c *http.Client, req *http.Request) (resp *http.Response, err error) {
 		if resp, err = c.Do(req); err == nil {
 			return resp, nil
 		}
+		// 请求已被取消，不再重试
+		if req.Context().Err() != nil {
+			break
+		}
 		if strings.Contains(err.Error(), "no such host") {
 			break
 		}